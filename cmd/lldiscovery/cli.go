@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/discovery"
+)
+
+// command is a subcommand of the lldiscovery binary. Without a subcommand the
+// binary runs the daemon.
+type command struct {
+	name    string
+	args    string // Synopsis of the arguments, shown in usage
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists all subcommands in the order they are shown in usage
+var commands []command
+
+func init() {
+	commands = []command{
+		{"neighbors", "[-json]", "list directly connected neighbors of this host", runNeighbors},
+		{"interfaces", "[-json]", "list local interfaces with addresses, prefixes and RDMA devices", runInterfaces},
+		{"segments", "[-json]", "list detected network segments", runSegments},
+		{"node", "[-json] <host>", "show a node by hostname or machine ID with its links", runNode},
+		{"watch", "[flags]", "full-screen view of neighbors that highlights changes as they arrive", runWatch},
+		{"rdma", "[-json]", "list local RDMA devices, their ports and parent interfaces", runRDMA},
+		{"probe", "[flags]", "announce this host, listen for neighbors and check them", runProbe},
+		{"doctor", "[flags]", "check this host for common reasons discovery fails", runDoctor},
+		{"render", "[flags] <snapshot.json>", "render a saved /graph snapshot without a daemon", runRender},
+		{"diff", "[-format text] <old.json> <new.json>", "compare two saved /graph snapshots", runDiff},
+	}
+
+	flag.Usage = usage
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// exitError is returned by subcommands that exit with a code other than 1
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// runCommand runs a subcommand and returns the process exit code
+func runCommand(cmd *command, args []string) int {
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "lldiscovery %s: %v\n", cmd.name, err)
+		var ee *exitError
+		if errors.As(err, &ee) {
+			return ee.code
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage:")
+	tw := newTable(out)
+	fmt.Fprintf(tw, "  lldiscovery [flags]\trun the discovery daemon\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  lldiscovery %s\t%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\nRun 'lldiscovery <command> -h' for command flags.\n\nDaemon flags:\n")
+	flag.PrintDefaults()
+}
+
+// newCommandFlags returns the flag set of a subcommand with a usage line
+// derived from its synopsis
+func newCommandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		cmd := findCommand(name)
+		fmt.Fprintf(fs.Output(), "Usage: lldiscovery %s %s\n\n%s\n\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// clientFlags are shared by subcommands querying a running daemon
+type clientFlags struct {
+	socket string
+	json   bool
+}
+
+func newClientFlags(name string) (*flag.FlagSet, *clientFlags) {
+	fs := newCommandFlags(name)
+	cf := &clientFlags{}
+	fs.StringVar(&cf.socket, "socket", defaultControlSocket, "control socket of the daemon (control_socket)")
+	fs.BoolVar(&cf.json, "json", false, "print JSON instead of a table")
+	return fs, cf
+}
+
+func runNeighbors(args []string) error {
+	fs, cf := newClientFlags("neighbors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	doc, err := newControlClient(cf.socket).graph(false)
+	if err != nil {
+		return err
+	}
+	return printNeighbors(os.Stdout, doc, cf.json)
+}
+
+func runInterfaces(args []string) error {
+	fs, cf := newClientFlags("interfaces")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	doc, err := newControlClient(cf.socket).graph(false)
+	if err != nil {
+		return err
+	}
+	return printInterfaces(os.Stdout, doc, cf.json)
+}
+
+func runSegments(args []string) error {
+	fs, cf := newClientFlags("segments")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	doc, err := newControlClient(cf.socket).graph(true)
+	if err != nil {
+		return err
+	}
+	return printSegments(os.Stdout, doc, cf.json)
+}
+
+func runNode(args []string) error {
+	fs, cf := newClientFlags("node")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one host")
+	}
+
+	doc, err := newControlClient(cf.socket).graph(false)
+	if err != nil {
+		return err
+	}
+	return printNode(os.Stdout, doc, fs.Arg(0), cf.json)
+}
+
+func runRDMA(args []string) error {
+	fs := newCommandFlags("rdma")
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	hostRoot := fs.String("host-root", "", "read /sys below this directory (e.g., /host in a container)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	discovery.SetHostRoot(*hostRoot)
+	return listRDMADevices(os.Stdout, *asJSON)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/collector"
+	"github.com/kad/lldiscovery/internal/config"
+	"github.com/kad/lldiscovery/internal/export"
+	"github.com/kad/lldiscovery/internal/server"
+	"github.com/kad/lldiscovery/internal/telemetry"
+)
+
+// runCollector runs collector mode: no discovery, only the HTTP API, the
+// exporter and the puller, all working on the merged graph of the fleet
+func runCollector(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, templates []*export.Template, outputs []*export.Output, logger *slog.Logger, metrics *telemetry.Metrics) {
+	c := collector.New(logger)
+	srvOpts, err := serverOptions(cfg, templates, logger)
+	if err != nil {
+		logger.Error("failed to configure HTTP server", "error", err)
+		os.Exit(1)
+	}
+	srv := server.New(cfg.HTTPAddress, c, logger, cfg.ShowSegments, append(srvOpts, server.WithCollector(c))...)
+
+	logger.Info("running in collector mode",
+		"pull_targets", len(cfg.Collector.Pull),
+		"pull_interval", cfg.Collector.PullInterval,
+		"source_timeout", cfg.NodeTimeout)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	errChan := make(chan error, 2)
+
+	go func() {
+		if err := srv.Run(ctx); err != nil && err != context.Canceled {
+			errChan <- fmt.Errorf("server: %w", err)
+		}
+	}()
+
+	if len(cfg.Collector.Pull) > 0 {
+		puller := collector.NewPuller(c, cfg.Collector.Pull, cfg.Collector.PullInterval, logger)
+		if cfg.Collector.PullTokenFile != "" {
+			token, err := readTokenFile(cfg.Collector.PullTokenFile)
+			if err != nil {
+				logger.Error("failed to read pull token", "error", err)
+				os.Exit(1)
+			}
+			puller.SetToken(token)
+		}
+		pullTLS := cfg.Collector.PullTLS
+		tlsConfig, err := collector.NewTLSConfig(pullTLS.CAFile, pullTLS.CertFile, pullTLS.KeyFile)
+		if err != nil {
+			logger.Error("failed to configure pull TLS", "error", err)
+			os.Exit(1)
+		}
+		puller.SetTLSConfig(tlsConfig)
+		go func() {
+			if err := puller.Run(ctx); err != nil && err != context.Canceled {
+				errChan <- fmt.Errorf("puller: %w", err)
+			}
+		}()
+	}
+
+	go runExporter(ctx, c, cfg, outputs, logger, metrics)
+
+	select {
+	case sig := <-sigChan:
+		logger.Info("received signal", "signal", sig)
+		cancel()
+	case err := <-errChan:
+		logger.Error("component error", "error", err)
+		cancel()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	logger.Info("shutdown complete")
+}
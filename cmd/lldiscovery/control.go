@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/collector"
+)
+
+// defaultControlSocket is where the CLI looks for the daemon. The daemon only
+// listens there when control_socket is set to this path.
+const defaultControlSocket = "/run/lldiscovery/lldiscovery.sock"
+
+// controlClient queries a running daemon over its control socket, or over
+// HTTP(S) for remote daemons
+type controlClient struct {
+	target string // Socket path or URL, for error messages
+	base   string // Base URL of the API
+	token  string // Bearer token, only sent to remote daemons
+	socket bool
+	http   *http.Client
+}
+
+func newControlClient(socket string) *controlClient {
+	return &controlClient{
+		target: socket,
+		base:   "http://lldiscovery",
+		socket: true,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// newRemoteClient queries a daemon's HTTP API at baseURL, e.g.
+// https://node-01:6469. token is sent as bearer token if not empty; tlsConfig,
+// from collector.NewTLSConfig, verifies the daemon and presents a client
+// certificate.
+func newRemoteClient(baseURL, token string, tlsConfig *tls.Config) *controlClient {
+	return &controlClient{
+		target: baseURL,
+		base:   strings.TrimSuffix(baseURL, "/"),
+		token:  token,
+		http:   collector.NewHTTPClient(10*time.Second, tlsConfig),
+	}
+}
+
+// graph fetches the v1 graph document, with segments if requested
+func (c *controlClient) graph(segments bool) (*api.Graph, error) {
+	url := c.base + "/graph"
+	if segments {
+		url += "?segments=true"
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if c.socket && (errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission)) {
+			return nil, fmt.Errorf("cannot connect to daemon at %s: %w (is control_socket enabled?)", c.target, err)
+		}
+		return nil, fmt.Errorf("cannot connect to daemon at %s: %w", c.target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("daemon returned %s: %s", resp.Status, body)
+	}
+
+	var doc api.Graph
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid response from daemon: %w", err)
+	}
+	return &doc, nil
+}
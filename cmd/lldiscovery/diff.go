@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/diff"
+	"github.com/kad/lldiscovery/internal/export"
+)
+
+// diffFoundCode is the exit code when the snapshots differ
+const diffFoundCode = 2
+
+// diffMarks prefix changes in text output
+var diffMarks = map[string]string{diff.Added: "+", diff.Removed: "-", diff.Changed: "~"}
+
+// withSegments fills in the segments of a snapshot saved without them, so
+// segment changes are reported for such snapshots too
+func withSegments(doc *api.Graph) *api.Graph {
+	if len(doc.Segments) > 0 {
+		return doc
+	}
+	nodes, edges, segments := snapshotTopology(doc)
+	doc.Segments = api.FromGraph(nodes, edges, segments).Segments
+	return doc
+}
+
+func printDiff(w io.Writer, report *diff.Report) error {
+	tw := newTable(w)
+	for _, c := range report.Changes {
+		fields := make([]string, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			fields = append(fields, fmt.Sprintf("%s: %s -> %s", f.Field, orDash(f.Old), orDash(f.New)))
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%s\n", diffMarks[c.Kind], c.Type, c.Name, strings.Join(fields, "; "))
+	}
+	return tw.Flush()
+}
+
+func runDiff(args []string) error {
+	fs := newCommandFlags("diff")
+	format := fs.String("format", "text", "output format: text, json or dot (union of both snapshots, changes colored)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two snapshot files")
+	}
+
+	old, err := loadSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	new, err := loadSnapshot(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	old, new = withSegments(old), withSegments(new)
+	report := diff.Compare(old, new)
+
+	switch *format {
+	case "text":
+		err = printDiff(os.Stdout, report)
+	case "json":
+		err = printJSON(os.Stdout, report)
+	case "dot":
+		_, err = io.WriteString(os.Stdout, export.GenerateDiffDOT(old, new))
+	default:
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Empty() {
+		return &exitError{code: diffFoundCode, err: fmt.Errorf("%d differences", len(report.Changes))}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/diff"
+)
+
+func TestPrintDiff(t *testing.T) {
+	old := testDocument()
+	new := testDocument()
+	new.Nodes[0].Interfaces[0].SpeedMbps = 10000 // host-a eth0
+	new.Edges = new.Edges[1:]
+
+	var buf bytes.Buffer
+	if err := printDiff(&buf, diff.Compare(old, new)); err != nil {
+		t.Fatalf("printDiff failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"~ interface  host-a:eth0",
+		"speed_mbps: 1000 -> 10000",
+		"- edge       host-local:eth0 -- host-a:eth0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 {
+		t.Errorf("expected 2 changes, got:\n%s", out)
+	}
+}
+
+func TestWithSegments(t *testing.T) {
+	doc := testDocument()
+	want := doc.Segments[0].ID
+	doc.Segments = []api.Segment{}
+	if got := withSegments(doc).Segments; len(got) != 1 || got[0].ID != want {
+		t.Errorf("expected segment %s to be recomputed, got %+v", want, got)
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/config"
+	"github.com/kad/lldiscovery/internal/discovery"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// doctorFailedCode is the exit code when doctor reports an error finding
+const doctorFailedCode = 2
+
+// Severities of doctor findings
+const (
+	severityOK    = "ok"
+	severityInfo  = "info"
+	severityWarn  = "warn"
+	severityError = "error"
+)
+
+// finding is the result of one doctor check
+type finding struct {
+	Interface string `json:"interface,omitempty"` // Empty for host-wide checks
+	Check     string `json:"check"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"` // What to do about it
+}
+
+// doctor inspects the host for reasons discovery does not work. File system
+// roots and external commands are fields so checks can run against fixtures.
+type doctor struct {
+	procRoot string
+	sysRoot  string
+	etcRoot  string
+	group    net.IP
+	port     int
+
+	// listen tries to bind the discovery port
+	listen func(port int) error
+	// command runs a firewall tool and returns its output
+	command func(name string, args ...string) ([]byte, error)
+}
+
+func newDoctor(cfg *config.Config) *doctor {
+	return &doctor{
+		procRoot: discovery.Host().Proc(),
+		sysRoot:  discovery.Host().Sys(),
+		etcRoot:  discovery.Host().Etc(),
+		group:    net.ParseIP(cfg.MulticastAddr),
+		port:     cfg.MulticastPort,
+		listen: func(port int) error {
+			conn, err := net.ListenUDP("udp6", &net.UDPAddr{Port: port})
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+		command: func(name string, args ...string) ([]byte, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return exec.CommandContext(ctx, name, args...).Output()
+		},
+	}
+}
+
+// run checks the host and every interface that is up and not a loopback.
+// active are the interfaces discovery uses, as returned by GetActiveInterfaces.
+func (d *doctor) run(ifaces []net.Interface, active []discovery.InterfaceInfo) []finding {
+	var findings []finding
+
+	port := d.checkPort()
+	portInUse := port.Severity == severityInfo
+	findings = append(findings, d.checkMachineID(), port)
+	findings = append(findings, d.checkFirewall()...)
+
+	memberships, err := d.multicastMemberships()
+	if err != nil {
+		findings = append(findings, finding{
+			Check:    "multicast-group",
+			Severity: severityWarn,
+			Message:  fmt.Sprintf("cannot read multicast memberships: %v", err),
+		})
+	}
+
+	byName := make(map[string]discovery.InterfaceInfo, len(active))
+	for _, info := range active {
+		byName[info.Name] = info
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		info, used := byName[iface.Name]
+		findings = append(findings, d.checkInterface(iface, info, used, memberships, portInUse)...)
+	}
+	return findings
+}
+
+func (d *doctor) checkInterface(iface net.Interface, info discovery.InterfaceInfo, used bool, memberships map[string]bool, portInUse bool) []finding {
+	name := iface.Name
+	var findings []finding
+	add := func(check, severity, message, hint string) {
+		findings = append(findings, finding{Interface: name, Check: check, Severity: severity, Message: message, Hint: hint})
+	}
+
+	if iface.Flags&net.FlagMulticast == 0 {
+		add("multicast-flag", severityError, "interface does not support multicast",
+			fmt.Sprintf("enable it with 'ip link set dev %s multicast on'", name))
+	}
+
+	if !used {
+		hint := "IPv6 must be enabled on the interface; lldiscovery only uses interfaces with a link-local address"
+		if d.readSys("proc", "sys/net/ipv6/conf", name, "disable_ipv6") == "1" {
+			hint = fmt.Sprintf("IPv6 is disabled: sysctl -w net.ipv6.conf.%s.disable_ipv6=0", name)
+		} else if d.readSys("proc", "sys/net/ipv6/conf", name, "addr_gen_mode") == "1" {
+			hint = fmt.Sprintf("link-local address generation is off: sysctl -w net.ipv6.conf.%s.addr_gen_mode=0, then bounce the link", name)
+		}
+		add("link-local", severityError, "no IPv6 link-local address, interface is not used for discovery", hint)
+		return findings
+	}
+	add("link-local", severityOK, info.LinkLocal, "")
+
+	switch {
+	case memberships == nil:
+	case memberships[name]:
+		add("multicast-group", severityOK, "member of "+d.group.String(), "")
+	case portInUse:
+		add("multicast-group", severityError, "not a member of "+d.group.String()+" although the discovery port is in use",
+			"check the daemon log for 'failed to join multicast group' on this interface")
+	default:
+		add("multicast-group", severityInfo, "not a member of "+d.group.String(),
+			"expected while lldiscovery is not running here")
+	}
+
+	findings = append(findings, d.checkSnooping(name)...)
+
+	devices := graph.RDMANames(info.RDMADevice, info.RDMADevices)
+	// An empty device name reports an InfiniBand interface without RDMA device
+	if len(devices) == 0 && d.readSys("sys", "class/net", name, "type") == discovery.ARPHRDInfiniband {
+		devices = []string{""}
+	}
+	for _, device := range devices {
+		findings = append(findings, d.checkRDMA(name, device)...)
+	}
+	return findings
+}
+
+// checkSnooping looks for bridges that snoop MLD without a querier. Without
+// queries, memberships time out and the bridge stops forwarding discovery
+// packets after a few minutes.
+func (d *doctor) checkSnooping(name string) []finding {
+	bridge := ""
+	if _, err := os.Stat(filepath.Join(d.sysRoot, "class/net", name, "bridge")); err == nil {
+		bridge = name
+	} else if link, err := os.Readlink(filepath.Join(d.sysRoot, "class/net", name, "brport/bridge")); err == nil {
+		bridge = filepath.Base(link)
+	}
+	if bridge == "" {
+		return nil
+	}
+
+	if d.readSys("sys", "class/net", bridge, "bridge/multicast_snooping") != "1" {
+		return []finding{{Interface: name, Check: "mld-snooping", Severity: severityOK,
+			Message: fmt.Sprintf("bridge %s does not snoop multicast", bridge)}}
+	}
+	if d.readSys("sys", "class/net", bridge, "bridge/multicast_querier") == "1" {
+		return []finding{{Interface: name, Check: "mld-snooping", Severity: severityOK,
+			Message: fmt.Sprintf("bridge %s snoops MLD and acts as querier", bridge)}}
+	}
+	return []finding{{
+		Interface: name,
+		Check:     "mld-snooping",
+		Severity:  severityWarn,
+		Message:   fmt.Sprintf("bridge %s snoops MLD but is not a querier; discovery stops when memberships time out unless another querier exists", bridge),
+		Hint: fmt.Sprintf("echo 1 > /sys/class/net/%s/bridge/multicast_querier, or disable snooping with 'ip link set %s type bridge mcast_snooping 0'",
+			bridge, bridge),
+	}}
+}
+
+// checkRDMA verifies the mapping between an interface and its RDMA device
+func (d *doctor) checkRDMA(name, device string) []finding {
+	if device == "" {
+		return []finding{{Interface: name, Check: "rdma", Severity: severityWarn,
+			Message: "InfiniBand interface without an RDMA device",
+			Hint:    "check that the RDMA driver (e.g. mlx5_ib) is loaded: 'rdma link show'"}}
+	}
+
+	var findings []finding
+	if d.readSys("sys", "class/infiniband", device, "node_guid") == "" {
+		findings = append(findings, finding{Interface: name, Check: "rdma", Severity: severityWarn,
+			Message: fmt.Sprintf("RDMA device %s has no node GUID", device)})
+	}
+
+	ports, _ := os.ReadDir(filepath.Join(d.sysRoot, "class/infiniband", device, "ports"))
+	var states []string
+	active := false
+	for _, port := range ports {
+		state := discovery.StripEnumPrefix(d.readSys("sys", "class/infiniband", device, "ports/"+port.Name()+"/state"))
+		states = append(states, "port "+port.Name()+" "+state)
+		active = active || state == "ACTIVE"
+	}
+	switch {
+	case len(ports) == 0:
+		findings = append(findings, finding{Interface: name, Check: "rdma", Severity: severityWarn,
+			Message: fmt.Sprintf("RDMA device %s has no ports", device)})
+	case !active:
+		findings = append(findings, finding{Interface: name, Check: "rdma", Severity: severityWarn,
+			Message: fmt.Sprintf("no active port on %s (%s)", device, strings.Join(states, ", ")),
+			Hint:    "check cabling and the subnet manager (InfiniBand) or link state"})
+	}
+	if len(findings) == 0 {
+		findings = append(findings, finding{Interface: name, Check: "rdma", Severity: severityOK,
+			Message: fmt.Sprintf("%s, %s", device, strings.Join(states, ", "))})
+	}
+	return findings
+}
+
+// checkMachineID reports the machine ID, which must be unique: receivers drop
+// packets carrying their own ID, so clones of one image ignore each other
+func (d *doctor) checkMachineID() finding {
+	id := d.readSys("etc", "machine-id")
+	if id == "" {
+		return finding{Check: "machine-id", Severity: severityError,
+			Message: "/etc/machine-id is missing or empty",
+			Hint:    "generate one with 'systemd-machine-id-setup'"}
+	}
+	return finding{Check: "machine-id", Severity: severityOK, Message: id,
+		Hint: "must be unique: hosts cloned from one image with the same ID do not see each other"}
+}
+
+// checkPort tries to bind the discovery port. It is in use while the daemon runs.
+func (d *doctor) checkPort() finding {
+	err := d.listen(d.port)
+	switch {
+	case err == nil:
+		return finding{Check: "port", Severity: severityOK,
+			Message: fmt.Sprintf("udp/%d can be bound (lldiscovery is not running)", d.port)}
+	case errors.Is(err, syscall.EADDRINUSE):
+		return finding{Check: "port", Severity: severityInfo,
+			Message: fmt.Sprintf("udp/%d is in use, presumably by the running daemon", d.port),
+			Hint:    fmt.Sprintf("if lldiscovery is not running, find the owner with 'ss -ulpn sport = :%d'", d.port)}
+	default:
+		return finding{Check: "port", Severity: severityError,
+			Message: fmt.Sprintf("cannot bind udp/%d: %v", d.port, err)}
+	}
+}
+
+// checkFirewall searches ip6tables and nftables rules for drops of the
+// discovery port. It is a heuristic: rules matching by other criteria, such
+// as interface or address sets, are not evaluated, and a port is only
+// recognized with an explicit udp match.
+func (d *doctor) checkFirewall() []finding {
+	var findings []finding
+	port := strconv.Itoa(d.port)
+	inspected := false
+
+	if out, err := d.command("ip6tables-save"); err == nil {
+		inspected = true
+		findings = append(findings, firewallFindings("ip6tables", string(out), port,
+			func(line string) bool { return iptablesMatchesPort(line, d.port) },
+			func(line string) bool {
+				fields := strings.Fields(line)
+				for i := 0; i+1 < len(fields); i++ {
+					if fields[i] == "-j" && (fields[i+1] == "DROP" || fields[i+1] == "REJECT") {
+						return true
+					}
+				}
+				return false
+			},
+			func(line string) bool { return strings.HasPrefix(line, ":INPUT DROP") })...)
+	}
+	if out, err := d.command("nft", "list", "ruleset"); err == nil {
+		inspected = true
+		findings = append(findings, firewallFindings("nftables", string(out), port,
+			func(line string) bool { return nftMatchesPort(line, d.port) },
+			func(line string) bool {
+				for _, field := range strings.Fields(line) {
+					if field == "drop" || field == "reject" {
+						return true
+					}
+				}
+				return false
+			},
+			func(line string) bool {
+				return strings.Contains(line, "hook input") && strings.Contains(line, "policy drop")
+			})...)
+	}
+
+	if !inspected {
+		return []finding{{Check: "firewall", Severity: severityInfo,
+			Message: "could not inspect ip6tables or nftables rules",
+			Hint:    "run doctor as root to check the firewall"}}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, finding{Check: "firewall", Severity: severityOK,
+			Message: fmt.Sprintf("no rules dropping udp/%s found", port)})
+	}
+	return findings
+}
+
+// firewallFindings reports rules that drop the port, and a default-drop input
+// policy without a rule mentioning the port
+func firewallFindings(tool, rules, port string, matchesPort, drops, dropPolicy func(string) bool) []finding {
+	var findings []finding
+	policyDrop, mentioned := false, false
+	for _, line := range strings.Split(rules, "\n") {
+		line = strings.TrimSpace(line)
+		if dropPolicy(line) {
+			policyDrop = true
+		}
+		if !matchesPort(line) {
+			continue
+		}
+		mentioned = true
+		if drops(line) {
+			findings = append(findings, finding{Check: "firewall", Severity: severityError,
+				Message: fmt.Sprintf("%s rule drops udp/%s: %s", tool, port, line),
+				Hint:    "remove the rule or accept the discovery port before it"})
+		}
+	}
+	if policyDrop && !mentioned {
+		findings = append(findings, finding{Check: "firewall", Severity: severityWarn,
+			Message: fmt.Sprintf("%s input policy drops by default and no rule mentions udp/%s", tool, port),
+			Hint:    fmt.Sprintf("accept udp/%s from fe80::/10", port)})
+	}
+	return findings
+}
+
+// iptablesMatchesPort reports whether an ip6tables-save rule such as
+// "-A INPUT -p udp -m multiport --dports 53,6000:7000 -j DROP" matches UDP
+// packets to port. Negated matches do not count.
+func iptablesMatchesPort(line string, port int) bool {
+	fields := strings.Fields(line)
+	udp, matches := false, false
+	for i := 0; i+1 < len(fields); i++ {
+		negated := i > 0 && fields[i-1] == "!"
+		switch fields[i] {
+		case "-p", "--protocol":
+			udp = fields[i+1] == "udp" && !negated
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			matches = portListContains(fields[i+1], port, ":") && !negated
+		}
+	}
+	return udp && matches
+}
+
+// nftMatchesPort reports whether an nft rule such as
+// "udp dport { 53, 6000-7000 } drop" or "meta l4proto udp th dport 6469 drop"
+// matches UDP packets to port. Negated matches do not count.
+func nftMatchesPort(line string, port int) bool {
+	// Split sets into tokens: "{53,6469}" becomes "{ 53 6469 }"
+	fields := strings.Fields(strings.NewReplacer("{", " { ", "}", " } ", ",", " ").Replace(line))
+	l4udp := false
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "l4proto" && fields[i+1] == "udp" {
+			l4udp = true
+		}
+	}
+
+	for i := 1; i+1 < len(fields); i++ {
+		if fields[i] != "dport" || !(fields[i-1] == "udp" || fields[i-1] == "th" && l4udp) {
+			continue
+		}
+		values := fields[i+1:]
+		if values[0] == "!=" {
+			continue
+		}
+		if values[0] == "==" {
+			values = values[1:]
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if values[0] != "{" {
+			values = values[:1]
+		} else if end := slices.Index(values, "}"); end > 0 {
+			values = values[1:end]
+		}
+		if portListContains(strings.Join(values, ","), port, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// portListContains reports whether a comma-separated list of ports and port
+// ranges, with bounds separated by sep, contains port. An open bound of an
+// ip6tables range ("6000:") extends to the end of the port space.
+func portListContains(list string, port int, sep string) bool {
+	for _, item := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(item, sep)
+		if !isRange {
+			last = first
+		}
+		if first == "" {
+			first = "0"
+		}
+		if last == "" {
+			last = "65535"
+		}
+		lo, err1 := strconv.Atoi(first)
+		hi, err2 := strconv.Atoi(last)
+		if err1 == nil && err2 == nil && lo <= port && port <= hi {
+			return true
+		}
+	}
+	return false
+}
+
+// multicastMemberships returns the interfaces that joined the discovery group,
+// from /proc/net/igmp6
+func (d *doctor) multicastMemberships() (map[string]bool, error) {
+	f, err := os.Open(filepath.Join(d.procRoot, "net/igmp6"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIGMP6(f, d.group)
+}
+
+// parseIGMP6 parses /proc/net/igmp6 lines such as
+// "2    eth0    ff0200000000000000000000000000fb     1 00000004 0"
+// and returns the interfaces that are members of group
+func parseIGMP6(r io.Reader, group net.IP) (map[string]bool, error) {
+	members := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		addr, err := hex.DecodeString(fields[2])
+		if err != nil || len(addr) != net.IPv6len {
+			continue
+		}
+		if net.IP(addr).Equal(group) {
+			members[fields[1]] = true
+		}
+	}
+	return members, scanner.Err()
+}
+
+// readSys reads a trimmed value below the proc, sys or etc root, "" on error
+func (d *doctor) readSys(root string, elem ...string) string {
+	base := map[string]string{"proc": d.procRoot, "sys": d.sysRoot, "etc": d.etcRoot}[root]
+	data, err := os.ReadFile(filepath.Join(append([]string{base}, elem...)...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func printFindings(w io.Writer, findings []finding, asJSON bool) error {
+	if asJSON {
+		if findings == nil {
+			findings = []finding{}
+		}
+		return printJSON(w, findings)
+	}
+
+	tw := newTable(w)
+	fmt.Fprintln(tw, "INTERFACE\tCHECK\tSTATUS\tDETAILS")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", orDash(f.Interface), f.Check, f.Severity, f.Message)
+		if f.Hint != "" && f.Severity != severityOK {
+			fmt.Fprintf(tw, "\t\t\t-> %s\n", f.Hint)
+		}
+	}
+	return tw.Flush()
+}
+
+func runDoctor(args []string) error {
+	fs := newCommandFlags("doctor")
+	configPath := fs.String("config", "", "configuration file providing multicast address and port")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	discovery.SetHostRoot(cfg.HostRoot)
+	d := newDoctor(cfg)
+	if d.group == nil {
+		return fmt.Errorf("invalid multicast address %q", cfg.MulticastAddr)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+	active, err := discovery.GetActiveInterfaces()
+	if err != nil {
+		return err
+	}
+
+	findings := d.run(ifaces, active)
+	if err := printFindings(os.Stdout, findings, *asJSON); err != nil {
+		return err
+	}
+
+	errorCount := 0
+	for _, f := range findings {
+		if f.Severity == severityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return &exitError{code: doctorFailedCode, err: fmt.Errorf("%d problems found", errorCount)}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/discovery"
+)
+
+const testIGMP6 = `1    lo              ff020000000000000000000000000001     1 0000000C 0
+2    eth0            ff0200000000000000000000000000fb     1 00000004 0
+2    eth0            ff02000000000000000000004c4c6469     1 00000004 0
+3    br0             ff020000000000000000000000000001     1 0000000C 0
+4    ib0             ff02000000000000000000004c4c6469     1 00000004 0
+`
+
+// writeFixture creates root/path with content, creating parent directories
+func writeFixture(t *testing.T, root, path, content string) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDoctor(t *testing.T) *doctor {
+	t.Helper()
+	root := t.TempDir()
+	d := &doctor{
+		procRoot: filepath.Join(root, "proc"),
+		sysRoot:  filepath.Join(root, "sys"),
+		etcRoot:  filepath.Join(root, "etc"),
+		group:    net.ParseIP("ff02::4c4c:6469"),
+		port:     9999,
+		listen:   func(int) error { return syscall.EADDRINUSE },
+		command: func(name string, args ...string) ([]byte, error) {
+			return nil, errors.New("not found")
+		},
+	}
+	writeFixture(t, d.procRoot, "net/igmp6", testIGMP6)
+	writeFixture(t, d.etcRoot, "machine-id", "0123456789abcdef\n")
+
+	// br0 snoops without querier, eth1 is one of its ports
+	writeFixture(t, d.sysRoot, "class/net/br0/bridge/multicast_snooping", "1\n")
+	writeFixture(t, d.sysRoot, "class/net/br0/bridge/multicast_querier", "0\n")
+	if err := os.MkdirAll(filepath.Join(d.sysRoot, "class/net/eth1/brport"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../br0", filepath.Join(d.sysRoot, "class/net/eth1/brport/bridge")); err != nil {
+		t.Fatal(err)
+	}
+
+	// ib0 is mlx5_0 with its only port down, ib1 has no RDMA device
+	writeFixture(t, d.sysRoot, "class/infiniband/mlx5_0/node_guid", "0002:c903:0001:0001\n")
+	writeFixture(t, d.sysRoot, "class/infiniband/mlx5_0/ports/1/state", "1: DOWN\n")
+	writeFixture(t, d.sysRoot, "class/net/ib1/type", "32\n")
+
+	writeFixture(t, d.procRoot, "sys/net/ipv6/conf/eth2/disable_ipv6", "1\n")
+	return d
+}
+
+func findingsFor(findings []finding, iface, check string) []finding {
+	var matched []finding
+	for _, f := range findings {
+		if f.Interface == iface && f.Check == check {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+func TestParseIGMP6(t *testing.T) {
+	members, err := parseIGMP6(strings.NewReader(testIGMP6), net.ParseIP("ff02::4c4c:6469"))
+	if err != nil {
+		t.Fatalf("parseIGMP6 failed: %v", err)
+	}
+	if len(members) != 2 || !members["eth0"] || !members["ib0"] {
+		t.Errorf("expected eth0 and ib0, got %v", members)
+	}
+}
+
+func TestDoctorRun(t *testing.T) {
+	d := testDoctor(t)
+	up := net.FlagUp | net.FlagMulticast
+	ifaces := []net.Interface{
+		{Name: "lo", Flags: up | net.FlagLoopback},
+		{Name: "eth0", Flags: up},
+		{Name: "eth1", Flags: up},
+		{Name: "eth2", Flags: up},
+		{Name: "eth3", Flags: net.FlagMulticast}, // Down, skipped
+		{Name: "br0", Flags: up},
+		{Name: "ib0", Flags: up},
+		{Name: "ib1", Flags: up},
+		{Name: "tun0", Flags: net.FlagUp},
+	}
+	active := []discovery.InterfaceInfo{
+		{Name: "eth0", LinkLocal: "fe80::1%eth0"},
+		{Name: "eth1", LinkLocal: "fe80::2%eth1"},
+		{Name: "br0", LinkLocal: "fe80::3%br0"},
+		{Name: "ib0", LinkLocal: "fe80::4%ib0", IsRDMA: true, RDMADevice: "mlx5_0"},
+		{Name: "ib1", LinkLocal: "fe80::5%ib1"},
+		{Name: "tun0", LinkLocal: "fe80::6%tun0"},
+	}
+	findings := d.run(ifaces, active)
+
+	tests := []struct {
+		iface, check, severity, contains string
+	}{
+		{"", "machine-id", severityOK, "0123456789abcdef"},
+		{"", "port", severityInfo, "in use"},
+		{"", "firewall", severityInfo, "could not inspect"},
+		{"eth0", "link-local", severityOK, "fe80::1"},
+		{"eth0", "multicast-group", severityOK, "member of ff02::4c4c:6469"},
+		{"eth1", "multicast-group", severityError, "not a member"},
+		{"eth1", "mld-snooping", severityWarn, "bridge br0 snoops MLD"},
+		{"br0", "mld-snooping", severityWarn, "bridge br0 snoops MLD"},
+		{"eth2", "link-local", severityError, "no IPv6 link-local"},
+		{"ib0", "rdma", severityWarn, "no active port on mlx5_0 (port 1 DOWN)"},
+		{"ib1", "rdma", severityWarn, "without an RDMA device"},
+		{"tun0", "multicast-flag", severityError, "does not support multicast"},
+	}
+	for _, tt := range tests {
+		matched := findingsFor(findings, tt.iface, tt.check)
+		if len(matched) != 1 {
+			t.Errorf("%s/%s: expected one finding, got %+v", tt.iface, tt.check, matched)
+			continue
+		}
+		if matched[0].Severity != tt.severity || !strings.Contains(matched[0].Message, tt.contains) {
+			t.Errorf("%s/%s: expected %s containing %q, got %+v", tt.iface, tt.check, tt.severity, tt.contains, matched[0])
+		}
+	}
+
+	if f := findingsFor(findings, "eth2", "link-local"); len(f) == 1 && !strings.Contains(f[0].Hint, "disable_ipv6=0") {
+		t.Errorf("expected disable_ipv6 hint, got %q", f[0].Hint)
+	}
+	for _, f := range findings {
+		if f.Interface == "lo" || f.Interface == "eth3" {
+			t.Errorf("unexpected finding for %s: %+v", f.Interface, f)
+		}
+	}
+}
+
+func TestDoctorRDMAActive(t *testing.T) {
+	d := testDoctor(t)
+	writeFixture(t, d.sysRoot, "class/infiniband/mlx5_0/ports/1/state", "4: ACTIVE\n")
+	findings := d.checkRDMA("ib0", "mlx5_0")
+	if len(findings) != 1 || findings[0].Severity != severityOK {
+		t.Errorf("expected one ok finding, got %+v", findings)
+	}
+}
+
+func TestDoctorFirewall(t *testing.T) {
+	tests := []struct {
+		name     string
+		outputs  map[string]string
+		severity []string
+	}{
+		{
+			name: "ip6tables drop",
+			outputs: map[string]string{"ip6tables-save": `*filter
+:INPUT ACCEPT [0:0]
+-A INPUT -p udp -m udp --dport 9999 -j DROP
+COMMIT`},
+			severity: []string{severityError},
+		},
+		{
+			name: "nftables policy drop",
+			outputs: map[string]string{"nft": `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		tcp dport 22 accept
+	}
+}`},
+			severity: []string{severityWarn},
+		},
+		{
+			name: "nftables accept",
+			outputs: map[string]string{"nft": `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		udp dport 9999 accept
+	}
+}`},
+			severity: []string{severityOK},
+		},
+		{
+			name: "nftables reject",
+			outputs: map[string]string{
+				"ip6tables-save": "*filter\n:INPUT ACCEPT [0:0]\nCOMMIT\n",
+				"nft":            "table ip6 f {\n\tchain input {\n\t\tudp dport 9999 reject\n\t}\n}\n",
+			},
+			severity: []string{severityError},
+		},
+		{
+			name: "ip6tables tcp drop",
+			outputs: map[string]string{"ip6tables-save": `*filter
+:INPUT ACCEPT [0:0]
+-A INPUT -p tcp -m tcp --dport 9999 -j DROP
+-A INPUT -p udp -m udp --dport 99990 -j DROP
+COMMIT`},
+			severity: []string{severityOK},
+		},
+		{
+			name: "nftables set drop",
+			outputs: map[string]string{"nft": `table inet filter {
+	chain input {
+		udp dport { 53, 9999 } drop
+	}
+}`},
+			severity: []string{severityError},
+		},
+		{
+			name:     "no tools",
+			outputs:  map[string]string{},
+			severity: []string{severityInfo},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := testDoctor(t)
+			d.command = func(name string, args ...string) ([]byte, error) {
+				out, ok := tt.outputs[name]
+				if !ok {
+					return nil, fmt.Errorf("%s: not found", name)
+				}
+				return []byte(out), nil
+			}
+			findings := d.checkFirewall()
+			var severities []string
+			for _, f := range findings {
+				severities = append(severities, f.Severity)
+			}
+			if fmt.Sprint(severities) != fmt.Sprint(tt.severity) {
+				t.Errorf("expected %v, got %+v", tt.severity, findings)
+			}
+		})
+	}
+}
+
+func TestFirewallMatchesPort(t *testing.T) {
+	tests := []struct {
+		rule    string
+		matches func(string, int) bool
+		want    bool
+	}{
+		{"-A INPUT -p udp -m udp --dport 6469 -j DROP", iptablesMatchesPort, true},
+		{"-A INPUT -p tcp -m tcp --dport 6469 -j DROP", iptablesMatchesPort, false},
+		{"-A INPUT -p udp -m udp --dport 64690 -j DROP", iptablesMatchesPort, false},
+		{"-A INPUT -m udp --dport 6469 -j DROP", iptablesMatchesPort, false},
+		{"-A INPUT -p udp -m udp ! --dport 6469 -j DROP", iptablesMatchesPort, false},
+		{"-A INPUT -p udp -m multiport --dports 53,6000:7000 -j DROP", iptablesMatchesPort, true},
+		{"-A INPUT -p udp -m udp --dport 6000: -j DROP", iptablesMatchesPort, true},
+		{"udp dport 6469 drop", nftMatchesPort, true},
+		{"tcp dport 6469 drop", nftMatchesPort, false},
+		{"udp dport 64690 drop", nftMatchesPort, false},
+		{"udp dport != 6469 drop", nftMatchesPort, false},
+		{"udp dport { 53, 6469 } drop", nftMatchesPort, true},
+		{"udp dport {53,6469} drop", nftMatchesPort, true},
+		{"udp dport { 53, 64690 } drop", nftMatchesPort, false},
+		{"udp dport 6000-7000 drop", nftMatchesPort, true},
+		{"meta l4proto udp th dport 6469 drop", nftMatchesPort, true},
+		{"meta l4proto tcp th dport 6469 drop", nftMatchesPort, false},
+	}
+	for _, tt := range tests {
+		if got := tt.matches(tt.rule, 6469); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.rule, tt.want, got)
+		}
+	}
+}
+
+func TestPrintFindings(t *testing.T) {
+	findings := []finding{
+		{Check: "port", Severity: severityOK, Message: "udp/9999 can be bound", Hint: "not shown"},
+		{Interface: "eth0", Check: "multicast-flag", Severity: severityError, Message: "no multicast", Hint: "enable it"},
+	}
+	var buf bytes.Buffer
+	if err := printFindings(&buf, findings, false); err != nil {
+		t.Fatalf("printFindings failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "-> enable it") || strings.Contains(out, "not shown") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := printFindings(&buf, nil, true); err != nil {
+		t.Fatalf("printFindings failed: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("expected empty JSON array, got %q", buf.String())
+	}
+}
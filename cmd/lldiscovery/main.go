@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -11,7 +13,9 @@ import (
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"github.com/kad/lldiscovery/internal/collector"
 	"github.com/kad/lldiscovery/internal/config"
 	"github.com/kad/lldiscovery/internal/discovery"
 	"github.com/kad/lldiscovery/internal/export"
@@ -28,7 +32,7 @@ var (
 	configPath  = flag.String("config", "", "path to configuration file")
 	logLevel    = flag.String("log-level", "", "log level (debug, info, warn, error)")
 	showVersion = flag.Bool("version", false, "show version and exit")
-	listRDMA    = flag.Bool("list-rdma", false, "list RDMA devices and their parent interfaces, then exit")
+	listRDMA    = flag.Bool("list-rdma", false, "deprecated: use 'lldiscovery rdma'")
 
 	// Timing parameters
 	sendInterval   = flag.Duration("send-interval", 0, "how often to send discovery packets (e.g., 30s)")
@@ -40,13 +44,24 @@ var (
 	multicastPort = flag.Int("multicast-port", 0, "UDP port for discovery protocol")
 
 	// Output parameters
-	outputFile  = flag.String("output-file", "", "path to DOT file output")
-	httpAddress = flag.String("http-address", "", "HTTP server bind address (e.g., :6469)")
+	outputFile    = flag.String("output-file", "", "path to DOT file output")
+	svgOutputFile = flag.String("svg-output-file", "", "path to SVG file output (rendered without graphviz)")
+	httpAddress   = flag.String("http-address", "", "HTTP server bind address (e.g., :6469)")
+	tlsCertFile   = flag.String("tls-cert-file", "", "serve the HTTP API over TLS with this certificate")
+	tlsKeyFile    = flag.String("tls-key-file", "", "private key for -tls-cert-file")
+	tlsClientCA   = flag.String("tls-client-ca-file", "", "require client certificates signed by this CA bundle")
+	tokensFile    = flag.String("tokens-file", "", "require bearer tokens listed in this file for API access")
+	controlSocket = flag.String("control-socket", "", "also serve the API on this Unix socket for the CLI subcommands")
+	hostRoot      = flag.String("host-root", "", "read /sys, /proc and /etc below this directory (e.g., /host in a container)")
 
 	// Feature flags
 	includeNeighbors = flag.Bool("include-neighbors", false, "share neighbor information for transitive discovery")
 	showSegments     = flag.Bool("show-segments", false, "detect and visualize network segments (3+ nodes on same interface)")
 
+	// Collector parameters
+	collectorMode = flag.Bool("collector", false, "run as a collector merging the graphs of many agents instead of discovering")
+	pushURL       = flag.String("push-url", "", "collector URL to push the local graph to (e.g., http://collector:6469/api/v1/push)")
+
 	// Telemetry parameters
 	telemetryEnabled       = flag.Bool("telemetry-enabled", false, "enable OpenTelemetry")
 	telemetryEndpoint      = flag.String("telemetry-endpoint", "", "OpenTelemetry endpoint URL (e.g., grpc://localhost:4317, http://localhost:4318)")
@@ -57,6 +72,12 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			os.Exit(runCommand(cmd, os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
 	if *showVersion {
@@ -67,7 +88,11 @@ func main() {
 	}
 
 	if *listRDMA {
-		listRDMADevices()
+		discovery.SetHostRoot(*hostRoot)
+		if err := listRDMADevices(os.Stdout, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
@@ -99,6 +124,9 @@ func main() {
 	if *outputFile != "" {
 		cfg.OutputFile = *outputFile
 	}
+	if *svgOutputFile != "" {
+		cfg.SVGOutputFile = *svgOutputFile
+	}
 	if *httpAddress != "" {
 		cfg.HTTPAddress = *httpAddress
 	}
@@ -111,6 +139,9 @@ func main() {
 		if f.Name == "show-segments" {
 			cfg.ShowSegments = *showSegments
 		}
+		if f.Name == "collector" {
+			cfg.Collector.Enabled = *collectorMode
+		}
 		if f.Name == "telemetry-enabled" {
 			cfg.Telemetry.Enabled = *telemetryEnabled
 		}
@@ -130,6 +161,35 @@ func main() {
 	if *telemetryEndpoint != "" {
 		cfg.Telemetry.Endpoint = *telemetryEndpoint
 	}
+	if *pushURL != "" {
+		cfg.Push.URL = *pushURL
+	}
+	if *controlSocket != "" {
+		cfg.ControlSocket = *controlSocket
+	}
+	if *hostRoot != "" {
+		cfg.HostRoot = *hostRoot
+	}
+	if *tokensFile != "" {
+		cfg.Auth.TokensFile = *tokensFile
+	}
+	if *tlsCertFile != "" {
+		cfg.TLS.CertFile = *tlsCertFile
+	}
+	if *tlsKeyFile != "" {
+		cfg.TLS.KeyFile = *tlsKeyFile
+	}
+	if *tlsClientCA != "" {
+		cfg.TLS.ClientCAFile = *tlsClientCA
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid TLS configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.ValidateCollector(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid collector configuration: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Parse endpoint URL to extract protocol and default ports
 	if err := cfg.Telemetry.ParseEndpoint(); err != nil {
@@ -183,7 +243,325 @@ func main() {
 		logger.Info("metrics initialized")
 	}
 
+	templates, err := loadTemplates(cfg.Templates)
+	if err != nil {
+		logger.Error("failed to load export templates", "error", err)
+		os.Exit(1)
+	}
+
+	outputs, err := buildOutputs(cfg, templates)
+	if err != nil {
+		logger.Error("invalid output configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.Collector.Enabled {
+		runCollector(ctx, cancel, cfg, templates, outputs, logger, metrics)
+		return
+	}
+
+	discovery.SetHostRoot(cfg.HostRoot)
+	g := newLocalGraph(cfg, logger)
+
+	var packetsReceived, packetsSent, errors, multicastFailures metric.Int64Counter
+	if metrics != nil {
+		packetsReceived = metrics.PacketsReceived
+		packetsSent = metrics.PacketsSent
+		errors = metrics.DiscoveryErrors
+		multicastFailures = metrics.MulticastJoinFailures
+	}
+
+	receiver, err := discovery.NewReceiver(cfg.MulticastAddr, cfg.MulticastPort, logger, newPacketHandler(ctx, g, cfg.IncludeNeighbors, logger, metrics), packetsReceived, multicastFailures)
+	if err != nil {
+		logger.Error("failed to create receiver", "error", err)
+		os.Exit(1)
+	}
+
+	sender := discovery.NewSender(cfg.MulticastAddr, cfg.MulticastPort, cfg.SendInterval, logger, packetsSent, errors, cfg.IncludeNeighbors, g)
+	sender.SetLabels(cfg.Labels)
+	sender.SetHealth(discovery.HealthThresholds{
+		ErrorRate: cfg.Health.ErrorRate,
+		DropRate:  cfg.Health.DropRate,
+	}, newHealthHandler(ctx, g, metrics))
+	srvOpts, err := serverOptions(cfg, templates, logger)
+	if err != nil {
+		logger.Error("failed to configure HTTP server", "error", err)
+		os.Exit(1)
+	}
+	srv := server.New(cfg.HTTPAddress, g, logger, cfg.ShowSegments, srvOpts...)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	errChan := make(chan error, 4)
+
+	go func() {
+		if err := receiver.Run(ctx); err != nil && err != context.Canceled {
+			errChan <- fmt.Errorf("receiver: %w", err)
+		}
+	}()
+
+	go func() {
+		if err := sender.Run(ctx); err != nil && err != context.Canceled {
+			errChan <- fmt.Errorf("sender: %w", err)
+		}
+	}()
+
+	carrierMonitor := discovery.NewCarrierMonitor(logger, func(ifaceName string, flaps int) {
+		recordFlaps(g, logger, g.GetLocalMachineID(), ifaceName, 0, flaps)
+	})
+	go func() {
+		// Discovery works without it, only local flaps go uncounted
+		if err := carrierMonitor.Run(ctx); err != nil && err != context.Canceled {
+			logger.Warn("carrier monitor stopped", "error", err)
+		}
+	}()
+
+	go func() {
+		if err := srv.Run(ctx); err != nil && err != context.Canceled {
+			errChan <- fmt.Errorf("server: %w", err)
+		}
+	}()
+
+	if cfg.Push.URL != "" {
+		logger.Info("pushing graph to collector", "url", cfg.Push.URL, "interval", cfg.Push.Interval)
+		pusher := collector.NewPusher(cfg.Push.URL, cfg.Push.Interval, g, logger)
+		if cfg.Push.TokenFile != "" {
+			token, err := readTokenFile(cfg.Push.TokenFile)
+			if err != nil {
+				logger.Error("failed to read push token", "error", err)
+				os.Exit(1)
+			}
+			pusher.SetToken(token)
+		}
+		tlsConfig, err := collector.NewTLSConfig(cfg.Push.TLS.CAFile, cfg.Push.TLS.CertFile, cfg.Push.TLS.KeyFile)
+		if err != nil {
+			logger.Error("failed to configure push TLS", "error", err)
+			os.Exit(1)
+		}
+		pusher.SetTLSConfig(tlsConfig)
+		go func() {
+			if err := pusher.Run(ctx); err != nil && err != context.Canceled {
+				errChan <- fmt.Errorf("pusher: %w", err)
+			}
+		}()
+	}
+
+	go runExporter(ctx, g, cfg, outputs, logger, metrics)
+
+	select {
+	case sig := <-sigChan:
+		logger.Info("received signal", "signal", sig)
+		cancel()
+	case err := <-errChan:
+		logger.Error("component error", "error", err)
+		cancel()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	logger.Info("shutdown complete")
+}
+
+// loadTemplates compiles the configured export templates, in config order
+func loadTemplates(configs []config.TemplateConfig) ([]*export.Template, error) {
+	templates := make([]*export.Template, 0, len(configs))
+	for _, tc := range configs {
+		tmpl, err := export.LoadTemplate(tc.Name, tc.Template)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates, nil
+}
+
+// buildOutputs resolves the configured outputs into exporter artifacts
+func buildOutputs(cfg *config.Config, templates []*export.Template) ([]*export.Output, error) {
+	byName := make(map[string]*export.Template)
+	for _, t := range templates {
+		byName[t.Name] = t
+	}
+
+	var outputs []*export.Output
+	for _, oc := range cfg.EffectiveOutputs() {
+		output := &export.Output{
+			Format:   oc.Format,
+			Path:     oc.Path,
+			Segments: *oc.Segments,
+			Template: byName[oc.Template],
+			Filter: graph.Filter{
+				Hosts:       oc.Filter.Hosts,
+				Labels:      oc.Filter.Labels,
+				Segments:    oc.Filter.Segments,
+				Prefixes:    oc.Filter.Prefixes,
+				Interfaces:  oc.Filter.Interfaces,
+				RDMAOnly:    oc.Filter.RDMAOnly,
+				DirectOnly:  oc.Filter.DirectOnly,
+				Around:      oc.Filter.Around,
+				Hops:        oc.Filter.Hops,
+				CollapseVFs: oc.Filter.CollapseVFs,
+			},
+		}
+		if err := output.Filter.Validate(); err != nil {
+			return nil, fmt.Errorf("output %s: %w", oc.Path, err)
+		}
+		if oc.Hook != nil {
+			output.Hook = &export.Hook{Command: oc.Hook.Command, Timeout: oc.Hook.Timeout}
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// topology is the graph maintained by the daemon: the local graph of an agent,
+// or the merged fleet view of a collector
+type topology interface {
+	server.Source
+	HasChanges() bool
+	ClearChanges()
+	RemoveExpired(timeout time.Duration) int
+}
+
+func runExporter(ctx context.Context, g topology, cfg *config.Config, outputs []*export.Output, logger *slog.Logger, metrics *telemetry.Metrics) {
+	exportTicker := time.NewTicker(cfg.ExportInterval)
+	defer exportTicker.Stop()
+
+	expireTicker := time.NewTicker(30 * time.Second)
+	defer expireTicker.Stop()
+
+	states := make([]*outputState, len(outputs))
+	for i, output := range outputs {
+		states[i] = &outputState{output: output}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-exportTicker.C:
+			exportChanges(ctx, g, states, logger, metrics)
+		case <-expireTicker.C:
+			removed := g.RemoveExpired(cfg.NodeTimeout)
+			if removed > 0 {
+				logger.Info("removed expired nodes", "count", removed)
+				if metrics != nil {
+					metrics.NodesExpired.Add(ctx, int64(removed))
+				}
+			}
+		}
+	}
+}
+
+// outputState tracks what the exporter last wrote to an output
+
+type outputState struct {
+	output  *export.Output
+	pending bool              // Graph changed since the output was last written
+	digest  [sha256.Size]byte // Content last written, zero before the first write
+}
+
+// exportChanges writes the outputs that are pending after a change of g.
+// An output whose content did not change, because its filter hides the
+// change, is neither written nor hooked. Failed outputs stay pending and are
+// retried on the next call, the others are not written again.
+func exportChanges(ctx context.Context, g topology, states []*outputState, logger *slog.Logger, metrics *telemetry.Metrics) {
+	// Clear before taking the snapshot so later changes are not lost
+	if g.HasChanges() {
+		g.ClearChanges()
+		for _, state := range states {
+			state.pending = true
+		}
+	}
+
+	var pending []*outputState
+	for _, state := range states {
+		if state.pending {
+			pending = append(pending, state)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	nodes := g.GetNodes()
+	edges := g.GetEdges()
+
+	// Segment detection is comparatively expensive, only run it when an output needs it
+	var segments []graph.NetworkSegment
+	for _, state := range pending {
+		if state.output.NeedsSegments() {
+			segments = g.GetNetworkSegments()
+			logger.Debug("detected network segments", "count", len(segments))
+			break
+		}
+	}
+
+	written, failed := 0, 0
+	for _, state := range pending {
+		ok, err := writeOutput(ctx, state, nodes, edges, segments, logger)
+		if err != nil {
+			logger.Error("failed to export graph", "format", state.output.Format, "file", state.output.Path, "error", err)
+			failed++
+			continue
+		}
+		state.pending = false
+		if ok {
+			written++
+		}
+	}
+
+	if written > 0 && failed == 0 && metrics != nil {
+		metrics.GraphExports.Add(ctx, 1)
+		metrics.NodesDiscovered.Add(ctx, int64(len(nodes)))
+	}
+}
+
+// writeOutput renders one output and, if its content changed since the last
+// write, atomically writes it and runs its hook. It reports whether the file
+// was written.
+func writeOutput(ctx context.Context, state *outputState, nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment, logger *slog.Logger) (bool, error) {
+	output := state.output
+	content, err := output.Generate(nodes, edges, segments)
+	if err != nil {
+		return false, err
+	}
+	digest := sha256.Sum256([]byte(content))
+	if digest == state.digest {
+		logger.Debug("exported graph unchanged", "format", output.Format, "file", output.Path)
+		return false, nil
+	}
+	if err := export.WriteFileAtomic(output.Path, content); err != nil {
+		return false, err
+	}
+	state.digest = digest
+	logger.Info("exported graph", "nodes", len(nodes), "format", output.Format, "file", output.Path)
+
+	if output.Hook == nil {
+		return true, nil
+	}
+	result, err := output.Hook.Run(ctx, output)
+	if err != nil {
+		logger.Error("export hook failed", "file", output.Path, "command", output.Hook.Command, "error", err, "output", result.Output)
+		return true, nil
+	}
+	if result.ExitCode != 0 {
+		logger.Warn("export hook exited with non-zero status", "file", output.Path, "command", output.Hook.Command,
+			"exit_code", result.ExitCode, "duration", result.Duration, "output", result.Output)
+		return true, nil
+	}
+	logger.Debug("export hook finished", "file", output.Path, "exit_code", 0, "duration", result.Duration)
+	return true, nil
+}
+
+// newLocalGraph creates a graph holding the local node with its active interfaces
+func newLocalGraph(cfg *config.Config, logger *slog.Logger) *graph.Graph {
 	g := graph.New()
+	g.SetFlapDampening(graph.FlapDampening{
+		Penalty:  cfg.FlapDampening.Penalty,
+		Suppress: cfg.FlapDampening.Suppress,
+		Reuse:    cfg.FlapDampening.Reuse,
+		HalfLife: cfg.FlapDampening.HalfLife,
+	})
 
 	// Get local machine info and interfaces for the graph
 	localInterfaces, err := discovery.GetActiveInterfaces()
@@ -199,6 +577,11 @@ func main() {
 				NodeGUID:       iface.NodeGUID,
 				SysImageGUID:   iface.SysImageGUID,
 				Speed:          iface.Speed,
+				RDMADevices:    iface.RDMADevices,
+				IPoIB:          iface.IPoIB,
+				Ethtool:        iface.Ethtool,
+				Stack:          iface.Stack,
+				SRIOV:          iface.SRIOV,
 			}
 		}
 
@@ -208,29 +591,42 @@ func main() {
 			hostname = "unknown"
 		}
 
-		machineID, err := os.ReadFile("/etc/machine-id")
+		machineID, err := discovery.ReadMachineID()
 		if err == nil {
-			g.SetLocalNode(strings.TrimSpace(string(machineID)), hostname, ifaceMap)
+			g.SetLocalNode(machineID, hostname, ifaceMap)
+			g.SetNodeLabels(machineID, cfg.Labels)
 			logger.Info("local node added to graph",
 				"hostname", hostname,
 				"interfaces", len(ifaceMap))
 		}
 	}
+	return g
+}
 
-	var packetsReceived, packetsSent, errors, multicastFailures metric.Int64Counter
-	if metrics != nil {
-		packetsReceived = metrics.PacketsReceived
-		packetsSent = metrics.PacketsSent
-		errors = metrics.DiscoveryErrors
-		multicastFailures = metrics.MulticastJoinFailures
-	}
-
-	receiver, err := discovery.NewReceiver(cfg.MulticastAddr, cfg.MulticastPort, logger, func(p *discovery.Packet, sourceIP, receivingIface string) {
+// newPacketHandler records received packets in g: a direct edge to the sender
+// and, with includeNeighbors, indirect edges to the neighbors it reports.
+// Packet loss and jitter go to metrics unless it is nil.
+func newPacketHandler(ctx context.Context, g *graph.Graph, includeNeighbors bool, logger *slog.Logger, metrics *telemetry.Metrics) discovery.PacketHandler {
+	return func(p *discovery.Packet, sourceIP, receivingIface string) {
 		// Add direct edge for received packet
 		g.AddOrUpdate(p.MachineID, p.Hostname, p.Interface, sourceIP, receivingIface, p.RDMADevice, p.NodeGUID, p.SysImageGUID, p.Speed, p.GlobalPrefixes, true, "")
+		g.SetNodeLabels(p.MachineID, p.Labels)
+		g.SetInterfaceRDMA(p.MachineID, p.Interface, p.RDMADevices)
+		g.SetInterfaceIPoIB(p.MachineID, p.Interface, p.IPoIB)
+		g.SetInterfaceEthtool(p.MachineID, p.Interface, p.Ethtool)
+		g.SetInterfaceStack(p.MachineID, p.Interface, p.Stack)
+		g.SetInterfaceSRIOV(p.MachineID, p.Interface, p.SRIOV)
+		g.SetInterfaceHealth(p.MachineID, p.Interface, p.Health)
+		if p.Health != nil && p.Health.CarrierChanges > 0 {
+			// A flap is a loss and a return of the carrier, two changes
+			recordFlaps(g, logger, p.MachineID, p.Interface, p.Sequence, (p.Health.CarrierChanges+1)/2)
+		}
+		if p.Sequence != 0 {
+			recordSequence(ctx, g, metrics, p, receivingIface)
+		}
 
 		// Process neighbors if included
-		if cfg.IncludeNeighbors && len(p.Neighbors) > 0 {
+		if includeNeighbors && len(p.Neighbors) > 0 {
 			localMachineID := g.GetLocalMachineID()
 			for _, neighbor := range p.Neighbors {
 				// Skip if neighbor is local node (avoid self-loop)
@@ -263,103 +659,125 @@ func main() {
 				)
 			}
 		}
-	}, packetsReceived, multicastFailures)
-	if err != nil {
-		logger.Error("failed to create receiver", "error", err)
-		os.Exit(1)
 	}
+}
 
-	sender := discovery.NewSender(cfg.MulticastAddr, cfg.MulticastPort, cfg.SendInterval, logger, packetsSent, errors, cfg.IncludeNeighbors, g)
-	srv := server.New(cfg.HTTPAddress, g, logger, cfg.ShowSegments)
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+// recordFlaps counts carrier losses of an interface of a node in g, once per
+// packet sequence number seq (0 if unknown), and warns when its links become
+// dampened
+func recordFlaps(g *graph.Graph, logger *slog.Logger, machineID, iface string, seq uint64, flaps int) {
+	if !g.RecordSequencedFlaps(machineID, iface, seq, flaps) {
+		return
+	}
+	if flap := g.GetInterfaceFlap(machineID, iface); flap != nil {
+		logger.Warn("link flapping, dampening changes",
+			"machine_id", machineID,
+			"interface", iface,
+			"flaps", flap.Flaps,
+			"penalty", flap.Penalty)
+	}
+}
 
-	errChan := make(chan error, 3)
+// recordSequence counts a sequenced packet on the link it arrived over and
+// records the link's loss and jitter in the neighbor metrics
+func recordSequence(ctx context.Context, g *graph.Graph, metrics *telemetry.Metrics, p *discovery.Packet, receivingIface string) {
+	interval := time.Duration(p.IntervalMs) * time.Millisecond
+	sample := g.RecordSequence(p.MachineID, p.Interface, receivingIface, p.Sequence, interval)
+	if metrics == nil {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("interface", receivingIface),
+		attribute.String("neighbor", p.Hostname),
+		attribute.String("remote_interface", p.Interface),
+	)
+	if sample.Missed > 0 {
+		metrics.NeighborMissed.Add(ctx, int64(sample.Missed), attrs)
+	}
+	if sample.Deviation > 0 {
+		metrics.NeighborJitter.Record(ctx, float64(sample.Deviation)/float64(time.Millisecond), attrs)
+	}
+	metrics.NeighborLoss.Record(ctx, sample.Loss, attrs)
+}
 
-	go func() {
-		if err := receiver.Run(ctx); err != nil && err != context.Canceled {
-			errChan <- fmt.Errorf("receiver: %w", err)
+// newHealthHandler records the health of local interfaces in g, which only
+// learns the local node's interfaces at startup, and in the link metrics
+func newHealthHandler(ctx context.Context, g *graph.Graph, metrics *telemetry.Metrics) discovery.HealthHandler {
+	return func(ifaceName string, health *graph.Health) {
+		g.SetInterfaceHealth(g.GetLocalMachineID(), ifaceName, health)
+		if metrics == nil || health == nil {
+			return
 		}
-	}()
-
-	go func() {
-		if err := sender.Run(ctx); err != nil && err != context.Canceled {
-			errChan <- fmt.Errorf("sender: %w", err)
+		if health.Degraded() {
+			metrics.LinkDegraded.Add(ctx, 1, metric.WithAttributes(attribute.String("interface", ifaceName)))
 		}
-	}()
-
-	go func() {
-		if err := srv.Run(ctx); err != nil && err != context.Canceled {
-			errChan <- fmt.Errorf("server: %w", err)
+		for counter, rate := range map[string]float64{
+			"rx_errors":     health.RxErrors,
+			"tx_errors":     health.TxErrors,
+			"rx_dropped":    health.RxDropped,
+			"tx_dropped":    health.TxDropped,
+			"rx_crc_errors": health.RxCRCErrors,
+			"rdma_errors":   health.RDMAErrors,
+		} {
+			metrics.LinkErrorRate.Record(ctx, rate, metric.WithAttributes(
+				attribute.String("interface", ifaceName),
+				attribute.String("counter", counter),
+			))
 		}
-	}()
-
-	go runExporter(ctx, g, cfg, logger, metrics)
-
-	select {
-	case sig := <-sigChan:
-		logger.Info("received signal", "signal", sig)
-		cancel()
-	case err := <-errChan:
-		logger.Error("component error", "error", err)
-		cancel()
 	}
-
-	time.Sleep(100 * time.Millisecond)
-	logger.Info("shutdown complete")
 }
 
-func runExporter(ctx context.Context, g *graph.Graph, cfg *config.Config, logger *slog.Logger, metrics *telemetry.Metrics) {
-	exportTicker := time.NewTicker(cfg.ExportInterval)
-	defer exportTicker.Stop()
-
-	expireTicker := time.NewTicker(30 * time.Second)
-	defer expireTicker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-exportTicker.C:
-			if g.HasChanges() {
-				nodes := g.GetNodes()
-				edges := g.GetEdges()
-
-				// Generate DOT with or without segments
-				var dot string
-				if cfg.ShowSegments {
-					segments := g.GetNetworkSegments()
-					dot = export.GenerateDOTWithSegments(nodes, edges, segments)
-					logger.Debug("detected network segments", "count", len(segments))
-				} else {
-					dot = export.GenerateDOT(nodes, edges)
-				}
-
-				if err := export.WriteDOTFile(cfg.OutputFile, dot); err != nil {
-					logger.Error("failed to write DOT file", "error", err)
-				} else {
-					logger.Info("exported graph", "nodes", len(nodes), "file", cfg.OutputFile)
-					g.ClearChanges()
-					if metrics != nil {
-						metrics.GraphExports.Add(ctx, 1)
-						metrics.NodesDiscovered.Add(ctx, int64(len(nodes)))
-					}
-				}
-			}
-		case <-expireTicker.C:
-			removed := g.RemoveExpired(cfg.NodeTimeout)
-			if removed > 0 {
-				logger.Info("removed expired nodes", "count", removed)
-				if metrics != nil {
-					metrics.NodesExpired.Add(ctx, int64(removed))
-				}
-			}
+// serverOptions returns the HTTP server options shared by agent and collector mode
+func serverOptions(cfg *config.Config, templates []*export.Template, logger *slog.Logger) ([]server.Option, error) {
+	opts := []server.Option{server.WithTemplates(templates...)}
+	if cfg.TLS.Enabled() {
+		opts = append(opts, server.WithTLS(server.TLSConfig{
+			CertFile:     cfg.TLS.CertFile,
+			KeyFile:      cfg.TLS.KeyFile,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+			MinVersion:   cfg.TLS.MinTLSVersion(),
+		}))
+	}
+	if cfg.ControlSocket != "" {
+		opts = append(opts, server.WithControlSocket(cfg.ControlSocket))
+	}
+	if cfg.Auth.TokensFile != "" {
+		tokens, err := server.LoadTokens(cfg.Auth.TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokens: %w", err)
 		}
+		if !cfg.TLS.Enabled() {
+			logger.Warn("API tokens are sent in clear text without TLS")
+		}
+		logger.Info("API authorization enabled", "tokens", len(tokens))
+		opts = append(opts, server.WithTokens(tokens))
+	}
+	return opts, nil
+}
+
+// readTokenFile returns the bearer token stored in path
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
 	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
 }
 
 func setupLogger(level string) *slog.Logger {
+	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: parseLogLevel(level),
+	})
+
+	return slog.New(handler)
+}
+
+// parseLogLevel maps a log level name to its slog level, defaulting to info
+func parseLogLevel(level string) slog.Level {
 	var logLevel slog.Level
 	switch level {
 	case "debug":
@@ -373,50 +791,53 @@ func setupLogger(level string) *slog.Logger {
 	default:
 		logLevel = slog.LevelInfo
 	}
-
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	})
-
-	return slog.New(handler)
+	return logLevel
 }
 
-func listRDMADevices() {
-	devices, err := discovery.GetRDMADevices()
+func listRDMADevices(w io.Writer, asJSON bool) error {
+	devices, err := discovery.GetRDMADeviceInfo()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+
+	if asJSON {
+		return printJSON(w, devices)
 	}
 
 	if len(devices) == 0 {
-		fmt.Println("No RDMA devices found")
-		fmt.Println("\nNote: RDMA devices can be:")
-		fmt.Println("  - Hardware: InfiniBand, RoCE adapters")
-		fmt.Println("  - Software: RXE (RDMA over Converged Ethernet)")
-		fmt.Println("\nTo create a software RXE device:")
-		fmt.Println("  sudo rdma link add rxe0 type rxe netdev eth0")
-		return
+		fmt.Fprintln(w, "No RDMA devices found")
+		fmt.Fprintln(w, "\nNote: RDMA devices can be:")
+		fmt.Fprintln(w, "  - Hardware: InfiniBand, RoCE adapters")
+		fmt.Fprintln(w, "  - Software: RXE (RDMA over Converged Ethernet)")
+		fmt.Fprintln(w, "\nTo create a software RXE device:")
+		fmt.Fprintln(w, "  sudo rdma link add rxe0 type rxe netdev eth0")
+		return nil
 	}
 
-	fmt.Printf("Found %d RDMA device(s):\n\n", len(devices))
+	fmt.Fprintf(w, "Found %d RDMA device(s):\n\n", len(devices))
 
-	for rdmaDevice, parentIfaces := range devices {
-		fmt.Printf("📡 %s\n", rdmaDevice)
+	// Link-local addresses of the parent interfaces
+	linkLocal := make(map[string]string)
+	if ifaces, err := discovery.GetActiveInterfaces(); err == nil {
+		for _, info := range ifaces {
+			linkLocal[info.Name] = info.LinkLocal
+		}
+	}
 
-		// Read additional info from sysfs
-		nodeGUID := readSysfs(fmt.Sprintf("/sys/class/infiniband/%s/node_guid", rdmaDevice))
-		sysImageGUID := readSysfs(fmt.Sprintf("/sys/class/infiniband/%s/sys_image_guid", rdmaDevice))
-		nodeType := readSysfs(fmt.Sprintf("/sys/class/infiniband/%s/node_type", rdmaDevice))
+	parents := make(map[string][]string, len(devices))
+	for _, device := range devices {
+		parents[device.Name] = device.Parents
+		fmt.Fprintf(w, "📡 %s\n", device.Name)
 
-		if nodeGUID != "" {
-			fmt.Printf("   Node GUID:      %s\n", nodeGUID)
+		if device.NodeGUID != "" {
+			fmt.Fprintf(w, "   Node GUID:      %s\n", device.NodeGUID)
 		}
-		if sysImageGUID != "" {
-			fmt.Printf("   Sys Image GUID: %s\n", sysImageGUID)
+		if device.SysImageGUID != "" {
+			fmt.Fprintf(w, "   Sys Image GUID: %s\n", device.SysImageGUID)
 		}
-		if nodeType != "" {
+		if device.NodeType != "" {
 			// node_type file format is like "1: CA" or just "1"
-			parts := strings.Split(nodeType, ":")
+			parts := strings.Split(device.NodeType, ":")
 			typeNum := strings.TrimSpace(parts[0])
 
 			typeDesc := ""
@@ -434,36 +855,53 @@ func listRDMADevices() {
 					typeDesc = " (Router)"
 				}
 			}
-			fmt.Printf("   Node Type:      %s%s\n", typeNum, typeDesc)
+			fmt.Fprintf(w, "   Node Type:      %s%s\n", typeNum, typeDesc)
 		}
 
-		fmt.Printf("   Parent interfaces:\n")
-		for _, iface := range parentIfaces {
-			fmt.Printf("      - %s\n", iface)
+		fmt.Fprintf(w, "   Parent interfaces:\n")
+		for _, iface := range device.Parents {
+			fmt.Fprintf(w, "      - %s\n", iface)
+			if addr, ok := linkLocal[iface]; ok {
+				fmt.Fprintf(w, "        IPv6 link-local: %s\n", addr)
+			}
+		}
 
-			// Try to get the interface's IP addresses
-			if ifaceObj, err := discovery.GetActiveInterfaces(); err == nil {
-				for _, info := range ifaceObj {
-					if info.Name == iface {
-						fmt.Printf("        IPv6 link-local: %s\n", info.LinkLocal)
-						break
-					}
+		for _, port := range device.Ports {
+			fmt.Fprintf(w, "   Port %d:         %s\n", port.Port, formatRDMAPort(port))
+			if port.LinkLayer == "InfiniBand" {
+				fmt.Fprintf(w, "      LID %s, SM LID %s\n", orDash(port.LID), orDash(port.SMLID))
+			}
+			for _, gid := range port.GIDs {
+				fmt.Fprintf(w, "      GID %-3d %s  %s", gid.Index, gid.GID, gid.Type)
+				if gid.Netdev != "" {
+					fmt.Fprintf(w, "  %s", gid.Netdev)
 				}
+				fmt.Fprintln(w)
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
-	fmt.Printf("Total: %d RDMA device(s) on %d network interface(s)\n",
-		len(devices), countUniqueInterfaces(devices))
+	fmt.Fprintf(w, "Total: %d RDMA device(s) on %d network interface(s)\n",
+		len(devices), countUniqueInterfaces(parents))
+	return nil
 }
 
-func readSysfs(path string) string {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return ""
+// formatRDMAPort summarizes state, link layer, rate and MTU of an RDMA port
+func formatRDMAPort(port graph.RDMAPort) string {
+	parts := []string{orDash(port.State)}
+	if port.PhysState != "" {
+		parts[0] += " (" + port.PhysState + ")"
+	}
+	for _, s := range []string{port.LinkLayer, port.Rate} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if port.ActiveMTU > 0 {
+		parts = append(parts, fmt.Sprintf("MTU %d", port.ActiveMTU))
 	}
-	return strings.TrimSpace(string(data))
+	return strings.Join(parts, ", ")
 }
 
 func countUniqueInterfaces(devices map[string][]string) int {
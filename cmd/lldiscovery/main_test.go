@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/discovery"
+	"github.com/kad/lldiscovery/internal/export"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func TestPacketHandlerCountsFlapsOncePerPacket(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+		"eth1": {IPAddress: "fe80::2"},
+	})
+	handle := newPacketHandler(context.Background(), g, false, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	// Both local NICs are on the sender's segment and receive every packet
+	for seq := uint64(1); seq <= 2; seq++ {
+		p := &discovery.Packet{
+			MachineID: "peer-id",
+			Hostname:  "peer-host",
+			Interface: "eth0",
+			Sequence:  seq,
+			Health:    &graph.Health{Status: graph.HealthDegraded, CarrierChanges: 2},
+		}
+		handle(p, "fe80::11", "eth0")
+		handle(p, "fe80::11", "eth1")
+	}
+
+	flap := g.GetInterfaceFlap("peer-id", "eth0")
+	if flap == nil || flap.Flaps != 2 {
+		t.Fatalf("expected one flap per packet, got %+v", flap)
+	}
+	if flap.Dampened {
+		t.Error("expected two flaps not to dampen the link")
+	}
+}
+
+func TestExportChangesWritesChangedOutputsOnly(t *testing.T) {
+	dir := t.TempDir()
+	all := &export.Output{Format: export.FormatDOT, Path: filepath.Join(dir, "all.dot")}
+	local := &export.Output{Format: export.FormatDOT, Path: filepath.Join(dir, "local.dot"), Filter: graph.Filter{Hosts: []string{"local-host"}}}
+	late := &export.Output{Format: export.FormatDOT, Path: filepath.Join(dir, "late", "all.dot")}
+	states := []*outputState{{output: all}, {output: local}, {output: late}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// A file in place of its directory makes the last output fail
+	if err := os.WriteFile(filepath.Dir(late.Path), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	exportChanges(context.Background(), g, states, logger, nil)
+	for _, path := range []string{all.Path, local.Path} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to be written: %v", path, err)
+		}
+	}
+	if g.HasChanges() || !states[2].pending || states[0].pending {
+		t.Fatal("expected only the failed output to stay pending")
+	}
+
+	// Only the failed output is retried
+	if err := os.Remove(all.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Dir(late.Path)); err != nil {
+		t.Fatal(err)
+	}
+	exportChanges(context.Background(), g, states, logger, nil)
+	if _, err := os.Stat(late.Path); err != nil {
+		t.Errorf("expected the failed output to be retried: %v", err)
+	}
+	if _, err := os.Stat(all.Path); err == nil {
+		t.Error("expected a written output not to be written again")
+	}
+
+	// A change hidden by the filter of an output does not rewrite it
+	if err := os.Remove(local.Path); err != nil {
+		t.Fatal(err)
+	}
+	g.AddOrUpdate("peer-id", "peer-host", "eth0", "fe80::2", "eth0", "", "", "", 0, nil, true, "")
+	exportChanges(context.Background(), g, states, logger, nil)
+	if _, err := os.Stat(all.Path); err != nil {
+		t.Errorf("expected the change to be exported: %v", err)
+	}
+	if _, err := os.Stat(local.Path); err == nil {
+		t.Error("expected an output whose content did not change not to be written")
+	}
+}
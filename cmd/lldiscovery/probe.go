@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/config"
+	"github.com/kad/lldiscovery/internal/discovery"
+)
+
+// probeFailedCode is the exit code of a probe that ran but did not see the
+// expected neighbors, as opposed to 1 for a probe that could not run
+const probeFailedCode = 2
+
+// stringList is a flag that may be repeated and accepts comma-separated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// probeReport is the result of a probe; with -json it is printed as is
+type probeReport struct {
+	Hostname   string           `json:"hostname"`
+	Interfaces []probeInterface `json:"interfaces"`
+	Neighbors  int              `json:"neighbors"`         // Distinct neighbor nodes on all interfaces
+	Missing    []string         `json:"missing,omitempty"` // Expected peers that were not seen
+	OK         bool             `json:"ok"`
+}
+
+// probeInterface lists the neighbors seen on one local interface
+type probeInterface struct {
+	Name      string     `json:"name"`
+	Neighbors []api.Edge `json:"neighbors"`
+}
+
+// newProbeReport collects the direct neighbors of the local node per interface
+// and checks them against the expected peers (hostnames or machine IDs) and the
+// minimum number of distinct neighbors
+func newProbeReport(doc *api.Graph, expect []string, minNeighbors int) (*probeReport, error) {
+	local, err := localNode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &probeReport{Hostname: local.Hostname, Interfaces: []probeInterface{}}
+	index := make(map[string]int)
+	for _, iface := range local.Interfaces {
+		index[iface.Name] = len(report.Interfaces)
+		report.Interfaces = append(report.Interfaces, probeInterface{Name: iface.Name, Neighbors: []api.Edge{}})
+	}
+
+	names := hostnames(doc)
+	neighbors := make(map[string]bool) // Machine IDs and hostnames seen
+	for _, e := range neighborEdges(doc) {
+		i, ok := index[e.Source.Interface]
+		if !ok {
+			// Interface went away during the probe; keep what it saw
+			i = len(report.Interfaces)
+			index[e.Source.Interface] = i
+			report.Interfaces = append(report.Interfaces, probeInterface{Name: e.Source.Interface})
+		}
+		report.Interfaces[i].Neighbors = append(report.Interfaces[i].Neighbors, e)
+		if !neighbors[e.Target.NodeID] {
+			report.Neighbors++
+		}
+		neighbors[e.Target.NodeID] = true
+		neighbors[names[e.Target.NodeID]] = true
+	}
+
+	for _, peer := range expect {
+		if !neighbors[peer] {
+			report.Missing = append(report.Missing, peer)
+		}
+	}
+	report.OK = len(report.Missing) == 0 && report.Neighbors >= minNeighbors
+	return report, nil
+}
+
+// failure describes why a report is not OK
+func (r *probeReport) failure(minNeighbors int) error {
+	var reasons []string
+	if len(r.Missing) > 0 {
+		reasons = append(reasons, "missing expected peers: "+strings.Join(r.Missing, ", "))
+	}
+	if r.Neighbors < minNeighbors {
+		reasons = append(reasons, fmt.Sprintf("saw %d neighbors, expected at least %d", r.Neighbors, minNeighbors))
+	}
+	return errors.New(strings.Join(reasons, "; "))
+}
+
+func printProbe(w io.Writer, doc *api.Graph, report *probeReport, asJSON bool) error {
+	if asJSON {
+		return printJSON(w, report)
+	}
+
+	names := hostnames(doc)
+	tw := newTable(w)
+	fmt.Fprintln(tw, "LOCAL IFACE\tNEIGHBOR\tREMOTE IFACE\tADDRESS\tSPEED\tRDMA")
+	for _, iface := range report.Interfaces {
+		if len(iface.Neighbors) == 0 {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t-\n", iface.Name)
+			continue
+		}
+		for _, e := range iface.Neighbors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				iface.Name,
+				names[e.Target.NodeID],
+				e.Target.Interface,
+				e.Target.Address,
+				formatSpeed(e.Target.SpeedMbps),
+				formatRDMA(e.Source, e.Target))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	status := "OK"
+	if !report.OK {
+		status = "FAILED"
+	}
+	_, err := fmt.Fprintf(w, "\n%s: %d neighbors on %d interfaces\n", status, report.Neighbors, len(report.Interfaces))
+	return err
+}
+
+func runProbe(args []string) error {
+	fs := newCommandFlags("probe")
+	configPath := fs.String("config", "", "configuration file providing multicast address, port and labels")
+	wait := fs.Duration("wait", 10*time.Second, "how long to listen for neighbors; peers announce every send_interval")
+	interval := fs.Duration("interval", 2*time.Second, "interval between discovery packets sent while probing")
+	minNeighbors := fs.Int("min-neighbors", 0, "fail unless at least this many distinct neighbors are seen")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	logLevel := fs.String("log-level", "warn", "log level (debug, info, warn, error), logged to stderr")
+	var expect stringList
+	fs.Var(&expect, "expect", "fail unless this hostname or machine ID is seen (repeatable, comma-separated)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *wait <= 0 || *interval <= 0 {
+		return fmt.Errorf("-wait and -interval must be positive")
+	}
+	if *minNeighbors < 0 {
+		return fmt.Errorf("-min-neighbors must not be negative")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: parseLogLevel(*logLevel)}))
+
+	discovery.SetHostRoot(cfg.HostRoot)
+	g := newLocalGraph(cfg, logger)
+	receiver, err := discovery.NewReceiver(cfg.MulticastAddr, cfg.MulticastPort, logger, newPacketHandler(context.Background(), g, false, logger, nil), nil, nil)
+	if err != nil {
+		return err
+	}
+	sender := discovery.NewSender(cfg.MulticastAddr, cfg.MulticastPort, *interval, logger, nil, nil, false, nil)
+	sender.SetLabels(cfg.Labels)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *wait)
+	defer cancel()
+
+	// The receiver returns early only if it cannot listen, e.g. when the
+	// daemon already holds the port
+	recvErr := make(chan error, 1)
+	go func() { recvErr <- receiver.Run(ctx) }()
+	go sender.Run(ctx)
+
+	select {
+	case err := <-recvErr:
+		if ctx.Err() == nil {
+			return fmt.Errorf("receiver: %w", err)
+		}
+	case <-ctx.Done():
+	}
+
+	doc := api.FromGraph(g.GetNodes(), g.GetEdges(), nil)
+	report, err := newProbeReport(doc, expect, *minNeighbors)
+	if err != nil {
+		return err
+	}
+	if err := printProbe(os.Stdout, doc, report, *asJSON); err != nil {
+		return err
+	}
+	if !report.OK {
+		return &exitError{code: probeFailedCode, err: report.failure(*minNeighbors)}
+	}
+	return nil
+}
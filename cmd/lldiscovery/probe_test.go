@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewProbeReport(t *testing.T) {
+	report, err := newProbeReport(testDocument(), []string{"host-b", "a"}, 3)
+	if err != nil {
+		t.Fatalf("newProbeReport failed: %v", err)
+	}
+	if !report.OK || len(report.Missing) != 0 {
+		t.Errorf("expected OK report, got %+v", report)
+	}
+	// host-a is seen on eth0 and ib0 but counts once
+	if report.Neighbors != 3 {
+		t.Errorf("expected 3 distinct neighbors, got %d", report.Neighbors)
+	}
+	if len(report.Interfaces) != 2 || len(report.Interfaces[0].Neighbors) != 3 || len(report.Interfaces[1].Neighbors) != 1 {
+		t.Errorf("unexpected interfaces: %+v", report.Interfaces)
+	}
+
+	report, err = newProbeReport(testDocument(), []string{"host-a", "host-x"}, 4)
+	if err != nil {
+		t.Fatalf("newProbeReport failed: %v", err)
+	}
+	if report.OK {
+		t.Error("expected failed report")
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "host-x" {
+		t.Errorf("expected host-x missing, got %v", report.Missing)
+	}
+	msg := report.failure(4).Error()
+	if !strings.Contains(msg, "host-x") || !strings.Contains(msg, "saw 3 neighbors, expected at least 4") {
+		t.Errorf("unexpected failure message %q", msg)
+	}
+
+	doc := testDocument()
+	doc.LocalNodeID = ""
+	if _, err := newProbeReport(doc, nil, 0); err == nil {
+		t.Error("expected error without local node")
+	}
+}
+
+func TestPrintProbe(t *testing.T) {
+	doc := testDocument()
+	report, err := newProbeReport(doc, []string{"host-x"}, 0)
+	if err != nil {
+		t.Fatalf("newProbeReport failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := printProbe(&buf, doc, report, false); err != nil {
+		t.Fatalf("printProbe failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"LOCAL IFACE", "host-c", "mlx5_0<->mlx5_1", "FAILED: 3 neighbors on 2 interfaces"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := printProbe(&buf, doc, report, true); err != nil {
+		t.Fatalf("printProbe failed: %v", err)
+	}
+	var decoded probeReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.OK || decoded.Hostname != "host-local" || len(decoded.Interfaces) != 2 || decoded.Missing[0] != "host-x" {
+		t.Errorf("unexpected JSON report: %+v", decoded)
+	}
+}
+
+func TestStringList(t *testing.T) {
+	var l stringList
+	l.Set("host-a, host-b")
+	l.Set("host-c")
+	if l.String() != "host-a,host-b,host-c" {
+		t.Errorf("unexpected list %q", l.String())
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/export"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// loadSnapshot reads a saved v1 graph document from a file, or from stdin
+// if path is "-"
+func loadSnapshot(path string) (*api.Graph, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var doc api.Graph
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if doc.Version != api.Version {
+		return nil, fmt.Errorf("%s: unsupported snapshot version %q", path, doc.Version)
+	}
+	return &doc, nil
+}
+
+// snapshotTopology reconstructs the graph of a snapshot. Segments saved in the
+// snapshot are used as is; otherwise they are recomputed from the local node's
+// point of view, which is not possible for collector snapshots.
+func snapshotTopology(doc *api.Graph) (map[string]*graph.Node, map[string]map[string][]*graph.Edge, []graph.NetworkSegment) {
+	nodes, edges := api.ToGraph(doc)
+	if len(doc.Segments) > 0 {
+		return nodes, edges, api.ToSegments(doc, edges)
+	}
+	return nodes, edges, graph.NewFromSnapshot(nodes, edges).GetNetworkSegments()
+}
+
+func runRender(args []string) error {
+	fs := newCommandFlags("render")
+	format := fs.String("format", export.FormatDOT, "output format: dot, svg, nwdiag, json or template")
+	templatePath := fs.String("template", "", "Go text/template file to render (implies -format template)")
+	segments := fs.Bool("segments", false, "include network segments in dot, svg and json output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one snapshot file")
+	}
+
+	output := &export.Output{Format: *format, Segments: *segments}
+	if *templatePath != "" {
+		tmpl, err := export.LoadTemplate("render", *templatePath)
+		if err != nil {
+			return err
+		}
+		output.Format = export.FormatTemplate
+		output.Template = tmpl
+	} else if output.Format == export.FormatTemplate {
+		return fmt.Errorf("-format template requires -template")
+	}
+
+	doc, err := loadSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	nodes, edges, segs := snapshotTopology(doc)
+
+	content, err := output.Generate(nodes, edges, segs)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(os.Stdout, content)
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/api"
+)
+
+func writeSnapshot(t *testing.T, doc *api.Graph) string {
+	t.Helper()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "graph.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	return path
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	doc, err := loadSnapshot(writeSnapshot(t, testDocument()))
+	if err != nil {
+		t.Fatalf("loadSnapshot failed: %v", err)
+	}
+	if len(doc.Nodes) != 4 || doc.LocalNodeID != "local" {
+		t.Errorf("unexpected snapshot: %d nodes, local %q", len(doc.Nodes), doc.LocalNodeID)
+	}
+
+	old := testDocument()
+	old.Version = ""
+	if _, err := loadSnapshot(writeSnapshot(t, old)); err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+		t.Errorf("expected version error, got %v", err)
+	}
+	if _, err := loadSnapshot(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestSnapshotTopology(t *testing.T) {
+	doc := testDocument()
+	nodes, edges, segments := snapshotTopology(doc)
+	if len(nodes) != 4 || len(edges["local"]) != 3 {
+		t.Errorf("unexpected topology: %d nodes, %d neighbors", len(nodes), len(edges["local"]))
+	}
+	if len(segments) != 1 || segments[0].ID != doc.Segments[0].ID {
+		t.Fatalf("expected the saved segment, got %+v", segments)
+	}
+
+	// Snapshots saved without segments get them recomputed
+	doc.Segments = []api.Segment{}
+	_, _, segments = snapshotTopology(doc)
+	if len(segments) != 1 || segments[0].StableID() != testDocument().Segments[0].ID {
+		t.Errorf("expected the segment to be recomputed, got %+v", segments)
+	}
+}
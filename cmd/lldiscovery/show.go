@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+)
+
+// Output of the client subcommands. Tables are meant for people; use -json for
+// scripts, which prints the relevant objects of the v1 API document.
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+// hostnames maps node IDs to hostnames
+func hostnames(doc *api.Graph) map[string]string {
+	names := make(map[string]string, len(doc.Nodes))
+	for _, n := range doc.Nodes {
+		names[n.ID] = n.Hostname
+	}
+	return names
+}
+
+// localNode returns the daemon's own node
+func localNode(doc *api.Graph) (*api.Node, error) {
+	for i := range doc.Nodes {
+		if doc.Nodes[i].ID == doc.LocalNodeID {
+			return &doc.Nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("daemon reported no local node (collector mode?)")
+}
+
+// formatSpeed renders Mbps as 100M, 10G, 2.5G; unknown speeds as "-"
+func formatSpeed(mbps int) string {
+	switch {
+	case mbps <= 0:
+		return "-"
+	case mbps < 1000:
+		return fmt.Sprintf("%dM", mbps)
+	case mbps%1000 == 0:
+		return fmt.Sprintf("%dG", mbps/1000)
+	default:
+		return fmt.Sprintf("%.1fG", float64(mbps)/1000)
+	}
+}
+
+// formatRDMA renders the RDMA devices of a link, "-" if neither side has one
+func formatRDMA(local, remote api.Endpoint) string {
+	l, r := rdmaNames(local.RDMADevice, local.RDMADevices), rdmaNames(remote.RDMADevice, remote.RDMADevices)
+	if l == "" && r == "" {
+		return "-"
+	}
+	return orDash(l) + "<->" + orDash(r)
+}
+
+// rdmaNames joins the RDMA devices of an interface, falling back to the single
+// device reported by older peers
+func rdmaNames(primary string, devices []api.RDMADevice) string {
+	if len(devices) == 0 {
+		return primary
+	}
+	names := make([]string, len(devices))
+	for i, d := range devices {
+		names[i] = d.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return now.Sub(t).Truncate(time.Second).String()
+}
+
+// neighborEdges returns the direct links of the local node, sorted by local
+// interface and neighbor hostname
+func neighborEdges(doc *api.Graph) []api.Edge {
+	names := hostnames(doc)
+	var edges []api.Edge
+	for _, e := range doc.Edges {
+		if e.Direct && e.Source.NodeID == doc.LocalNodeID {
+			edges = append(edges, e)
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Source.Interface != edges[j].Source.Interface {
+			return edges[i].Source.Interface < edges[j].Source.Interface
+		}
+		return names[edges[i].Target.NodeID] < names[edges[j].Target.NodeID]
+	})
+	return edges
+}
+
+func printNeighbors(w io.Writer, doc *api.Graph, asJSON bool) error {
+	if _, err := localNode(doc); err != nil {
+		return err
+	}
+	edges := neighborEdges(doc)
+	if asJSON {
+		if edges == nil {
+			edges = []api.Edge{}
+		}
+		return printJSON(w, edges)
+	}
+
+	names := hostnames(doc)
+	lastSeen := make(map[string]time.Time, len(doc.Nodes))
+	for _, n := range doc.Nodes {
+		lastSeen[n.ID] = n.LastSeen
+	}
+	now := time.Now()
+
+	tw := newTable(w)
+	fmt.Fprintln(tw, "LOCAL IFACE\tNEIGHBOR\tREMOTE IFACE\tADDRESS\tSPEED\tRDMA\tLAST SEEN")
+	for _, e := range edges {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Source.Interface,
+			names[e.Target.NodeID],
+			e.Target.Interface,
+			e.Target.Address,
+			formatSpeed(e.Target.SpeedMbps),
+			formatRDMA(e.Source, e.Target),
+			formatAge(lastSeen[e.Target.NodeID], now))
+	}
+	return tw.Flush()
+}
+
+func printInterfaces(w io.Writer, doc *api.Graph, asJSON bool) error {
+	local, err := localNode(doc)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(w, local.Interfaces)
+	}
+
+	neighbors := make(map[string]int)
+	for _, e := range neighborEdges(doc) {
+		neighbors[e.Source.Interface]++
+	}
+
+	tw := newTable(w)
+	fmt.Fprintln(tw, "INTERFACE\tADDRESS\tPREFIXES\tSPEED\tRDMA\tNODE GUID\tNEIGHBORS")
+	for _, iface := range local.Interfaces {
+		prefixes := strings.Join(iface.Prefixes, ",")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			iface.Name,
+			iface.IPAddress,
+			orDash(prefixes),
+			formatSpeed(iface.SpeedMbps),
+			orDash(rdmaNames(iface.RDMADevice, iface.RDMADevices)),
+			orDash(iface.NodeGUID),
+			neighbors[iface.Name])
+	}
+	return tw.Flush()
+}
+
+func printSegments(w io.Writer, doc *api.Graph, asJSON bool) error {
+	if asJSON {
+		return printJSON(w, doc.Segments)
+	}
+
+	names := hostnames(doc)
+	tw := newTable(w)
+	fmt.Fprintln(tw, "SEGMENT\tINTERFACE\tPREFIXES\tPKEY\tVLAN\tNODES\tMEMBERS")
+	for _, seg := range doc.Segments {
+		members := make([]string, 0, len(seg.Members))
+		for _, m := range seg.Members {
+			members = append(members, orDash(names[m.NodeID]))
+		}
+		sort.Strings(members)
+		vlan := ""
+		if seg.VLANID != 0 {
+			vlan = fmt.Sprint(seg.VLANID)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			seg.ID,
+			seg.Interface,
+			orDash(strings.Join(seg.Prefixes, ",")),
+			orDash(seg.PKey),
+			orDash(vlan),
+			len(seg.Members),
+			strings.Join(members, ","))
+	}
+	return tw.Flush()
+}
+
+// nodeDetails is the -json output of the node subcommand
+type nodeDetails struct {
+	Node  api.Node   `json:"node"`
+	Edges []api.Edge `json:"edges"` // Links with the node at either end
+}
+
+// findNode looks a node up by machine ID, then by hostname
+func findNode(doc *api.Graph, host string) (*api.Node, error) {
+	for i := range doc.Nodes {
+		if doc.Nodes[i].ID == host {
+			return &doc.Nodes[i], nil
+		}
+	}
+	var found *api.Node
+	for i := range doc.Nodes {
+		if doc.Nodes[i].Hostname == host {
+			if found != nil {
+				return nil, fmt.Errorf("hostname %q is ambiguous, use the machine ID", host)
+			}
+			found = &doc.Nodes[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no node %q", host)
+	}
+	return found, nil
+}
+
+func printNode(w io.Writer, doc *api.Graph, host string, asJSON bool) error {
+	node, err := findNode(doc, host)
+	if err != nil {
+		return err
+	}
+
+	details := nodeDetails{Node: *node, Edges: []api.Edge{}}
+	for _, e := range doc.Edges {
+		if e.Source.NodeID == node.ID || e.Target.NodeID == node.ID {
+			details.Edges = append(details.Edges, e)
+		}
+	}
+	if asJSON {
+		return printJSON(w, details)
+	}
+
+	labels := make([]string, 0, len(node.Labels))
+	for k, v := range node.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	tw := newTable(w)
+	fmt.Fprintf(tw, "Hostname:\t%s\n", node.Hostname)
+	fmt.Fprintf(tw, "Machine ID:\t%s\n", node.ID)
+	fmt.Fprintf(tw, "Local:\t%t\n", node.IsLocal)
+	fmt.Fprintf(tw, "Last seen:\t%s\n", node.LastSeen.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Labels:\t%s\n", orDash(strings.Join(labels, ",")))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nInterfaces:")
+	tw = newTable(w)
+	fmt.Fprintln(tw, "  INTERFACE\tADDRESS\tPREFIXES\tSPEED\tRDMA\tDRIVER")
+	for _, iface := range node.Interfaces {
+		driver := ""
+		if iface.Ethtool != nil {
+			driver = iface.Ethtool.Driver
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+			iface.Name,
+			iface.IPAddress,
+			orDash(strings.Join(iface.Prefixes, ",")),
+			formatSpeed(iface.SpeedMbps),
+			orDash(rdmaNames(iface.RDMADevice, iface.RDMADevices)),
+			orDash(driver))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	names := hostnames(doc)
+	fmt.Fprintln(w, "\nLinks:")
+	tw = newTable(w)
+	fmt.Fprintln(tw, "  INTERFACE\tPEER\tPEER IFACE\tSPEED\tRDMA\tTYPE")
+	for _, e := range details.Edges {
+		near, far := e.Source, e.Target
+		if near.NodeID != node.ID {
+			near, far = far, near
+		}
+		kind := "direct"
+		if !e.Direct {
+			kind = "via " + orDash(names[e.LearnedFrom])
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+			near.Interface,
+			names[far.NodeID],
+			far.Interface,
+			formatSpeed(far.SpeedMbps),
+			formatRDMA(near, far),
+			kind)
+	}
+	return tw.Flush()
+}
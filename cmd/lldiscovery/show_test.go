@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// testDocument is a local node with three neighbors on eth0 (forming a segment)
+// and one RDMA neighbor on ib0
+func testDocument() *api.Graph {
+	g := graph.New()
+	g.SetLocalNode("local", "host-local", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1", GlobalPrefixes: []string{"10.0.0.0/24"}, Speed: 1000},
+		"ib0":  {IPAddress: "fe80::10", RDMADevice: "mlx5_0", NodeGUID: "0x1", Speed: 100000},
+	})
+	g.AddOrUpdate("a", "host-a", "eth0", "fe80::2", "eth0", "", "", "", 1000, []string{"10.0.0.0/24"}, true, "")
+	g.AddOrUpdate("b", "host-b", "eth0", "fe80::3", "eth0", "", "", "", 1000, []string{"10.0.0.0/24"}, true, "")
+	g.AddOrUpdate("c", "host-c", "eth0", "fe80::4", "eth0", "", "", "", 2500, []string{"10.0.0.0/24"}, true, "")
+	g.AddOrUpdate("a", "host-a", "ib0", "fe80::11", "ib0", "mlx5_1", "0x2", "", 100000, nil, true, "")
+	g.SetNodeLabels("a", map[string]string{"rack": "r1"})
+	g.SetInterfaceEthtool("a", "eth0", &graph.Ethtool{Duplex: "full", Driver: "igb"})
+	return api.FromGraph(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+}
+
+func TestPrintNeighbors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printNeighbors(&buf, testDocument(), false); err != nil {
+		t.Fatalf("printNeighbors failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected header and 4 neighbors, got:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "LOCAL IFACE") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if !strings.Contains(lines[3], "host-c") || !strings.Contains(lines[3], "2.5G") {
+		t.Errorf("expected host-c at 2.5G on the third row, got %q", lines[3])
+	}
+	if !strings.Contains(lines[4], "ib0") || !strings.Contains(lines[4], "mlx5_0<->mlx5_1") || !strings.Contains(lines[4], "100G") {
+		t.Errorf("expected RDMA neighbor on ib0, got %q", lines[4])
+	}
+
+	buf.Reset()
+	if err := printNeighbors(&buf, testDocument(), true); err != nil {
+		t.Fatalf("printNeighbors failed: %v", err)
+	}
+	var edges []api.Edge
+	if err := json.Unmarshal(buf.Bytes(), &edges); err != nil || len(edges) != 4 {
+		t.Errorf("expected 4 edges as JSON, got %d (%v)", len(edges), err)
+	}
+
+	// A collector has no local node
+	doc := testDocument()
+	doc.LocalNodeID = ""
+	if err := printNeighbors(&buf, doc, false); err == nil {
+		t.Error("expected error without local node")
+	}
+}
+
+func TestPrintInterfaces(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printInterfaces(&buf, testDocument(), false); err != nil {
+		t.Fatalf("printInterfaces failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"eth0", "10.0.0.0/24", "1G", "ib0", "mlx5_0", "0x1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if !strings.HasSuffix(lines[1], "3") || !strings.HasSuffix(lines[2], "1") {
+		t.Errorf("expected neighbor counts 3 and 1, got:\n%s", out)
+	}
+}
+
+func TestPrintSegments(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printSegments(&buf, testDocument(), false); err != nil {
+		t.Fatalf("printSegments failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and 1 segment, got:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "host-a,host-b,host-c,host-local") {
+		t.Errorf("expected sorted members, got %q", lines[1])
+	}
+}
+
+func TestPrintNode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printNode(&buf, testDocument(), "host-a", false); err != nil {
+		t.Fatalf("printNode failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Hostname:", "host-a", "rack=r1", "Interfaces:", "DRIVER", "igb", "Links:", "host-local"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := printNode(&buf, testDocument(), "a", true); err != nil {
+		t.Fatalf("printNode by machine ID failed: %v", err)
+	}
+	var details nodeDetails
+	if err := json.Unmarshal(buf.Bytes(), &details); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if details.Node.Hostname != "host-a" || len(details.Edges) != 2 {
+		t.Errorf("unexpected details: %+v", details)
+	}
+
+	if err := printNode(&buf, testDocument(), "nope", false); err == nil {
+		t.Error("expected error for unknown node")
+	}
+}
+
+func TestFormatSpeed(t *testing.T) {
+	tests := map[int]string{0: "-", 100: "100M", 1000: "1G", 2500: "2.5G", 100000: "100G"}
+	for mbps, want := range tests {
+		if got := formatSpeed(mbps); got != want {
+			t.Errorf("formatSpeed(%d) = %q, want %q", mbps, got, want)
+		}
+	}
+}
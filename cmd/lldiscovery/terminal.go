@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminal is a terminal switched to raw input for the watch view: key presses
+// are delivered without waiting for Enter and are not echoed. Ctrl-C still
+// raises SIGINT.
+type terminal struct {
+	fd    int
+	saved *unix.Termios
+}
+
+func openTerminal(fd uintptr) (*terminal, error) {
+	saved, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	if err != nil {
+		return nil, fmt.Errorf("watch needs an interactive terminal: %w", err)
+	}
+
+	raw := *saved
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(int(fd), unix.TCSETS, &raw); err != nil {
+		return nil, fmt.Errorf("set terminal mode: %w", err)
+	}
+	return &terminal{fd: int(fd), saved: saved}, nil
+}
+
+// restore returns the terminal to the mode it had before openTerminal
+func (t *terminal) restore() {
+	unix.IoctlSetTermios(t.fd, unix.TCSETS, t.saved)
+}
+
+// size returns the terminal's width and height, 0 if unknown
+func (t *terminal) size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(t.fd, unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
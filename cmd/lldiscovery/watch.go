@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/collector"
+	"github.com/kad/lldiscovery/internal/diff"
+)
+
+// watchHighlight is how long added, changed and removed neighbors stay highlighted
+const watchHighlight = 10 * time.Second
+
+// watchSorts are the sort orders of the watch view, cycled with 's'
+var watchSorts = []string{"iface", "host", "age", "speed"}
+
+// ANSI escape sequences used by the watch view
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiReverse   = "\x1b[7m"
+	ansiClear     = "\x1b[H\x1b[2J"
+	ansiAltScreen = "\x1b[?1049h\x1b[?25l" // Alternate screen, cursor hidden
+	ansiMainScr   = "\x1b[?25h\x1b[?1049l"
+)
+
+var watchColors = map[string]string{
+	diff.Added:   "\x1b[32m",
+	diff.Removed: "\x1b[31m",
+	diff.Changed: "\x1b[33m",
+}
+
+// watchRow is a link from a local interface to a neighbor
+type watchRow struct {
+	id          string // Edge ID
+	localIface  string
+	neighbor    string
+	remoteIface string
+	address     string
+	speed       int
+	rdma        string
+	segment     string // Primary prefix or ID of the segment the link belongs to
+	lastSeen    time.Time
+}
+
+// sameLink reports whether two observations of a link look the same; the
+// last-seen time changes on every packet and is not compared
+func (r watchRow) sameLink(o watchRow) bool {
+	return r.neighbor == o.neighbor && r.address == o.address && r.speed == o.speed &&
+		r.rdma == o.rdma && r.segment == o.segment
+}
+
+// watchRows returns the neighbor links of the daemon's local node
+func watchRows(doc *api.Graph) ([]watchRow, error) {
+	if _, err := localNode(doc); err != nil {
+		return nil, err
+	}
+
+	lastSeen := make(map[string]time.Time, len(doc.Nodes))
+	for _, n := range doc.Nodes {
+		lastSeen[n.ID] = n.LastSeen
+	}
+
+	// Segments by local interface and member
+	segments := make(map[string]string)
+	for _, seg := range doc.Segments {
+		name := seg.ID
+		if len(seg.Prefixes) > 0 {
+			name = seg.Prefixes[0]
+		}
+		for _, m := range seg.Members {
+			segments[seg.Interface+"|"+m.NodeID] = name
+		}
+	}
+
+	names := hostnames(doc)
+	var rows []watchRow
+	for _, e := range neighborEdges(doc) {
+		rows = append(rows, watchRow{
+			id:          e.ID,
+			localIface:  e.Source.Interface,
+			neighbor:    names[e.Target.NodeID],
+			remoteIface: e.Target.Interface,
+			address:     e.Target.Address,
+			speed:       e.Target.SpeedMbps,
+			rdma:        formatRDMA(e.Source, e.Target),
+			segment:     segments[e.Source.Interface+"|"+e.Target.NodeID],
+			lastSeen:    lastSeen[e.Target.NodeID],
+		})
+	}
+	return rows, nil
+}
+
+// watchChange is a highlighted change of a row
+type watchChange struct {
+	kind string
+	at   time.Time
+}
+
+// watchState is the model of the watch view
+type watchState struct {
+	source   string // Daemon being watched
+	hostname string
+	rows     map[string]watchRow
+	gone     map[string]watchRow // Removed rows, shown while highlighted
+	changes  map[string]watchChange
+	updated  time.Time
+	err      error // Last poll error; the last good rows stay visible
+	polled   bool
+
+	sortBy  string
+	filter  string
+	editing bool // Filter is being typed
+}
+
+func newWatchState(source, sortBy, filter string) *watchState {
+	return &watchState{
+		source:  source,
+		rows:    make(map[string]watchRow),
+		gone:    make(map[string]watchRow),
+		changes: make(map[string]watchChange),
+		sortBy:  sortBy,
+		filter:  filter,
+	}
+}
+
+// update applies a poll result. Differences to the previous poll are
+// highlighted; the first poll highlights nothing.
+func (s *watchState) update(doc *api.Graph, err error, now time.Time) {
+	if err == nil {
+		var rows []watchRow
+		if rows, err = watchRows(doc); err == nil {
+			s.apply(rows, now)
+			if local, lerr := localNode(doc); lerr == nil {
+				s.hostname = local.Hostname
+			}
+			s.updated = now
+		}
+	}
+	s.err = err
+}
+
+func (s *watchState) apply(rows []watchRow, now time.Time) {
+	current := make(map[string]watchRow, len(rows))
+	for _, r := range rows {
+		current[r.id] = r
+		if !s.polled {
+			continue
+		}
+		if prev, ok := s.rows[r.id]; !ok {
+			s.changes[r.id] = watchChange{diff.Added, now}
+			delete(s.gone, r.id)
+		} else if !prev.sameLink(r) {
+			s.changes[r.id] = watchChange{diff.Changed, now}
+		}
+	}
+	for id, r := range s.rows {
+		if _, ok := current[id]; !ok && s.polled {
+			s.gone[id] = r
+			s.changes[id] = watchChange{diff.Removed, now}
+		}
+	}
+	s.rows = current
+	s.polled = true
+}
+
+// expire drops highlights older than watchHighlight
+func (s *watchState) expire(now time.Time) {
+	for id, c := range s.changes {
+		if now.Sub(c.at) >= watchHighlight {
+			delete(s.changes, id)
+			delete(s.gone, id)
+		}
+	}
+}
+
+// matches reports whether a row passes the filter: a case-insensitive
+// substring of the neighbor hostname or either interface name
+func (s *watchState) matches(r watchRow) bool {
+	if s.filter == "" {
+		return true
+	}
+	f := strings.ToLower(s.filter)
+	for _, field := range []string{r.neighbor, r.localIface, r.remoteIface} {
+		if strings.Contains(strings.ToLower(field), f) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible returns the filtered rows, including removed rows still highlighted,
+// in the selected order
+func (s *watchState) visible() []watchRow {
+	var rows []watchRow
+	for _, m := range []map[string]watchRow{s.rows, s.gone} {
+		for _, r := range m {
+			if s.matches(r) {
+				rows = append(rows, r)
+			}
+		}
+	}
+
+	less := map[string]func(a, b watchRow) bool{
+		"iface": func(a, b watchRow) bool { return a.localIface < b.localIface },
+		"host":  func(a, b watchRow) bool { return a.neighbor < b.neighbor },
+		"age":   func(a, b watchRow) bool { return a.lastSeen.After(b.lastSeen) },
+		"speed": func(a, b watchRow) bool { return a.speed > b.speed },
+	}[s.sortBy]
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if less != nil {
+			if less(a, b) {
+				return true
+			}
+			if less(b, a) {
+				return false
+			}
+		}
+		return a.id < b.id
+	})
+	return rows
+}
+
+// nextSort cycles through watchSorts
+func (s *watchState) nextSort() {
+	for i, name := range watchSorts {
+		if name == s.sortBy {
+			s.sortBy = watchSorts[(i+1)%len(watchSorts)]
+			return
+		}
+	}
+	s.sortBy = watchSorts[0]
+}
+
+// key handles a key press and reports whether watch should exit
+func (s *watchState) key(b byte) bool {
+	if s.editing {
+		switch b {
+		case '\r', '\n':
+			s.editing = false
+		case 0x1b: // Escape
+			s.editing = false
+			s.filter = ""
+		case 0x7f, 0x08: // Backspace
+			if s.filter != "" {
+				s.filter = s.filter[:len(s.filter)-1]
+			}
+		default:
+			if b >= 0x20 && b < 0x7f {
+				s.filter += string(b)
+			}
+		}
+		return false
+	}
+
+	switch b {
+	case 'q', 'Q':
+		return true
+	case 's':
+		s.nextSort()
+	case '/':
+		s.editing = true
+		s.filter = ""
+	case 'c':
+		s.filter = ""
+	}
+	return false
+}
+
+// render draws the view, at most width columns and height lines.
+// Colors and screen clearing are only written when color is set.
+func (s *watchState) render(w io.Writer, now time.Time, width, height int, color bool) error {
+	var out []string
+
+	title := fmt.Sprintf("lldiscovery watch  %s  %s", orDash(s.hostname), s.source)
+	if !s.updated.IsZero() {
+		title += "  updated " + s.updated.Format("15:04:05")
+	}
+	out = append(out, truncate(title, width))
+	status := ""
+	if s.err != nil {
+		status = "error: " + s.err.Error()
+	}
+	out = append(out, truncate(status, width))
+
+	rows := s.visible()
+	var table bytes.Buffer
+	tw := newTable(&table)
+	fmt.Fprintln(tw, "LOCAL IFACE\tNEIGHBOR\tREMOTE IFACE\tADDRESS\tSPEED\tRDMA\tSEGMENT\tLAST SEEN")
+	for _, r := range rows {
+		age := formatAge(r.lastSeen, now)
+		if _, gone := s.gone[r.id]; gone {
+			age = "gone"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.localIface, r.neighbor, r.remoteIface, r.address,
+			formatSpeed(r.speed), r.rdma, orDash(r.segment), age)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	for i, line := range lines {
+		line = truncate(line, width)
+		switch {
+		case !color:
+		case i == 0:
+			line = ansiBold + line + ansiReset
+		default:
+			if c, ok := s.changes[rows[i-1].id]; ok {
+				line = watchColors[c.kind] + line + ansiReset
+			}
+		}
+		out = append(out, line)
+	}
+
+	// Keep the footer on the last line
+	if height > 0 && len(out) > height-1 {
+		out = out[:height-1]
+	}
+	for height > 0 && len(out) < height-1 {
+		out = append(out, "")
+	}
+
+	footer := fmt.Sprintf("q quit  s sort: %s  / filter  c clear  %d neighbors", s.sortBy, len(rows))
+	if s.editing {
+		footer = "filter: " + s.filter + "_"
+	} else if s.filter != "" {
+		footer += "  filter: " + s.filter
+	}
+	footer = truncate(footer, width)
+	if color {
+		footer = ansiReverse + footer + ansiReset
+	}
+	out = append(out, footer)
+
+	var buf bytes.Buffer
+	if color {
+		buf.WriteString(ansiClear)
+	}
+	for i, line := range out {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(line)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// truncate shortens s to width columns; width <= 0 means no limit
+func truncate(s string, width int) string {
+	if width <= 0 || len(s) <= width {
+		return s
+	}
+	return s[:width]
+}
+
+func runWatch(args []string) error {
+	fs := newCommandFlags("watch")
+	socket := fs.String("socket", defaultControlSocket, "control socket of the local daemon (control_socket)")
+	url := fs.String("url", "", "HTTP API of a remote daemon, e.g. https://node-01:6469 (instead of -socket)")
+	tokenFile := fs.String("token-file", "", "file containing a graph:read API token for -url")
+	caFile := fs.String("ca-file", "", "CA bundle to verify the daemon at -url (default: system roots)")
+	certFile := fs.String("cert-file", "", "client certificate for a daemon at -url that requires one")
+	keyFile := fs.String("key-file", "", "private key for -cert-file")
+	interval := fs.Duration("interval", 2*time.Second, "how often to poll the daemon")
+	sortBy := fs.String("sort", "iface", "initial sort order: iface, host, age or speed")
+	filter := fs.String("filter", "", "initial filter on hostname or interface name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+	valid := false
+	for _, name := range watchSorts {
+		valid = valid || name == *sortBy
+	}
+	if !valid {
+		return fmt.Errorf("unsupported sort order %q", *sortBy)
+	}
+
+	client := newControlClient(*socket)
+	if *url != "" {
+		token := ""
+		if *tokenFile != "" {
+			var err error
+			if token, err = readTokenFile(*tokenFile); err != nil {
+				return err
+			}
+		}
+		tlsConfig, err := collector.NewTLSConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			return err
+		}
+		client = newRemoteClient(*url, token, tlsConfig)
+	}
+
+	term, err := openTerminal(os.Stdin.Fd())
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+	fmt.Fprint(os.Stdout, ansiAltScreen)
+	defer fmt.Fprint(os.Stdout, ansiMainScr)
+
+	state := newWatchState(client.target, *sortBy, *filter)
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil {
+				close(keys)
+				return
+			} else if n == 1 {
+				keys <- buf[0]
+			}
+		}
+	}()
+
+	type pollResult struct {
+		doc *api.Graph
+		err error
+	}
+	results := make(chan pollResult, 1)
+	poll := func() {
+		doc, err := client.graph(true)
+		results <- pollResult{doc, err}
+	}
+	go poll()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGWINCH)
+	defer signal.Stop(signals)
+
+	pollTicker := time.NewTicker(*interval)
+	defer pollTicker.Stop()
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+	polling := true
+
+	for {
+		now := time.Now()
+		state.expire(now)
+		width, height := term.size()
+		if err := state.render(os.Stdout, now, width, height, true); err != nil {
+			return err
+		}
+
+		select {
+		case b, ok := <-keys:
+			if !ok || state.key(b) {
+				return nil
+			}
+		case r := <-results:
+			state.update(r.doc, r.err, time.Now())
+			polling = false
+		case <-pollTicker.C:
+			if !polling {
+				polling = true
+				go poll()
+			}
+		case sig := <-signals:
+			if sig != syscall.SIGWINCH {
+				return nil
+			}
+		case <-redraw.C:
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/diff"
+)
+
+func TestWatchRows(t *testing.T) {
+	rows, err := watchRows(testDocument())
+	if err != nil {
+		t.Fatalf("watchRows failed: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+	for _, r := range rows {
+		switch r.localIface {
+		case "eth0":
+			if r.segment != "10.0.0.0/24" {
+				t.Errorf("expected %s on eth0 in segment 10.0.0.0/24, got %q", r.neighbor, r.segment)
+			}
+		case "ib0":
+			if r.segment != "" || r.rdma != "mlx5_0<->mlx5_1" {
+				t.Errorf("unexpected ib0 row %+v", r)
+			}
+		}
+	}
+}
+
+func TestWatchStateChanges(t *testing.T) {
+	now := time.Now()
+	s := newWatchState("test", "iface", "")
+	s.update(testDocument(), nil, now)
+	if len(s.changes) != 0 || s.hostname != "host-local" {
+		t.Fatalf("first poll must not highlight: %+v", s.changes)
+	}
+
+	// host-c leaves, host-a's eth0 speed changes
+	doc := testDocument()
+	removed := doc.Edges[2].ID // local:eth0--c:eth0
+	doc.Edges = append(doc.Edges[:2], doc.Edges[3:]...)
+	doc.Edges[0].Target.SpeedMbps = 10000
+	s.update(doc, nil, now.Add(time.Second))
+
+	if c := s.changes[doc.Edges[0].ID]; c.kind != diff.Changed {
+		t.Errorf("expected host-a link changed, got %+v", c)
+	}
+	if c := s.changes[removed]; c.kind != diff.Removed {
+		t.Errorf("expected host-c link removed, got %+v", c)
+	}
+	if len(s.visible()) != 4 {
+		t.Errorf("expected the removed link to stay visible while highlighted")
+	}
+
+	// host-c comes back
+	s.update(testDocument(), nil, now.Add(2*time.Second))
+	if c := s.changes[removed]; c.kind != diff.Added || len(s.gone) != 0 {
+		t.Errorf("expected host-c link added, got %+v", c)
+	}
+
+	// A failed poll keeps the rows
+	s.update(nil, errors.New("connection refused"), now.Add(3*time.Second))
+	if s.err == nil || len(s.rows) != 4 {
+		t.Errorf("expected error with rows kept, got %v and %d rows", s.err, len(s.rows))
+	}
+
+	// host-a's speed went back at the last poll, so both highlights are recent
+	s.expire(now.Add(time.Second + watchHighlight))
+	if len(s.changes) != 2 {
+		t.Errorf("expected 2 highlights, got %+v", s.changes)
+	}
+	s.expire(now.Add(2*time.Second + watchHighlight))
+	if len(s.changes) != 0 {
+		t.Errorf("expected all highlights to expire, got %+v", s.changes)
+	}
+}
+
+func TestWatchStateSortAndFilter(t *testing.T) {
+	s := newWatchState("test", "speed", "")
+	s.update(testDocument(), nil, time.Now())
+
+	rows := s.visible()
+	if rows[0].localIface != "ib0" || rows[1].neighbor != "host-c" {
+		t.Errorf("expected fastest links first, got %s/%s", rows[0].neighbor, rows[1].neighbor)
+	}
+
+	s.key('s')
+	if s.sortBy != "iface" {
+		t.Errorf("expected sort to cycle to iface, got %s", s.sortBy)
+	}
+
+	for _, b := range []byte("/HOST-B\r") {
+		s.key(b)
+	}
+	if s.editing || s.filter != "HOST-B" {
+		t.Fatalf("unexpected filter state %q (editing %t)", s.filter, s.editing)
+	}
+	if rows := s.visible(); len(rows) != 1 || rows[0].neighbor != "host-b" {
+		t.Errorf("expected host-b only, got %+v", rows)
+	}
+
+	s.filter = "ib"
+	if rows := s.visible(); len(rows) != 1 || rows[0].localIface != "ib0" {
+		t.Errorf("expected interface filter to match ib0, got %+v", rows)
+	}
+
+	s.key('c')
+	if len(s.visible()) != 4 {
+		t.Error("expected 'c' to clear the filter")
+	}
+	if !s.key('q') {
+		t.Error("expected 'q' to quit")
+	}
+}
+
+func TestWatchRender(t *testing.T) {
+	now := time.Now()
+	s := newWatchState("/run/lldiscovery/lldiscovery.sock", "iface", "")
+	s.update(testDocument(), nil, now)
+	doc := testDocument()
+	doc.Edges[0].Target.SpeedMbps = 10000
+	s.update(doc, nil, now)
+
+	var buf bytes.Buffer
+	if err := s.render(&buf, now, 80, 10, false); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 10 {
+		t.Errorf("expected 10 lines, got %d:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "host-local") || !strings.HasPrefix(lines[2], "LOCAL IFACE") {
+		t.Errorf("unexpected header:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[9], "sort: iface") || !strings.Contains(lines[9], "4 neighbors") {
+		t.Errorf("unexpected footer %q", lines[9])
+	}
+	for _, line := range lines {
+		if len(line) > 80 {
+			t.Errorf("line exceeds width: %q", line)
+		}
+	}
+
+	buf.Reset()
+	if err := s.render(&buf, now, 200, 10, true); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), watchColors[diff.Changed]+"eth0") {
+		t.Errorf("expected the changed row highlighted:\n%q", buf.String())
+	}
+}
@@ -0,0 +1,11 @@
+package api
+
+import (
+	_ "embed"
+)
+
+// OpenAPI is the OpenAPI 3.1 document describing the HTTP API, including the
+// JSON Schema of the v1 graph document
+//
+//go:embed openapi.json
+var OpenAPI []byte
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIMatchesTypes keeps the published schema in sync with the Go types:
+// every JSON field must be documented, and fields without omitempty must be required.
+func TestOpenAPIMatchesTypes(t *testing.T) {
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(OpenAPI, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.1") {
+		t.Errorf("expected OpenAPI 3.1, got %q", doc.OpenAPI)
+	}
+
+	types := []interface{}{Graph{}, Node{}, Interface{}, RDMADevice{}, RDMAPort{}, RDMAGID{}, IPoIB{}, Ethtool{}, Stack{}, SRIOV{}, Health{}, Endpoint{}, Edge{}, Flap{}, Reception{}, Segment{}, SegmentMember{}, Adapter{}, AdapterMember{}, SourceList{}, Source{}}
+	for _, v := range types {
+		typ := reflect.TypeOf(v)
+		schema, ok := doc.Components.Schemas[typ.Name()]
+		if !ok {
+			t.Errorf("schema %s missing", typ.Name())
+			continue
+		}
+
+		required := make(map[string]bool)
+		for _, r := range schema.Required {
+			required[r] = true
+		}
+
+		for i := 0; i < typ.NumField(); i++ {
+			tag := typ.Field(i).Tag.Get("json")
+			name, opts, _ := strings.Cut(tag, ",")
+			if _, ok := schema.Properties[name]; !ok {
+				t.Errorf("%s.%s missing from schema", typ.Name(), name)
+			}
+			if opts != "omitempty" && !required[name] {
+				t.Errorf("%s.%s is always present but not required in schema", typ.Name(), name)
+			}
+			delete(schema.Properties, name)
+		}
+		for name := range schema.Properties {
+			t.Errorf("%s.%s in schema but not in Go type", typ.Name(), name)
+		}
+	}
+}
@@ -0,0 +1,822 @@
+// Package api defines the versioned JSON representation of the topology
+// served by the HTTP API. Types in this package are a public contract:
+// fields may be added, but names and meanings of existing fields do not change
+// within a version.
+package api
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// Version is the schema version reported in Graph.Version
+const Version = "v1"
+
+// Graph is the /graph response document
+type Graph struct {
+	Version     string    `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	LocalNodeID string    `json:"local_node_id,omitempty"`
+	Nodes       []Node    `json:"nodes"`
+	Edges       []Edge    `json:"edges"`
+	Segments    []Segment `json:"segments"` // Empty unless segment detection is enabled
+	Adapters    []Adapter `json:"adapters"` // RDMA adapters backing several interfaces
+}
+
+// Node is a discovered machine
+type Node struct {
+	ID         string            `json:"id"` // Machine ID from /etc/machine-id
+	Hostname   string            `json:"hostname"`
+	IsLocal    bool              `json:"is_local"`
+	LastSeen   time.Time         `json:"last_seen"`
+	Labels     map[string]string `json:"labels,omitempty"` // Operator-assigned node labels
+	Interfaces []Interface       `json:"interfaces"`
+}
+
+// Interface is a network interface of a node
+type Interface struct {
+	Name         string       `json:"name"`
+	IPAddress    string       `json:"ip_address"`
+	Prefixes     []string     `json:"prefixes"` // Global unicast network prefixes
+	RDMADevice   string       `json:"rdma_device,omitempty"`
+	NodeGUID     string       `json:"node_guid,omitempty"`
+	SysImageGUID string       `json:"sys_image_guid,omitempty"`
+	RDMADevices  []RDMADevice `json:"rdma_devices,omitempty"` // All RDMA devices; rdma_device and the GUIDs repeat the first
+	IPoIB        *IPoIB       `json:"ipoib,omitempty"`        // Set for IP-over-InfiniBand interfaces
+	Ethtool      *Ethtool     `json:"ethtool,omitempty"`      // Link settings and driver, if known
+	Stack        *Stack       `json:"stack,omitempty"`        // VLAN, bond or bridge relationships
+	SRIOV        *SRIOV       `json:"sriov,omitempty"`        // SR-IOV role and switchdev port identity
+	Health       *Health      `json:"health,omitempty"`       // Error and drop rates, once sampled twice
+	SpeedMbps    int          `json:"speed_mbps"`             // 0 if unknown
+}
+
+// IPoIB describes the partition and HCA port of an IP-over-InfiniBand interface
+type IPoIB struct {
+	PKey   string `json:"pkey"`             // e.g. "0x8001", full-membership bit set
+	Parent string `json:"parent,omitempty"` // Parent of child interfaces such as ib0.8001
+	Mode   string `json:"mode,omitempty"`   // "datagram" or "connected"
+	Device string `json:"device,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
+// Ethtool holds the link settings and driver of an interface as reported by ethtool
+type Ethtool struct {
+	Duplex          string `json:"duplex,omitempty"`  // "full" or "half"
+	Autoneg         string `json:"autoneg,omitempty"` // "on" or "off"
+	Port            string `json:"port,omitempty"`    // Connector type, e.g. "TP", "FIBRE", "DA"
+	Driver          string `json:"driver,omitempty"`
+	DriverVersion   string `json:"driver_version,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	BusInfo         string `json:"bus_info,omitempty"` // PCI address, e.g. "0000:3b:00.0"
+}
+
+// Stack relates an interface to the other interfaces of its host
+type Stack struct {
+	Kind     string   `json:"kind,omitempty"`      // "vlan", "bond", "bridge" or "" for a member port
+	Parent   string   `json:"parent,omitempty"`    // Lower interface of a VLAN
+	VLANID   int      `json:"vlan_id,omitempty"`   // 802.1Q VLAN ID
+	BondMode string   `json:"bond_mode,omitempty"` // e.g. "802.3ad"
+	Members  []string `json:"members,omitempty"`   // Bond slaves or bridge ports
+	Master   string   `json:"master,omitempty"`    // Bond or bridge the interface is a member of
+}
+
+// SRIOV describes the SR-IOV role and switchdev port identity of an interface
+type SRIOV struct {
+	Role         string `json:"role,omitempty"` // "pf", "vf", "representor" or "" for a plain switchdev port
+	PF           string `json:"pf,omitempty"`   // PF interface of a VF or representor
+	VF           int    `json:"vf,omitempty"`   // VF index of a VF or representor
+	NumVFs       int    `json:"num_vfs,omitempty"`
+	PhysPortID   string `json:"phys_port_id,omitempty"`
+	PhysSwitchID string `json:"phys_switch_id,omitempty"`
+	PhysPortName string `json:"phys_port_name,omitempty"` // e.g. "p0" or "pf0vf3"
+}
+
+// Health holds the error and drop rates of an interface, per second between
+// its last two discovery packets
+type Health struct {
+	Status         string  `json:"status"`              // "ok" or "degraded"
+	RxErrors       float64 `json:"rx_errors,omitempty"` // Includes CRC errors
+	TxErrors       float64 `json:"tx_errors,omitempty"`
+	RxDropped      float64 `json:"rx_dropped,omitempty"`
+	TxDropped      float64 `json:"tx_dropped,omitempty"`
+	RxCRCErrors    float64 `json:"rx_crc_errors,omitempty"`
+	RDMAErrors     float64 `json:"rdma_errors,omitempty"`     // Symbol, receive and link integrity errors of the RDMA ports
+	CarrierChanges int     `json:"carrier_changes,omitempty"` // Link flaps since the previous packet
+}
+
+// RDMADevice is an RDMA device carrying an interface, with the ports of the
+// device that belong to the interface
+type RDMADevice struct {
+	Name         string     `json:"name"`
+	NodeGUID     string     `json:"node_guid,omitempty"`
+	SysImageGUID string     `json:"sys_image_guid,omitempty"`
+	Ports        []RDMAPort `json:"ports,omitempty"`
+}
+
+// RDMAPort is a port of an RDMA device
+type RDMAPort struct {
+	Port      int       `json:"port"`
+	State     string    `json:"state,omitempty"`      // e.g. "ACTIVE"
+	PhysState string    `json:"phys_state,omitempty"` // e.g. "LinkUp"
+	LinkLayer string    `json:"link_layer,omitempty"` // "InfiniBand" or "Ethernet"
+	Rate      string    `json:"rate,omitempty"`
+	ActiveMTU int       `json:"active_mtu,omitempty"` // Bytes, estimated from the netdev MTU
+	LID       string    `json:"lid,omitempty"`
+	SMLID     string    `json:"sm_lid,omitempty"`
+	GIDs      []RDMAGID `json:"gids,omitempty"`
+}
+
+// RDMAGID is a populated GID table entry of an RDMA port
+type RDMAGID struct {
+	Index  int    `json:"index"`
+	GID    string `json:"gid"`
+	Type   string `json:"type,omitempty"`   // "IB/RoCE v1" or "RoCE v2"
+	Netdev string `json:"netdev,omitempty"` // Network device of RoCE GIDs
+}
+
+// Endpoint is one side of an edge
+type Endpoint struct {
+	NodeID       string       `json:"node_id"`
+	Interface    string       `json:"interface"`
+	Address      string       `json:"address"`
+	Prefixes     []string     `json:"prefixes"`
+	RDMADevice   string       `json:"rdma_device,omitempty"`
+	NodeGUID     string       `json:"node_guid,omitempty"`
+	SysImageGUID string       `json:"sys_image_guid,omitempty"`
+	RDMADevices  []RDMADevice `json:"rdma_devices,omitempty"`
+	SpeedMbps    int          `json:"speed_mbps"`
+	VF           string       `json:"vf,omitempty"` // VFs folded into the interface, a PF, with ?collapse_vfs
+}
+
+// Edge is a link between two interfaces
+type Edge struct {
+	ID          string     `json:"id"`
+	Source      Endpoint   `json:"source"`
+	Target      Endpoint   `json:"target"`
+	Direct      bool       `json:"direct"`
+	LearnedFrom string     `json:"learned_from,omitempty"` // Node ID that reported an indirect edge
+	Flap        *Flap      `json:"flap,omitempty"`         // Set once either end lost its carrier
+	Reception   *Reception `json:"reception,omitempty"`    // Set on direct edges of the reporting node
+}
+
+// Flap is the carrier flap history of the less stable end of a link
+type Flap struct {
+	Flaps    int       `json:"flaps"`
+	Penalty  float64   `json:"penalty"`  // Decays by half every half-life
+	Dampened bool      `json:"dampened"` // Changes of the link do not trigger exports
+	LastFlap time.Time `json:"last_flap"`
+}
+
+// Reception is how reliably the target's discovery packets arrive at the
+// source, from their sequence numbers
+type Reception struct {
+	Received uint64  `json:"received"`
+	Expected uint64  `json:"expected"`
+	Gaps     uint64  `json:"gaps"`      // Runs of missing packets
+	Loss     float64 `json:"loss"`      // Fraction of expected packets missing
+	JitterMs float64 `json:"jitter_ms"` // Smoothed deviation of arrivals from the send interval
+}
+
+// Segment is a shared network (switch/VLAN) detected from connectivity
+type Segment struct {
+	ID        string          `json:"id"`
+	Interface string          `json:"interface"`
+	Prefixes  []string        `json:"prefixes"`          // First entry is the primary prefix
+	PKey      string          `json:"pkey,omitempty"`    // InfiniBand partition of IPoIB segments
+	VLANID    int             `json:"vlan_id,omitempty"` // 802.1Q VLAN of the segment
+	Members   []SegmentMember `json:"members"`
+}
+
+// SegmentMember is a node attached to a segment
+type SegmentMember struct {
+	NodeID    string `json:"node_id"`
+	Interface string `json:"interface,omitempty"`
+	EdgeID    string `json:"edge_id,omitempty"` // Edge connecting the member to the segment, if known
+}
+
+// Adapter is a physical RDMA adapter backing several interfaces, possibly on
+// different nodes, identified by the system image GUID of its devices
+type Adapter struct {
+	SysImageGUID string          `json:"sys_image_guid"`
+	MultiHost    bool            `json:"multi_host"` // Members on more than one node
+	Members      []AdapterMember `json:"members"`
+}
+
+// AdapterMember is an interface backed by a device of an adapter
+type AdapterMember struct {
+	NodeID     string `json:"node_id"`
+	Interface  string `json:"interface"`
+	RDMADevice string `json:"rdma_device"`
+	NodeGUID   string `json:"node_guid,omitempty"`
+}
+
+// SourceList is the /api/v1/sources response of a collector
+type SourceList struct {
+	Version string   `json:"version"`
+	Sources []Source `json:"sources"`
+}
+
+// Source modes reported in Source.Mode
+const (
+	ModePush = "push" // The agent pushes snapshots to the collector
+	ModePull = "pull" // The collector fetches the agent's /graph
+)
+
+// Source is an agent feeding a collector, either by pushing snapshots or by
+// being pulled
+type Source struct {
+	Mode       string     `json:"mode"`                  // "push" or "pull"
+	Address    string     `json:"address"`               // Pull URL or address of the pushing agent
+	NodeID     string     `json:"node_id,omitempty"`     // Agent machine ID, empty until the first update
+	Hostname   string     `json:"hostname,omitempty"`    // Agent hostname, empty until the first update
+	LastUpdate *time.Time `json:"last_update,omitempty"` // Last successful update
+	Nodes      int        `json:"nodes"`                 // Nodes in the last snapshot
+	Edges      int        `json:"edges"`                 // Edges in the last snapshot
+	Error      string     `json:"error,omitempty"`       // Last pull error, cleared on success
+}
+
+// EdgeID builds the stable identifier of an edge
+func EdgeID(sourceID, sourceIface, targetID, targetIface string) string {
+	return sourceID + ":" + sourceIface + "--" + targetID + ":" + targetIface
+}
+
+// FromGraph converts graph snapshots into the v1 document.
+// Output is sorted so identical topologies produce identical documents.
+func FromGraph(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment) *Graph {
+	doc := &Graph{
+		Version:     Version,
+		GeneratedAt: timestamp(time.Now()),
+		Nodes:       []Node{},
+		Edges:       []Edge{},
+		Segments:    []Segment{},
+		Adapters:    []Adapter{},
+	}
+
+	nodeIDs := make([]string, 0, len(nodes))
+	for id := range nodes {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+
+	for _, id := range nodeIDs {
+		node := nodes[id]
+		if node.IsLocal {
+			doc.LocalNodeID = id
+		}
+
+		n := Node{
+			ID:         id,
+			Hostname:   node.Hostname,
+			IsLocal:    node.IsLocal,
+			LastSeen:   timestamp(node.LastSeen),
+			Labels:     node.Labels,
+			Interfaces: []Interface{},
+		}
+
+		ifaceNames := make([]string, 0, len(node.Interfaces))
+		for name := range node.Interfaces {
+			ifaceNames = append(ifaceNames, name)
+		}
+		sort.Strings(ifaceNames)
+
+		for _, name := range ifaceNames {
+			details := node.Interfaces[name]
+			n.Interfaces = append(n.Interfaces, Interface{
+				Name:         name,
+				IPAddress:    details.IPAddress,
+				Prefixes:     nonNil(details.GlobalPrefixes),
+				RDMADevice:   details.RDMADevice,
+				NodeGUID:     details.NodeGUID,
+				SysImageGUID: details.SysImageGUID,
+				RDMADevices:  fromRDMADevices(details.RDMADevices),
+				IPoIB:        fromIPoIB(details.IPoIB),
+				Ethtool:      fromEthtool(details.Ethtool),
+				Stack:        fromStack(details.Stack),
+				SRIOV:        fromSRIOV(details.SRIOV),
+				Health:       fromHealth(details.Health),
+				SpeedMbps:    details.Speed,
+			})
+		}
+		doc.Nodes = append(doc.Nodes, n)
+	}
+
+	for srcID, dests := range edges {
+		for dstID, edgeList := range dests {
+			for _, edge := range edgeList {
+				doc.Edges = append(doc.Edges, Edge{
+					ID: EdgeID(srcID, edge.LocalInterface, dstID, edge.RemoteInterface),
+					Source: Endpoint{
+						NodeID:       srcID,
+						Interface:    edge.LocalInterface,
+						Address:      edge.LocalAddress,
+						Prefixes:     nonNil(edge.LocalPrefixes),
+						RDMADevice:   edge.LocalRDMADevice,
+						NodeGUID:     edge.LocalNodeGUID,
+						SysImageGUID: edge.LocalSysImageGUID,
+						RDMADevices:  fromRDMADevices(edge.LocalRDMADevices),
+						SpeedMbps:    edge.LocalSpeed,
+						VF:           edge.LocalVF,
+					},
+					Target: Endpoint{
+						NodeID:       dstID,
+						Interface:    edge.RemoteInterface,
+						Address:      edge.RemoteAddress,
+						Prefixes:     nonNil(edge.RemotePrefixes),
+						RDMADevice:   edge.RemoteRDMADevice,
+						NodeGUID:     edge.RemoteNodeGUID,
+						SysImageGUID: edge.RemoteSysImageGUID,
+						RDMADevices:  fromRDMADevices(edge.RemoteRDMADevices),
+						SpeedMbps:    edge.RemoteSpeed,
+						VF:           edge.RemoteVF,
+					},
+					Direct:      edge.Direct,
+					LearnedFrom: edge.LearnedFrom,
+					Flap:        fromFlap(edge.Flap),
+					Reception:   fromReception(edge.Reception),
+				})
+			}
+		}
+	}
+	sort.Slice(doc.Edges, func(i, j int) bool {
+		if doc.Edges[i].ID != doc.Edges[j].ID {
+			return doc.Edges[i].ID < doc.Edges[j].ID
+		}
+		return doc.Edges[i].Target.Address < doc.Edges[j].Target.Address
+	})
+
+	sourceIDs := make([]string, 0, len(edges))
+	for id := range edges {
+		sourceIDs = append(sourceIDs, id)
+	}
+	sort.Strings(sourceIDs)
+
+	for _, seg := range segments {
+		s := Segment{
+			ID:        seg.StableID(),
+			Interface: seg.Interface,
+			Prefixes:  segmentPrefixes(seg),
+			PKey:      seg.PKey,
+			VLANID:    seg.VLANID,
+			Members:   []SegmentMember{},
+		}
+		for _, nodeID := range seg.ConnectedNodes {
+			member := SegmentMember{NodeID: nodeID}
+			if edge, ok := seg.EdgeInfo[nodeID]; ok && edge != nil {
+				member.Interface = edge.RemoteInterface
+				member.EdgeID = findEdgeID(edges, sourceIDs, nodeID, edge)
+			} else if node, ok := nodes[nodeID]; ok {
+				if _, has := node.Interfaces[seg.Interface]; has {
+					member.Interface = seg.Interface
+				}
+			}
+			s.Members = append(s.Members, member)
+		}
+		sort.Slice(s.Members, func(i, j int) bool {
+			return s.Members[i].NodeID < s.Members[j].NodeID
+		})
+		doc.Segments = append(doc.Segments, s)
+	}
+	sort.Slice(doc.Segments, func(i, j int) bool {
+		return doc.Segments[i].ID < doc.Segments[j].ID
+	})
+
+	for _, adapter := range graph.GroupAdapters(nodes) {
+		a := Adapter{
+			SysImageGUID: adapter.SysImageGUID,
+			MultiHost:    adapter.MultiHost(),
+			Members:      make([]AdapterMember, 0, len(adapter.Members)),
+		}
+		for _, m := range adapter.Members {
+			a.Members = append(a.Members, AdapterMember{
+				NodeID:     m.MachineID,
+				Interface:  m.Interface,
+				RDMADevice: m.RDMADevice,
+				NodeGUID:   m.NodeGUID,
+			})
+		}
+		doc.Adapters = append(doc.Adapters, a)
+	}
+
+	return doc
+}
+
+// ToGraph converts a v1 document back into graph snapshots, the inverse of
+// FromGraph for nodes and edges. Segments are not converted; they are derived
+// data and can be recomputed from the result.
+func ToGraph(doc *Graph) (map[string]*graph.Node, map[string]map[string][]*graph.Edge) {
+	nodes := make(map[string]*graph.Node, len(doc.Nodes))
+	for _, n := range doc.Nodes {
+		node := &graph.Node{
+			Hostname:   n.Hostname,
+			MachineID:  n.ID,
+			LastSeen:   n.LastSeen,
+			Interfaces: make(map[string]graph.InterfaceDetails, len(n.Interfaces)),
+			IsLocal:    n.IsLocal,
+			Labels:     n.Labels,
+		}
+		for _, iface := range n.Interfaces {
+			node.Interfaces[iface.Name] = graph.InterfaceDetails{
+				IPAddress:      iface.IPAddress,
+				GlobalPrefixes: nilIfEmpty(iface.Prefixes),
+				RDMADevice:     iface.RDMADevice,
+				NodeGUID:       iface.NodeGUID,
+				SysImageGUID:   iface.SysImageGUID,
+				Speed:          iface.SpeedMbps,
+				RDMADevices:    toRDMADevices(iface.RDMADevices),
+				IPoIB:          toIPoIB(iface.IPoIB),
+				Ethtool:        toEthtool(iface.Ethtool),
+				Stack:          toStack(iface.Stack),
+				SRIOV:          toSRIOV(iface.SRIOV),
+				Health:         toHealth(iface.Health),
+			}
+		}
+		nodes[n.ID] = node
+	}
+
+	edges := make(map[string]map[string][]*graph.Edge)
+	for _, e := range doc.Edges {
+		if edges[e.Source.NodeID] == nil {
+			edges[e.Source.NodeID] = make(map[string][]*graph.Edge)
+		}
+		edges[e.Source.NodeID][e.Target.NodeID] = append(edges[e.Source.NodeID][e.Target.NodeID], &graph.Edge{
+			LocalInterface:     e.Source.Interface,
+			LocalAddress:       e.Source.Address,
+			LocalPrefixes:      nilIfEmpty(e.Source.Prefixes),
+			LocalRDMADevice:    e.Source.RDMADevice,
+			LocalNodeGUID:      e.Source.NodeGUID,
+			LocalSysImageGUID:  e.Source.SysImageGUID,
+			LocalSpeed:         e.Source.SpeedMbps,
+			LocalRDMADevices:   toRDMADevices(e.Source.RDMADevices),
+			RemoteInterface:    e.Target.Interface,
+			RemoteAddress:      e.Target.Address,
+			RemotePrefixes:     nilIfEmpty(e.Target.Prefixes),
+			RemoteRDMADevice:   e.Target.RDMADevice,
+			RemoteNodeGUID:     e.Target.NodeGUID,
+			RemoteSysImageGUID: e.Target.SysImageGUID,
+			RemoteSpeed:        e.Target.SpeedMbps,
+			RemoteRDMADevices:  toRDMADevices(e.Target.RDMADevices),
+			Direct:             e.Direct,
+			LearnedFrom:        e.LearnedFrom,
+			LocalVF:            e.Source.VF,
+			RemoteVF:           e.Target.VF,
+			Flap:               toFlap(e.Flap),
+			Reception:          toReception(e.Reception),
+		})
+	}
+
+	return nodes, edges
+}
+
+// ToSegments converts the segments of a v1 document back into graph segments,
+// linking members to the edges returned by ToGraph for the same document.
+// Segment IDs are the stable IDs of the document.
+func ToSegments(doc *Graph, edges map[string]map[string][]*graph.Edge) []graph.NetworkSegment {
+	byID := make(map[string]*graph.Edge)
+	for srcID, dests := range edges {
+		for dstID, edgeList := range dests {
+			for _, edge := range edgeList {
+				byID[EdgeID(srcID, edge.LocalInterface, dstID, edge.RemoteInterface)] = edge
+			}
+		}
+	}
+
+	segments := make([]graph.NetworkSegment, 0, len(doc.Segments))
+	for _, s := range doc.Segments {
+		seg := graph.NetworkSegment{
+			ID:              s.ID,
+			Interface:       s.Interface,
+			NetworkPrefixes: nilIfEmpty(s.Prefixes),
+			EdgeInfo:        make(map[string]*graph.Edge),
+			PKey:            s.PKey,
+			VLANID:          s.VLANID,
+		}
+		if len(s.Prefixes) > 0 {
+			seg.NetworkPrefix = s.Prefixes[0]
+		}
+		for _, m := range s.Members {
+			seg.ConnectedNodes = append(seg.ConnectedNodes, m.NodeID)
+			if edge, ok := byID[m.EdgeID]; ok {
+				seg.EdgeInfo[m.NodeID] = edge
+			}
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// findEdgeID locates the edge towards a segment member that matches the segment's
+// edge info. Sources are scanned in sorted order so the result is deterministic.
+func findEdgeID(edges map[string]map[string][]*graph.Edge, sourceIDs []string, nodeID string, edge *graph.Edge) string {
+	for _, srcID := range sourceIDs {
+		for _, candidate := range edges[srcID][nodeID] {
+			if candidate.LocalInterface == edge.LocalInterface &&
+				candidate.RemoteInterface == edge.RemoteInterface &&
+				candidate.RemoteAddress == edge.RemoteAddress {
+				return EdgeID(srcID, edge.LocalInterface, nodeID, edge.RemoteInterface)
+			}
+		}
+	}
+	return ""
+}
+
+// segmentPrefixes returns all prefixes with the primary prefix first
+func segmentPrefixes(seg graph.NetworkSegment) []string {
+	prefixes := []string{}
+	if seg.NetworkPrefix != "" {
+		prefixes = append(prefixes, seg.NetworkPrefix)
+	}
+	for _, p := range seg.NetworkPrefixes {
+		if p != seg.NetworkPrefix {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// timestamp normalizes times to UTC with second precision so they encode as plain RFC3339
+func timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// fromRDMADevices converts the RDMA devices of an interface or edge end, nil if none
+func fromRDMADevices(devices []graph.RDMADevice) []RDMADevice {
+	if len(devices) == 0 {
+		return nil
+	}
+	result := make([]RDMADevice, len(devices))
+	for i, d := range devices {
+		result[i] = RDMADevice{
+			Name:         d.Name,
+			NodeGUID:     d.NodeGUID,
+			SysImageGUID: d.SysImageGUID,
+			Ports:        fromRDMAPorts(d.Ports),
+		}
+	}
+	return result
+}
+
+func toRDMADevices(devices []RDMADevice) []graph.RDMADevice {
+	if len(devices) == 0 {
+		return nil
+	}
+	result := make([]graph.RDMADevice, len(devices))
+	for i, d := range devices {
+		result[i] = graph.RDMADevice{
+			Name:         d.Name,
+			NodeGUID:     d.NodeGUID,
+			SysImageGUID: d.SysImageGUID,
+			Ports:        toRDMAPorts(d.Ports),
+		}
+	}
+	return result
+}
+
+func fromIPoIB(ipoib *graph.IPoIB) *IPoIB {
+	if ipoib == nil {
+		return nil
+	}
+	return &IPoIB{PKey: ipoib.PKey, Parent: ipoib.Parent, Mode: ipoib.Mode, Device: ipoib.Device, Port: ipoib.Port}
+}
+
+func toIPoIB(ipoib *IPoIB) *graph.IPoIB {
+	if ipoib == nil {
+		return nil
+	}
+	return &graph.IPoIB{PKey: ipoib.PKey, Parent: ipoib.Parent, Mode: ipoib.Mode, Device: ipoib.Device, Port: ipoib.Port}
+}
+
+func fromEthtool(et *graph.Ethtool) *Ethtool {
+	if et == nil {
+		return nil
+	}
+	return &Ethtool{
+		Duplex:          et.Duplex,
+		Autoneg:         et.Autoneg,
+		Port:            et.Port,
+		Driver:          et.Driver,
+		DriverVersion:   et.DriverVersion,
+		FirmwareVersion: et.FirmwareVersion,
+		BusInfo:         et.BusInfo,
+	}
+}
+
+func toEthtool(et *Ethtool) *graph.Ethtool {
+	if et == nil {
+		return nil
+	}
+	return &graph.Ethtool{
+		Duplex:          et.Duplex,
+		Autoneg:         et.Autoneg,
+		Port:            et.Port,
+		Driver:          et.Driver,
+		DriverVersion:   et.DriverVersion,
+		FirmwareVersion: et.FirmwareVersion,
+		BusInfo:         et.BusInfo,
+	}
+}
+
+func fromStack(stack *graph.Stack) *Stack {
+	if stack == nil {
+		return nil
+	}
+	return &Stack{
+		Kind:     stack.Kind,
+		Parent:   stack.Parent,
+		VLANID:   stack.VLANID,
+		BondMode: stack.BondMode,
+		Members:  append([]string(nil), stack.Members...),
+		Master:   stack.Master,
+	}
+}
+
+func toStack(stack *Stack) *graph.Stack {
+	if stack == nil {
+		return nil
+	}
+	return &graph.Stack{
+		Kind:     stack.Kind,
+		Parent:   stack.Parent,
+		VLANID:   stack.VLANID,
+		BondMode: stack.BondMode,
+		Members:  append([]string(nil), stack.Members...),
+		Master:   stack.Master,
+	}
+}
+
+func fromSRIOV(sriov *graph.SRIOV) *SRIOV {
+	if sriov == nil {
+		return nil
+	}
+	return &SRIOV{
+		Role:         sriov.Role,
+		PF:           sriov.PF,
+		VF:           sriov.VF,
+		NumVFs:       sriov.NumVFs,
+		PhysPortID:   sriov.PhysPortID,
+		PhysSwitchID: sriov.PhysSwitchID,
+		PhysPortName: sriov.PhysPortName,
+	}
+}
+
+func toSRIOV(sriov *SRIOV) *graph.SRIOV {
+	if sriov == nil {
+		return nil
+	}
+	return &graph.SRIOV{
+		Role:         sriov.Role,
+		PF:           sriov.PF,
+		VF:           sriov.VF,
+		NumVFs:       sriov.NumVFs,
+		PhysPortID:   sriov.PhysPortID,
+		PhysSwitchID: sriov.PhysSwitchID,
+		PhysPortName: sriov.PhysPortName,
+	}
+}
+
+func fromFlap(flap *graph.Flap) *Flap {
+	if flap == nil {
+		return nil
+	}
+	return &Flap{
+		Flaps:    flap.Flaps,
+		Penalty:  flap.Penalty,
+		Dampened: flap.Dampened,
+		LastFlap: timestamp(flap.LastFlap),
+	}
+}
+
+func toFlap(flap *Flap) *graph.Flap {
+	if flap == nil {
+		return nil
+	}
+	return &graph.Flap{
+		Flaps:    flap.Flaps,
+		Penalty:  flap.Penalty,
+		Dampened: flap.Dampened,
+		LastFlap: flap.LastFlap,
+	}
+}
+
+func fromReception(r *graph.Reception) *Reception {
+	if r == nil {
+		return nil
+	}
+	return &Reception{
+		Received: r.Received,
+		Expected: r.Expected,
+		Gaps:     r.Gaps,
+		Loss:     math.Round(r.Loss()*1e4) / 1e4,
+		JitterMs: r.JitterMs,
+	}
+}
+
+// toReception drops the loss, which the graph derives from the counts
+func toReception(r *Reception) *graph.Reception {
+	if r == nil {
+		return nil
+	}
+	return &graph.Reception{
+		Received: r.Received,
+		Expected: r.Expected,
+		Gaps:     r.Gaps,
+		JitterMs: r.JitterMs,
+	}
+}
+
+func fromHealth(health *graph.Health) *Health {
+	if health == nil {
+		return nil
+	}
+	return &Health{
+		Status:         health.Status,
+		RxErrors:       health.RxErrors,
+		TxErrors:       health.TxErrors,
+		RxDropped:      health.RxDropped,
+		TxDropped:      health.TxDropped,
+		RxCRCErrors:    health.RxCRCErrors,
+		RDMAErrors:     health.RDMAErrors,
+		CarrierChanges: health.CarrierChanges,
+	}
+}
+
+func toHealth(health *Health) *graph.Health {
+	if health == nil {
+		return nil
+	}
+	return &graph.Health{
+		Status:         health.Status,
+		RxErrors:       health.RxErrors,
+		TxErrors:       health.TxErrors,
+		RxDropped:      health.RxDropped,
+		TxDropped:      health.TxDropped,
+		RxCRCErrors:    health.RxCRCErrors,
+		RDMAErrors:     health.RDMAErrors,
+		CarrierChanges: health.CarrierChanges,
+	}
+}
+
+// fromRDMAPorts converts the ports of an RDMA device, nil if none
+func fromRDMAPorts(ports []graph.RDMAPort) []RDMAPort {
+	if len(ports) == 0 {
+		return nil
+	}
+	result := make([]RDMAPort, len(ports))
+	for i, p := range ports {
+		result[i] = RDMAPort{
+			Port:      p.Port,
+			State:     p.State,
+			PhysState: p.PhysState,
+			LinkLayer: p.LinkLayer,
+			Rate:      p.Rate,
+			ActiveMTU: p.ActiveMTU,
+			LID:       p.LID,
+			SMLID:     p.SMLID,
+		}
+		for _, gid := range p.GIDs {
+			result[i].GIDs = append(result[i].GIDs, RDMAGID(gid))
+		}
+	}
+	return result
+}
+
+func toRDMAPorts(ports []RDMAPort) []graph.RDMAPort {
+	if len(ports) == 0 {
+		return nil
+	}
+	result := make([]graph.RDMAPort, len(ports))
+	for i, p := range ports {
+		result[i] = graph.RDMAPort{
+			Port:      p.Port,
+			State:     p.State,
+			PhysState: p.PhysState,
+			LinkLayer: p.LinkLayer,
+			Rate:      p.Rate,
+			ActiveMTU: p.ActiveMTU,
+			LID:       p.LID,
+			SMLID:     p.SMLID,
+		}
+		for _, gid := range p.GIDs {
+			result[i].GIDs = append(result[i].GIDs, graph.RDMAGID(gid))
+		}
+	}
+	return result
+}
+
+// nilIfEmpty is the inverse of nonNil, so round-tripped snapshots match the originals
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// nonNil returns an empty slice instead of nil so lists encode as [] rather than null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func createTestGraph() *graph.Graph {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1", GlobalPrefixes: []string{"192.168.1.0/24"}, Speed: 1000},
+		"ib0":  {IPAddress: "fe80::9", RDMADevice: "mlx5_0", NodeGUID: "0x1", Speed: 100000},
+	})
+	g.AddOrUpdate("node-a", "alpha", "eth0", "fe80::100", "eth0", "", "", "", 1000, []string{"192.168.1.0/24"}, true, "")
+	g.AddOrUpdate("node-b", "bravo", "eth0", "fe80::200", "eth0", "", "", "", 1000, []string{"192.168.1.0/24"}, true, "")
+	g.AddOrUpdate("node-c", "charlie", "ib0", "fe80::300", "ib0", "mlx5_1", "0x2", "0x3", 100000, nil, true, "")
+	g.AddOrUpdateIndirectEdge("node-d", "delta", "eth1", "fe80::400", "", "", "", 10000, nil,
+		"eth1", "fe80::101", "", "", "", 10000, nil, "node-a")
+	return g
+}
+
+func TestFromGraph(t *testing.T) {
+	g := createTestGraph()
+	doc := FromGraph(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+
+	if doc.Version != "v1" {
+		t.Errorf("expected version v1, got %q", doc.Version)
+	}
+	if doc.LocalNodeID != "local-id" {
+		t.Errorf("expected local node ID, got %q", doc.LocalNodeID)
+	}
+
+	// Nodes sorted by ID
+	var ids []string
+	for _, n := range doc.Nodes {
+		ids = append(ids, n.ID)
+	}
+	if got := strings.Join(ids, ","); got != "local-id,node-a,node-b,node-c,node-d" {
+		t.Errorf("unexpected node order: %s", got)
+	}
+
+	local := doc.Nodes[0]
+	if !local.IsLocal || len(local.Interfaces) != 2 || local.Interfaces[1].RDMADevice != "mlx5_0" {
+		t.Errorf("unexpected local node: %+v", local)
+	}
+
+	if len(doc.Edges) != 4 {
+		t.Fatalf("expected 4 edges, got %d", len(doc.Edges))
+	}
+	var rdma, indirect *Edge
+	for i := range doc.Edges {
+		switch doc.Edges[i].Target.NodeID {
+		case "node-c":
+			rdma = &doc.Edges[i]
+		case "node-d":
+			indirect = &doc.Edges[i]
+		}
+	}
+	if rdma == nil || rdma.ID != "local-id:ib0--node-c:ib0" || rdma.Target.RDMADevice != "mlx5_1" || rdma.Target.SysImageGUID != "0x3" {
+		t.Errorf("unexpected RDMA edge: %+v", rdma)
+	}
+	if indirect == nil || indirect.Direct || indirect.LearnedFrom != "node-a" {
+		t.Errorf("unexpected indirect edge: %+v", indirect)
+	}
+
+	if len(doc.Segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(doc.Segments))
+	}
+	seg := doc.Segments[0]
+	if !strings.HasPrefix(seg.ID, "seg-") || seg.Prefixes[0] != "192.168.1.0/24" || len(seg.Members) != 3 {
+		t.Errorf("unexpected segment: %+v", seg)
+	}
+	for _, m := range seg.Members {
+		if m.Interface != "eth0" {
+			t.Errorf("expected member %s on eth0, got %q", m.NodeID, m.Interface)
+		}
+		if m.NodeID != "local-id" && m.EdgeID != EdgeID("local-id", "eth0", m.NodeID, "eth0") {
+			t.Errorf("expected member %s to reference its edge, got %q", m.NodeID, m.EdgeID)
+		}
+	}
+}
+
+func TestFromGraphJSON(t *testing.T) {
+	g := createTestGraph()
+	doc := FromGraph(g.GetNodes(), g.GetEdges(), nil)
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	body := string(data)
+
+	for _, key := range []string{`"version":"v1"`, `"generated_at"`, `"local_node_id"`, `"is_local"`, `"last_seen"`, `"speed_mbps"`, `"segments":[]`, `"adapters":[]`, `"learned_from":"node-a"`} {
+		if !strings.Contains(body, key) {
+			t.Errorf("expected %s in JSON", key)
+		}
+	}
+	for _, key := range []string{`"MachineID"`, `"EdgeInfo"`, `"Hostname"`, `null`} {
+		if strings.Contains(body, key) {
+			t.Errorf("unexpected %s in JSON", key)
+		}
+	}
+
+	// Timestamps are plain RFC3339
+	var decoded struct {
+		GeneratedAt string `json:"generated_at"`
+		Nodes       []struct {
+			LastSeen string `json:"last_seen"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	for _, ts := range []string{decoded.GeneratedAt, decoded.Nodes[0].LastSeen} {
+		if _, err := time.Parse(time.RFC3339, ts); err != nil || strings.Contains(ts, ".") {
+			t.Errorf("expected RFC3339 timestamp, got %q", ts)
+		}
+	}
+}
+
+func TestFromGraphAdapters(t *testing.T) {
+	g := createTestGraph()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"ib0": {IPAddress: "fe80::9", RDMADevice: "mlx5_0", NodeGUID: "0x1", SysImageGUID: "0x3"},
+		"ib1": {IPAddress: "fe80::a", RDMADevice: "mlx5_2", NodeGUID: "0x4", SysImageGUID: "0x3"},
+	})
+	doc := FromGraph(g.GetNodes(), g.GetEdges(), nil)
+
+	// node-c's mlx5_1 reports the same system image GUID: a multi-host adapter
+	if len(doc.Adapters) != 1 {
+		t.Fatalf("expected 1 adapter, got %+v", doc.Adapters)
+	}
+	a := doc.Adapters[0]
+	if a.SysImageGUID != "0x3" || !a.MultiHost || len(a.Members) != 3 {
+		t.Fatalf("unexpected adapter: %+v", a)
+	}
+	if m := a.Members[2]; m.NodeID != "node-c" || m.Interface != "ib0" || m.RDMADevice != "mlx5_1" || m.NodeGUID != "0x2" {
+		t.Errorf("unexpected remote member: %+v", m)
+	}
+}
+
+func TestFromGraphDeterministic(t *testing.T) {
+	g := createTestGraph()
+	nodes, edges, segments := g.GetNodes(), g.GetEdges(), g.GetNetworkSegments()
+
+	first := FromGraph(nodes, edges, segments)
+	second := FromGraph(nodes, edges, segments)
+	second.GeneratedAt = first.GeneratedAt
+
+	a, _ := json.Marshal(first)
+	b, _ := json.Marshal(second)
+	if string(a) != string(b) {
+		t.Error("expected identical documents for identical input")
+	}
+}
+
+func TestToGraph(t *testing.T) {
+	g := createTestGraph()
+	g.SetNodeLabels("node-a", map[string]string{"rack": "r1"})
+	g.SetInterfaceRDMA("node-c", "ib0", []graph.RDMADevice{
+		{Name: "mlx5_1", NodeGUID: "0x2", SysImageGUID: "0x3", Ports: []graph.RDMAPort{{
+			Port: 1, State: "ACTIVE", LinkLayer: "InfiniBand", Rate: "100 Gb/sec (4X EDR)", ActiveMTU: 4096, LID: "0x5",
+			GIDs: []graph.RDMAGID{{Index: 0, GID: "fe80:0000:0000:0000:0002:c903:0001:0002", Type: "IB/RoCE v1"}},
+		}}},
+		{Name: "mlx5_2", NodeGUID: "0x4", SysImageGUID: "0x3"},
+	})
+	g.SetInterfaceIPoIB("node-c", "ib0", &graph.IPoIB{PKey: "0x8001", Mode: "datagram", Device: "mlx5_1", Port: 1})
+	g.SetInterfaceEthtool("node-c", "ib0", &graph.Ethtool{Driver: "mlx5_core", FirmwareVersion: "28.39.1002", BusInfo: "0000:5e:00.0"})
+	g.SetInterfaceStack("node-c", "ib0", &graph.Stack{Kind: graph.StackBond, BondMode: "active-backup", Members: []string{"ib1", "ib2"}})
+	g.SetInterfaceSRIOV("node-c", "ib0", &graph.SRIOV{Role: graph.SRIOVPF, NumVFs: 4, PhysPortID: "0c42a103"})
+	g.SetInterfaceHealth("node-c", "ib0", &graph.Health{Status: graph.HealthDegraded, RxCRCErrors: 1.5, CarrierChanges: 1})
+	doc := FromGraph(g.GetNodes(), g.GetEdges(), nil)
+	for i := range doc.Edges {
+		if doc.Edges[i].Target.NodeID == "node-c" {
+			doc.Edges[i].Target.VF = "ib0v3"
+			doc.Edges[i].Flap = &Flap{Flaps: 3, Penalty: 2800, Dampened: true, LastFlap: doc.GeneratedAt}
+			doc.Edges[i].Reception = &Reception{Received: 8, Expected: 10, Gaps: 1, Loss: 0.2, JitterMs: 12.5}
+		}
+	}
+
+	// Round-trip through JSON, as the collector receives it
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	var decoded Graph
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	nodes, edges := ToGraph(&decoded)
+	if len(nodes) != len(g.GetNodes()) {
+		t.Errorf("expected %d nodes, got %d", len(g.GetNodes()), len(nodes))
+	}
+	if !nodes["local-id"].IsLocal {
+		t.Error("expected local node to stay local")
+	}
+	if nodes["node-a"].Labels["rack"] != "r1" {
+		t.Error("expected labels to round-trip")
+	}
+	if nodes["local-id"].Interfaces["ib0"].RDMADevice != "mlx5_0" {
+		t.Error("expected interface details to round-trip")
+	}
+	if devices := nodes["node-c"].Interfaces["ib0"].RDMADevices; len(devices) != 2 || len(devices[0].Ports) != 1 ||
+		devices[0].Ports[0].LID != "0x5" || len(devices[0].Ports[0].GIDs) != 1 {
+		t.Errorf("expected RDMA devices to round-trip, got %+v", devices)
+	}
+	if ipoib := nodes["node-c"].Interfaces["ib0"].IPoIB; ipoib == nil || ipoib.PKey != "0x8001" || ipoib.Port != 1 {
+		t.Errorf("expected IPoIB attributes to round-trip, got %+v", ipoib)
+	}
+	if et := nodes["node-c"].Interfaces["ib0"].Ethtool; et == nil || et.Driver != "mlx5_core" || et.BusInfo != "0000:5e:00.0" {
+		t.Errorf("expected link settings to round-trip, got %+v", et)
+	}
+	if stack := nodes["node-c"].Interfaces["ib0"].Stack; stack == nil || stack.BondMode != "active-backup" || len(stack.Members) != 2 {
+		t.Errorf("expected stack to round-trip, got %+v", stack)
+	}
+	if e := edges["local-id"]["node-c"]; len(e) != 1 || len(e[0].RemoteRDMADevices) != 2 {
+		t.Errorf("expected edge RDMA devices to round-trip, got %+v", e)
+	}
+	if sriov := nodes["node-c"].Interfaces["ib0"].SRIOV; sriov == nil || sriov.NumVFs != 4 || sriov.PhysPortID != "0c42a103" {
+		t.Errorf("expected SR-IOV attributes to round-trip, got %+v", sriov)
+	}
+	if h := nodes["node-c"].Interfaces["ib0"].Health; h == nil || !h.Degraded() || h.RxCRCErrors != 1.5 || h.CarrierChanges != 1 {
+		t.Errorf("expected health to round-trip, got %+v", h)
+	}
+	if e := edges["local-id"]["node-c"]; len(e) != 1 || e[0].Flap == nil || !e[0].Flap.Dampened || e[0].Flap.Flaps != 3 {
+		t.Errorf("expected the flap history to round-trip, got %+v", e)
+	}
+	if e := edges["local-id"]["node-c"]; len(e) != 1 || e[0].Reception == nil || e[0].Reception.Gaps != 1 || e[0].Reception.Loss() != 0.2 {
+		t.Errorf("expected the reception to round-trip, got %+v", e)
+	}
+	if e := edges["local-id"]["node-c"]; len(e) != 1 || e[0].RemoteVF != "ib0v3" {
+		t.Errorf("expected the folded VF to round-trip, got %+v", e)
+	}
+
+	// The round-tripped graph produces the same document and the same segments
+	again := FromGraph(nodes, edges, nil)
+	again.GeneratedAt = doc.GeneratedAt
+	a, _ := json.Marshal(doc)
+	b, _ := json.Marshal(again)
+	if string(a) != string(b) {
+		t.Errorf("round trip changed the document:\n%s\n%s", a, b)
+	}
+
+	want := g.GetNetworkSegments()
+	got := graph.NewFromSnapshot(nodes, edges).GetNetworkSegments()
+	if len(got) != len(want) {
+		t.Errorf("expected %d segments after round trip, got %d", len(want), len(got))
+	}
+}
+
+func TestToSegments(t *testing.T) {
+	g := createTestGraph()
+	segments := g.GetNetworkSegments()
+	if len(segments) == 0 {
+		t.Fatal("test graph has no segments")
+	}
+	doc := FromGraph(g.GetNodes(), g.GetEdges(), segments)
+
+	nodes, edges := ToGraph(doc)
+	got := ToSegments(doc, edges)
+	if len(got) != len(doc.Segments) {
+		t.Fatalf("expected %d segments, got %d", len(doc.Segments), len(got))
+	}
+
+	// Converting back yields the same segments, including member edges
+	again := FromGraph(nodes, edges, got)
+	a, _ := json.Marshal(doc.Segments)
+	b, _ := json.Marshal(again.Segments)
+	if string(a) != string(b) {
+		t.Errorf("round trip changed the segments:\n%s\n%s", a, b)
+	}
+	for _, seg := range got {
+		if seg.ID != seg.StableID() {
+			t.Errorf("expected stable ID %s, got %s", seg.StableID(), seg.ID)
+		}
+		if len(seg.EdgeInfo) == 0 {
+			t.Errorf("segment %s has no edge info", seg.ID)
+		}
+	}
+}
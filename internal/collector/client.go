@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// MaxSnapshotSize bounds pushed and pulled snapshots
+const MaxSnapshotSize = 32 << 20
+
+// Puller periodically fetches the /graph document of each target agent
+type Puller struct {
+	collector *Collector
+	targets   []string
+	interval  time.Duration
+	token     string
+	client    *http.Client
+	logger    *slog.Logger
+}
+
+// NewPuller creates a puller for the given /graph URLs. Each request times out
+// after the pull interval.
+func NewPuller(c *Collector, targets []string, interval time.Duration, logger *slog.Logger) *Puller {
+	return &Puller{
+		collector: c,
+		targets:   targets,
+		interval:  interval,
+		client:    NewHTTPClient(interval, nil),
+		logger:    logger,
+	}
+}
+
+// SetToken sets the bearer token sent to agents. Must be called before Run.
+func (p *Puller) SetToken(token string) {
+	p.token = token
+}
+
+// SetTLSConfig sets the client TLS configuration for HTTPS agents, see
+// NewTLSConfig. Must be called before Run.
+func (p *Puller) SetTLSConfig(cfg *tls.Config) {
+	p.client = NewHTTPClient(p.interval, cfg)
+}
+
+func (p *Puller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.pullAll(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			p.pullAll(ctx)
+		}
+	}
+}
+
+// pullAll fetches all targets concurrently and waits for them to finish
+func (p *Puller) pullAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, target := range p.targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			if err := p.pull(ctx, target); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				p.logger.Warn("failed to pull agent graph", "url", target, "error", err)
+				p.collector.RecordError(target, err)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+func (p *Puller) pull(ctx context.Context, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var doc api.Graph
+	if err := json.NewDecoder(io.LimitReader(resp.Body, MaxSnapshotSize)).Decode(&doc); err != nil {
+		return fmt.Errorf("invalid graph document: %w", err)
+	}
+	return p.collector.Ingest(api.ModePull, target, &doc)
+}
+
+// Snapshotter provides the local topology to push
+type Snapshotter interface {
+	GetNodes() map[string]*graph.Node
+	GetEdges() map[string]map[string][]*graph.Edge
+}
+
+// Pusher periodically sends the local graph to a collector
+type Pusher struct {
+	url      string
+	interval time.Duration
+	graph    Snapshotter
+	token    string
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+// NewPusher creates a pusher posting to the collector's /api/v1/push URL
+func NewPusher(url string, interval time.Duration, g Snapshotter, logger *slog.Logger) *Pusher {
+	return &Pusher{
+		url:      url,
+		interval: interval,
+		graph:    g,
+		client:   NewHTTPClient(interval, nil),
+		logger:   logger,
+	}
+}
+
+// SetToken sets the bearer token sent to the collector. Must be called before Run.
+func (p *Pusher) SetToken(token string) {
+	p.token = token
+}
+
+// SetTLSConfig sets the client TLS configuration for an HTTPS collector, see
+// NewTLSConfig. Must be called before Run.
+func (p *Pusher) SetTLSConfig(cfg *tls.Config) {
+	p.client = NewHTTPClient(p.interval, cfg)
+}
+
+func (p *Pusher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Push(ctx); err != nil && ctx.Err() == nil {
+			p.logger.Warn("failed to push graph to collector", "url", p.url, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push sends one snapshot. Segments are not included; the collector detects
+// them from the snapshot.
+func (p *Pusher) Push(ctx context.Context) error {
+	data, err := json.Marshal(api.FromGraph(p.graph.GetNodes(), p.graph.GetEdges(), nil))
+	if err != nil {
+		return fmt.Errorf("failed to encode graph: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	p.logger.Debug("pushed graph to collector", "url", p.url, "bytes", len(data))
+	return nil
+}
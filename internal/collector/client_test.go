@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func TestPuller(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pull-secret" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(agentSnapshot("a", "host-a", map[string]string{"b": "host-b"}))
+	}))
+	defer agent.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	c := New(testLogger())
+	p := NewPuller(c, []string{agent.URL + "/graph", broken.URL + "/graph"}, time.Second, testLogger())
+	p.SetToken("pull-secret")
+	p.pullAll(context.Background())
+
+	if len(c.GetNodes()) != 2 {
+		t.Errorf("expected 2 nodes from pulled agent, got %d", len(c.GetNodes()))
+	}
+
+	sources := c.Sources()
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(sources))
+	}
+	for _, s := range sources {
+		switch s.Address {
+		case agent.URL + "/graph":
+			if s.NodeID != "a" || s.Error != "" {
+				t.Errorf("unexpected source for working agent: %+v", s)
+			}
+		case broken.URL + "/graph":
+			if s.Error == "" {
+				t.Error("expected error for broken agent")
+			}
+		default:
+			t.Errorf("unexpected source %q", s.Address)
+		}
+	}
+}
+
+func TestPusher(t *testing.T) {
+	var received api.Graph
+	collectorSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer push-secret" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer collectorSrv.Close()
+
+	g := graph.New()
+	g.SetLocalNode("a", "host-a", map[string]graph.InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("b", "host-b", "eth0", "fe80::2", "eth0", "", "", "", 0, nil, true, "")
+
+	p := NewPusher(collectorSrv.URL, time.Second, g, testLogger())
+	if err := p.Push(context.Background()); err == nil {
+		t.Error("expected error without token")
+	}
+
+	p.SetToken("push-secret")
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if received.LocalNodeID != "a" || len(received.Nodes) != 2 || len(received.Edges) != 1 {
+		t.Errorf("unexpected pushed document: %+v", received)
+	}
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "snapshot has no local node", http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	p = NewPusher(rejecting.URL, time.Second, g, testLogger())
+	if err := p.Push(context.Background()); err == nil {
+		t.Error("expected error when the collector rejects the snapshot")
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	if cfg, err := NewTLSConfig("", "", ""); cfg != nil || err != nil {
+		t.Errorf("expected no TLS configuration without files, got %v, %v", cfg, err)
+	}
+	if _, err := NewTLSConfig("", filepath.Join(dir, "agent.crt"), ""); err == nil {
+		t.Error("expected error for a certificate without key")
+	}
+	if _, err := NewTLSConfig(filepath.Join(dir, "missing.crt"), "", ""); err == nil {
+		t.Error("expected error for a missing CA bundle")
+	}
+	bogus := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(bogus, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTLSConfig(bogus, "", ""); err == nil {
+		t.Error("expected error for a CA bundle without certificates")
+	}
+}
@@ -0,0 +1,289 @@
+// Package collector aggregates the topology reported by many agents into a
+// single fleet-wide graph. Agents either push their /graph document to the
+// collector or are pulled by it; snapshots are merged by machine ID.
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// source is the last snapshot received from one agent
+type source struct {
+	mode     string
+	address  string
+	nodeID   string
+	hostname string
+	updated  time.Time
+	nodes    map[string]*graph.Node
+	edges    map[string]map[string][]*graph.Edge
+	segments []graph.NetworkSegment // Detected from the agent's point of view
+	err      string
+}
+
+// Collector holds per-agent snapshots and the merged view built from them.
+// It provides the same read methods as graph.Graph, so it can be served and
+// exported in place of the local graph.
+type Collector struct {
+	mu       sync.RWMutex
+	sources  map[string]*source // By sourceKey
+	merged   *graph.Graph
+	segments []graph.NetworkSegment
+	changed  bool
+	logger   *slog.Logger
+}
+
+func New(logger *slog.Logger) *Collector {
+	return &Collector{
+		sources: make(map[string]*source),
+		merged:  graph.New(),
+		logger:  logger,
+	}
+}
+
+// sourceKey identifies a source: pushing agents by machine ID, pull targets by URL
+func sourceKey(mode, address, nodeID string) string {
+	if mode == api.ModePush {
+		return api.ModePush + "/" + nodeID
+	}
+	return api.ModePull + "/" + address
+}
+
+// Ingest stores a snapshot reported by an agent and rebuilds the merged view.
+// The document must be a v1 graph that identifies its local node.
+func (c *Collector) Ingest(mode, address string, doc *api.Graph) error {
+	if doc.Version != api.Version {
+		return fmt.Errorf("unsupported snapshot version %q", doc.Version)
+	}
+	if doc.LocalNodeID == "" {
+		return errors.New("snapshot has no local node")
+	}
+
+	nodes, edges := api.ToGraph(doc)
+	local, ok := nodes[doc.LocalNodeID]
+	if !ok || !local.IsLocal {
+		return fmt.Errorf("local node %s missing from snapshot", doc.LocalNodeID)
+	}
+
+	// Segment detection is relative to a local node, so run it from the agent's
+	// point of view before the snapshot is merged with the others
+	segments := graph.NewFromSnapshot(nodes, edges).GetNetworkSegments()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := sourceKey(mode, address, doc.LocalNodeID)
+	if _, exists := c.sources[key]; !exists {
+		c.logger.Info("new collector source", "mode", mode, "address", address, "hostname", local.Hostname)
+	}
+	c.sources[key] = &source{
+		mode:     mode,
+		address:  address,
+		nodeID:   doc.LocalNodeID,
+		hostname: local.Hostname,
+		updated:  time.Now(),
+		nodes:    nodes,
+		edges:    edges,
+		segments: segments,
+	}
+	c.rebuild()
+	return nil
+}
+
+// RecordError notes a failed pull. The last good snapshot of the target is kept
+// until it expires.
+func (c *Collector) RecordError(address string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := sourceKey(api.ModePull, address, "")
+	s, ok := c.sources[key]
+	if !ok {
+		s = &source{mode: api.ModePull, address: address}
+		c.sources[key] = s
+	}
+	s.err = err.Error()
+}
+
+// RemoveExpired drops snapshots not refreshed within timeout and returns how many
+// were removed. Pull targets are kept in the source list with their last error.
+func (c *Collector) RemoveExpired(timeout time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for key, s := range c.sources {
+		if s.nodes == nil || now.Sub(s.updated) <= timeout {
+			continue
+		}
+		c.logger.Info("collector source expired", "mode", s.mode, "address", s.address, "hostname", s.hostname,
+			"last_update", s.updated)
+		if s.mode == api.ModePull {
+			c.sources[key] = &source{mode: api.ModePull, address: s.address, err: s.err}
+		} else {
+			delete(c.sources, key)
+		}
+		removed++
+	}
+	if removed > 0 {
+		c.rebuild()
+	}
+	return removed
+}
+
+// rebuild merges all snapshots into a new graph. Must be called with mu held.
+//
+// Nodes are keyed by machine ID. A node's report about itself wins; otherwise
+// the most recently seen report is used. Edges are keyed by both endpoints and
+// interfaces; direct observations win over edges learned transitively.
+func (c *Collector) rebuild() {
+	keys := make([]string, 0, len(c.sources))
+	for key := range c.sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nodes := make(map[string]*graph.Node)
+	selfReported := make(map[string]bool)
+	edges := make(map[string]map[string][]*graph.Edge)
+	var segments []graph.NetworkSegment
+
+	for _, key := range keys {
+		s := c.sources[key]
+		if s.nodes == nil {
+			continue
+		}
+
+		for id, node := range s.nodes {
+			merged := *node
+			merged.IsLocal = false
+			self := id == s.nodeID
+			if self {
+				// Agents do not refresh LastSeen of their own node
+				merged.LastSeen = s.updated
+			}
+
+			if existing, ok := nodes[id]; ok {
+				// A node's report about itself wins over what its neighbors saw
+				if selfReported[id] && !self {
+					continue
+				}
+				if selfReported[id] == self && !merged.LastSeen.After(existing.LastSeen) {
+					continue
+				}
+			}
+			nodes[id] = &merged
+			if self {
+				selfReported[id] = true
+			}
+		}
+
+		for srcID, dests := range s.edges {
+			for dstID, edgeList := range dests {
+				for _, edge := range edgeList {
+					mergeEdge(edges, srcID, dstID, edge)
+				}
+			}
+		}
+
+		segments = append(segments, s.segments...)
+	}
+
+	c.merged = graph.NewFromSnapshot(nodes, edges)
+	c.segments = graph.MergeSegments(segments)
+	c.changed = true
+}
+
+// mergeEdge adds an edge unless the same link is already known, replacing an
+// indirect edge with a direct one
+func mergeEdge(edges map[string]map[string][]*graph.Edge, srcID, dstID string, edge *graph.Edge) {
+	if edges[srcID] == nil {
+		edges[srcID] = make(map[string][]*graph.Edge)
+	}
+	for i, existing := range edges[srcID][dstID] {
+		if existing.LocalInterface == edge.LocalInterface && existing.RemoteInterface == edge.RemoteInterface {
+			if edge.Direct && !existing.Direct {
+				edges[srcID][dstID][i] = edge
+			}
+			return
+		}
+	}
+	edges[srcID][dstID] = append(edges[srcID][dstID], edge)
+}
+
+func (c *Collector) GetNodes() map[string]*graph.Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.merged.GetNodes()
+}
+
+func (c *Collector) GetEdges() map[string]map[string][]*graph.Edge {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.merged.GetEdges()
+}
+
+// GetNetworkSegments returns the segments detected by every agent, merged
+func (c *Collector) GetNetworkSegments() []graph.NetworkSegment {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]graph.NetworkSegment(nil), c.segments...)
+}
+
+func (c *Collector) HasChanges() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.changed
+}
+
+func (c *Collector) ClearChanges() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.changed = false
+}
+
+// Sources lists all known sources, sorted by mode and address
+func (c *Collector) Sources() []api.Source {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]api.Source, 0, len(c.sources))
+	for _, s := range c.sources {
+		src := api.Source{
+			Mode:     s.mode,
+			Address:  s.address,
+			NodeID:   s.nodeID,
+			Hostname: s.hostname,
+			Nodes:    len(s.nodes),
+			Error:    s.err,
+		}
+		if s.nodes != nil {
+			updated := s.updated.UTC().Truncate(time.Second)
+			src.LastUpdate = &updated
+		}
+		for _, dests := range s.edges {
+			for _, edgeList := range dests {
+				src.Edges += len(edgeList)
+			}
+		}
+		result = append(result, src)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Mode != result[j].Mode {
+			return result[i].Mode < result[j].Mode
+		}
+		if result[i].Address != result[j].Address {
+			return result[i].Address < result[j].Address
+		}
+		return result[i].NodeID < result[j].NodeID
+	})
+	return result
+}
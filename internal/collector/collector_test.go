@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+// agentSnapshot builds the /graph document of an agent that sees the given
+// neighbors on eth0, all on 10.0.0.0/24
+func agentSnapshot(id, hostname string, neighbors map[string]string) *api.Graph {
+	g := graph.New()
+	g.SetLocalNode(id, hostname, map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::" + id, GlobalPrefixes: []string{"10.0.0.0/24"}, Speed: 1000},
+	})
+	for nid, nhost := range neighbors {
+		g.AddOrUpdate(nid, nhost, "eth0", "fe80::"+nid, "eth0", "", "", "", 1000, []string{"10.0.0.0/24"}, true, "")
+	}
+	return api.FromGraph(g.GetNodes(), g.GetEdges(), nil)
+}
+
+func TestCollector_Merge(t *testing.T) {
+	c := New(testLogger())
+
+	// Three hosts on one switch; a and b report, c does not run an agent
+	if err := c.Ingest(api.ModePush, "10.0.0.1", agentSnapshot("a", "host-a", map[string]string{"b": "host-b", "c": "host-c"})); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if err := c.Ingest(api.ModePull, "http://b:6469/graph", agentSnapshot("b", "host-b", map[string]string{"a": "host-a", "c": "host-c"})); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	if !c.HasChanges() {
+		t.Error("expected changes after ingest")
+	}
+
+	nodes := c.GetNodes()
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 merged nodes, got %d", len(nodes))
+	}
+	for id, node := range nodes {
+		if node.IsLocal {
+			t.Errorf("expected no local node in merged view, %s is local", id)
+		}
+	}
+
+	// Self-reports carry all interfaces, including the prefix
+	if prefixes := nodes["a"].Interfaces["eth0"].GlobalPrefixes; len(prefixes) != 1 {
+		t.Errorf("expected self-reported interface details for a, got %v", prefixes)
+	}
+
+	edges := c.GetEdges()
+	count := 0
+	for _, dests := range edges {
+		for _, edgeList := range dests {
+			count += len(edgeList)
+		}
+	}
+	if count != 4 {
+		t.Errorf("expected 4 edges (a->b, a->c, b->a, b->c), got %d", count)
+	}
+
+	// Both agents see the same segment; it appears once with all members
+	segments := c.GetNetworkSegments()
+	if len(segments) != 1 {
+		t.Fatalf("expected 1 merged segment, got %d", len(segments))
+	}
+	if len(segments[0].ConnectedNodes) != 3 {
+		t.Errorf("expected 3 segment members, got %v", segments[0].ConnectedNodes)
+	}
+}
+
+func TestCollector_SelfReportWins(t *testing.T) {
+	c := New(testLogger())
+
+	// b is seen by a under an old hostname, and reports itself with the new one
+	c.Ingest(api.ModePush, "10.0.0.2", agentSnapshot("b", "host-b-new", map[string]string{"a": "host-a"}))
+	c.Ingest(api.ModePush, "10.0.0.1", agentSnapshot("a", "host-a", map[string]string{"b": "host-b-old"}))
+
+	if hostname := c.GetNodes()["b"].Hostname; hostname != "host-b-new" {
+		t.Errorf("expected self-reported hostname, got %q", hostname)
+	}
+}
+
+func TestCollector_DirectEdgeWins(t *testing.T) {
+	edges := make(map[string]map[string][]*graph.Edge)
+	indirect := &graph.Edge{LocalInterface: "eth0", RemoteInterface: "eth0", Direct: false, LearnedFrom: "x"}
+	direct := &graph.Edge{LocalInterface: "eth0", RemoteInterface: "eth0", Direct: true}
+
+	mergeEdge(edges, "a", "b", indirect)
+	mergeEdge(edges, "a", "b", direct)
+	mergeEdge(edges, "a", "b", indirect)
+
+	if len(edges["a"]["b"]) != 1 || !edges["a"]["b"][0].Direct {
+		t.Errorf("expected a single direct edge, got %+v", edges["a"]["b"])
+	}
+}
+
+func TestCollector_InvalidSnapshot(t *testing.T) {
+	c := New(testLogger())
+
+	noLocal := agentSnapshot("a", "host-a", nil)
+	noLocal.LocalNodeID = ""
+	if err := c.Ingest(api.ModePush, "10.0.0.1", noLocal); err == nil {
+		t.Error("expected error for snapshot without local node")
+	}
+
+	wrongVersion := agentSnapshot("a", "host-a", nil)
+	wrongVersion.Version = "v0"
+	if err := c.Ingest(api.ModePush, "10.0.0.1", wrongVersion); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+
+	unknownLocal := agentSnapshot("a", "host-a", nil)
+	unknownLocal.LocalNodeID = "z"
+	if err := c.Ingest(api.ModePush, "10.0.0.1", unknownLocal); err == nil {
+		t.Error("expected error for local node missing from snapshot")
+	}
+
+	if len(c.GetNodes()) != 0 || c.HasChanges() {
+		t.Error("expected rejected snapshots to leave the graph untouched")
+	}
+}
+
+func TestCollector_RemoveExpired(t *testing.T) {
+	c := New(testLogger())
+	c.Ingest(api.ModePush, "10.0.0.1", agentSnapshot("a", "host-a", map[string]string{"b": "host-b"}))
+	c.Ingest(api.ModePull, "http://c:6469/graph", agentSnapshot("c", "host-c", nil))
+	c.ClearChanges()
+
+	if removed := c.RemoveExpired(time.Minute); removed != 0 {
+		t.Errorf("expected no fresh source to expire, got %d", removed)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if removed := c.RemoveExpired(time.Millisecond); removed != 2 {
+		t.Errorf("expected 2 sources to expire, got %d", removed)
+	}
+	if len(c.GetNodes()) != 0 {
+		t.Errorf("expected empty merged view, got %d nodes", len(c.GetNodes()))
+	}
+	if !c.HasChanges() {
+		t.Error("expected changes after expiry")
+	}
+
+	// The pull target stays listed without a snapshot; the pushing agent is forgotten
+	sources := c.Sources()
+	if len(sources) != 1 || sources[0].Mode != api.ModePull || sources[0].LastUpdate != nil {
+		t.Errorf("unexpected sources after expiry: %+v", sources)
+	}
+}
+
+func TestCollector_Sources(t *testing.T) {
+	c := New(testLogger())
+	c.Ingest(api.ModePush, "10.0.0.1", agentSnapshot("a", "host-a", map[string]string{"b": "host-b"}))
+	c.RecordError("http://down:6469/graph", errors.New("connection refused"))
+
+	sources := c.Sources()
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(sources))
+	}
+
+	pull, push := sources[0], sources[1]
+	if pull.Mode != api.ModePull || pull.Error != "connection refused" || pull.NodeID != "" {
+		t.Errorf("unexpected pull source: %+v", pull)
+	}
+	if push.NodeID != "a" || push.Hostname != "host-a" || push.Nodes != 2 || push.Edges != 1 || push.LastUpdate == nil {
+		t.Errorf("unexpected push source: %+v", push)
+	}
+
+	// A successful pull clears the error
+	c.Ingest(api.ModePull, "http://down:6469/graph", agentSnapshot("d", "host-d", nil))
+	if err := c.Sources()[0].Error; err != "" {
+		t.Errorf("expected error to be cleared, got %q", err)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -11,17 +12,146 @@ import (
 )
 
 type Config struct {
-	SendInterval     time.Duration   `json:"send_interval"`
-	NodeTimeout      time.Duration   `json:"node_timeout"`
-	ExportInterval   time.Duration   `json:"export_interval"`
-	MulticastAddr    string          `json:"multicast_address"`
-	MulticastPort    int             `json:"multicast_port"`
-	OutputFile       string          `json:"output_file"`
-	HTTPAddress      string          `json:"http_address"`
-	LogLevel         string          `json:"log_level"`
-	IncludeNeighbors bool            `json:"include_neighbors"`
-	ShowSegments     bool            `json:"show_segments"`
-	Telemetry        TelemetryConfig `json:"telemetry"`
+	SendInterval     time.Duration     `json:"send_interval"`
+	NodeTimeout      time.Duration     `json:"node_timeout"`
+	ExportInterval   time.Duration     `json:"export_interval"`
+	MulticastAddr    string            `json:"multicast_address"`
+	MulticastPort    int               `json:"multicast_port"`
+	OutputFile       string            `json:"output_file"`
+	SVGOutputFile    string            `json:"svg_output_file"` // Optional SVG rendering written alongside the DOT file
+	HTTPAddress      string            `json:"http_address"`
+	ControlSocket    string            `json:"control_socket"` // Unix socket for the CLI, empty disables
+	HostRoot         string            `json:"host_root"`      // Directory holding the host's /sys, /proc and /etc, "/" if empty
+	TLS              TLSConfig         `json:"tls"`            // HTTPS for the HTTP API
+	Auth             AuthConfig        `json:"auth"`           // Bearer tokens for the HTTP API
+	LogLevel         string            `json:"log_level"`
+	IncludeNeighbors bool              `json:"include_neighbors"`
+	ShowSegments     bool              `json:"show_segments"`
+	Labels           map[string]string `json:"labels"`         // Advertised node labels, used by label filters
+	Templates        []TemplateConfig  `json:"templates"`      // User-defined text/template exporters
+	Outputs          []OutputConfig    `json:"outputs"`        // Additional artifacts written by the exporter
+	Collector        CollectorConfig   `json:"collector"`      // Fleet-wide aggregation instead of local discovery
+	Push             PushConfig        `json:"push"`           // Agent side of collector mode
+	Health           HealthConfig      `json:"health"`         // When an interface's error and drop rates mark it degraded
+	FlapDampening    FlapConfig        `json:"flap_dampening"` // Penalties of links that lose their carrier
+	Telemetry        TelemetryConfig   `json:"telemetry"`
+}
+
+// TLSConfig serves the HTTP API over HTTPS when CertFile is set. The certificate,
+// key and client CA bundle are reloaded when they change on disk.
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"` // Require client certificates signed by this CA bundle
+	MinVersion   string `json:"min_version"`    // "1.2" (default) or "1.3"
+}
+
+// ClientTLSConfig configures the client side of HTTPS connections to other
+// lldiscovery instances. Peers are verified against CAFile, or the system
+// trust store if empty; CertFile and KeyFile are presented to peers that
+// require client certificates.
+type ClientTLSConfig struct {
+	CAFile   string `json:"ca_file"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// AuthConfig enables bearer-token authorization of the HTTP API. The tokens
+// file lists named tokens and their scopes (graph:read, events:read, admin).
+type AuthConfig struct {
+	TokensFile string `json:"tokens_file"`
+}
+
+// tlsVersions are the accepted TLSConfig.MinVersion values
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CollectorConfig enables collector mode. A collector does not take part in
+// discovery; it merges the graphs pushed by agents or pulled from them.
+// Sources not refreshed within node_timeout are dropped from the merged view.
+type CollectorConfig struct {
+	Enabled       bool            `json:"enabled"`
+	Pull          []string        `json:"pull"`            // Agent /graph URLs to fetch
+	PullInterval  time.Duration   `json:"pull_interval"`   // Also the per-request timeout
+	PullTokenFile string          `json:"pull_token_file"` // Bearer token sent to agents
+	PullTLS       ClientTLSConfig `json:"pull_tls"`        // CA and client certificate for HTTPS agents
+}
+
+// PushConfig makes an agent push its graph to a collector
+type PushConfig struct {
+	URL       string          `json:"url"` // Collector /api/v1/push URL
+	Interval  time.Duration   `json:"interval"`
+	TokenFile string          `json:"token_file"` // Bearer token with admin scope on the collector
+	TLS       ClientTLSConfig `json:"tls"`        // CA and client certificate for an HTTPS collector
+}
+
+// OutputConfig describes an artifact maintained by the periodic exporter
+type OutputConfig struct {
+	Format   string       `json:"format"`   // dot, svg, nwdiag, json or template
+	Path     string       `json:"path"`     // Output file, replaced atomically
+	Segments *bool        `json:"segments"` // Include network segments; defaults to show_segments
+	Template string       `json:"template"` // Template name when format is "template"
+	Filter   FilterConfig `json:"filter"`
+	Hook     *HookConfig  `json:"hook"` // Optional command run after each write
+}
+
+// FilterConfig selects the subgraph written to an output
+type FilterConfig struct {
+	Hosts       []string `json:"hosts"`      // Hostname glob patterns
+	Labels      []string `json:"labels"`     // "key=value" or "key" selectors
+	Segments    []string `json:"segments"`   // Segment IDs
+	Prefixes    []string `json:"prefixes"`   // CIDR prefixes
+	Interfaces  []string `json:"interfaces"` // Interface name glob patterns
+	RDMAOnly    bool     `json:"rdma_only"`
+	DirectOnly  bool     `json:"direct_only"`
+	Around      string   `json:"around"`       // Hostname or machine ID
+	Hops        int      `json:"hops"`         // Radius around Around, default 1
+	CollapseVFs bool     `json:"collapse_vfs"` // Fold SR-IOV VFs into their PF
+}
+
+// HookConfig is a post-write command, given as an argument list (no shell)
+type HookConfig struct {
+	Command []string      `json:"command"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// outputFormats are the formats accepted in OutputConfig.Format
+var outputFormats = map[string]bool{
+	"dot":      true,
+	"svg":      true,
+	"nwdiag":   true,
+	"json":     true,
+	"template": true,
+}
+
+// HealthConfig holds the rates, per second between two discovery packets, above
+// which an interface is reported as degraded. Errors include RDMA port errors;
+// any carrier change also degrades an interface.
+type HealthConfig struct {
+	ErrorRate float64 `json:"error_rate"`
+	DropRate  float64 `json:"drop_rate"`
+}
+
+// FlapConfig tunes the dampening of flapping links: every carrier loss adds
+// Penalty, the penalty halves every HalfLife, and a link is dampened above
+// Suppress until it decays below Reuse.
+type FlapConfig struct {
+	Penalty  float64       `json:"penalty"`
+	Suppress float64       `json:"suppress"`
+	Reuse    float64       `json:"reuse"`
+	HalfLife time.Duration `json:"half_life"`
+}
+
+// TemplateConfig describes a named user-defined export template.
+// The rendered output is served at /export/{name} and, when Output is set,
+// written periodically by the exporter.
+type TemplateConfig struct {
+	Name     string `json:"name"`
+	Template string `json:"template"` // Path to a Go text/template file
+	Output   string `json:"output"`   // Optional output file path
 }
 
 type TelemetryConfig struct {
@@ -46,6 +176,22 @@ func Default() *Config {
 		LogLevel:         "info",
 		IncludeNeighbors: false,
 		ShowSegments:     false,
+		Collector: CollectorConfig{
+			PullInterval: 30 * time.Second,
+		},
+		Push: PushConfig{
+			Interval: 30 * time.Second,
+		},
+		Health: HealthConfig{
+			ErrorRate: 0,
+			DropRate:  10,
+		},
+		FlapDampening: FlapConfig{
+			Penalty:  1000,
+			Suppress: 2000,
+			Reuse:    750,
+			HalfLife: 15 * time.Minute,
+		},
 		Telemetry: TelemetryConfig{
 			Enabled:       false,
 			Endpoint:      "grpc://localhost:4317",
@@ -93,16 +239,47 @@ func Load(path string) (*Config, error) {
 	}
 
 	var rawConfig struct {
-		SendInterval     string          `json:"send_interval"`
-		NodeTimeout      string          `json:"node_timeout"`
-		ExportInterval   string          `json:"export_interval"`
-		MulticastAddr    string          `json:"multicast_address"`
-		MulticastPort    int             `json:"multicast_port"`
-		OutputFile       string          `json:"output_file"`
-		HTTPAddress      string          `json:"http_address"`
-		LogLevel         string          `json:"log_level"`
-		IncludeNeighbors bool            `json:"include_neighbors"`
-		Telemetry        TelemetryConfig `json:"telemetry"`
+		SendInterval     string            `json:"send_interval"`
+		NodeTimeout      string            `json:"node_timeout"`
+		ExportInterval   string            `json:"export_interval"`
+		MulticastAddr    string            `json:"multicast_address"`
+		MulticastPort    int               `json:"multicast_port"`
+		OutputFile       string            `json:"output_file"`
+		SVGOutputFile    string            `json:"svg_output_file"`
+		HTTPAddress      string            `json:"http_address"`
+		ControlSocket    string            `json:"control_socket"`
+		HostRoot         string            `json:"host_root"`
+		TLS              TLSConfig         `json:"tls"`
+		Auth             AuthConfig        `json:"auth"`
+		LogLevel         string            `json:"log_level"`
+		IncludeNeighbors bool              `json:"include_neighbors"`
+		Labels           map[string]string `json:"labels"`
+		Templates        []TemplateConfig  `json:"templates"`
+		Outputs          []rawOutputConfig `json:"outputs"`
+		Collector        struct {
+			Enabled       bool            `json:"enabled"`
+			Pull          []string        `json:"pull"`
+			PullInterval  string          `json:"pull_interval"`
+			PullTokenFile string          `json:"pull_token_file"`
+			PullTLS       ClientTLSConfig `json:"pull_tls"`
+		} `json:"collector"`
+		Push struct {
+			URL       string          `json:"url"`
+			Interval  string          `json:"interval"`
+			TokenFile string          `json:"token_file"`
+			TLS       ClientTLSConfig `json:"tls"`
+		} `json:"push"`
+		Health struct {
+			ErrorRate *float64 `json:"error_rate"`
+			DropRate  *float64 `json:"drop_rate"`
+		} `json:"health"`
+		FlapDampening struct {
+			Penalty  float64 `json:"penalty"`
+			Suppress float64 `json:"suppress"`
+			Reuse    float64 `json:"reuse"`
+			HalfLife string  `json:"half_life"`
+		} `json:"flap_dampening"`
+		Telemetry TelemetryConfig `json:"telemetry"`
 	}
 
 	if err := json.Unmarshal(data, &rawConfig); err != nil {
@@ -133,18 +310,113 @@ func Load(path string) (*Config, error) {
 	if rawConfig.OutputFile != "" {
 		cfg.OutputFile = rawConfig.OutputFile
 	}
+	if rawConfig.SVGOutputFile != "" {
+		cfg.SVGOutputFile = rawConfig.SVGOutputFile
+	}
 	if rawConfig.HTTPAddress != "" {
 		cfg.HTTPAddress = rawConfig.HTTPAddress
 	}
 	if rawConfig.OutputFile != "" {
 		cfg.OutputFile = rawConfig.OutputFile
 	}
+	cfg.ControlSocket = rawConfig.ControlSocket
+	cfg.HostRoot = rawConfig.HostRoot
+	cfg.TLS = rawConfig.TLS
+	if err := cfg.TLS.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.Auth = rawConfig.Auth
 	if rawConfig.LogLevel != "" {
 		cfg.LogLevel = rawConfig.LogLevel
 	}
 
 	cfg.IncludeNeighbors = rawConfig.IncludeNeighbors
 
+	for key := range rawConfig.Labels {
+		if key == "" || strings.ContainsAny(key, "=,") {
+			return nil, fmt.Errorf("invalid label key %q", key)
+		}
+	}
+	cfg.Labels = rawConfig.Labels
+
+	if len(rawConfig.Templates) > 0 {
+		if err := validateTemplates(rawConfig.Templates); err != nil {
+			return nil, err
+		}
+		cfg.Templates = rawConfig.Templates
+	}
+
+	for _, raw := range rawConfig.Outputs {
+		output := OutputConfig{
+			Format:   raw.Format,
+			Path:     raw.Path,
+			Segments: raw.Segments,
+			Template: raw.Template,
+			Filter:   raw.Filter,
+		}
+		if raw.Hook != nil {
+			output.Hook = &HookConfig{Command: raw.Hook.Command}
+			if raw.Hook.Timeout != "" {
+				if d, err := time.ParseDuration(raw.Hook.Timeout); err == nil {
+					output.Hook.Timeout = d
+				}
+			}
+		}
+		cfg.Outputs = append(cfg.Outputs, output)
+	}
+	if err := validateOutputs(cfg.Outputs, cfg.Templates); err != nil {
+		return nil, err
+	}
+
+	cfg.Collector.Enabled = rawConfig.Collector.Enabled
+	cfg.Collector.Pull = rawConfig.Collector.Pull
+	cfg.Collector.PullTokenFile = rawConfig.Collector.PullTokenFile
+	cfg.Collector.PullTLS = rawConfig.Collector.PullTLS
+	if rawConfig.Collector.PullInterval != "" {
+		if d, err := time.ParseDuration(rawConfig.Collector.PullInterval); err == nil {
+			cfg.Collector.PullInterval = d
+		}
+	}
+	cfg.Push.URL = rawConfig.Push.URL
+	cfg.Push.TokenFile = rawConfig.Push.TokenFile
+	cfg.Push.TLS = rawConfig.Push.TLS
+	if rawConfig.Push.Interval != "" {
+		if d, err := time.ParseDuration(rawConfig.Push.Interval); err == nil {
+			cfg.Push.Interval = d
+		}
+	}
+	if err := cfg.ValidateCollector(); err != nil {
+		return nil, err
+	}
+
+	if rawConfig.Health.ErrorRate != nil {
+		cfg.Health.ErrorRate = *rawConfig.Health.ErrorRate
+	}
+	if rawConfig.Health.DropRate != nil {
+		cfg.Health.DropRate = *rawConfig.Health.DropRate
+	}
+	if cfg.Health.ErrorRate < 0 || cfg.Health.DropRate < 0 {
+		return nil, fmt.Errorf("health rates must not be negative")
+	}
+
+	if rawConfig.FlapDampening.Penalty != 0 {
+		cfg.FlapDampening.Penalty = rawConfig.FlapDampening.Penalty
+	}
+	if rawConfig.FlapDampening.Suppress != 0 {
+		cfg.FlapDampening.Suppress = rawConfig.FlapDampening.Suppress
+	}
+	if rawConfig.FlapDampening.Reuse != 0 {
+		cfg.FlapDampening.Reuse = rawConfig.FlapDampening.Reuse
+	}
+	if rawConfig.FlapDampening.HalfLife != "" {
+		if d, err := time.ParseDuration(rawConfig.FlapDampening.HalfLife); err == nil {
+			cfg.FlapDampening.HalfLife = d
+		}
+	}
+	if err := cfg.FlapDampening.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Merge telemetry config
 	if rawConfig.Telemetry.Endpoint != "" || rawConfig.Telemetry.Enabled {
 		cfg.Telemetry = rawConfig.Telemetry
@@ -158,6 +430,191 @@ func Load(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// Validate checks that the dampening thresholds are ordered and positive
+func (f FlapConfig) Validate() error {
+	if f.Penalty <= 0 || f.Reuse <= 0 || f.HalfLife <= 0 {
+		return fmt.Errorf("flap_dampening: penalty, reuse and half_life must be positive")
+	}
+	if f.Suppress <= f.Reuse {
+		return fmt.Errorf("flap_dampening: suppress (%g) must be above reuse (%g)", f.Suppress, f.Reuse)
+	}
+	return nil
+}
+
+// rawOutputConfig is the on-disk form of OutputConfig with string durations
+type rawOutputConfig struct {
+	Format   string       `json:"format"`
+	Path     string       `json:"path"`
+	Segments *bool        `json:"segments"`
+	Template string       `json:"template"`
+	Filter   FilterConfig `json:"filter"`
+	Hook     *struct {
+		Command []string `json:"command"`
+		Timeout string   `json:"timeout"`
+	} `json:"hook"`
+}
+
+// validateOutputs checks output formats, paths, template references and hooks
+func validateOutputs(outputs []OutputConfig, templates []TemplateConfig) error {
+	for i, o := range outputs {
+		if !outputFormats[o.Format] {
+			return fmt.Errorf("output %d: unsupported format %q (use dot, svg, nwdiag, json or template)", i, o.Format)
+		}
+		if o.Path == "" {
+			return fmt.Errorf("output %d: path is required", i)
+		}
+		if o.Format == "template" {
+			found := false
+			for _, t := range templates {
+				if t.Name == o.Template {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("output %d: unknown template %q", i, o.Template)
+			}
+		}
+		if o.Filter.Hops < 0 {
+			return fmt.Errorf("output %d: filter hops must not be negative", i)
+		}
+		if o.Hook != nil && len(o.Hook.Command) == 0 {
+			return fmt.Errorf("output %d: hook command is required", i)
+		}
+	}
+	return nil
+}
+
+// EffectiveOutputs returns every artifact the exporter maintains: the legacy
+// output_file (DOT) and svg_output_file, template outputs, then the configured
+// outputs. Legacy entries are skipped when an explicit output uses the same path.
+// Segments is always resolved to a non-nil value.
+func (c *Config) EffectiveOutputs() []OutputConfig {
+	explicit := make(map[string]bool)
+	for _, o := range c.Outputs {
+		explicit[o.Path] = true
+	}
+
+	var outputs []OutputConfig
+	addLegacy := func(o OutputConfig) {
+		if o.Path != "" && !explicit[o.Path] {
+			outputs = append(outputs, o)
+		}
+	}
+	addLegacy(OutputConfig{Format: "dot", Path: c.OutputFile})
+	addLegacy(OutputConfig{Format: "svg", Path: c.SVGOutputFile})
+	for _, t := range c.Templates {
+		addLegacy(OutputConfig{Format: "template", Path: t.Output, Template: t.Name})
+	}
+	outputs = append(outputs, c.Outputs...)
+
+	for i := range outputs {
+		if outputs[i].Segments == nil {
+			segments := c.ShowSegments
+			outputs[i].Segments = &segments
+		}
+	}
+	return outputs
+}
+
+// ValidateCollector checks pull and push URLs. Exported so it can be re-run after
+// CLI flags override the config file.
+func (c *Config) ValidateCollector() error {
+	for _, target := range c.Collector.Pull {
+		if err := validateHTTPURL(target); err != nil {
+			return fmt.Errorf("collector pull %q: %w", target, err)
+		}
+	}
+	if err := c.Collector.PullTLS.Validate(); err != nil {
+		return fmt.Errorf("collector pull_tls: %w", err)
+	}
+	if err := c.Push.TLS.Validate(); err != nil {
+		return fmt.Errorf("push tls: %w", err)
+	}
+	if c.Push.URL != "" {
+		if c.Collector.Enabled {
+			return fmt.Errorf("push url cannot be used in collector mode")
+		}
+		if err := validateHTTPURL(c.Push.URL); err != nil {
+			return fmt.Errorf("push url %q: %w", c.Push.URL, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the client certificate and key are given together
+func (t ClientTLSConfig) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+// Enabled reports whether the HTTP API is served over TLS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Validate checks that certificate and key are given together and that the
+// minimum version is supported. Exported so it can be re-run after CLI flags
+// override the config file.
+func (t TLSConfig) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls: cert_file and key_file must be set together")
+	}
+	if t.ClientCAFile != "" && t.CertFile == "" {
+		return fmt.Errorf("tls: client_ca_file requires cert_file and key_file")
+	}
+	if _, ok := tlsVersions[t.MinVersion]; !ok {
+		return fmt.Errorf("tls: unsupported min_version %q (use 1.2 or 1.3)", t.MinVersion)
+	}
+	return nil
+}
+
+// MinTLSVersion returns MinVersion as a crypto/tls version constant
+func (t TLSConfig) MinTLSVersion() uint16 {
+	if v, ok := tlsVersions[t.MinVersion]; ok {
+		return v
+	}
+	return tls.VersionTLS12
+}
+
+// validateHTTPURL checks that s is an absolute http or https URL
+func validateHTTPURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	return nil
+}
+
+// validateTemplates checks that every template has a unique URL-safe name and a source file
+func validateTemplates(templates []TemplateConfig) error {
+	seen := make(map[string]bool)
+	for i, t := range templates {
+		if t.Name == "" {
+			return fmt.Errorf("template %d: name is required", i)
+		}
+		if strings.ContainsAny(t.Name, "/?#% ") {
+			return fmt.Errorf("template %q: name must not contain '/', '?', '#', '%%' or spaces", t.Name)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("template %q: duplicate name", t.Name)
+		}
+		seen[t.Name] = true
+		if t.Template == "" {
+			return fmt.Errorf("template %q: template path is required", t.Name)
+		}
+	}
+	return nil
+}
+
 // ParseEndpoint parses the endpoint URL and extracts protocol and address.
 // Supports formats:
 //   - grpc://host:port (default port 4317)
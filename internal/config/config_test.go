@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"os"
 	"path/filepath"
 	"testing"
@@ -83,6 +84,7 @@ func TestLoad_ValidConfig(t *testing.T) {
 		"multicast_address": "ff02::1",
 		"multicast_port": 8888,
 		"output_file": "/tmp/test.dot",
+		"svg_output_file": "/tmp/test.svg",
 		"http_address": ":9090",
 		"log_level": "debug",
 		"include_neighbors": true,
@@ -129,6 +131,10 @@ func TestLoad_ValidConfig(t *testing.T) {
 		t.Errorf("Expected output_file /tmp/test.dot, got %s", cfg.OutputFile)
 	}
 
+	if cfg.SVGOutputFile != "/tmp/test.svg" {
+		t.Errorf("Expected svg_output_file /tmp/test.svg, got %s", cfg.SVGOutputFile)
+	}
+
 	if cfg.HTTPAddress != ":9090" {
 		t.Errorf("Expected http_address :9090, got %s", cfg.HTTPAddress)
 	}
@@ -276,6 +282,402 @@ func TestLoad_InvalidTelemetryEndpoint(t *testing.T) {
 	}
 }
 
+func TestLoad_Templates(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	configData := `{
+		"templates": [
+			{"name": "ansible", "template": "/etc/lldiscovery/ansible.tmpl", "output": "/tmp/inventory.ini"},
+			{"name": "cables", "template": "/etc/lldiscovery/cables.tmpl"}
+		]
+	}`
+
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.Templates) != 2 {
+		t.Fatalf("Expected 2 templates, got %d", len(cfg.Templates))
+	}
+	if cfg.Templates[0].Name != "ansible" || cfg.Templates[0].Output != "/tmp/inventory.ini" {
+		t.Errorf("Unexpected first template: %+v", cfg.Templates[0])
+	}
+	if cfg.Templates[1].Output != "" {
+		t.Errorf("Expected HTTP-only template to have no output, got %q", cfg.Templates[1].Output)
+	}
+}
+
+func TestLoad_InvalidTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates string
+		wantErr   string
+	}{
+		{"missing name", `[{"template": "a.tmpl"}]`, "name is required"},
+		{"missing path", `[{"name": "a"}]`, "template path is required"},
+		{"duplicate", `[{"name": "a", "template": "a.tmpl"}, {"name": "a", "template": "b.tmpl"}]`, "duplicate name"},
+		{"slash in name", `[{"name": "a/b", "template": "a.tmpl"}]`, "must not contain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			configData := `{"templates": ` + tt.templates + `}`
+			if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			_, err := Load(configPath)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got nil", tt.wantErr)
+			}
+			if !contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Outputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	configData := `{
+		"templates": [{"name": "hosts", "template": "/etc/lldiscovery/hosts.tmpl"}],
+		"outputs": [
+			{"format": "nwdiag", "path": "/tmp/topology.puml"},
+			{"format": "json", "path": "/tmp/topology.json", "segments": false},
+			{
+				"format": "dot",
+				"path": "/tmp/rdma.dot",
+				"filter": {"hosts": ["compute-*"], "rdma_only": true, "labels": ["rack=r1"], "prefixes": ["10.0.0.0/8"], "around": "gw-01", "hops": 2},
+				"hook": {"command": ["dot", "-Tsvg", "-o", "/tmp/rdma.svg", "{path}"], "timeout": "10s"}
+			},
+			{"format": "template", "path": "/tmp/hosts", "template": "hosts", "hook": {"command": ["true"], "timeout": "bogus"}}
+		]
+	}`
+
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.Outputs) != 4 {
+		t.Fatalf("Expected 4 outputs, got %d", len(cfg.Outputs))
+	}
+	if cfg.Outputs[0].Segments != nil {
+		t.Error("Expected unset segments to stay nil")
+	}
+	if cfg.Outputs[1].Segments == nil || *cfg.Outputs[1].Segments {
+		t.Error("Expected segments=false to be preserved")
+	}
+
+	rdma := cfg.Outputs[2]
+	if !rdma.Filter.RDMAOnly || len(rdma.Filter.Hosts) != 1 || rdma.Filter.Hosts[0] != "compute-*" {
+		t.Errorf("Unexpected filter: %+v", rdma.Filter)
+	}
+	if len(rdma.Filter.Labels) != 1 || len(rdma.Filter.Prefixes) != 1 || rdma.Filter.Around != "gw-01" || rdma.Filter.Hops != 2 {
+		t.Errorf("Unexpected subgraph filter: %+v", rdma.Filter)
+	}
+	if rdma.Hook == nil || len(rdma.Hook.Command) != 5 || rdma.Hook.Timeout != 10*time.Second {
+		t.Errorf("Unexpected hook: %+v", rdma.Hook)
+	}
+
+	// Invalid hook timeout falls back to the default (zero)
+	if cfg.Outputs[3].Hook.Timeout != 0 {
+		t.Errorf("Expected zero timeout for invalid duration, got %v", cfg.Outputs[3].Hook.Timeout)
+	}
+}
+
+func TestLoad_InvalidOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs string
+		wantErr string
+	}{
+		{"unknown format", `[{"format": "pdf", "path": "/tmp/x"}]`, "unsupported format"},
+		{"missing path", `[{"format": "dot"}]`, "path is required"},
+		{"unknown template", `[{"format": "template", "path": "/tmp/x", "template": "nope"}]`, "unknown template"},
+		{"empty hook", `[{"format": "dot", "path": "/tmp/x", "hook": {"command": []}}]`, "hook command is required"},
+		{"negative hops", `[{"format": "dot", "path": "/tmp/x", "filter": {"around": "gw-01", "hops": -1}}]`, "hops must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			configData := `{"outputs": ` + tt.outputs + `}`
+			if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			_, err := Load(configPath)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got nil", tt.wantErr)
+			}
+			if !contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Labels(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{"labels": {"rack": "r1", "role": "compute"}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Labels["rack"] != "r1" || cfg.Labels["role"] != "compute" {
+		t.Errorf("Unexpected labels: %v", cfg.Labels)
+	}
+
+	// Keys that cannot be expressed in a label selector are rejected
+	configData = `{"labels": {"a=b": "c"}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, err := Load(configPath); err == nil || !contains(err.Error(), "invalid label key") {
+		t.Errorf("Expected invalid label key error, got %v", err)
+	}
+}
+
+func TestLoad_Health(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{"health": {"error_rate": 0.5}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Health.ErrorRate != 0.5 || cfg.Health.DropRate != 10 {
+		t.Errorf("Unexpected health config: %+v", cfg.Health)
+	}
+
+	configData = `{"health": {"drop_rate": -1}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, err := Load(configPath); err == nil || !contains(err.Error(), "must not be negative") {
+		t.Errorf("Expected negative rate error, got %v", err)
+	}
+}
+
+func TestLoad_FlapDampening(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{"flap_dampening": {"half_life": "5m", "suppress": 3000}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := FlapConfig{Penalty: 1000, Suppress: 3000, Reuse: 750, HalfLife: 5 * time.Minute}
+	if cfg.FlapDampening != want {
+		t.Errorf("Unexpected flap dampening %+v, want %+v", cfg.FlapDampening, want)
+	}
+
+	configData = `{"flap_dampening": {"suppress": 500}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, err := Load(configPath); err == nil || !contains(err.Error(), "must be above reuse") {
+		t.Errorf("Expected threshold order error, got %v", err)
+	}
+}
+
+func TestLoad_Collector(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{
+		"collector": {
+			"enabled": true,
+			"pull": ["http://node1:6469/graph", "https://node2:6469/graph"],
+			"pull_interval": "15s",
+			"pull_token_file": "/etc/lldiscovery/pull.token",
+			"pull_tls": {"ca_file": "/etc/lldiscovery/tls/ca.crt", "cert_file": "/etc/lldiscovery/tls/collector.crt", "key_file": "/etc/lldiscovery/tls/collector.key"}
+		},
+		"auth": {"tokens_file": "/etc/lldiscovery/tokens.json"},
+		"control_socket": "/run/lldiscovery/lldiscovery.sock"
+	}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.Collector.Enabled || len(cfg.Collector.Pull) != 2 || cfg.Collector.PullInterval != 15*time.Second {
+		t.Errorf("Unexpected collector config: %+v", cfg.Collector)
+	}
+	if cfg.ControlSocket != "/run/lldiscovery/lldiscovery.sock" {
+		t.Errorf("Unexpected control socket %q", cfg.ControlSocket)
+	}
+	if cfg.Collector.PullTokenFile != "/etc/lldiscovery/pull.token" || cfg.Auth.TokensFile != "/etc/lldiscovery/tokens.json" {
+		t.Errorf("Unexpected token files: %q, %q", cfg.Collector.PullTokenFile, cfg.Auth.TokensFile)
+	}
+	want := ClientTLSConfig{CAFile: "/etc/lldiscovery/tls/ca.crt", CertFile: "/etc/lldiscovery/tls/collector.crt", KeyFile: "/etc/lldiscovery/tls/collector.key"}
+	if cfg.Collector.PullTLS != want {
+		t.Errorf("Unexpected pull TLS config: %+v", cfg.Collector.PullTLS)
+	}
+	if cfg.Push.Interval != 30*time.Second {
+		t.Errorf("Expected default push interval 30s, got %v", cfg.Push.Interval)
+	}
+}
+
+func TestLoad_InvalidCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"bad pull scheme", `{"collector": {"enabled": true, "pull": ["ftp://node1/graph"]}}`, "scheme must be http or https"},
+		{"pull without host", `{"collector": {"enabled": true, "pull": ["http:///graph"]}}`, "missing host"},
+		{"bad push url", `{"push": {"url": "collector:6469"}}`, "push url"},
+		{"push in collector mode", `{"collector": {"enabled": true}, "push": {"url": "http://c:6469/api/v1/push"}}`, "collector mode"},
+		{"push cert without key", `{"push": {"url": "https://c:6469/api/v1/push", "tls": {"cert_file": "agent.crt"}}}`, "push tls: cert_file and key_file"},
+		{"pull key without cert", `{"collector": {"enabled": true, "pull_tls": {"key_file": "collector.key"}}}`, "collector pull_tls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			_, err := Load(configPath)
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoad_TLS(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{
+		"tls": {
+			"cert_file": "/etc/lldiscovery/tls/server.crt",
+			"key_file": "/etc/lldiscovery/tls/server.key",
+			"client_ca_file": "/etc/lldiscovery/tls/ca.crt",
+			"min_version": "1.3"
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.TLS.Enabled() || cfg.TLS.ClientCAFile != "/etc/lldiscovery/tls/ca.crt" {
+		t.Errorf("Unexpected TLS config: %+v", cfg.TLS)
+	}
+	if cfg.TLS.MinTLSVersion() != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %x", cfg.TLS.MinTLSVersion())
+	}
+
+	if Default().TLS.Enabled() {
+		t.Error("Expected TLS to be disabled by default")
+	}
+	if Default().TLS.MinTLSVersion() != tls.VersionTLS12 {
+		t.Error("Expected TLS 1.2 minimum by default")
+	}
+}
+
+func TestLoad_InvalidTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"cert without key", `{"tls": {"cert_file": "server.crt"}}`, "must be set together"},
+		{"key without cert", `{"tls": {"key_file": "server.key"}}`, "must be set together"},
+		{"client CA without cert", `{"tls": {"client_ca_file": "ca.crt"}}`, "requires cert_file"},
+		{"bad min version", `{"tls": {"cert_file": "server.crt", "key_file": "server.key", "min_version": "1.0"}}`, "unsupported min_version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			_, err := Load(configPath)
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEffectiveOutputs(t *testing.T) {
+	off := false
+	cfg := Default()
+	cfg.OutputFile = "/tmp/topology.dot"
+	cfg.SVGOutputFile = "/tmp/topology.svg"
+	cfg.ShowSegments = true
+	cfg.Templates = []TemplateConfig{
+		{Name: "hosts", Template: "/etc/hosts.tmpl", Output: "/tmp/hosts"},
+		{Name: "http-only", Template: "/etc/http.tmpl"},
+	}
+	cfg.Outputs = []OutputConfig{
+		{Format: "dot", Path: "/tmp/topology.svg", Segments: &off}, // Replaces legacy SVG path
+		{Format: "json", Path: "/tmp/topology.json"},
+	}
+
+	outputs := cfg.EffectiveOutputs()
+
+	var got []string
+	for _, o := range outputs {
+		got = append(got, o.Format+":"+o.Path)
+		if o.Segments == nil {
+			t.Errorf("Expected resolved segments for %s", o.Path)
+		}
+	}
+	expected := []string{"dot:/tmp/topology.dot", "template:/tmp/hosts", "dot:/tmp/topology.svg", "json:/tmp/topology.json"}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected outputs %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Output %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+
+	if !*outputs[0].Segments || *outputs[2].Segments || !*outputs[3].Segments {
+		t.Error("Expected segments to default to show_segments unless set explicitly")
+	}
+	if outputs[1].Template != "hosts" {
+		t.Errorf("Expected template output to reference 'hosts', got %q", outputs[1].Template)
+	}
+	if cfg.Outputs[1].Segments != nil {
+		t.Error("Expected EffectiveOutputs not to modify the configured outputs")
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) &&
 		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
@@ -0,0 +1,365 @@
+// Package diff compares two topology snapshots. Nodes, interfaces, edges and
+// segments are matched by their stable v1 IDs, so snapshots taken at different
+// times by different daemons can be compared.
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/api"
+)
+
+// Kinds of change
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Element types, in the order changes are reported
+const (
+	TypeNode      = "node"
+	TypeInterface = "interface"
+	TypeEdge      = "edge"
+	TypeSegment   = "segment"
+)
+
+var typeOrder = map[string]int{TypeNode: 0, TypeInterface: 1, TypeEdge: 2, TypeSegment: 3}
+
+// Change is an added, removed or changed element
+type Change struct {
+	Kind   string        `json:"kind"`
+	Type   string        `json:"type"`
+	ID     string        `json:"id"`               // Node ID, "<node ID>:<interface>", edge ID or segment ID
+	Name   string        `json:"name"`             // Human-readable name built from hostnames
+	Fields []FieldChange `json:"fields,omitempty"` // Changed attributes, for Kind Changed
+}
+
+// FieldChange is an attribute that differs between the snapshots
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Report lists the differences between two snapshots, sorted by type and ID
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// Empty reports whether the snapshots are equivalent
+func (r *Report) Empty() bool {
+	return len(r.Changes) == 0
+}
+
+// Kinds returns the kind of change of every element of the given type by ID
+func (r *Report) Kinds(elementType string) map[string]string {
+	kinds := make(map[string]string)
+	for _, c := range r.Changes {
+		if c.Type == elementType {
+			kinds[c.ID] = c.Kind
+		}
+	}
+	return kinds
+}
+
+// InterfaceID identifies an interface across snapshots
+func InterfaceID(nodeID, iface string) string {
+	return nodeID + ":" + iface
+}
+
+// Compare returns the differences from old to new. Timestamps (generated_at,
+// last_seen), is_local and the node an indirect edge was learned from are not
+// compared; they change without the topology changing.
+func Compare(old, new *api.Graph) *Report {
+	names := hostnames(old, new)
+	r := &Report{Changes: []Change{}}
+
+	oldNodes, newNodes := nodesByID(old), nodesByID(new)
+	for _, id := range unionKeys(oldNodes, newNodes) {
+		o, n := oldNodes[id], newNodes[id]
+		r.add(TypeNode, id, names[id], o != nil, n != nil, func() []FieldChange {
+			var fields []FieldChange
+			fields = compareField(fields, "hostname", o.Hostname, n.Hostname)
+			fields = compareField(fields, "labels", formatLabels(o.Labels), formatLabels(n.Labels))
+			return fields
+		})
+	}
+
+	oldIfaces, newIfaces := interfacesByID(old), interfacesByID(new)
+	for _, id := range unionKeys(oldIfaces, newIfaces) {
+		o, n := oldIfaces[id], newIfaces[id]
+		nodeID, name, _ := strings.Cut(id, ":")
+		r.add(TypeInterface, id, names[nodeID]+":"+name, o != nil, n != nil, func() []FieldChange {
+			var fields []FieldChange
+			fields = compareField(fields, "ip_address", o.IPAddress, n.IPAddress)
+			fields = compareField(fields, "prefixes", strings.Join(o.Prefixes, ","), strings.Join(n.Prefixes, ","))
+			fields = compareField(fields, "speed_mbps", fmt.Sprint(o.SpeedMbps), fmt.Sprint(n.SpeedMbps))
+			fields = compareField(fields, "rdma_device", o.RDMADevice, n.RDMADevice)
+			fields = compareField(fields, "rdma_devices", rdmaDevices(o), rdmaDevices(n))
+			fields = compareField(fields, "node_guid", o.NodeGUID, n.NodeGUID)
+			fields = compareField(fields, "sys_image_guid", o.SysImageGUID, n.SysImageGUID)
+			fields = compareField(fields, "pkey", pkey(o), pkey(n))
+			fields = compareField(fields, "stack", stack(o), stack(n))
+			fields = compareField(fields, "sriov", sriov(o), sriov(n))
+			oe, ne := ethtool(o), ethtool(n)
+			fields = compareField(fields, "driver", oe.Driver, ne.Driver)
+			fields = compareField(fields, "driver_version", oe.DriverVersion, ne.DriverVersion)
+			fields = compareField(fields, "firmware_version", oe.FirmwareVersion, ne.FirmwareVersion)
+			fields = compareField(fields, "duplex", oe.Duplex, ne.Duplex)
+			return fields
+		})
+	}
+
+	oldEdges, newEdges := edgesByID(old), edgesByID(new)
+	for _, id := range unionKeys(oldEdges, newEdges) {
+		o, n := oldEdges[id], newEdges[id]
+		e := n
+		if e == nil {
+			e = o
+		}
+		name := names[e.Source.NodeID] + ":" + e.Source.Interface + " -- " + names[e.Target.NodeID] + ":" + e.Target.Interface
+		r.add(TypeEdge, id, name, o != nil, n != nil, func() []FieldChange {
+			var fields []FieldChange
+			fields = compareField(fields, "direct", fmt.Sprint(o.Direct), fmt.Sprint(n.Direct))
+			fields = compareEndpoint(fields, "source", o.Source, n.Source)
+			fields = compareEndpoint(fields, "target", o.Target, n.Target)
+			return fields
+		})
+	}
+
+	oldSegments, newSegments := segmentsByID(old), segmentsByID(new)
+	for _, id := range unionKeys(oldSegments, newSegments) {
+		o, n := oldSegments[id], newSegments[id]
+		s := n
+		if s == nil {
+			s = o
+		}
+		name := s.Interface
+		if len(s.Prefixes) > 0 {
+			name += " " + s.Prefixes[0]
+		}
+		r.add(TypeSegment, id, name, o != nil, n != nil, func() []FieldChange {
+			var fields []FieldChange
+			fields = compareField(fields, "prefixes", strings.Join(o.Prefixes, ","), strings.Join(n.Prefixes, ","))
+			fields = compareField(fields, "vlan_id", vlanID(o), vlanID(n))
+			fields = compareField(fields, "members", segmentMembers(o, names), segmentMembers(n, names))
+			return fields
+		})
+	}
+
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		a, b := r.Changes[i], r.Changes[j]
+		if a.Type != b.Type {
+			return typeOrder[a.Type] < typeOrder[b.Type]
+		}
+		return a.ID < b.ID
+	})
+	return r
+}
+
+// add records an element present in old, new or both. fields is only called
+// when the element is in both snapshots.
+func (r *Report) add(elementType, id, name string, inOld, inNew bool, fields func() []FieldChange) {
+	c := Change{Type: elementType, ID: id, Name: name}
+	switch {
+	case !inOld:
+		c.Kind = Added
+	case !inNew:
+		c.Kind = Removed
+	default:
+		c.Fields = fields()
+		if len(c.Fields) == 0 {
+			return
+		}
+		c.Kind = Changed
+	}
+	r.Changes = append(r.Changes, c)
+}
+
+func compareField(fields []FieldChange, field, old, new string) []FieldChange {
+	if old == new {
+		return fields
+	}
+	return append(fields, FieldChange{Field: field, Old: old, New: new})
+}
+
+// compareEndpoint compares the link attributes of one side of an edge. Address
+// and prefixes are reported on the interface instead.
+func compareEndpoint(fields []FieldChange, side string, old, new api.Endpoint) []FieldChange {
+	fields = compareField(fields, side+".speed_mbps", fmt.Sprint(old.SpeedMbps), fmt.Sprint(new.SpeedMbps))
+	fields = compareField(fields, side+".rdma_device", old.RDMADevice, new.RDMADevice)
+	fields = compareField(fields, side+".node_guid", old.NodeGUID, new.NodeGUID)
+	fields = compareField(fields, side+".sys_image_guid", old.SysImageGUID, new.SysImageGUID)
+	return fields
+}
+
+// hostnames maps node IDs of both snapshots to hostnames, preferring new names
+func hostnames(old, new *api.Graph) map[string]string {
+	names := make(map[string]string)
+	for _, doc := range []*api.Graph{old, new} {
+		for _, n := range doc.Nodes {
+			names[n.ID] = n.Hostname
+		}
+	}
+	return names
+}
+
+func nodesByID(doc *api.Graph) map[string]*api.Node {
+	nodes := make(map[string]*api.Node, len(doc.Nodes))
+	for i := range doc.Nodes {
+		nodes[doc.Nodes[i].ID] = &doc.Nodes[i]
+	}
+	return nodes
+}
+
+func interfacesByID(doc *api.Graph) map[string]*api.Interface {
+	ifaces := make(map[string]*api.Interface)
+	for i := range doc.Nodes {
+		for j := range doc.Nodes[i].Interfaces {
+			iface := &doc.Nodes[i].Interfaces[j]
+			ifaces[InterfaceID(doc.Nodes[i].ID, iface.Name)] = iface
+		}
+	}
+	return ifaces
+}
+
+func edgesByID(doc *api.Graph) map[string]*api.Edge {
+	edges := make(map[string]*api.Edge, len(doc.Edges))
+	for i := range doc.Edges {
+		edges[doc.Edges[i].ID] = &doc.Edges[i]
+	}
+	return edges
+}
+
+func segmentsByID(doc *api.Graph) map[string]*api.Segment {
+	segments := make(map[string]*api.Segment, len(doc.Segments))
+	for i := range doc.Segments {
+		segments[doc.Segments[i].ID] = &doc.Segments[i]
+	}
+	return segments
+}
+
+// unionKeys returns the sorted keys of both maps
+func unionKeys[T any](a, b map[string]T) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rdmaDevices lists the RDMA devices of an interface, falling back to the
+// single device of older snapshots
+func rdmaDevices(iface *api.Interface) string {
+	if len(iface.RDMADevices) == 0 {
+		return iface.RDMADevice
+	}
+	names := make([]string, len(iface.RDMADevices))
+	for i, d := range iface.RDMADevices {
+		names[i] = d.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// pkey returns the partition of an IPoIB interface, "" for other interfaces
+func pkey(iface *api.Interface) string {
+	if iface.IPoIB == nil {
+		return ""
+	}
+	return iface.IPoIB.PKey
+}
+
+// stack describes the VLAN, bond or bridge relationships of an interface,
+// e.g. "vlan 100 on bond0" or "bond 802.3ad of ens1f0,ens1f1"
+func stack(iface *api.Interface) string {
+	s := iface.Stack
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	switch s.Kind {
+	case "vlan":
+		parts = append(parts, fmt.Sprintf("vlan %d", s.VLANID))
+		if s.Parent != "" {
+			parts = append(parts, "on "+s.Parent)
+		}
+	case "bond", "bridge":
+		parts = append(parts, s.Kind)
+		if s.BondMode != "" {
+			parts = append(parts, s.BondMode)
+		}
+		if len(s.Members) > 0 {
+			parts = append(parts, "of "+strings.Join(s.Members, ","))
+		}
+	}
+	if s.Master != "" {
+		parts = append(parts, "in "+s.Master)
+	}
+	return strings.Join(parts, " ")
+}
+
+// sriov describes the SR-IOV role of an interface, e.g. "pf with 8 vfs",
+// "vf 3 of ens1f0np0" or "representor of vf 3"
+func sriov(iface *api.Interface) string {
+	s := iface.SRIOV
+	if s == nil {
+		return ""
+	}
+	switch s.Role {
+	case "pf":
+		return fmt.Sprintf("pf with %d vfs", s.NumVFs)
+	case "vf":
+		return strings.TrimSuffix(fmt.Sprintf("vf %d of %s", s.VF, s.PF), " of ")
+	case "representor":
+		return fmt.Sprintf("representor of vf %d", s.VF)
+	}
+	return ""
+}
+
+// vlanID returns the VLAN of a segment, "" if untagged
+func vlanID(s *api.Segment) string {
+	if s.VLANID == 0 {
+		return ""
+	}
+	return fmt.Sprint(s.VLANID)
+}
+
+// ethtool returns the link settings of an interface, empty if unknown
+func ethtool(iface *api.Interface) api.Ethtool {
+	if iface.Ethtool == nil {
+		return api.Ethtool{}
+	}
+	return *iface.Ethtool
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// segmentMembers lists the members of a segment by hostname, sorted
+func segmentMembers(s *api.Segment, names map[string]string) string {
+	members := make([]string, 0, len(s.Members))
+	for _, m := range s.Members {
+		name := names[m.NodeID]
+		if name == "" {
+			name = m.NodeID
+		}
+		members = append(members, name)
+	}
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
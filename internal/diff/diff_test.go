@@ -0,0 +1,160 @@
+package diff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// snapshot builds a local node with neighbors on eth0, optionally dropping
+// host-c, adding host-d and changing host-a's speed
+func snapshot(changed bool) *api.Graph {
+	g := graph.New()
+	g.SetLocalNode("local", "host-local", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1", GlobalPrefixes: []string{"10.0.0.0/24"}, Speed: 1000},
+	})
+	speed := 1000
+	if changed {
+		speed = 10000
+	}
+	g.AddOrUpdate("a", "host-a", "eth0", "fe80::2", "eth0", "", "", "", speed, []string{"10.0.0.0/24"}, true, "")
+	g.AddOrUpdate("b", "host-b", "eth0", "fe80::3", "eth0", "", "", "", 1000, []string{"10.0.0.0/24"}, true, "")
+	if changed {
+		g.AddOrUpdate("d", "host-d", "eth0", "fe80::5", "eth0", "", "", "", 1000, []string{"10.0.0.0/24"}, true, "")
+		g.SetNodeLabels("b", map[string]string{"rack": "r2"})
+	} else {
+		g.AddOrUpdate("c", "host-c", "eth0", "fe80::4", "eth0", "", "", "", 1000, []string{"10.0.0.0/24"}, true, "")
+	}
+	return api.FromGraph(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+}
+
+func findChange(r *Report, elementType, id string) *Change {
+	for i := range r.Changes {
+		if r.Changes[i].Type == elementType && r.Changes[i].ID == id {
+			return &r.Changes[i]
+		}
+	}
+	return nil
+}
+
+func TestCompareIdentical(t *testing.T) {
+	r := Compare(snapshot(false), snapshot(false))
+	if !r.Empty() {
+		t.Errorf("expected no changes, got %+v", r.Changes)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	r := Compare(snapshot(false), snapshot(true))
+
+	tests := []struct {
+		elementType, id, kind string
+	}{
+		{TypeNode, "c", Removed},
+		{TypeNode, "d", Added},
+		{TypeNode, "b", Changed},
+		{TypeInterface, "c:eth0", Removed},
+		{TypeInterface, "d:eth0", Added},
+		{TypeInterface, "a:eth0", Changed},
+		{TypeEdge, api.EdgeID("local", "eth0", "c", "eth0"), Removed},
+		{TypeEdge, api.EdgeID("local", "eth0", "d", "eth0"), Added},
+		{TypeEdge, api.EdgeID("local", "eth0", "a", "eth0"), Changed},
+	}
+	for _, tt := range tests {
+		c := findChange(r, tt.elementType, tt.id)
+		if c == nil {
+			t.Errorf("expected %s %s to be %s, not reported", tt.elementType, tt.id, tt.kind)
+			continue
+		}
+		if c.Kind != tt.kind {
+			t.Errorf("expected %s %s to be %s, got %s", tt.elementType, tt.id, tt.kind, c.Kind)
+		}
+	}
+	if c := findChange(r, TypeNode, "a"); c != nil {
+		t.Errorf("host-a itself did not change: %+v", c)
+	}
+
+	iface := findChange(r, TypeInterface, "a:eth0")
+	if iface.Name != "host-a:eth0" || len(iface.Fields) != 1 ||
+		iface.Fields[0] != (FieldChange{Field: "speed_mbps", Old: "1000", New: "10000"}) {
+		t.Errorf("unexpected interface change: %+v", iface)
+	}
+	if node := findChange(r, TypeNode, "b"); node.Fields[0] != (FieldChange{Field: "labels", Old: "", New: "rack=r2"}) {
+		t.Errorf("unexpected node change: %+v", node)
+	}
+	if edge := findChange(r, TypeEdge, api.EdgeID("local", "eth0", "a", "eth0")); edge.Fields[0].Field != "target.speed_mbps" {
+		t.Errorf("unexpected edge change: %+v", edge)
+	}
+
+	// The segment keeps its ID; its members changed
+	if len(snapshot(true).Segments) != 1 {
+		t.Fatal("expected one segment")
+	}
+	seg := findChange(r, TypeSegment, snapshot(true).Segments[0].ID)
+	if seg == nil || seg.Kind != Changed || seg.Fields[0].Old != "host-a,host-b,host-c,host-local" || seg.Fields[0].New != "host-a,host-b,host-d,host-local" {
+		t.Errorf("unexpected segment change: %+v", seg)
+	}
+
+	// Changes are ordered by type, then ID
+	for i := 1; i < len(r.Changes); i++ {
+		a, b := r.Changes[i-1], r.Changes[i]
+		if typeOrder[a.Type] > typeOrder[b.Type] || (a.Type == b.Type && a.ID > b.ID) {
+			t.Errorf("changes out of order: %s %s before %s %s", a.Type, a.ID, b.Type, b.ID)
+		}
+	}
+}
+
+func TestCompareFirmware(t *testing.T) {
+	old, new := snapshot(false), snapshot(false)
+	for _, doc := range []*api.Graph{old, new} {
+		for i := range doc.Nodes {
+			if doc.Nodes[i].ID == "b" {
+				doc.Nodes[i].Interfaces[0].Ethtool = &api.Ethtool{Driver: "ice", FirmwareVersion: "4.40"}
+			}
+		}
+	}
+	new.Nodes[1].Interfaces[0].Ethtool = &api.Ethtool{Driver: "ice", FirmwareVersion: "4.50"}
+	if new.Nodes[1].ID != "b" {
+		t.Fatalf("expected host-b second, got %s", new.Nodes[1].ID)
+	}
+
+	iface := findChange(Compare(old, new), TypeInterface, "b:eth0")
+	if iface == nil || len(iface.Fields) != 1 || iface.Fields[0] != (FieldChange{Field: "firmware_version", Old: "4.40", New: "4.50"}) {
+		t.Errorf("unexpected interface change: %+v", iface)
+	}
+}
+
+func TestCompareStack(t *testing.T) {
+	old, new := snapshot(false), snapshot(false)
+	new.Nodes[1].Interfaces[0].Stack = &api.Stack{Kind: "bond", BondMode: "802.3ad", Members: []string{"ens1f0", "ens1f1"}}
+	if new.Nodes[1].ID != "b" {
+		t.Fatalf("expected host-b second, got %s", new.Nodes[1].ID)
+	}
+
+	iface := findChange(Compare(old, new), TypeInterface, "b:eth0")
+	if iface == nil || len(iface.Fields) != 1 || iface.Fields[0] != (FieldChange{Field: "stack", Old: "", New: "bond 802.3ad of ens1f0,ens1f1"}) {
+		t.Errorf("unexpected interface change: %+v", iface)
+	}
+}
+
+func TestCompareSRIOV(t *testing.T) {
+	old, new := snapshot(false), snapshot(false)
+	old.Nodes[1].Interfaces[0].SRIOV = &api.SRIOV{Role: "pf", NumVFs: 4}
+	new.Nodes[1].Interfaces[0].SRIOV = &api.SRIOV{Role: "pf", NumVFs: 8}
+
+	iface := findChange(Compare(old, new), TypeInterface, "b:eth0")
+	if iface == nil || len(iface.Fields) != 1 || iface.Fields[0] != (FieldChange{Field: "sriov", Old: "pf with 4 vfs", New: "pf with 8 vfs"}) {
+		t.Errorf("unexpected interface change: %+v", iface)
+	}
+}
+
+func TestCompareIgnoresTimestamps(t *testing.T) {
+	old, new := snapshot(false), snapshot(false)
+	new.Nodes[0].LastSeen = new.Nodes[0].LastSeen.Add(time.Hour)
+	new.GeneratedAt = new.GeneratedAt.Add(time.Hour)
+	if r := Compare(old, new); !r.Empty() {
+		t.Errorf("expected timestamps to be ignored, got %+v", r.Changes)
+	}
+}
@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// FlapHandler is called with the number of carrier losses of a local interface
+type FlapHandler func(ifaceName string, flaps int)
+
+// CarrierMonitor counts carrier losses of local interfaces as they happen,
+// from netlink link updates. Peers learn of them from the carrier_changes
+// count in the interface's health.
+type CarrierMonitor struct {
+	logger  *slog.Logger
+	handler FlapHandler
+	carrier map[string]bool // Last known carrier per interface
+}
+
+func NewCarrierMonitor(logger *slog.Logger, handler FlapHandler) *CarrierMonitor {
+	return &CarrierMonitor{
+		logger:  logger,
+		handler: handler,
+		carrier: make(map[string]bool),
+	}
+}
+
+// Run subscribes to link updates and reports carrier losses until ctx is done
+func (m *CarrierMonitor) Run(ctx context.Context) error {
+	updates := make(chan netlink.LinkUpdate)
+	done := make(chan struct{})
+	defer close(done)
+
+	if err := netlink.LinkSubscribeWithOptions(updates, done, netlink.LinkSubscribeOptions{
+		ListExisting: true, // Learn the current carrier without counting it
+		ErrorCallback: func(err error) {
+			m.logger.Warn("link update failed", "error", err)
+		},
+	}); err != nil {
+		return fmt.Errorf("subscribe to link updates: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-updates:
+			if !ok {
+				return errors.New("link updates closed")
+			}
+			name := update.Attrs().Name
+			if update.Header.Type == unix.RTM_DELLINK {
+				delete(m.carrier, name)
+				continue
+			}
+			if m.carrierLost(name, update.IfInfomsg.Flags&unix.IFF_LOWER_UP != 0) {
+				m.logger.Debug("carrier lost", "interface", name)
+				m.handler(name, 1)
+			}
+		}
+	}
+}
+
+// carrierLost records the carrier of an interface and reports whether it went
+// from up to down. The first state seen of an interface is not a loss.
+func (m *CarrierMonitor) carrierLost(name string, up bool) bool {
+	was, known := m.carrier[name]
+	m.carrier[name] = up
+	return known && was && !up
+}
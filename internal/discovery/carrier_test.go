@@ -0,0 +1,29 @@
+package discovery
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestCarrierLost(t *testing.T) {
+	m := NewCarrierMonitor(slog.Default(), nil)
+
+	for i, step := range []struct {
+		name string
+		up   bool
+		lost bool
+	}{
+		{"eth0", false, false}, // First state seen is not a loss
+		{"eth0", true, false},
+		{"eth0", true, false}, // Other attribute changes
+		{"eth0", false, true},
+		{"eth0", false, false},
+		{"eth1", true, false},
+		{"eth0", true, false},
+		{"eth0", false, true},
+	} {
+		if got := m.carrierLost(step.name, step.up); got != step.lost {
+			t.Errorf("step %d: carrierLost(%s, %v) = %v, want %v", i, step.name, step.up, got, step.lost)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kad/lldiscovery/internal/graph"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Values of the ethtool duplex, autoneg and port attributes (linux/ethtool.h)
+const (
+	ethtoolDuplexHalf     = 0x00
+	ethtoolDuplexFull     = 0x01
+	ethtoolAutonegDisable = 0x00
+	ethtoolAutonegEnable  = 0x01
+)
+
+// ethtoolPorts names the connector types of ETHTOOL_A_LINKINFO_PORT
+var ethtoolPorts = map[uint8]string{
+	0x00: "TP",
+	0x01: "AUI",
+	0x02: "BNC",
+	0x03: "MII",
+	0x04: "FIBRE",
+	0x05: "DA",
+	0xef: "NONE",
+	0xff: "OTHER",
+}
+
+// ethtoolNetlink is the ethtool netlink connection kept open while a Sender
+// runs, so the family is resolved once rather than for every interface on
+// every tick. Lookups while no Sender runs dial a connection of their own.
+var ethtoolNetlink struct {
+	mu     sync.Mutex
+	conn   *genetlink.Conn // nil if the kernel has no ethtool family
+	family uint16
+	users  int
+}
+
+// openEthtool keeps an ethtool netlink connection open until the matching
+// closeEthtool
+func openEthtool() {
+	e := &ethtoolNetlink
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.users++
+	if e.users == 1 {
+		e.conn, e.family = dialEthtool()
+	}
+}
+
+// closeEthtool closes the connection opened by openEthtool once its last user
+// is done
+func closeEthtool() {
+	e := &ethtoolNetlink
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.users--
+	if e.users == 0 && e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// dialEthtool connects to the ethtool netlink family and returns the
+// connection and family ID, nil if the family is unavailable
+func dialEthtool() (*genetlink.Conn, uint16) {
+	conn, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, 0
+	}
+	family, err := conn.GetFamily(unix.ETHTOOL_GENL_NAME)
+	if err != nil {
+		// Kernel older than 5.6
+		conn.Close()
+		return nil, 0
+	}
+	return conn, family.ID
+}
+
+// getEthtool returns the link settings and driver of an interface, nil if
+// nothing is known. The driver comes from the ETHTOOL_GDRVINFO ioctl, the link
+// settings from the ethtool netlink family (Linux 5.6+). sysfs fills in the
+// duplex, driver and bus address when those are unavailable.
+func getEthtool(ifaceName string) *graph.Ethtool {
+	et := &graph.Ethtool{}
+	readEthtoolDriver(ifaceName, et)
+	readEthtoolLink(ifaceName, et)
+	readSysfsEthtool(host.ClassNet(), ifaceName, et)
+	if *et == (graph.Ethtool{}) {
+		return nil
+	}
+	return et
+}
+
+// readEthtoolDriver fills in the driver, its version, the firmware version and
+// the bus address with the ETHTOOL_GDRVINFO ioctl
+func readEthtoolDriver(ifaceName string, et *graph.Ethtool) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	defer unix.Close(fd)
+
+	info, err := unix.IoctlGetEthtoolDrvinfo(fd, ifaceName)
+	if err != nil {
+		// Virtual interfaces without ethtool support
+		return
+	}
+	et.Driver = unix.ByteSliceToString(info.Driver[:])
+	et.DriverVersion = unix.ByteSliceToString(info.Version[:])
+	et.FirmwareVersion = unix.ByteSliceToString(info.Fw_version[:])
+	et.BusInfo = unix.ByteSliceToString(info.Bus_info[:])
+	if et.FirmwareVersion == "N/A" {
+		et.FirmwareVersion = ""
+	}
+}
+
+// readEthtoolLink fills in duplex, autonegotiation and the connector type
+// with the ethtool netlink family
+func readEthtoolLink(ifaceName string, et *graph.Ethtool) {
+	e := &ethtoolNetlink
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	conn, family := e.conn, e.family
+	if e.users == 0 {
+		conn, family = dialEthtool()
+		if conn != nil {
+			defer conn.Close()
+		}
+	}
+	if conn == nil {
+		return
+	}
+
+	requests := []struct {
+		command uint8
+		header  uint16
+		decode  func([]byte, *graph.Ethtool) error
+	}{
+		{unix.ETHTOOL_MSG_LINKMODES_GET, unix.ETHTOOL_A_LINKMODES_HEADER, decodeLinkModes},
+		{unix.ETHTOOL_MSG_LINKINFO_GET, unix.ETHTOOL_A_LINKINFO_HEADER, decodeLinkInfo},
+	}
+	for _, req := range requests {
+		ae := netlink.NewAttributeEncoder()
+		ae.Nested(req.header, func(nae *netlink.AttributeEncoder) error {
+			nae.String(unix.ETHTOOL_A_HEADER_DEV_NAME, ifaceName)
+			nae.Uint32(unix.ETHTOOL_A_HEADER_FLAGS, unix.ETHTOOL_FLAG_COMPACT_BITSETS)
+			return nil
+		})
+		data, err := ae.Encode()
+		if err != nil {
+			continue
+		}
+		msgs, err := conn.Execute(genetlink.Message{
+			Header: genetlink.Header{Command: req.command, Version: unix.ETHTOOL_GENL_VERSION},
+			Data:   data,
+		}, family, netlink.Request)
+		if err != nil {
+			// Not supported by the driver
+			continue
+		}
+		for _, msg := range msgs {
+			_ = req.decode(msg.Data, et)
+		}
+	}
+}
+
+// decodeLinkModes reads autonegotiation and duplex from an
+// ETHTOOL_MSG_LINKMODES_GET reply
+func decodeLinkModes(data []byte, et *graph.Ethtool) error {
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return err
+	}
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.ETHTOOL_A_LINKMODES_AUTONEG:
+			switch ad.Uint8() {
+			case ethtoolAutonegEnable:
+				et.Autoneg = "on"
+			case ethtoolAutonegDisable:
+				et.Autoneg = "off"
+			}
+		case unix.ETHTOOL_A_LINKMODES_DUPLEX:
+			switch ad.Uint8() {
+			case ethtoolDuplexFull:
+				et.Duplex = "full"
+			case ethtoolDuplexHalf:
+				et.Duplex = "half"
+			}
+		}
+	}
+	return ad.Err()
+}
+
+// decodeLinkInfo reads the connector type from an ETHTOOL_MSG_LINKINFO_GET reply
+func decodeLinkInfo(data []byte, et *graph.Ethtool) error {
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return err
+	}
+	for ad.Next() {
+		if ad.Type() == unix.ETHTOOL_A_LINKINFO_PORT {
+			et.Port = ethtoolPorts[ad.Uint8()]
+		}
+	}
+	return ad.Err()
+}
+
+// readSysfsEthtool fills in what ethtool did not report from
+// /sys/class/net/<iface>: the duplex, the driver bound to the device and the
+// device's bus address
+func readSysfsEthtool(netPath, ifaceName string, et *graph.Ethtool) {
+	dir := filepath.Join(netPath, ifaceName)
+	if et.Duplex == "" {
+		// "unknown" while the link is down
+		if duplex := readSysfsValue(filepath.Join(dir, "duplex")); duplex == "full" || duplex == "half" {
+			et.Duplex = duplex
+		}
+	}
+	if et.Driver == "" {
+		if link, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+			et.Driver = filepath.Base(link)
+		}
+	}
+	if et.BusInfo == "" {
+		if link, err := os.Readlink(filepath.Join(dir, "device")); err == nil {
+			et.BusInfo = filepath.Base(link)
+		}
+	}
+}
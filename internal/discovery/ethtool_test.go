@@ -0,0 +1,122 @@
+package discovery
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// encodeAttributes builds a netlink attribute payload as the kernel sends it
+func encodeAttributes(t *testing.T, fn func(ae *netlink.AttributeEncoder)) []byte {
+	t.Helper()
+	ae := netlink.NewAttributeEncoder()
+	fn(ae)
+	data, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeLinkModes(t *testing.T) {
+	data := encodeAttributes(t, func(ae *netlink.AttributeEncoder) {
+		ae.Nested(unix.ETHTOOL_A_LINKMODES_HEADER, func(nae *netlink.AttributeEncoder) error {
+			nae.String(unix.ETHTOOL_A_HEADER_DEV_NAME, "ens1f0")
+			return nil
+		})
+		ae.Uint8(unix.ETHTOOL_A_LINKMODES_AUTONEG, ethtoolAutonegDisable)
+		ae.Uint32(unix.ETHTOOL_A_LINKMODES_SPEED, 100000)
+		ae.Uint8(unix.ETHTOOL_A_LINKMODES_DUPLEX, ethtoolDuplexFull)
+	})
+
+	var et graph.Ethtool
+	if err := decodeLinkModes(data, &et); err != nil {
+		t.Fatal(err)
+	}
+	if et.Autoneg != "off" || et.Duplex != "full" {
+		t.Errorf("unexpected link modes %+v", et)
+	}
+
+	// DUPLEX_UNKNOWN, as reported while the link is down
+	data = encodeAttributes(t, func(ae *netlink.AttributeEncoder) {
+		ae.Uint8(unix.ETHTOOL_A_LINKMODES_AUTONEG, ethtoolAutonegEnable)
+		ae.Uint8(unix.ETHTOOL_A_LINKMODES_DUPLEX, 0xff)
+	})
+	et = graph.Ethtool{}
+	if err := decodeLinkModes(data, &et); err != nil {
+		t.Fatal(err)
+	}
+	if et.Autoneg != "on" || et.Duplex != "" {
+		t.Errorf("unexpected link modes %+v", et)
+	}
+}
+
+func TestDecodeLinkInfo(t *testing.T) {
+	for port, want := range map[uint8]string{0x00: "TP", 0x04: "FIBRE", 0x05: "DA", 0xef: "NONE", 0x42: ""} {
+		data := encodeAttributes(t, func(ae *netlink.AttributeEncoder) {
+			ae.Uint8(unix.ETHTOOL_A_LINKINFO_PORT, port)
+		})
+		var et graph.Ethtool
+		if err := decodeLinkInfo(data, &et); err != nil {
+			t.Fatal(err)
+		}
+		if et.Port != want {
+			t.Errorf("port %#x: got %q, want %q", port, et.Port, want)
+		}
+	}
+}
+
+func TestReadSysfsEthtool(t *testing.T) {
+	tests := []struct {
+		fixture string
+		iface   string
+		want    graph.Ethtool
+	}{
+		{"roce", "ens1f1", graph.Ethtool{Duplex: "full", Driver: "mlx5_core", BusInfo: "0000:3b:00.1"}},
+		{"roce", "eno1", graph.Ethtool{Duplex: "full", Driver: "igb", BusInfo: "0000:02:00.0"}},
+		{"infiniband", "ib0", graph.Ethtool{Driver: "mlx5_core", BusInfo: "0000:5e:00.0"}},
+		{"laptop", "wlp0s20f3", graph.Ethtool{Driver: "iwlwifi", BusInfo: "0000:00:14.3"}},
+		{"laptop", "missing", graph.Ethtool{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+"/"+tt.iface, func(t *testing.T) {
+			netPath := filepath.Join("testdata", "hostfs", tt.fixture, "sys", "class", "net")
+			var et graph.Ethtool
+			readSysfsEthtool(netPath, tt.iface, &et)
+			if et != tt.want {
+				t.Errorf("got %+v, want %+v", et, tt.want)
+			}
+		})
+	}
+
+	// What ethtool reported is kept
+	et := graph.Ethtool{Duplex: "half", Driver: "mlx5_core", DriverVersion: "24.10", BusInfo: "0000:3b:00.0"}
+	readSysfsEthtool(filepath.Join("testdata", "hostfs", "laptop", "sys", "class", "net"), "enp0s31f6", &et)
+	if et.Duplex != "half" || et.Driver != "mlx5_core" || et.BusInfo != "0000:3b:00.0" {
+		t.Errorf("expected ethtool values to be kept, got %+v", et)
+	}
+}
+
+func TestOpenEthtool(t *testing.T) {
+	openEthtool()
+	openEthtool()
+	closeEthtool()
+	if ethtoolNetlink.users != 1 {
+		t.Fatalf("expected one user left, got %d", ethtoolNetlink.users)
+	}
+	conn := ethtoolNetlink.conn
+
+	// Lookups use the open connection
+	readEthtoolLink("lo", &graph.Ethtool{})
+	if ethtoolNetlink.conn != conn {
+		t.Error("expected the connection to be kept")
+	}
+
+	closeEthtool()
+	if ethtoolNetlink.users != 0 || ethtoolNetlink.conn != nil {
+		t.Errorf("expected the connection to be closed, got %d users", ethtoolNetlink.users)
+	}
+}
@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// rdmaErrorCounters are the RDMA port counters that indicate a bad link, read
+// from /sys/class/infiniband/<dev>/ports/<n>/counters/. Discards are left out:
+// they signal congestion rather than a faulty cable or port.
+var rdmaErrorCounters = []string{
+	"symbol_error",
+	"port_rcv_errors",
+	"link_error_recovery",
+	"link_downed",
+	"local_link_integrity_errors",
+	"excessive_buffer_overrun_errors",
+}
+
+// HealthThresholds decide when an interface is degraded. Errors are the rx,
+// tx and RDMA error rates combined; any carrier change degrades an interface.
+type HealthThresholds struct {
+	ErrorRate float64 // Errors per second above which an interface is degraded
+	DropRate  float64 // Drops per second above which an interface is degraded
+}
+
+// HealthHandler receives the health of a local interface after each sample
+type HealthHandler func(ifaceName string, health *graph.Health)
+
+// healthCounters are the raw counters of one interface
+type healthCounters struct {
+	rxErrors, txErrors, rxDropped, txDropped, rxCRCErrors, carrierChanges, rdmaErrors uint64
+}
+
+type healthSample struct {
+	at       time.Time
+	counters healthCounters
+}
+
+// HealthSampler turns interface counters into rates between two samples. It
+// is not safe for concurrent use; the sender samples from one goroutine.
+type HealthSampler struct {
+	thresholds HealthThresholds
+	last       map[string]healthSample
+	now        func() time.Time
+}
+
+// NewHealthSampler creates a sampler that rates interfaces with thresholds
+func NewHealthSampler(thresholds HealthThresholds) *HealthSampler {
+	return &HealthSampler{
+		thresholds: thresholds,
+		last:       make(map[string]healthSample),
+		now:        time.Now,
+	}
+}
+
+// Sample reads the counters of an interface and its RDMA ports and returns
+// the rates since the previous sample, nil on the first sample or if the
+// counters cannot be read
+func (s *HealthSampler) Sample(ifaceName string, devices []graph.RDMADevice) *graph.Health {
+	counters, ok := readHealthCounters(ifaceName, devices)
+	if !ok {
+		delete(s.last, ifaceName)
+		return nil
+	}
+	now := s.now()
+	prev, seen := s.last[ifaceName]
+	s.last[ifaceName] = healthSample{at: now, counters: counters}
+	if !seen {
+		return nil
+	}
+	seconds := now.Sub(prev.at).Seconds()
+	if seconds <= 0 {
+		return nil
+	}
+
+	rate := func(cur, old uint64) float64 {
+		if cur < old {
+			// The interface was recreated and its counters restarted
+			return 0
+		}
+		return math.Round(float64(cur-old)/seconds*100) / 100
+	}
+	h := &graph.Health{
+		RxErrors:    rate(counters.rxErrors, prev.counters.rxErrors),
+		TxErrors:    rate(counters.txErrors, prev.counters.txErrors),
+		RxDropped:   rate(counters.rxDropped, prev.counters.rxDropped),
+		TxDropped:   rate(counters.txDropped, prev.counters.txDropped),
+		RxCRCErrors: rate(counters.rxCRCErrors, prev.counters.rxCRCErrors),
+		RDMAErrors:  rate(counters.rdmaErrors, prev.counters.rdmaErrors),
+	}
+	if counters.carrierChanges > prev.counters.carrierChanges {
+		h.CarrierChanges = int(counters.carrierChanges - prev.counters.carrierChanges)
+	}
+
+	h.Status = graph.HealthOK
+	if h.RxErrors+h.TxErrors+h.RDMAErrors > s.thresholds.ErrorRate ||
+		h.RxDropped+h.TxDropped > s.thresholds.DropRate || h.CarrierChanges > 0 {
+		h.Status = graph.HealthDegraded
+	}
+	return h
+}
+
+// readHealthCounters reads /sys/class/net/<iface>/statistics, carrier_changes
+// and the error counters of the given RDMA ports. Missing RDMA counters are
+// skipped; ok is false when the interface statistics are unavailable.
+func readHealthCounters(ifaceName string, devices []graph.RDMADevice) (counters healthCounters, ok bool) {
+	stats := host.ClassNet(ifaceName, "statistics")
+	read := func(path string) (uint64, bool) {
+		v, err := strconv.ParseUint(readSysfsValue(path), 10, 64)
+		return v, err == nil
+	}
+
+	if counters.rxErrors, ok = read(filepath.Join(stats, "rx_errors")); !ok {
+		return counters, false
+	}
+	counters.txErrors, _ = read(filepath.Join(stats, "tx_errors"))
+	counters.rxDropped, _ = read(filepath.Join(stats, "rx_dropped"))
+	counters.txDropped, _ = read(filepath.Join(stats, "tx_dropped"))
+	counters.rxCRCErrors, _ = read(filepath.Join(stats, "rx_crc_errors"))
+	counters.carrierChanges, _ = read(host.ClassNet(ifaceName, "carrier_changes"))
+
+	for _, dev := range devices {
+		for _, port := range dev.Ports {
+			dir := host.ClassInfiniband(dev.Name, "ports", fmt.Sprint(port.Port), "counters")
+			for _, name := range rdmaErrorCounters {
+				if v, ok := read(filepath.Join(dir, name)); ok {
+					counters.rdmaErrors += v
+				}
+			}
+		}
+	}
+	return counters, true
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// writeCounters writes interface and RDMA counters below root
+func writeCounters(t *testing.T, root string, counters map[string]string) {
+	t.Helper()
+	for path, value := range counters {
+		path = filepath.Join(root, "sys", "class", path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHealthSampler(t *testing.T) {
+	root := t.TempDir()
+	SetHostRoot(root)
+	t.Cleanup(func() { SetHostRoot("") })
+
+	writeCounters(t, root, map[string]string{
+		"net/ens1f0/statistics/rx_errors":                    "100",
+		"net/ens1f0/statistics/tx_errors":                    "0",
+		"net/ens1f0/statistics/rx_dropped":                   "50",
+		"net/ens1f0/statistics/tx_dropped":                   "0",
+		"net/ens1f0/statistics/rx_crc_errors":                "40",
+		"net/ens1f0/carrier_changes":                         "2",
+		"infiniband/mlx5_0/ports/1/counters/symbol_error":    "7",
+		"infiniband/mlx5_0/ports/1/counters/port_rcv_errors": "3",
+	})
+	devices := []graph.RDMADevice{{Name: "mlx5_0", Ports: []graph.RDMAPort{{Port: 1}}}}
+
+	now := time.Unix(1000, 0)
+	s := NewHealthSampler(HealthThresholds{ErrorRate: 1, DropRate: 10})
+	s.now = func() time.Time { return now }
+
+	if h := s.Sample("ens1f0", devices); h != nil {
+		t.Fatalf("expected no health on the first sample, got %+v", h)
+	}
+
+	now = now.Add(10 * time.Second)
+	writeCounters(t, root, map[string]string{
+		"net/ens1f0/statistics/rx_errors":     "105",
+		"net/ens1f0/statistics/rx_dropped":    "60",
+		"net/ens1f0/statistics/rx_crc_errors": "45",
+	})
+	h := s.Sample("ens1f0", devices)
+	if h == nil {
+		t.Fatal("expected health on the second sample")
+	}
+	want := graph.Health{Status: graph.HealthOK, RxErrors: 0.5, RxDropped: 1, RxCRCErrors: 0.5}
+	if *h != want {
+		t.Errorf("got %+v, want %+v", *h, want)
+	}
+
+	now = now.Add(10 * time.Second)
+	writeCounters(t, root, map[string]string{
+		"net/ens1f0/statistics/rx_errors":                 "0",
+		"net/ens1f0/carrier_changes":                      "4",
+		"infiniband/mlx5_0/ports/1/counters/symbol_error": "57",
+	})
+	h = s.Sample("ens1f0", devices)
+	want = graph.Health{Status: graph.HealthDegraded, RDMAErrors: 5, CarrierChanges: 2}
+	if h == nil || *h != want {
+		t.Errorf("got %+v, want %+v", h, want)
+	}
+	if got := h.Summary(); got != "rdma errors 5.00/s, 2 carrier changes" {
+		t.Errorf("unexpected summary %q", got)
+	}
+
+	if h := s.Sample("eth9", nil); h != nil {
+		t.Errorf("expected no health for an interface without counters, got %+v", h)
+	}
+}
@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"path/filepath"
+)
+
+// HostFS locates the host's /sys, /proc and /etc below a root directory. The
+// root is "/" when running on the host; an agent in a container reads the host
+// filesystem mounted elsewhere, e.g. at /host. Tests point it at fixture trees.
+type HostFS struct {
+	Root string
+}
+
+// host is the filesystem inspected by the discovery package
+var host = HostFS{Root: "/"}
+
+// SetHostRoot makes the discovery package read /sys, /proc and /etc below
+// root. An empty root means "/". It must be called before discovery starts.
+func SetHostRoot(root string) {
+	if root == "" {
+		root = "/"
+	}
+	host = HostFS{Root: root}
+}
+
+// Host returns the filesystem inspected by the discovery package
+func Host() HostFS {
+	return host
+}
+
+// Sys returns a path below the host's /sys
+func (h HostFS) Sys(elem ...string) string {
+	return h.path("sys", elem)
+}
+
+// Proc returns a path below the host's /proc
+func (h HostFS) Proc(elem ...string) string {
+	return h.path("proc", elem)
+}
+
+// Etc returns a path below the host's /etc
+func (h HostFS) Etc(elem ...string) string {
+	return h.path("etc", elem)
+}
+
+// ClassNet returns /sys/class/net/<elem...>, where network interfaces live
+func (h HostFS) ClassNet(elem ...string) string {
+	return h.Sys(append([]string{"class", "net"}, elem...)...)
+}
+
+// ClassInfiniband returns /sys/class/infiniband/<elem...>, where RDMA devices live
+func (h HostFS) ClassInfiniband(elem ...string) string {
+	return h.Sys(append([]string{"class", "infiniband"}, elem...)...)
+}
+
+func (h HostFS) path(dir string, elem []string) string {
+	return filepath.Join(append([]string{h.Root, dir}, elem...)...)
+}
@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// useHostFixture points the package at a fixture tree in testdata/hostfs. The
+// trees are reduced copies of real machines, keeping the sysfs symlink layout:
+// class entries link to devices, and devices link back to their PCI function.
+func useHostFixture(t *testing.T, name string) {
+	t.Helper()
+	SetHostRoot(filepath.Join("testdata", "hostfs", name))
+	t.Cleanup(func() { SetHostRoot("") })
+}
+
+func TestHostFSPaths(t *testing.T) {
+	SetHostRoot("")
+	if got := Host().ClassNet("eth0", "speed"); got != "/sys/class/net/eth0/speed" {
+		t.Errorf("unexpected path %q", got)
+	}
+
+	SetHostRoot("/host")
+	t.Cleanup(func() { SetHostRoot("") })
+	for got, want := range map[string]string{
+		Host().ClassInfiniband("mlx5_0"): "/host/sys/class/infiniband/mlx5_0",
+		Host().Proc("net", "igmp6"):      "/host/proc/net/igmp6",
+		Host().Etc("machine-id"):         "/host/etc/machine-id",
+	} {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestHostFixtureRoCE(t *testing.T) {
+	useHostFixture(t, "roce")
+
+	if id, err := ReadMachineID(); err != nil || id != "0f6c4c2b9e8a4d1f8c3b2a1908f7e6d5" {
+		t.Errorf("ReadMachineID() = %q, %v", id, err)
+	}
+	if got := getRDMADevicesForInterface("ens1f1"); !reflect.DeepEqual(got, []string{"mlx5_1"}) {
+		t.Errorf("unexpected devices for ens1f1: %v", got)
+	}
+	if got := getRDMADevicesForInterface("eno1"); len(got) != 0 {
+		t.Errorf("expected no devices for eno1, got %v", got)
+	}
+	if got := getLinkSpeed("ens1f0"); got != 100000 {
+		t.Errorf("getLinkSpeed(ens1f0) = %d", got)
+	}
+
+	devices, err := GetRDMADeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %+v", devices)
+	}
+	for i, d := range devices {
+		if d.SysImageGUID != "0c42:a103:0003:0001" {
+			t.Errorf("%s: unexpected system image GUID %q", d.Name, d.SysImageGUID)
+		}
+		if want := []string{"ens1f0", "ens1f1"}[i]; len(d.Parents) != 1 || d.Parents[0] != want {
+			t.Errorf("%s: parents %v, want %s", d.Name, d.Parents, want)
+		}
+		if len(d.Ports) != 1 || d.Ports[0].LinkLayer != "Ethernet" || d.Ports[0].ActiveMTU != 4096 || len(d.Ports[0].GIDs) != 2 {
+			t.Errorf("%s: unexpected ports %+v", d.Name, d.Ports)
+		}
+	}
+	if devices[1].NodeGUID != "0c42:a103:0003:0002" {
+		t.Errorf("unexpected node GUID %q", devices[1].NodeGUID)
+	}
+
+	ports := getRDMAPortsForInterface("mlx5_1", "ens1f1")
+	if len(ports) != 1 || ports[0].GIDs[0].Netdev != "ens1f1" || ports[0].GIDs[1].Type != "RoCE v2" {
+		t.Errorf("unexpected ports for ens1f1: %+v", ports)
+	}
+}
+
+func TestHostFixtureInfiniBand(t *testing.T) {
+	useHostFixture(t, "infiniband")
+
+	// The child interface shares the PCI function of its parent
+	for _, iface := range []string{"ib0", "ib0.8001"} {
+		if got := getRDMADevicesForInterface(iface); !reflect.DeepEqual(got, []string{"mlx5_0"}) {
+			t.Errorf("unexpected devices for %s: %v", iface, got)
+		}
+	}
+
+	ports := getRDMAPortsForInterface("mlx5_0", "ib0")
+	if len(ports) != 1 || ports[0].LinkLayer != "InfiniBand" || ports[0].LID != "0x12" || ports[0].SMLID != "0x1" {
+		t.Fatalf("unexpected ports for ib0: %+v", ports)
+	}
+	if ports[0].ActiveMTU != 2048 {
+		t.Errorf("expected active MTU 2048 in datagram mode, got %d", ports[0].ActiveMTU)
+	}
+	if child := getRDMAPortsForInterface("mlx5_0", "ib0.8001"); len(child) != 1 || child[0].ActiveMTU != 0 {
+		t.Errorf("expected unknown active MTU in connected mode, got %+v", child)
+	}
+
+	ipoib := getIPoIB("ib0.8001", []graph.RDMADevice{{Name: "mlx5_0", Ports: ports}})
+	if ipoib == nil || ipoib.PKey != "0x8001" || ipoib.Parent != "ib0" || ipoib.Mode != "connected" || ipoib.Device != "mlx5_0" || ipoib.Port != 1 {
+		t.Errorf("unexpected IPoIB for ib0.8001: %+v", ipoib)
+	}
+}
+
+func TestHostFixtureLaptop(t *testing.T) {
+	useHostFixture(t, "laptop")
+
+	// Soft-RoCE devices are virtual and name their interface in a parent file
+	if got := getRDMADevicesForInterface("enp0s31f6"); !reflect.DeepEqual(got, []string{"rxe0"}) {
+		t.Errorf("unexpected devices for enp0s31f6: %v", got)
+	}
+	if got := getRDMADevicesForInterface("wlp0s20f3"); len(got) != 0 {
+		t.Errorf("expected no devices for wlp0s20f3, got %v", got)
+	}
+	devices, err := GetRDMADevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(devices, map[string][]string{"rxe0": {"enp0s31f6"}}) {
+		t.Errorf("unexpected devices %v", devices)
+	}
+	if got := getLinkSpeed("enp0s31f6"); got != 1000 {
+		t.Errorf("getLinkSpeed(enp0s31f6) = %d", got)
+	}
+	if getIPoIB("enp0s31f6", nil) != nil {
+		t.Error("expected no IPoIB attributes on Ethernet")
+	}
+}
+
+func TestHostFixtureSRIOV(t *testing.T) {
+	useHostFixture(t, "sriov")
+
+	// A PF in switchdev mode with two VFs and their representors
+	const switchID = "8a3b0c0003a2420c"
+	tests := map[string]*graph.SRIOV{
+		"ens1f0np0":     {Role: graph.SRIOVPF, NumVFs: 2, PhysPortID: "0c42a10300a2420c", PhysSwitchID: switchID, PhysPortName: "p0"},
+		"ens1f0v0":      {Role: graph.SRIOVVF, PF: "ens1f0np0", VF: 0, PhysPortID: "0c42a10300a2420c"},
+		"ens1f0v1":      {Role: graph.SRIOVVF, PF: "ens1f0np0", VF: 1, PhysPortID: "0c42a10300a2420c"},
+		"ens1f0npf0vf1": {Role: graph.SRIOVRepresentor, PF: "ens1f0np0", VF: 1, PhysSwitchID: switchID, PhysPortName: "pf0vf1"},
+	}
+	for iface, want := range tests {
+		if got := getSRIOV(iface); got == nil || *got != *want {
+			t.Errorf("%s: got %+v, want %+v", iface, got, want)
+		}
+	}
+
+	useHostFixture(t, "roce")
+	if got := getSRIOV("ens1f0"); got != nil {
+		t.Errorf("expected no SR-IOV attributes without VFs, got %+v", got)
+	}
+}
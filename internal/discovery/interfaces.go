@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/kad/lldiscovery/internal/graph"
 	"github.com/mdlayher/wifi"
 	"github.com/vishvananda/netlink"
 )
@@ -18,10 +19,15 @@ type InterfaceInfo struct {
 	LinkLocal      string
 	GlobalPrefixes []string // Global unicast network prefixes (e.g., "2001:db8:1::/64")
 	IsRDMA         bool
-	RDMADevice     string
+	RDMADevice     string // First of RDMADevices
 	NodeGUID       string
 	SysImageGUID   string
-	Speed          int // Link speed in Mbps
+	RDMADevices    []graph.RDMADevice // All RDMA devices carrying this interface, with their ports
+	IPoIB          *graph.IPoIB       // Partition and HCA port of IPoIB interfaces
+	Ethtool        *graph.Ethtool     // Link settings and driver
+	Stack          *graph.Stack       // VLAN, bond or bridge relationships
+	SRIOV          *graph.SRIOV       // SR-IOV role and switchdev port identity
+	Speed          int                // Link speed in Mbps
 }
 
 func GetActiveInterfaces() ([]InterfaceInfo, error) {
@@ -33,6 +39,9 @@ func GetActiveInterfaces() ([]InterfaceInfo, error) {
 	// Get RDMA device to netdev mapping
 	rdmaMap := getRDMADeviceMapping()
 
+	// Get VLAN, bond and bridge relationships
+	stacks := getInterfaceStacks()
+
 	var result []InterfaceInfo
 
 	for _, iface := range interfaces {
@@ -103,16 +112,28 @@ func GetActiveInterfaces() ([]InterfaceInfo, error) {
 				GlobalPrefixes: globalPrefixes,
 			}
 
-			// Check if this interface has an RDMA device
-			if rdmaDevice, ok := rdmaMap[iface.Name]; ok {
+			// Check if this interface has RDMA devices
+			for _, rdmaDevice := range rdmaMap[iface.Name] {
+				info.RDMADevices = append(info.RDMADevices, graph.RDMADevice{
+					Name:         rdmaDevice,
+					NodeGUID:     getRDMANodeGUID(rdmaDevice),
+					SysImageGUID: getRDMASysImageGUID(rdmaDevice),
+					Ports:        getRDMAPortsForInterface(rdmaDevice, iface.Name),
+				})
+			}
+			if len(info.RDMADevices) > 0 {
 				info.IsRDMA = true
-				info.RDMADevice = rdmaDevice
-				info.NodeGUID = getRDMANodeGUID(rdmaDevice)
-				info.SysImageGUID = getRDMASysImageGUID(rdmaDevice)
+				info.RDMADevice = info.RDMADevices[0].Name
+				info.NodeGUID = info.RDMADevices[0].NodeGUID
+				info.SysImageGUID = info.RDMADevices[0].SysImageGUID
 			}
+			info.IPoIB = getIPoIB(iface.Name, info.RDMADevices)
 
 			// Get link speed
 			info.Speed = getLinkSpeed(iface.Name)
+			info.Ethtool = getEthtool(iface.Name)
+			info.Stack = stacks[iface.Name]
+			info.SRIOV = getSRIOV(iface.Name)
 
 			result = append(result, info)
 		}
@@ -154,10 +175,10 @@ func ParseSourceAddr(addr string) string {
 	return addr
 }
 
-// getRDMADeviceMapping returns a map of network interface name to RDMA device name
+// getRDMADeviceMapping returns a map of network interface name to RDMA device names
 // Uses netlink to get the parent interface for RDMA devices
-func getRDMADeviceMapping() map[string]string {
-	result := make(map[string]string)
+func getRDMADeviceMapping() map[string][]string {
+	result := make(map[string][]string)
 
 	// Use netlink to list all links
 	links, err := netlink.LinkList()
@@ -172,33 +193,36 @@ func getRDMADeviceMapping() map[string]string {
 			continue
 		}
 
-		// Check if this interface has an associated RDMA device
-		rdmaDevice := getRDMADeviceForInterface(attrs.Name)
-		if rdmaDevice != "" {
-			result[attrs.Name] = rdmaDevice
+		// Check if this interface has associated RDMA devices
+		if rdmaDevices := getRDMADevicesForInterface(attrs.Name); len(rdmaDevices) > 0 {
+			result[attrs.Name] = rdmaDevices
 		}
 	}
 
 	return result
 }
 
-// getRDMADeviceForInterface finds the RDMA device associated with a network interface
-func getRDMADeviceForInterface(ifaceName string) string {
+// getRDMADevicesForInterface finds the RDMA devices associated with a network
+// interface, sorted by name. A PCI function may expose several RDMA devices.
+func getRDMADevicesForInterface(ifaceName string) []string {
 	// First try hardware RDMA: /sys/class/net/<ifaceName>/device/infiniband/
-	devicePath := fmt.Sprintf("/sys/class/net/%s/device/infiniband", ifaceName)
-	entries, err := os.ReadDir(devicePath)
+	entries, err := os.ReadDir(host.ClassNet(ifaceName, "device", "infiniband"))
 	if err == nil && len(entries) > 0 {
-		// Return the first RDMA device found
-		return entries[0].Name()
+		devices := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			devices = append(devices, entry.Name())
+		}
+		return devices
 	}
 
-	// For software RDMA (RXE), check if any RDMA device has this interface as parent
-	ibPath := "/sys/class/infiniband"
+	// For software RDMA (RXE), check which RDMA devices have this interface as parent
+	ibPath := host.ClassInfiniband()
 	ibEntries, err := os.ReadDir(ibPath)
 	if err != nil {
-		return ""
+		return nil
 	}
 
+	var devices []string
 	for _, entry := range ibEntries {
 		// Follow symlinks - RXE devices are symlinks in /sys/class/infiniband/
 		entryPath := filepath.Join(ibPath, entry.Name())
@@ -213,17 +237,17 @@ func getRDMADeviceForInterface(ifaceName string) string {
 		if err == nil {
 			parent := strings.TrimSpace(string(parentData))
 			if parent == ifaceName {
-				return entry.Name()
+				devices = append(devices, entry.Name())
 			}
 		}
 	}
 
-	return ""
+	return devices
 }
 
 // getRDMANodeGUID reads the node GUID for an RDMA device
 func getRDMANodeGUID(rdmaDevice string) string {
-	data, err := os.ReadFile(fmt.Sprintf("/sys/class/infiniband/%s/node_guid", rdmaDevice))
+	data, err := os.ReadFile(host.ClassInfiniband(rdmaDevice, "node_guid"))
 	if err != nil {
 		return ""
 	}
@@ -232,7 +256,7 @@ func getRDMANodeGUID(rdmaDevice string) string {
 
 // getRDMASysImageGUID reads the system image GUID for an RDMA device
 func getRDMASysImageGUID(rdmaDevice string) string {
-	data, err := os.ReadFile(fmt.Sprintf("/sys/class/infiniband/%s/sys_image_guid", rdmaDevice))
+	data, err := os.ReadFile(host.ClassInfiniband(rdmaDevice, "sys_image_guid"))
 	if err != nil {
 		return ""
 	}
@@ -244,8 +268,7 @@ func getRDMASysImageGUID(rdmaDevice string) string {
 // Returns 0 if speed cannot be determined
 func getLinkSpeed(ifaceName string) int {
 	// First try sysfs
-	speedPath := fmt.Sprintf("/sys/class/net/%s/speed", ifaceName)
-	data, err := os.ReadFile(speedPath)
+	data, err := os.ReadFile(host.ClassNet(ifaceName, "speed"))
 	if err == nil {
 		speedStr := strings.TrimSpace(string(data))
 		var speed int
@@ -411,7 +434,7 @@ func GetRDMADevices() (map[string][]string, error) {
 	rdmaDevices := make(map[string][]string)
 
 	// List all RDMA devices in /sys/class/infiniband/
-	ibPath := "/sys/class/infiniband"
+	ibPath := host.ClassInfiniband()
 	entries, err := os.ReadDir(ibPath)
 	if err != nil {
 		// No RDMA devices present
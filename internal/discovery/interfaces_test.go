@@ -95,11 +95,11 @@ func TestParseSourceAddr(t *testing.T) {
 	}
 }
 
-func TestGetRDMADeviceForInterface_NotExists(t *testing.T) {
+func TestGetRDMADevicesForInterface_NotExists(t *testing.T) {
 	// Test with a non-existent interface
-	result := getRDMADeviceForInterface("nonexistent-interface-xyz")
-	if result != "" {
-		t.Errorf("expected empty string for non-existent interface, got %v", result)
+	result := getRDMADevicesForInterface("nonexistent-interface-xyz")
+	if len(result) != 0 {
+		t.Errorf("expected no devices for non-existent interface, got %v", result)
 	}
 }
 
@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"path/filepath"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// ARPHRDInfiniband is the ARP hardware type of IPoIB interfaces
+// (ARPHRD_INFINIBAND), as read from /sys/class/net/<iface>/type
+const ARPHRDInfiniband = "32"
+
+// getIPoIB returns the IPoIB attributes of an interface, nil if it is not an
+// IPoIB interface. The HCA and port are taken from the interface's RDMA
+// devices when the interface runs on a single port.
+func getIPoIB(ifaceName string, devices []graph.RDMADevice) *graph.IPoIB {
+	return readIPoIB(host.ClassNet(), ifaceName, devices)
+}
+
+// readIPoIB reads the IPoIB attributes of an interface below netPath
+func readIPoIB(netPath, ifaceName string, devices []graph.RDMADevice) *graph.IPoIB {
+	dir := filepath.Join(netPath, ifaceName)
+	if readSysfsValue(filepath.Join(dir, "type")) != ARPHRDInfiniband {
+		return nil
+	}
+
+	pkey := graph.NormalizePKey(readSysfsValue(filepath.Join(dir, "pkey")))
+	if pkey == "" {
+		return nil
+	}
+
+	ipoib := &graph.IPoIB{
+		PKey:   pkey,
+		Parent: readSysfsValue(filepath.Join(dir, "parent")),
+		Mode:   readSysfsValue(filepath.Join(dir, "mode")),
+	}
+	if len(devices) > 0 {
+		ipoib.Device = devices[0].Name
+		if len(devices[0].Ports) == 1 {
+			ipoib.Port = devices[0].Ports[0].Port
+		}
+	}
+	return ipoib
+}
@@ -0,0 +1,46 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func TestReadIPoIB(t *testing.T) {
+	net := t.TempDir()
+	writeSysfs(t, net, "eth0/type", "1")
+	writeSysfs(t, net, "ib0/type", "32")
+	writeSysfs(t, net, "ib0/pkey", "0xffff")
+	writeSysfs(t, net, "ib0/mode", "datagram")
+	writeSysfs(t, net, "ib0.8001/type", "32")
+	writeSysfs(t, net, "ib0.8001/pkey", "0x8001")
+	writeSysfs(t, net, "ib0.8001/mode", "connected")
+	writeSysfs(t, net, "ib0.8001/parent", "ib0")
+	writeSysfs(t, net, "ib1/type", "32")
+	writeSysfs(t, net, "ib1/pkey", "0x0002") // Limited member
+
+	devices := []graph.RDMADevice{{Name: "mlx5_0", Ports: []graph.RDMAPort{{Port: 1}}}}
+
+	if got := readIPoIB(net, "eth0", nil); got != nil {
+		t.Errorf("expected nil for Ethernet, got %+v", got)
+	}
+	if got := readIPoIB(net, "missing", nil); got != nil {
+		t.Errorf("expected nil for missing interface, got %+v", got)
+	}
+
+	got := readIPoIB(net, "ib0", devices)
+	if got == nil || *got != (graph.IPoIB{PKey: "0xffff", Mode: "datagram", Device: "mlx5_0", Port: 1}) {
+		t.Errorf("unexpected ib0: %+v", got)
+	}
+	got = readIPoIB(net, "ib0.8001", devices)
+	if got == nil || *got != (graph.IPoIB{PKey: "0x8001", Parent: "ib0", Mode: "connected", Device: "mlx5_0", Port: 1}) {
+		t.Errorf("unexpected ib0.8001: %+v", got)
+	}
+
+	// Without a single port the HCA port is unknown
+	dual := []graph.RDMADevice{{Name: "mlx4_0", Ports: []graph.RDMAPort{{Port: 1}, {Port: 2}}}}
+	got = readIPoIB(net, "ib1", dual)
+	if got == nil || got.PKey != "0x8002" || got.Device != "mlx4_0" || got.Port != 0 {
+		t.Errorf("unexpected ib1: %+v", got)
+	}
+}
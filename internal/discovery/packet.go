@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
 )
 
 type NeighborInfo struct {
@@ -29,17 +31,26 @@ type NeighborInfo struct {
 }
 
 type Packet struct {
-	Hostname       string         `json:"hostname"`
-	MachineID      string         `json:"machine_id"`
-	Timestamp      int64          `json:"timestamp"`
-	Interface      string         `json:"interface"`
-	SourceIP       string         `json:"source_ip"`
-	GlobalPrefixes []string       `json:"global_prefixes,omitempty"` // Global unicast network prefixes on this interface
-	RDMADevice     string         `json:"rdma_device,omitempty"`
-	NodeGUID       string         `json:"node_guid,omitempty"`
-	SysImageGUID   string         `json:"sys_image_guid,omitempty"`
-	Speed          int            `json:"speed,omitempty"` // Link speed in Mbps
-	Neighbors      []NeighborInfo `json:"neighbors,omitempty"`
+	Hostname       string             `json:"hostname"`
+	MachineID      string             `json:"machine_id"`
+	Timestamp      int64              `json:"timestamp"`
+	Sequence       uint64             `json:"seq,omitempty"`         // Per-interface packet counter, starting at 1
+	IntervalMs     int64              `json:"interval_ms,omitempty"` // Send interval of the sender
+	Interface      string             `json:"interface"`
+	SourceIP       string             `json:"source_ip"`
+	GlobalPrefixes []string           `json:"global_prefixes,omitempty"` // Global unicast network prefixes on this interface
+	RDMADevice     string             `json:"rdma_device,omitempty"`
+	NodeGUID       string             `json:"node_guid,omitempty"`
+	SysImageGUID   string             `json:"sys_image_guid,omitempty"`
+	RDMADevices    []graph.RDMADevice `json:"rdma_devices,omitempty"` // All RDMA devices with ports; rdma_device and the GUIDs repeat the first
+	IPoIB          *graph.IPoIB       `json:"ipoib,omitempty"`        // Partition and HCA port of IPoIB interfaces
+	Ethtool        *graph.Ethtool     `json:"ethtool,omitempty"`      // Link settings and driver of the sending interface
+	Stack          *graph.Stack       `json:"stack,omitempty"`        // VLAN, bond or bridge relationships of the sending interface
+	SRIOV          *graph.SRIOV       `json:"sriov,omitempty"`        // SR-IOV role and switchdev port of the sending interface
+	Health         *graph.Health      `json:"health,omitempty"`       // Error and drop rates of the sending interface
+	Speed          int                `json:"speed,omitempty"`        // Link speed in Mbps
+	Labels         map[string]string  `json:"labels,omitempty"`       // Operator-assigned node labels
+	Neighbors      []NeighborInfo     `json:"neighbors,omitempty"`
 }
 
 func NewPacket(iface, sourceIP string) (*Packet, error) {
@@ -48,7 +59,7 @@ func NewPacket(iface, sourceIP string) (*Packet, error) {
 		hostname = "unknown"
 	}
 
-	machineID, err := readMachineID()
+	machineID, err := ReadMachineID()
 	if err != nil {
 		return nil, err
 	}
@@ -72,8 +83,9 @@ func UnmarshalPacket(data []byte) (*Packet, error) {
 	return &p, err
 }
 
-func readMachineID() (string, error) {
-	data, err := os.ReadFile("/etc/machine-id")
+// ReadMachineID returns the host's machine ID from /etc/machine-id
+func ReadMachineID() (string, error) {
+	data, err := os.ReadFile(host.Etc("machine-id"))
 	if err != nil {
 		return "", err
 	}
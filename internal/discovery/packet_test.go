@@ -46,6 +46,8 @@ func TestPacket_Unmarshal(t *testing.T) {
 		"hostname": "test-host",
 		"machine_id": "test-machine-id",
 		"timestamp": 1234567890,
+		"seq": 42,
+		"interval_ms": 30000,
 		"interface": "eth0",
 		"source_ip": "fe80::1",
 		"rdma_device": "mlx5_0",
@@ -70,6 +72,10 @@ func TestPacket_Unmarshal(t *testing.T) {
 		t.Errorf("Expected timestamp 1234567890, got %d", packet.Timestamp)
 	}
 
+	if packet.Sequence != 42 || packet.IntervalMs != 30000 {
+		t.Errorf("Expected seq 42 every 30000ms, got %d every %dms", packet.Sequence, packet.IntervalMs)
+	}
+
 	if packet.RDMADevice != "mlx5_0" {
 		t.Errorf("Expected rdma_device mlx5_0, got %s", packet.RDMADevice)
 	}
@@ -210,6 +216,37 @@ func TestPacket_OmitEmptyRDMA(t *testing.T) {
 	}
 }
 
+func TestPacket_Labels(t *testing.T) {
+	packet := Packet{
+		Hostname:  "test-host",
+		MachineID: "test-machine-id",
+		Labels:    map[string]string{"rack": "r1", "role": "compute"},
+	}
+
+	data, err := packet.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal packet: %v", err)
+	}
+
+	decoded, err := UnmarshalPacket(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal packet: %v", err)
+	}
+	if decoded.Labels["rack"] != "r1" || decoded.Labels["role"] != "compute" {
+		t.Errorf("Expected labels to round-trip, got %v", decoded.Labels)
+	}
+
+	// Labels should be omitted when empty
+	data, _ = json.Marshal(Packet{Hostname: "test-host"})
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if _, exists := result["labels"]; exists {
+		t.Error("Expected labels to be omitted when empty")
+	}
+}
+
 func TestNeighborInfo_CompleteEdgeInformation(t *testing.T) {
 	neighbor := NeighborInfo{
 		MachineID:          "neighbor-id",
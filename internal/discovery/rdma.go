@@ -0,0 +1,225 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// RDMADeviceInfo describes an RDMA device with its parent interfaces and ports
+type RDMADeviceInfo struct {
+	Name         string           `json:"name"`
+	NodeGUID     string           `json:"node_guid,omitempty"`
+	SysImageGUID string           `json:"sys_image_guid,omitempty"`
+	NodeType     string           `json:"node_type,omitempty"` // e.g. "1: CA"
+	Parents      []string         `json:"parents"`             // Network interfaces backed by the device
+	Ports        []graph.RDMAPort `json:"ports"`
+}
+
+// IB MTUs a port can negotiate, largest first
+var ibMTUs = []int{4096, 2048, 1024, 512, 256}
+
+// roceOverhead is what the kernel subtracts from the netdev MTU before picking
+// the RoCE path MTU: GRH, UDP, BTH, XRC, AtomicETH and ICRC headers
+// (iboe_get_mtu in include/rdma/ib_addr.h)
+const roceOverhead = 40 + 8 + 12 + 4 + 28 + 4
+
+// ipoibHeader is the encapsulation header of IPoIB datagram mode
+const ipoibHeader = 4
+
+// GetRDMADeviceInfo returns every RDMA device with its attributes, sorted by name
+func GetRDMADeviceInfo() ([]RDMADeviceInfo, error) {
+	devices, err := GetRDMADevices()
+	if err != nil {
+		return nil, err
+	}
+
+	ibPath, netPath := host.ClassInfiniband(), host.ClassNet()
+	result := make([]RDMADeviceInfo, 0, len(devices))
+	for name, parents := range devices {
+		sort.Strings(parents)
+		info := RDMADeviceInfo{
+			Name:         name,
+			NodeGUID:     getRDMANodeGUID(name),
+			SysImageGUID: getRDMASysImageGUID(name),
+			NodeType:     readSysfsValue(filepath.Join(ibPath, name, "node_type")),
+			Parents:      parents,
+			Ports:        readRDMAPorts(ibPath, name),
+		}
+		for i := range info.Ports {
+			port := &info.Ports[i]
+			port.ActiveMTU = estimateActiveMTU(netPath, portNetdev(*port, parents), port.LinkLayer)
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// getRDMAPortsForInterface returns the ports of an RDMA device that carry the
+// given interface. RoCE ports are matched by the netdev of their GID entries,
+// InfiniBand ports by the interface's dev_port. If neither identifies a port,
+// all ports of the device are returned.
+func getRDMAPortsForInterface(rdmaDevice, ifaceName string) []graph.RDMAPort {
+	ports := readRDMAPorts(host.ClassInfiniband(), rdmaDevice)
+	for i := range ports {
+		ports[i].ActiveMTU = estimateActiveMTU(host.ClassNet(), ifaceName, ports[i].LinkLayer)
+	}
+	return selectInterfacePorts(ports, ifaceName, readSysfsValue(host.ClassNet(ifaceName, "dev_port")))
+}
+
+// portNetdev returns the network device of a port: the netdev of its GIDs,
+// else the first parent interface of the device
+func portNetdev(port graph.RDMAPort, parents []string) string {
+	for _, gid := range port.GIDs {
+		if gid.Netdev != "" {
+			return gid.Netdev
+		}
+	}
+	if len(parents) > 0 {
+		return parents[0]
+	}
+	return ""
+}
+
+// selectInterfacePorts picks the ports of the interface from all device ports
+func selectInterfacePorts(ports []graph.RDMAPort, ifaceName, devPort string) []graph.RDMAPort {
+	var matched []graph.RDMAPort
+	for _, port := range ports {
+		for _, gid := range port.GIDs {
+			if gid.Netdev == ifaceName {
+				matched = append(matched, port)
+				break
+			}
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+
+	// dev_port is zero-based, RDMA port numbers start at 1
+	if n, err := strconv.Atoi(devPort); err == nil && len(ports) > 1 {
+		for _, port := range ports {
+			if port.Port == n+1 {
+				return []graph.RDMAPort{port}
+			}
+		}
+	}
+	return ports
+}
+
+// readRDMAPorts reads the ports of an RDMA device below ibPath, sorted by port
+// number. The GID tables only list populated entries.
+func readRDMAPorts(ibPath, rdmaDevice string) []graph.RDMAPort {
+	portsPath := filepath.Join(ibPath, rdmaDevice, "ports")
+	entries, err := os.ReadDir(portsPath)
+	if err != nil {
+		return nil
+	}
+
+	var ports []graph.RDMAPort
+	for _, entry := range entries {
+		n, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(portsPath, entry.Name())
+		ports = append(ports, graph.RDMAPort{
+			Port:      n,
+			State:     StripEnumPrefix(readSysfsValue(filepath.Join(dir, "state"))),
+			PhysState: StripEnumPrefix(readSysfsValue(filepath.Join(dir, "phys_state"))),
+			LinkLayer: readSysfsValue(filepath.Join(dir, "link_layer")),
+			Rate:      readSysfsValue(filepath.Join(dir, "rate")),
+			LID:       readSysfsValue(filepath.Join(dir, "lid")),
+			SMLID:     readSysfsValue(filepath.Join(dir, "sm_lid")),
+			GIDs:      readGIDs(dir),
+		})
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].Port < ports[j].Port
+	})
+	return ports
+}
+
+// readGIDs reads the non-zero entries of a port's GID table with their types
+// and, for RoCE, network devices
+func readGIDs(portDir string) []graph.RDMAGID {
+	entries, err := os.ReadDir(filepath.Join(portDir, "gids"))
+	if err != nil {
+		return nil
+	}
+
+	var gids []graph.RDMAGID
+	for _, entry := range entries {
+		index, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		gid := readSysfsValue(filepath.Join(portDir, "gids", entry.Name()))
+		if gid == "" || strings.Trim(gid, "0:") == "" {
+			continue
+		}
+		gids = append(gids, graph.RDMAGID{
+			Index:  index,
+			GID:    gid,
+			Type:   readSysfsValue(filepath.Join(portDir, "gid_attrs", "types", entry.Name())),
+			Netdev: readSysfsValue(filepath.Join(portDir, "gid_attrs", "ndevs", entry.Name())),
+		})
+	}
+	sort.Slice(gids, func(i, j int) bool {
+		return gids[i].Index < gids[j].Index
+	})
+	return gids
+}
+
+// estimateActiveMTU derives the active MTU of a port from the MTU of its
+// network device, the way the kernel does for RoCE and IPoIB datagram mode.
+// sysfs does not expose the active MTU, and IPoIB connected mode hides it.
+func estimateActiveMTU(netPath, ifaceName, linkLayer string) int {
+	mtu, err := strconv.Atoi(readSysfsValue(filepath.Join(netPath, ifaceName, "mtu")))
+	if err != nil {
+		return 0
+	}
+
+	switch linkLayer {
+	case "Ethernet":
+		mtu -= roceOverhead
+	case "InfiniBand":
+		if readSysfsValue(filepath.Join(netPath, ifaceName, "mode")) == "connected" {
+			return 0
+		}
+		mtu += ipoibHeader
+	default:
+		return 0
+	}
+
+	for _, ibMTU := range ibMTUs {
+		if mtu >= ibMTU {
+			return ibMTU
+		}
+	}
+	return 0
+}
+
+// StripEnumPrefix turns sysfs enum values such as "4: ACTIVE" into "ACTIVE"
+func StripEnumPrefix(value string) string {
+	if _, name, ok := strings.Cut(value, ": "); ok {
+		return name
+	}
+	return value
+}
+
+// readSysfsValue reads a trimmed sysfs attribute, "" if it cannot be read
+func readSysfsValue(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
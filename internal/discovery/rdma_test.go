@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// writeSysfs creates root/path with content, creating parent directories
+func writeSysfs(t *testing.T, root, path, content string) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadRDMAPorts(t *testing.T) {
+	ib := t.TempDir()
+
+	// mlx5_0: dual-port RoCE device, port 2 down
+	writeSysfs(t, ib, "mlx5_0/ports/1/state", "4: ACTIVE")
+	writeSysfs(t, ib, "mlx5_0/ports/1/phys_state", "5: LinkUp")
+	writeSysfs(t, ib, "mlx5_0/ports/1/link_layer", "Ethernet")
+	writeSysfs(t, ib, "mlx5_0/ports/1/rate", "100 Gb/sec (2X HDR)")
+	writeSysfs(t, ib, "mlx5_0/ports/1/lid", "0x0")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gids/0", "fe80:0000:0000:0000:0202:c9ff:fe00:0001")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gids/1", "fe80:0000:0000:0000:0202:c9ff:fe00:0001")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gids/2", "0000:0000:0000:0000:0000:0000:0000:0000")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gid_attrs/types/0", "IB/RoCE v1")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gid_attrs/types/1", "RoCE v2")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gid_attrs/ndevs/0", "eth2")
+	writeSysfs(t, ib, "mlx5_0/ports/1/gid_attrs/ndevs/1", "eth2")
+	writeSysfs(t, ib, "mlx5_0/ports/2/state", "1: DOWN")
+	writeSysfs(t, ib, "mlx5_0/ports/2/link_layer", "Ethernet")
+	writeSysfs(t, ib, "mlx5_0/ports/2/gids/0", "0000:0000:0000:0000:0000:0000:0000:0000")
+
+	ports := readRDMAPorts(ib, "mlx5_0")
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 ports, got %+v", ports)
+	}
+	p := ports[0]
+	if p.Port != 1 || p.State != "ACTIVE" || p.PhysState != "LinkUp" || p.LinkLayer != "Ethernet" || p.Rate != "100 Gb/sec (2X HDR)" || p.LID != "0x0" {
+		t.Errorf("unexpected port 1: %+v", p)
+	}
+	if len(p.GIDs) != 2 || p.GIDs[1].Type != "RoCE v2" || p.GIDs[1].Netdev != "eth2" {
+		t.Errorf("expected two populated GIDs, got %+v", p.GIDs)
+	}
+	if ports[1].State != "DOWN" || len(ports[1].GIDs) != 0 {
+		t.Errorf("unexpected port 2: %+v", ports[1])
+	}
+
+	if ports := readRDMAPorts(ib, "missing"); ports != nil {
+		t.Errorf("expected no ports for missing device, got %+v", ports)
+	}
+}
+
+func TestSelectInterfacePorts(t *testing.T) {
+	ports := []graph.RDMAPort{
+		{Port: 1, GIDs: []graph.RDMAGID{{Index: 0, GID: "fe80::1", Netdev: "eth2"}}},
+		{Port: 2, GIDs: []graph.RDMAGID{{Index: 0, GID: "fe80::2", Netdev: "eth3"}}},
+	}
+	if got := selectInterfacePorts(ports, "eth3", ""); len(got) != 1 || got[0].Port != 2 {
+		t.Errorf("expected port 2 by GID netdev, got %+v", got)
+	}
+
+	// InfiniBand GIDs have no netdev; dev_port selects the port
+	ib := []graph.RDMAPort{{Port: 1}, {Port: 2}}
+	if got := selectInterfacePorts(ib, "ib1", "1"); len(got) != 1 || got[0].Port != 2 {
+		t.Errorf("expected port 2 by dev_port, got %+v", got)
+	}
+	if got := selectInterfacePorts(ib, "ib0", ""); len(got) != 2 {
+		t.Errorf("expected all ports without a match, got %+v", got)
+	}
+}
+
+func TestEstimateActiveMTU(t *testing.T) {
+	net := t.TempDir()
+	writeSysfs(t, net, "eth0/mtu", "1500")
+	writeSysfs(t, net, "eth1/mtu", "9000")
+	writeSysfs(t, net, "ib0/mtu", "2044")
+	writeSysfs(t, net, "ib0/mode", "datagram")
+	writeSysfs(t, net, "ib1/mtu", "4092")
+	writeSysfs(t, net, "ib2/mtu", "65520")
+	writeSysfs(t, net, "ib2/mode", "connected")
+
+	tests := []struct {
+		iface, linkLayer string
+		want             int
+	}{
+		{"eth0", "Ethernet", 1024},
+		{"eth1", "Ethernet", 4096},
+		{"ib0", "InfiniBand", 2048},
+		{"ib1", "InfiniBand", 4096},
+		{"ib2", "InfiniBand", 0},
+		{"eth0", "", 0},
+		{"missing", "Ethernet", 0},
+	}
+	for _, tt := range tests {
+		if got := estimateActiveMTU(net, tt.iface, tt.linkLayer); got != tt.want {
+			t.Errorf("estimateActiveMTU(%s, %s) = %d, want %d", tt.iface, tt.linkLayer, got, tt.want)
+		}
+	}
+}
@@ -28,7 +28,7 @@ type Receiver struct {
 }
 
 func NewReceiver(multicastAddr string, port int, logger *slog.Logger, handler PacketHandler, packetsReceived, multicastFailures metric.Int64Counter) (*Receiver, error) {
-	machineID, err := readMachineID()
+	machineID, err := ReadMachineID()
 	if err != nil {
 		return nil, fmt.Errorf("read machine-id: %w", err)
 	}
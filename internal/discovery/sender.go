@@ -29,6 +29,10 @@ type Sender struct {
 	errors           metric.Int64Counter
 	includeNeighbors bool
 	neighborProvider NeighborProvider
+	labels           map[string]string
+	health           *HealthSampler
+	healthHandler    HealthHandler
+	sequence         map[string]uint64 // Last sequence number sent per interface
 }
 
 func NewSender(multicastAddr string, port int, interval time.Duration, logger *slog.Logger, packetsSent, errors metric.Int64Counter, includeNeighbors bool, neighborProvider NeighborProvider) *Sender {
@@ -42,13 +46,31 @@ func NewSender(multicastAddr string, port int, interval time.Duration, logger *s
 		errors:           errors,
 		includeNeighbors: includeNeighbors,
 		neighborProvider: neighborProvider,
+		sequence:         make(map[string]uint64),
 	}
 }
 
+// SetLabels sets the node labels advertised in discovery packets.
+// Must be called before Run.
+func (s *Sender) SetLabels(labels map[string]string) {
+	s.labels = labels
+}
+
+// SetHealth enables sampling of interface error and drop counters. The health
+// of each interface is advertised in its packets and passed to handler.
+// Must be called before Run.
+func (s *Sender) SetHealth(thresholds HealthThresholds, handler HealthHandler) {
+	s.health = NewHealthSampler(thresholds)
+	s.healthHandler = handler
+}
+
 func (s *Sender) Run(ctx context.Context) error {
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
 
+	openEthtool()
+	defer closeEthtool()
+
 	s.sendDiscovery()
 
 	for {
@@ -77,6 +99,7 @@ func (s *Sender) sendDiscovery() {
 	}
 
 	span.SetAttributes(attribute.Int("interface_count", len(interfaces)))
+	s.pruneSequences(interfaces)
 
 	for _, iface := range interfaces {
 		if err := s.sendOnInterface(ctx, iface); err != nil {
@@ -93,6 +116,21 @@ func (s *Sender) sendDiscovery() {
 	}
 }
 
+// pruneSequences forgets the sequence numbers of interfaces that are no longer
+// active. An interface that returns starts over at 1, which receivers take
+// as a restart.
+func (s *Sender) pruneSequences(interfaces []InterfaceInfo) {
+	active := make(map[string]bool, len(interfaces))
+	for _, iface := range interfaces {
+		active[iface.Name] = true
+	}
+	for name := range s.sequence {
+		if !active[name] {
+			delete(s.sequence, name)
+		}
+	}
+}
+
 func (s *Sender) sendOnInterface(ctx context.Context, iface InterfaceInfo) error {
 	ctx, span := s.tracer.Start(ctx, "send_on_interface",
 		trace.WithAttributes(
@@ -113,6 +151,24 @@ func (s *Sender) sendOnInterface(ctx context.Context, iface InterfaceInfo) error
 		packet.RDMADevice = iface.RDMADevice
 		packet.NodeGUID = iface.NodeGUID
 		packet.SysImageGUID = iface.SysImageGUID
+		packet.RDMADevices = iface.RDMADevices
+	}
+	packet.IPoIB = iface.IPoIB
+	packet.Ethtool = iface.Ethtool
+	packet.Stack = iface.Stack
+	packet.SRIOV = iface.SRIOV
+
+	// Number packets per interface so receivers can count losses
+	s.sequence[iface.Name]++
+	packet.Sequence = s.sequence[iface.Name]
+	packet.IntervalMs = s.interval.Milliseconds()
+
+	// Add error and drop rates since the previous packet if enabled
+	if s.health != nil {
+		packet.Health = s.health.Sample(iface.Name, iface.RDMADevices)
+		if s.healthHandler != nil {
+			s.healthHandler(iface.Name, packet.Health)
+		}
 	}
 
 	// Add link speed if available
@@ -121,6 +177,9 @@ func (s *Sender) sendOnInterface(ctx context.Context, iface InterfaceInfo) error
 	// Add global unicast prefixes if available
 	packet.GlobalPrefixes = iface.GlobalPrefixes
 
+	// Add node labels if configured
+	packet.Labels = s.labels
+
 	// Add neighbors if enabled
 	if s.includeNeighbors && s.neighborProvider != nil {
 		neighbors := s.neighborProvider.GetDirectNeighbors()
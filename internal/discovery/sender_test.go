@@ -0,0 +1,21 @@
+package discovery
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSenderPruneSequences(t *testing.T) {
+	s := NewSender("ff02::4c4c:6469", 9999, time.Second, slog.Default(), nil, nil, false, nil)
+	s.sequence["eth0"] = 5
+	s.sequence["eth1"] = 3
+
+	s.pruneSequences([]InterfaceInfo{{Name: "eth0"}})
+	if s.sequence["eth0"] != 5 {
+		t.Errorf("expected the sequence of an active interface to be kept, got %d", s.sequence["eth0"])
+	}
+	if _, ok := s.sequence["eth1"]; ok {
+		t.Error("expected the sequence of a vanished interface to be forgotten")
+	}
+}
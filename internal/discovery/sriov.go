@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// representorPortName matches the phys_port_name of a VF representor in
+// switchdev mode, "pf0vf3", or "c1pf0vf3" on SmartNICs with several controllers
+var representorPortName = regexp.MustCompile(`^(?:c\d+)?pf(\d+)vf(\d+)$`)
+
+// getSRIOV returns the SR-IOV role and switchdev port identity of an
+// interface, nil if it has neither
+func getSRIOV(ifaceName string) *graph.SRIOV {
+	return readSRIOV(host.ClassNet(), ifaceName)
+}
+
+// readSRIOV reads the SR-IOV attributes of an interface below netPath. The
+// phys_* files fail to read for drivers without switchdev support. A VF links
+// to its PF's PCI function with device/physfn, a PF lists its VFs as
+// device/virtfn<n>. Representors share the PCI function of their uplink and
+// are recognised by their port name.
+func readSRIOV(netPath, ifaceName string) *graph.SRIOV {
+	dir := filepath.Join(netPath, ifaceName)
+	device := filepath.Join(dir, "device")
+	sriov := &graph.SRIOV{
+		PhysPortID:   readSysfsValue(filepath.Join(dir, "phys_port_id")),
+		PhysSwitchID: readSysfsValue(filepath.Join(dir, "phys_switch_id")),
+		PhysPortName: readSysfsValue(filepath.Join(dir, "phys_port_name")),
+	}
+
+	if _, err := os.Stat(filepath.Join(device, "physfn")); err == nil {
+		sriov.Role = graph.SRIOVVF
+		sriov.PF = uplinkNetdev(filepath.Join(device, "physfn", "net"))
+		sriov.VF = vfIndex(device)
+	} else if m := representorPortName.FindStringSubmatch(sriov.PhysPortName); m != nil {
+		sriov.Role = graph.SRIOVRepresentor
+		sriov.PF = uplinkNetdev(filepath.Join(device, "net"))
+		sriov.VF, _ = strconv.Atoi(m[2])
+	} else if n, _ := strconv.Atoi(readSysfsValue(filepath.Join(device, "sriov_numvfs"))); n > 0 {
+		sriov.Role = graph.SRIOVPF
+		sriov.NumVFs = n
+	}
+
+	if *sriov == (graph.SRIOV{}) {
+		return nil
+	}
+	return sriov
+}
+
+// uplinkNetdev returns the interface of a PCI function that is not a
+// representor, given the function's net directory
+func uplinkNetdev(netDir string) string {
+	entries, err := os.ReadDir(netDir)
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		portName := readSysfsValue(filepath.Join(netDir, name, "phys_port_name"))
+		if !representorPortName.MatchString(portName) {
+			return name
+		}
+	}
+	return ""
+}
+
+// vfIndex returns the index of a VF among the virtfn<n> links of its PF, 0 if
+// not found
+func vfIndex(device string) int {
+	self, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return 0
+	}
+	links, _ := filepath.Glob(filepath.Join(device, "physfn", "virtfn*"))
+	for _, link := range links {
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil || filepath.Base(target) != filepath.Base(self) {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(link), "virtfn")); err == nil {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"sort"
+
+	"github.com/kad/lldiscovery/internal/graph"
+	"github.com/vishvananda/netlink"
+)
+
+// getInterfaceStacks returns the VLAN, bond and bridge relationships of the
+// host's interfaces by name, read with netlink
+func getInterfaceStacks() map[string]*graph.Stack {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil
+	}
+	return buildStacks(links)
+}
+
+// buildStacks derives the stacks of a list of links. Interfaces that are
+// neither a VLAN, bond or bridge nor a member of one are omitted.
+func buildStacks(links []netlink.Link) map[string]*graph.Stack {
+	byIndex := make(map[int]netlink.Link, len(links))
+	for _, link := range links {
+		byIndex[link.Attrs().Index] = link
+	}
+
+	stacks := make(map[string]*graph.Stack)
+	stack := func(name string) *graph.Stack {
+		if stacks[name] == nil {
+			stacks[name] = &graph.Stack{}
+		}
+		return stacks[name]
+	}
+
+	for _, link := range links {
+		attrs := link.Attrs()
+		switch l := link.(type) {
+		case *netlink.Vlan:
+			s := stack(attrs.Name)
+			s.Kind = graph.StackVLAN
+			s.VLANID = l.VlanId
+			// The parent may be in another network namespace
+			if parent, ok := byIndex[attrs.ParentIndex]; ok {
+				s.Parent = parent.Attrs().Name
+			}
+		case *netlink.Bond:
+			s := stack(attrs.Name)
+			s.Kind = graph.StackBond
+			s.BondMode = l.Mode.String()
+		case *netlink.Bridge:
+			stack(attrs.Name).Kind = graph.StackBridge
+		}
+
+		// Only bond and bridge members; VRF and other masters are not stacks
+		master, ok := byIndex[attrs.MasterIndex]
+		if attrs.MasterIndex == 0 || !ok {
+			continue
+		}
+		switch master.(type) {
+		case *netlink.Bond, *netlink.Bridge:
+		default:
+			continue
+		}
+		stack(attrs.Name).Master = master.Attrs().Name
+		m := stack(master.Attrs().Name)
+		m.Members = append(m.Members, attrs.Name)
+	}
+
+	for _, s := range stacks {
+		sort.Strings(s.Members)
+	}
+	return stacks
+}
@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+	"github.com/vishvananda/netlink"
+)
+
+func TestBuildStacks(t *testing.T) {
+	// Two ports in an LACP bond, VLAN 100 on the bond bridged into br100,
+	// a VLAN whose parent is in another namespace and a VRF member
+	links := []netlink.Link{
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 1, Name: "lo"}},
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "ens1f1", MasterIndex: 4}},
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 3, Name: "ens1f0", MasterIndex: 4}},
+		&netlink.Bond{LinkAttrs: netlink.LinkAttrs{Index: 4, Name: "bond0"}, Mode: netlink.BOND_MODE_802_3AD},
+		&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Index: 5, Name: "bond0.100", ParentIndex: 4, MasterIndex: 6}, VlanId: 100},
+		&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Index: 6, Name: "br100"}},
+		&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Index: 7, Name: "eth0.200", ParentIndex: 42}, VlanId: 200},
+		&netlink.Vrf{LinkAttrs: netlink.LinkAttrs{Index: 8, Name: "mgmt"}, Table: 10},
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 9, Name: "eno1", MasterIndex: 8}},
+	}
+
+	want := map[string]*graph.Stack{
+		"ens1f0":    {Master: "bond0"},
+		"ens1f1":    {Master: "bond0"},
+		"bond0":     {Kind: graph.StackBond, BondMode: "802.3ad", Members: []string{"ens1f0", "ens1f1"}},
+		"bond0.100": {Kind: graph.StackVLAN, Parent: "bond0", VLANID: 100, Master: "br100"},
+		"br100":     {Kind: graph.StackBridge, Members: []string{"bond0.100"}},
+		"eth0.200":  {Kind: graph.StackVLAN, VLANID: 200},
+	}
+	got := buildStacks(links)
+	if !reflect.DeepEqual(got, want) {
+		for name, s := range got {
+			t.Logf("%s: %+v", name, *s)
+		}
+		t.Errorf("unexpected stacks")
+	}
+}
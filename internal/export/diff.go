@@ -0,0 +1,136 @@
+package export
+
+import (
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/diff"
+)
+
+// GenerateDiffDOT draws the union of two snapshots with the usual conventions,
+// coloring added elements green, removed elements red and changed elements
+// orange. Segments are drawn if either snapshot has them.
+func GenerateDiffDOT(old, new *api.Graph) string {
+	report := diff.Compare(old, new)
+	union := unionDocument(old, new)
+	nodes, edges := api.ToGraph(union)
+	segments := api.ToSegments(union, edges)
+
+	sc := buildScene(nodes, edges, segments)
+	sc.legend = []string{"Diff: green = added, red = removed, orange = changed"}
+
+	nodeKinds := report.Kinds(diff.TypeNode)
+	ifaceKinds := report.Kinds(diff.TypeInterface)
+	for i := range sc.machines {
+		m := &sc.machines[i]
+		m.diff = nodeKinds[m.id]
+		for j := range m.ifaces {
+			m.ifaces[j].diff = ifaceKinds[diff.InterfaceID(m.id, m.ifaces[j].name)]
+		}
+	}
+
+	// Scene links connect interface nodes; map them back to edge IDs
+	edgeKinds := report.Kinds(diff.TypeEdge)
+	linkKinds := make(map[string]string)
+	for _, e := range union.Edges {
+		if kind, ok := edgeKinds[e.ID]; ok {
+			from := interfaceNodeID(e.Source.NodeID, e.Source.Interface)
+			to := interfaceNodeID(e.Target.NodeID, e.Target.Interface)
+			linkKinds[from+"--"+to] = kind
+			linkKinds[to+"--"+from] = kind
+		}
+	}
+	for i := range sc.links {
+		sc.links[i].diff = linkKinds[sc.links[i].from+"--"+sc.links[i].to]
+	}
+
+	// Scene segments are in the order of segments; hub links of a changed
+	// segment are colored by whether the member joined or left
+	segmentKinds := report.Kinds(diff.TypeSegment)
+	oldMembers, newMembers := segmentMembers(old), segmentMembers(new)
+	for i := range sc.segments {
+		id := segments[i].ID
+		seg := &sc.segments[i]
+		seg.diff = segmentKinds[id]
+		for j := range seg.links {
+			link := &seg.links[j]
+			switch {
+			case seg.diff != diff.Changed:
+				link.diff = seg.diff
+			case !oldMembers[id][link.to]:
+				link.diff = diff.Added
+			case !newMembers[id][link.to]:
+				link.diff = diff.Removed
+			}
+		}
+	}
+
+	return writeDOT(sc, len(segments) > 0)
+}
+
+// unionDocument merges two snapshots: everything in new, plus the nodes,
+// interfaces, edges and segments only found in old
+func unionDocument(old, new *api.Graph) *api.Graph {
+	union := &api.Graph{
+		Version:     new.Version,
+		GeneratedAt: new.GeneratedAt,
+		LocalNodeID: new.LocalNodeID,
+	}
+
+	index := make(map[string]int, len(new.Nodes))
+	for _, n := range new.Nodes {
+		n.Interfaces = append([]api.Interface(nil), n.Interfaces...)
+		index[n.ID] = len(union.Nodes)
+		union.Nodes = append(union.Nodes, n)
+	}
+	for _, n := range old.Nodes {
+		i, ok := index[n.ID]
+		if !ok {
+			n.IsLocal = n.ID == union.LocalNodeID
+			union.Nodes = append(union.Nodes, n)
+			continue
+		}
+		have := make(map[string]bool, len(union.Nodes[i].Interfaces))
+		for _, iface := range union.Nodes[i].Interfaces {
+			have[iface.Name] = true
+		}
+		for _, iface := range n.Interfaces {
+			if !have[iface.Name] {
+				union.Nodes[i].Interfaces = append(union.Nodes[i].Interfaces, iface)
+			}
+		}
+	}
+
+	edges := make(map[string]bool, len(new.Edges))
+	union.Edges = append(union.Edges, new.Edges...)
+	for _, e := range new.Edges {
+		edges[e.ID] = true
+	}
+	for _, e := range old.Edges {
+		if !edges[e.ID] {
+			union.Edges = append(union.Edges, e)
+		}
+	}
+
+	segments := make(map[string]bool, len(new.Segments))
+	union.Segments = append(union.Segments, new.Segments...)
+	for _, s := range new.Segments {
+		segments[s.ID] = true
+	}
+	for _, s := range old.Segments {
+		if !segments[s.ID] {
+			union.Segments = append(union.Segments, s)
+		}
+	}
+	return union
+}
+
+// segmentMembers maps segment IDs to the scene IDs of their member interfaces
+func segmentMembers(doc *api.Graph) map[string]map[string]bool {
+	members := make(map[string]map[string]bool, len(doc.Segments))
+	for _, s := range doc.Segments {
+		members[s.ID] = make(map[string]bool, len(s.Members))
+		for _, m := range s.Members {
+			members[s.ID][interfaceNodeID(m.NodeID, m.Interface)] = true
+		}
+	}
+	return members
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func TestGenerateDiffDOT(t *testing.T) {
+	build := func(changed bool) *api.Graph {
+		g := graph.New()
+		g.SetLocalNode("local", "host-local", map[string]graph.InterfaceDetails{
+			"eth0": {IPAddress: "fe80::1", Speed: 1000},
+			"ib0":  {IPAddress: "fe80::10", RDMADevice: "mlx5_0", Speed: 100000},
+		})
+		g.AddOrUpdate("a", "host-a", "eth0", "fe80::2", "eth0", "", "", "", 1000, nil, true, "")
+		if changed {
+			g.AddOrUpdate("b", "host-b", "ib0", "fe80::11", "ib0", "mlx5_1", "", "", 100000, nil, true, "")
+		} else {
+			g.AddOrUpdate("c", "host-c", "ib0", "fe80::12", "ib0", "mlx5_2", "", "", 100000, nil, true, "")
+		}
+		return api.FromGraph(g.GetNodes(), g.GetEdges(), nil)
+	}
+
+	dot := GenerateDiffDOT(build(false), build(true))
+
+	for _, want := range []string{
+		"// Diff: green = added",
+		"cluster_b {\n    style=rounded;\n    color=\"#2e7d32\"",
+		"cluster_c {\n    style=rounded;\n    color=\"#c62828\"",
+		"cluster_local {\n    style=rounded;\n    color=blue",
+		`"local__ib0" -- "b__ib0" [label=`,
+		`"local__ib0" -- "c__ib0" [label=`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected %q in:\n%s", want, dot)
+		}
+	}
+
+	for _, line := range strings.Split(dot, "\n") {
+		switch {
+		case strings.Contains(line, `"local__ib0" -- "b__ib0"`):
+			if !strings.Contains(line, `color="#2e7d32"`) {
+				t.Errorf("expected added link in green: %s", line)
+			}
+		case strings.Contains(line, `"local__ib0" -- "c__ib0"`):
+			if !strings.Contains(line, `color="#c62828"`) {
+				t.Errorf("expected removed link in red: %s", line)
+			}
+		case strings.Contains(line, `"local__eth0" -- "a__eth0"`):
+			if strings.Contains(line, "#") {
+				t.Errorf("expected unchanged link without diff color: %s", line)
+			}
+		}
+	}
+
+	// Without changes the drawing matches the regular export
+	same := GenerateDiffDOT(build(false), build(false))
+	nodes, edges := api.ToGraph(build(false))
+	regular := GenerateDOTWithSegments(nodes, edges, nil)
+	if strings.Replace(same, "  // Diff: green = added, red = removed, orange = changed\n", "", 1) != regular {
+		t.Errorf("unchanged diff differs from regular export:\n%s\n%s", same, regular)
+	}
+}
@@ -29,6 +29,14 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 			networkName = strings.ReplaceAll(networkName, ":", "_")
 			networkName = strings.ReplaceAll(networkName, ".", "_")
 		}
+		if segment.PKey != "" {
+			// Partitions may share a prefix or interface name
+			networkName += "_pkey_" + strings.TrimPrefix(segment.PKey, "0x")
+		}
+		if segment.VLANID != 0 {
+			// VLANs may share an interface name
+			networkName += fmt.Sprintf("_vlan_%d", segment.VLANID)
+		}
 
 		// Determine network speed and color
 		// Collect all speeds from segment members
@@ -59,12 +67,36 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 		}
 
 		networkColor := getNetworkColor(segmentSpeed, hasRDMA)
+		for _, nodeID := range segment.ConnectedNodes {
+			node, exists := nodes[nodeID]
+			if !exists {
+				continue
+			}
+			iface := segmentMemberInterface(segment, node)
+			if edge, ok := segment.EdgeInfo[nodeID]; ok {
+				iface = edge.RemoteInterface
+			}
+			if flap := interfaceFlap(edges, nodeID, iface); flap != nil && flap.Dampened {
+				networkColor = dampenedNetworkColor
+				break
+			}
+			if node.Interfaces[iface].Health.Degraded() {
+				networkColor = degradedNetworkColor
+			}
+		}
 
 		sb.WriteString(fmt.Sprintf("  network %s {\n", networkName))
 
-		// Add network address with all prefixes and speed
-		if len(segment.NetworkPrefixes) > 0 {
-			prefixStr := strings.Join(segment.NetworkPrefixes, ", ")
+		// Add network address with all prefixes, the partition and speed
+		addressParts := append([]string(nil), segment.NetworkPrefixes...)
+		if segment.PKey != "" {
+			addressParts = append(addressParts, graph.PartitionLabel(segment.PKey))
+		}
+		if segment.VLANID != 0 {
+			addressParts = append(addressParts, fmt.Sprintf("VLAN %d", segment.VLANID))
+		}
+		if len(addressParts) > 0 {
+			prefixStr := strings.Join(addressParts, ", ")
 			if segmentSpeed > 0 {
 				sb.WriteString(fmt.Sprintf("    address = \"%s (%d Mbps)\"\n", prefixStr, segmentSpeed))
 			} else {
@@ -112,13 +144,13 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 				ifaceName = edge.LocalInterface
 				ipAddress = edge.LocalAddress
 				speed = edge.LocalSpeed
-				rdmaDevice = edge.LocalRDMADevice
+				rdmaDevice = rdmaLabel(edge.LocalRDMADevice, edge.LocalRDMADevices)
 			} else {
 				// Remote node - use remote interface
 				ifaceName = edge.RemoteInterface
 				ipAddress = edge.RemoteAddress
 				speed = edge.RemoteSpeed
-				rdmaDevice = edge.RemoteRDMADevice
+				rdmaDevice = rdmaLabel(edge.RemoteRDMADevice, edge.RemoteRDMADevices)
 			}
 
 			// Clean up IPv6 zone identifier
@@ -141,6 +173,9 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 				if rdmaDevice != "" {
 					addrStr += fmt.Sprintf(", %s", rdmaDevice)
 				}
+				if node.Interfaces[edge.RemoteInterface].Health.Degraded() {
+					addrStr += ", degraded"
+				}
 				if strings.Contains(addrStr, "(") {
 					addrStr += ")"
 				}
@@ -300,6 +335,14 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 				}
 
 				networkColor := getNetworkColor(maxSpeed, hasRDMA)
+				srcDegraded := srcNode.Interfaces[edge.LocalInterface].Health.Degraded()
+				dstDegraded := dstNode.Interfaces[edge.RemoteInterface].Health.Degraded()
+				if srcDegraded || dstDegraded {
+					networkColor = degradedNetworkColor
+				}
+				if edge.Flap != nil && edge.Flap.Dampened {
+					networkColor = dampenedNetworkColor
+				}
 
 				peerNetworkName := fmt.Sprintf("p2p_%d", peerNetworkIdx)
 				peerNetworkIdx++
@@ -353,8 +396,11 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 				srcAddrStr := strings.Split(edge.LocalAddress, "%")[0]
 				if edge.LocalInterface != "" {
 					srcAddrStr += " (" + edge.LocalInterface
-					if edge.LocalRDMADevice != "" {
-						srcAddrStr += fmt.Sprintf(", %s", edge.LocalRDMADevice)
+					if rdma := rdmaLabel(edge.LocalRDMADevice, edge.LocalRDMADevices); rdma != "" {
+						srcAddrStr += fmt.Sprintf(", %s", rdma)
+					}
+					if srcDegraded {
+						srcAddrStr += ", degraded"
 					}
 					srcAddrStr += ")"
 				}
@@ -370,8 +416,11 @@ func ExportNwdiag(nodes map[string]*graph.Node, edges map[string]map[string][]*g
 				dstAddrStr := strings.Split(edge.RemoteAddress, "%")[0]
 				if edge.RemoteInterface != "" {
 					dstAddrStr += " (" + edge.RemoteInterface
-					if edge.RemoteRDMADevice != "" {
-						dstAddrStr += fmt.Sprintf(", %s", edge.RemoteRDMADevice)
+					if rdma := rdmaLabel(edge.RemoteRDMADevice, edge.RemoteRDMADevices); rdma != "" {
+						dstAddrStr += fmt.Sprintf(", %s", rdma)
+					}
+					if dstDegraded {
+						dstAddrStr += ", degraded"
 					}
 					dstAddrStr += ")"
 				}
@@ -422,6 +471,14 @@ func makeEdgeKeyWithInterfaces(nodeA, nodeB, ifaceA, ifaceB string) string {
 	return fmt.Sprintf("%s:%s:%s:%s", nodeB, nodeA, ifaceB, ifaceA)
 }
 
+// degradedNetworkColor marks networks with a member interface that exceeds its
+// error or drop thresholds, overriding the speed and RDMA colors
+const degradedNetworkColor = "#FF6347" // Tomato
+
+// dampenedNetworkColor marks networks with a dampened flapping link; it
+// overrides the degraded color, as a flapping link also counts errors
+const dampenedNetworkColor = "#FF8C00" // Dark orange
+
 // getNetworkColor returns a color based on speed and RDMA presence
 func getNetworkColor(speedMbps int, hasRDMA bool) string {
 	if hasRDMA {
@@ -110,3 +110,72 @@ func TestExportNwdiagEmptyGraph(t *testing.T) {
 		t.Error("Expected @enduml even for empty graph")
 	}
 }
+
+func TestExportPartitions(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"ib0":      {IPAddress: "fe80::1%ib0", GlobalPrefixes: []string{"10.0.0.0/24"}, IPoIB: &graph.IPoIB{PKey: "0xffff"}},
+		"ib0.8001": {IPAddress: "fe80::2%ib0.8001", GlobalPrefixes: []string{"10.1.0.0/24"}, IPoIB: &graph.IPoIB{PKey: "0x8001"}},
+	})
+	for _, id := range []string{"node1", "node2", "node3"} {
+		g.AddOrUpdate(id+"-id", id, "ib0", "fe80::"+id[4:]+"1", "ib0", "", "", "", 0, []string{"10.0.0.0/24"}, true, "")
+		g.SetInterfaceIPoIB(id+"-id", "ib0", &graph.IPoIB{PKey: "0xffff"})
+		g.AddOrUpdate(id+"-id", id, "ib0.8001", "fe80::"+id[4:]+"2", "ib0.8001", "", "", "", 0, []string{"10.1.0.0/24"}, true, "")
+		g.SetInterfaceIPoIB(id+"-id", "ib0.8001", &graph.IPoIB{PKey: "0x8001"})
+	}
+	nodes, edges, segments := g.GetNodes(), g.GetEdges(), g.GetNetworkSegments()
+
+	nwdiag := ExportNwdiag(nodes, edges, segments)
+	for _, want := range []string{
+		"network 10_0_0_0_24_pkey_ffff {",
+		"network 10_1_0_0_24_pkey_8001 {",
+		"address = \"10.1.0.0/24, P_Key 0x8001",
+	} {
+		if !strings.Contains(nwdiag, want) {
+			t.Errorf("nwdiag output missing %q:\n%s", want, nwdiag)
+		}
+	}
+
+	dot := GenerateDOTWithSegments(nodes, edges, segments)
+	for _, want := range []string{"P_Key 0x8001", "P_Key 0xffff (default)"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+}
+
+func TestExportVLANs(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"bond0.100": {IPAddress: "fe80::1", GlobalPrefixes: []string{"10.100.0.0/24"},
+			Stack: &graph.Stack{Kind: graph.StackVLAN, Parent: "bond0", VLANID: 100}},
+		"bond0": {IPAddress: "fe80::2", Stack: &graph.Stack{Kind: graph.StackBond, BondMode: "802.3ad", Members: []string{"ens1f0", "ens1f1"}}},
+	})
+	for _, id := range []string{"node1", "node2", "node3"} {
+		g.AddOrUpdate(id+"-id", id, "eth0.100", "fe80::"+id[4:]+"1", "bond0.100", "", "", "", 0, []string{"10.100.0.0/24"}, true, "")
+		g.SetInterfaceStack(id+"-id", "eth0.100", &graph.Stack{Kind: graph.StackVLAN, Parent: "eth0", VLANID: 100})
+	}
+	nodes, edges, segments := g.GetNodes(), g.GetEdges(), g.GetNetworkSegments()
+
+	nwdiag := ExportNwdiag(nodes, edges, segments)
+	for _, want := range []string{"network 10_100_0_0_24_vlan_100 {", "address = \"10.100.0.0/24, VLAN 100"} {
+		if !strings.Contains(nwdiag, want) {
+			t.Errorf("nwdiag output missing %q:\n%s", want, nwdiag)
+		}
+	}
+
+	// The VLAN is drawn over its bond, and the bond over its ports
+	dot := GenerateDOTWithSegments(nodes, edges, segments)
+	for _, want := range []string{
+		"\"local-id__bond0.100\" -- \"local-id__bond0\" [style=dotted, color=gray];",
+		"\"local-id__bond0\" -- \"local-id__ens1f0\" [style=dotted, color=gray];",
+		"\"local-id__bond0\" [label=\"bond0\\nfe80::2\\nbond 802.3ad\", shape=box, style=\"rounded,dashed\"",
+		"\"local-id__ens1f1\" [label=\"ens1f1\", shape=box, style=\"rounded,dashed\"",
+		"\"node1-id__eth0.100\" -- \"node1-id__eth0\" [style=dotted, color=gray];",
+		"VLAN 100",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// Output formats supported by the periodic exporter
+const (
+	FormatDOT      = "dot"
+	FormatSVG      = "svg"
+	FormatNwdiag   = "nwdiag"
+	FormatJSON     = "json"
+	FormatTemplate = "template"
+)
+
+// DefaultHookTimeout bounds post-write hooks that do not set a timeout
+const DefaultHookTimeout = 30 * time.Second
+
+// hookWaitDelay bounds the wait for the output of a hook after it exited or
+// was killed, in case a process it left behind still holds its output
+const hookWaitDelay = 2 * time.Second
+
+// Output is an artifact maintained by the periodic exporter
+type Output struct {
+	Format   string
+	Path     string
+	Segments bool         // Include network segments (always on for nwdiag and templates)
+	Filter   graph.Filter // Subgraph to export
+	Template *Template    // Template to render when Format is FormatTemplate
+	Hook     *Hook        // Optional command run after a successful write
+}
+
+// NeedsSegments reports whether the output renders network segments or
+// its filter selects by segment
+func (o *Output) NeedsSegments() bool {
+	return o.rendersSegments() || o.Filter.NeedsSegments()
+}
+
+// rendersSegments reports whether network segments appear in the output
+func (o *Output) rendersSegments() bool {
+	return o.Segments || o.Format == FormatNwdiag || o.Format == FormatTemplate
+}
+
+// Generate renders the output from a topology snapshot.
+// Segments must be provided when NeedsSegments is true; they are ignored otherwise.
+func (o *Output) Generate(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment) (string, error) {
+	if !o.NeedsSegments() {
+		segments = nil
+	}
+	nodes, edges, segments = o.Filter.Apply(nodes, edges, segments)
+	if !o.rendersSegments() {
+		segments = nil
+	}
+
+	switch o.Format {
+	case FormatDOT:
+		return GenerateDOTWithSegments(nodes, edges, segments), nil
+	case FormatSVG:
+		return GenerateSVGWithSegments(nodes, edges, segments), nil
+	case FormatNwdiag:
+		return ExportNwdiag(nodes, edges, segments), nil
+	case FormatJSON:
+		return GenerateJSON(nodes, edges, segments)
+	case FormatTemplate:
+		if o.Template == nil {
+			return "", fmt.Errorf("no template configured for %s", o.Path)
+		}
+		return o.Template.Render(NewTemplateData(nodes, edges, segments))
+	default:
+		return "", fmt.Errorf("unsupported output format %q", o.Format)
+	}
+}
+
+// GenerateJSON renders the topology as the versioned /graph document
+func GenerateJSON(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment) (string, error) {
+	data, err := json.MarshalIndent(api.FromGraph(nodes, edges, segments), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// WriteFileAtomic writes content to a temporary file in the target directory
+// and renames it into place, so readers never observe a partially written file.
+func WriteFileAtomic(filename, content string) error {
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	// Remove the temporary file on any failure before the rename
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if _, err := tmp.WriteString(content); err != nil {
+		return fail(fmt.Errorf("failed to write file: %w", err))
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(fmt.Errorf("failed to sync file: %w", err))
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return fail(fmt.Errorf("failed to set file mode: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return nil
+}
+
+// Hook is a command run after an output has been written.
+// The argument "{path}" is replaced with the output path; the path and format
+// are also passed in LLDISCOVERY_OUTPUT_PATH and LLDISCOVERY_OUTPUT_FORMAT.
+type Hook struct {
+	Command []string
+	Timeout time.Duration // DefaultHookTimeout if zero
+}
+
+// HookResult describes a finished hook invocation
+type HookResult struct {
+	ExitCode int
+	Output   string // Combined stdout and stderr, trimmed
+	Duration time.Duration
+}
+
+// Run executes the hook for a written output. A non-zero exit code is
+// reported in the result, not as an error; errors mean the command could
+// not be started or did not finish within the timeout. The hook runs in its
+// own process group, which is killed as a whole on timeout.
+func (h *Hook) Run(ctx context.Context, o *Output) (HookResult, error) {
+	var result HookResult
+	if len(h.Command) == 0 {
+		return result, errors.New("empty hook command")
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := make([]string, len(h.Command))
+	for i, arg := range h.Command {
+		args[i] = strings.ReplaceAll(arg, "{path}", o.Path)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"LLDISCOVERY_OUTPUT_PATH="+o.Path,
+		"LLDISCOVERY_OUTPUT_FORMAT="+o.Format,
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// Kill the processes the hook started too: they keep its output open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = hookWaitDelay
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	result.Output = strings.TrimSpace(out.String())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("hook timed out after %s", timeout)
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return result, errors.New("hook exited but left processes holding its output")
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return result, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to run hook: %w", err)
+	}
+	return result, nil
+}
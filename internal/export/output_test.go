@@ -0,0 +1,188 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "sub", "topology.dot")
+
+	if err := WriteFileAtomic(filename, "first"); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if err := WriteFileAtomic(filename, "second"); err != nil {
+		t.Fatalf("WriteFileAtomic failed on replace: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("expected replaced content, got %q", data)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("expected mode 0644, got %v", info.Mode().Perm())
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the output file in directory, got %d entries", len(entries))
+	}
+}
+
+func TestOutputGenerate(t *testing.T) {
+	g := createTemplateTestGraph()
+	nodes, edges, segments := g.GetNodes(), g.GetEdges(), g.GetNetworkSegments()
+
+	tmpl, err := ParseTemplate("hosts", "{{range .Nodes}}{{.Hostname}} {{end}}")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		output Output
+		want   string
+		absent string
+	}{
+		{"dot", Output{Format: FormatDOT}, "graph lldiscovery {", "segment_0"},
+		{"dot with segments", Output{Format: FormatDOT, Segments: true}, "segment_0", ""},
+		{"svg", Output{Format: FormatSVG}, "<svg xmlns=", ""},
+		{"nwdiag", Output{Format: FormatNwdiag}, "nwdiag {", ""},
+		{"json", Output{Format: FormatJSON}, "\"segments\": []", "seg-"},
+		{"json with segments", Output{Format: FormatJSON, Segments: true}, "\"id\": \"seg-", ""},
+		{"template", Output{Format: FormatTemplate, Template: tmpl}, "alpha bravo charlie local-host ", ""},
+		{"filtered", Output{Format: FormatTemplate, Template: tmpl, Filter: graph.Filter{RDMAOnly: true}}, "charlie local-host ", "alpha"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tt.output.Generate(nodes, edges, segments)
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("expected output to contain %q", tt.want)
+			}
+			if tt.absent != "" && strings.Contains(out, tt.absent) {
+				t.Errorf("expected output not to contain %q", tt.absent)
+			}
+		})
+	}
+
+	// Segment filters select nodes even when segments are not rendered
+	bySegment := &Output{Format: FormatDOT, Filter: graph.Filter{Segments: []string{segments[0].StableID()}}}
+	if !bySegment.NeedsSegments() {
+		t.Error("expected segment filter to need segments")
+	}
+	out, err := bySegment.Generate(nodes, edges, segments)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.Contains(out, "local-host") || strings.Contains(out, "segment_0") {
+		t.Error("expected segment members without rendered segments")
+	}
+
+	if _, err := (&Output{Format: "pdf"}).Generate(nodes, edges, segments); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestGenerateJSON(t *testing.T) {
+	g := createTemplateTestGraph()
+
+	out, err := GenerateJSON(g.GetNodes(), g.GetEdges(), []graph.NetworkSegment{})
+	if err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out), &response); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"version", "nodes", "edges", "segments"} {
+		if _, ok := response[key]; !ok {
+			t.Errorf("expected %q in JSON output", key)
+		}
+	}
+}
+
+func TestHookRun(t *testing.T) {
+	dir := t.TempDir()
+	o := &Output{Format: FormatDOT, Path: filepath.Join(dir, "topology.dot")}
+
+	// Argument substitution and environment
+	marker := filepath.Join(dir, "marker")
+	h := &Hook{Command: []string{"sh", "-c", "echo \"$1 $LLDISCOVERY_OUTPUT_FORMAT\" > " + marker, "hook", "{path}"}}
+	result, err := h.Run(context.Background(), o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", result.ExitCode)
+	}
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != o.Path+" dot" {
+		t.Errorf("unexpected hook arguments: %q", data)
+	}
+
+	// Non-zero exit is reported, not an error
+	h = &Hook{Command: []string{"sh", "-c", "echo failed >&2; exit 3"}}
+	result, err = h.Run(context.Background(), o)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.ExitCode != 3 || result.Output != "failed" {
+		t.Errorf("expected exit code 3 with output, got %d %q", result.ExitCode, result.Output)
+	}
+
+	// Timeout
+	h = &Hook{Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond}
+	if _, err := h.Run(context.Background(), o); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	// Timeout of a hook that backgrounded a child holding its output
+	h = &Hook{Command: []string{"sh", "-c", "sleep 5 & sleep 5"}, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	if _, err := h.Run(context.Background(), o); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= hookWaitDelay {
+		t.Errorf("expected the process group to be killed on timeout, took %s", elapsed)
+	}
+
+	// A child left behind after the hook exited is not waited for forever
+	h = &Hook{Command: []string{"sh", "-c", "sleep 5 &"}}
+	if _, err := h.Run(context.Background(), o); err == nil || !strings.Contains(err.Error(), "left processes") {
+		t.Errorf("expected error for a leftover child, got %v", err)
+	}
+
+	// Missing command
+	h = &Hook{Command: []string{filepath.Join(dir, "does-not-exist")}}
+	if _, err := h.Run(context.Background(), o); err == nil {
+		t.Error("expected error for missing command")
+	}
+}
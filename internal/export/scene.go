@@ -0,0 +1,871 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// scene is a renderer-neutral description of the topology drawing.
+// It holds everything the DOT and SVG exporters share: which machines and
+// interfaces are shown, which segment hubs exist, which links are hidden
+// behind segments and how every element is styled.
+type scene struct {
+	machines []sceneMachine
+	segments []sceneSegment
+	links    []sceneLink // Connections between interfaces
+	legend   []string    // Extra comment lines for the header
+}
+
+type sceneMachine struct {
+	id       string
+	hostname string
+	shortID  string
+	local    bool
+	ifaces   []sceneIface
+	adapters []sceneAdapter
+	stacks   []sceneLink // Stacked interface to the interfaces it is built on
+	diff     string      // Kind of change in a diff drawing, "" otherwise
+}
+
+// sceneAdapter groups the interfaces of a machine backed by one physical RDMA adapter
+type sceneAdapter struct {
+	id     string   // "<machineID>_adapter_<n>"
+	label  []string // Label lines
+	ifaces []string // Interface names, each in at most one adapter
+}
+
+type sceneIface struct {
+	id      string   // "<machineID>__<interface>"
+	name    string   // Interface name
+	label   []string // Label lines
+	tooltip []string // Link settings and driver, shown on hover
+	rdma    bool
+	lower   bool // Only shown because a stacked interface is built on it
+	diff    string
+}
+
+type sceneSegment struct {
+	id    string
+	label []string
+	links []sceneLink // Hub-to-interface connections
+	diff  string
+}
+
+// sceneLink is a line between two drawable elements.
+// A zero penwidth means the renderer default is used.
+type sceneLink struct {
+	from     string
+	to       string
+	label    []string
+	penwidth float64
+	color    string // "darkorange", "red", "blue", "gray" or "" for default
+	direct   bool   // Direct (bold) or indirect (dashed); hub links are always solid
+	diff     string
+}
+
+// buildScene converts graph data into a scene using the repository's visual conventions
+func buildScene(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment) *scene {
+	sc := &scene{}
+
+	segmentEdgeMap := buildSegmentEdgeMap(nodes, segments)
+	connectedInterfaces := collectConnectedInterfaces(edges)
+	adapters := graph.GroupAdapters(nodes)
+
+	// Machines with their connected interfaces
+	for _, machineID := range sortedNodeIDs(nodes) {
+		node := nodes[machineID]
+		machine := sceneMachine{
+			id:       machineID,
+			hostname: node.Hostname,
+			shortID:  shortMachineID(machineID),
+			local:    node.IsLocal,
+		}
+
+		var ifaceNames []string
+		for iface := range node.Interfaces {
+			// Only include interfaces that have connections
+			if connectedInterfaces[machineID][iface] {
+				ifaceNames = append(ifaceNames, iface)
+			}
+		}
+		sort.Strings(ifaceNames)
+
+		for _, iface := range ifaceNames {
+			details := node.Interfaces[iface]
+			machine.ifaces = append(machine.ifaces, sceneIface{
+				id:      interfaceNodeID(machineID, iface),
+				name:    iface,
+				label:   interfaceLabel(iface, details),
+				tooltip: interfaceTooltip(details.Ethtool),
+				rdma:    rdmaLabel(details.RDMADevice, details.RDMADevices) != "",
+			})
+		}
+		machine.adapters = machineAdapters(machineID, ifaceNames, adapters, nodes)
+		machine.ifaces, machine.stacks = machineStacks(machineID, node, machine.ifaces)
+
+		sc.machines = append(sc.machines, machine)
+	}
+
+	// Segment hubs and their connections to member interfaces
+	for i, segment := range segments {
+		seg := sceneSegment{
+			id:    fmt.Sprintf("segment_%d", i),
+			label: segmentLabel(segment),
+		}
+		sourceID := segmentSource(segment, nodes)
+
+		for _, nodeID := range segment.ConnectedNodes {
+			for _, ifaceName := range segmentNodeInterfaces(segment, nodeID, nodes, connectedInterfaces) {
+				link := sceneLink{
+					from:  seg.id,
+					to:    interfaceNodeID(nodeID, ifaceName),
+					color: "gray",
+				}
+
+				if edge, hasEdge := segment.EdgeInfo[nodeID]; hasEdge && edge.RemoteInterface == ifaceName {
+					// Build edge label with address info
+					label := edge.RemoteAddress
+
+					// Add speed if available
+					if edge.RemoteSpeed > 0 {
+						label += fmt.Sprintf("\n%d Mbps", edge.RemoteSpeed)
+					} else if strings.Contains(ifaceName, "wl") {
+						// WiFi interfaces without reported speed show the default
+						label += "\n100 Mbps"
+					}
+
+					// Add RDMA info if present
+					if rdma := rdmaLabel(edge.RemoteRDMADevice, edge.RemoteRDMADevices); rdma != "" {
+						label += fmt.Sprintf("\n[%s]", rdma)
+					}
+
+					// VFs folded into the member's PF
+					if edge.RemoteVF != "" {
+						label += "\nVF " + edge.RemoteVF
+					}
+
+					link.label = strings.Split(label, "\n")
+					link.penwidth = calculatePenwidth(edge.RemoteSpeed)
+
+					// RDMA segments get blue color
+					if edge.RemoteRDMADevice != "" && edge.LocalRDMADevice != "" {
+						link.color = "blue"
+					}
+				} else if node, exists := nodes[nodeID]; exists {
+					// No edge info for this interface, use node's interface details
+					if ifaceDetails, ok := node.Interfaces[ifaceName]; ok {
+						label := ifaceDetails.IPAddress
+						speed := ifaceDetails.Speed
+						if speed == 0 && strings.Contains(ifaceName, "wl") {
+							speed = 100 // Default WiFi
+						}
+						if speed > 0 {
+							label += fmt.Sprintf("\n%d Mbps", speed)
+						}
+						if label != "" {
+							link.label = strings.Split(label, "\n")
+						}
+						link.penwidth = calculatePenwidth(speed)
+					}
+				}
+
+				// Degraded members are red, whatever their medium
+				if line := degradedLabel(nodes[nodeID], ifaceName); line != "" {
+					link.label = append(link.label, line)
+					link.color = "red"
+				}
+				if flap := interfaceFlap(edges, nodeID, ifaceName); flap != nil {
+					link.label = append(link.label, flapLabel(flap))
+					if flap.Dampened {
+						link.color = "darkorange"
+					}
+				}
+				if edge, hasEdge := segment.EdgeInfo[nodeID]; hasEdge && edge.RemoteInterface == ifaceName {
+					if line := receptionLabel(linkReception(edges, sourceID, nodeID, edge)); line != "" {
+						link.label = append(link.label, line)
+					}
+				}
+
+				seg.links = append(seg.links, link)
+			}
+		}
+
+		sc.segments = append(sc.segments, seg)
+	}
+
+	// Connections between interfaces (excluding those represented by segments)
+	edgesAdded := make(map[string]bool) // Track to avoid showing both directions of same edge
+
+	for _, srcMachineID := range sortedEdgeSources(edges) {
+		dests := edges[srcMachineID]
+
+		var dstMachineIDs []string
+		for dstMachineID := range dests {
+			dstMachineIDs = append(dstMachineIDs, dstMachineID)
+		}
+		sort.Strings(dstMachineIDs)
+
+		for _, dstMachineID := range dstMachineIDs {
+			edgeList := dests[dstMachineID]
+
+			// Sort edges by local interface name for deterministic output
+			sort.Slice(edgeList, func(i, j int) bool {
+				if edgeList[i].LocalInterface != edgeList[j].LocalInterface {
+					return edgeList[i].LocalInterface < edgeList[j].LocalInterface
+				}
+				// If local interfaces are the same, sort by remote interface
+				return edgeList[i].RemoteInterface < edgeList[j].RemoteInterface
+			})
+
+			for _, edge := range edgeList {
+				// Check if this specific edge (on this interface) is part of a segment
+				if len(segments) > 0 {
+					if interfaceMap, exists := segmentEdgeMap[srcMachineID+":"+dstMachineID]; exists {
+						if interfaceMap[edge.LocalInterface] {
+							continue // Skip this edge - it's represented by the segment
+						}
+					}
+				}
+
+				srcIfaceNodeID := interfaceNodeID(srcMachineID, edge.LocalInterface)
+				dstIfaceNodeID := interfaceNodeID(dstMachineID, edge.RemoteInterface)
+
+				// Create a canonical edge key for deduplication
+				edgeKey := fmt.Sprintf("%s--%s", srcIfaceNodeID, dstIfaceNodeID)
+				reverseKey := fmt.Sprintf("%s--%s", dstIfaceNodeID, srcIfaceNodeID)
+				if edgesAdded[edgeKey] || edgesAdded[reverseKey] {
+					continue
+				}
+				edgesAdded[edgeKey] = true
+
+				maxSpeed := edge.LocalSpeed
+				if edge.RemoteSpeed > maxSpeed {
+					maxSpeed = edge.RemoteSpeed
+				}
+
+				link := sceneLink{
+					from:     srcIfaceNodeID,
+					to:       dstIfaceNodeID,
+					label:    edgeLabel(edge),
+					penwidth: calculatePenwidth(maxSpeed),
+					direct:   edge.Direct,
+				}
+				if edge.LocalRDMADevice != "" && edge.RemoteRDMADevice != "" {
+					link.color = "blue"
+				}
+				for _, line := range []string{
+					degradedLabel(nodes[srcMachineID], edge.LocalInterface),
+					degradedLabel(nodes[dstMachineID], edge.RemoteInterface),
+				} {
+					if line != "" {
+						link.label = append(link.label, line)
+						link.color = "red"
+					}
+				}
+				// Dampened links stay drawn but stand out
+				if edge.Flap != nil {
+					link.label = append(link.label, flapLabel(edge.Flap))
+					if edge.Flap.Dampened {
+						link.color = "darkorange"
+					}
+				}
+				if line := receptionLabel(edge.Reception); line != "" {
+					link.label = append(link.label, line)
+				}
+
+				sc.links = append(sc.links, link)
+			}
+		}
+	}
+
+	return sc
+}
+
+// machineAdapters returns the adapters backing the shown interfaces of a
+// machine. An interface backed by devices of several adapters, such as a bond
+// across two cards, is drawn in the first one.
+func machineAdapters(machineID string, shown []string, adapters []graph.Adapter, nodes map[string]*graph.Node) []sceneAdapter {
+	placed := make(map[string]bool)
+	var result []sceneAdapter
+	for _, adapter := range adapters {
+		sa := sceneAdapter{
+			id:    fmt.Sprintf("%s_adapter_%d", machineID, len(result)),
+			label: []string{"adapter " + adapter.SysImageGUID},
+		}
+		var others []string
+		for _, m := range adapter.Members {
+			if m.MachineID != machineID {
+				if node, ok := nodes[m.MachineID]; ok && !containsString(others, node.Hostname) {
+					others = append(others, node.Hostname)
+				}
+				continue
+			}
+			if !placed[m.Interface] && containsString(shown, m.Interface) {
+				placed[m.Interface] = true
+				sa.ifaces = append(sa.ifaces, m.Interface)
+			}
+		}
+		if len(sa.ifaces) == 0 {
+			continue
+		}
+		if len(others) > 0 {
+			sort.Strings(others)
+			sa.label = append(sa.label, "shared with "+strings.Join(others, ", "))
+		}
+		result = append(result, sa)
+	}
+	return result
+}
+
+// machineStacks adds the interfaces that the shown interfaces of a machine are
+// stacked on, such as the parent of a VLAN, the ports of a bond or the PF of a
+// VF, and links each stacked interface to them. Interfaces without their own
+// connections are marked lower.
+func machineStacks(machineID string, node *graph.Node, ifaces []sceneIface) ([]sceneIface, []sceneLink) {
+	shown := make(map[string]bool, len(ifaces))
+	queue := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		shown[iface.name] = true
+		queue = append(queue, iface.name)
+	}
+
+	var links []sceneLink
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, lower := range lowerInterfaces(node.Interfaces[name]) {
+			links = append(links, sceneLink{
+				from:  interfaceNodeID(machineID, name),
+				to:    interfaceNodeID(machineID, lower),
+				color: "gray",
+			})
+			if shown[lower] {
+				continue
+			}
+			shown[lower] = true
+			queue = append(queue, lower)
+
+			// Bond and bridge members usually have no address and are not
+			// in the interface list
+			label := []string{lower}
+			details, ok := node.Interfaces[lower]
+			if ok {
+				label = interfaceLabel(lower, details)
+			}
+			ifaces = append(ifaces, sceneIface{
+				id:      interfaceNodeID(machineID, lower),
+				name:    lower,
+				label:   label,
+				tooltip: interfaceTooltip(details.Ethtool),
+				rdma:    rdmaLabel(details.RDMADevice, details.RDMADevices) != "",
+				lower:   true,
+			})
+		}
+	}
+	return ifaces, links
+}
+
+// lowerInterfaces returns the interfaces an interface is drawn on top of: the
+// lower interfaces of its stack and the PF of a VF or representor
+func lowerInterfaces(details graph.InterfaceDetails) []string {
+	lower := details.Stack.Lower()
+	if sriov := details.SRIOV; sriov != nil && sriov.PF != "" && sriov.Role != graph.SRIOVPF {
+		lower = append(append([]string(nil), lower...), sriov.PF)
+	}
+	return lower
+}
+
+// shortMachineID truncates a machine ID to its first 8 characters for display
+func shortMachineID(machineID string) string {
+	if len(machineID) > 8 {
+		return machineID[:8]
+	}
+	return machineID
+}
+
+// interfaceNodeID builds the drawable ID of an interface inside a machine cluster
+func interfaceNodeID(machineID, iface string) string {
+	return fmt.Sprintf("%s__%s", machineID, iface)
+}
+
+// interfaceLabel builds the label lines for an interface node with IP and RDMA info
+func interfaceLabel(iface string, details graph.InterfaceDetails) []string {
+	label := []string{iface}
+	if details.IPAddress != "" {
+		label = append(label, details.IPAddress)
+	}
+	if details.Speed > 0 {
+		label = append(label, fmt.Sprintf("%d Mbps", details.Speed))
+	}
+	if stack := details.Stack.Label(); stack != "" {
+		label = append(label, stack)
+	}
+	if sriov := details.SRIOV.Label(); sriov != "" {
+		label = append(label, sriov)
+	}
+	if rdma := rdmaLabel(details.RDMADevice, details.RDMADevices); rdma != "" {
+		label = append(label, fmt.Sprintf("[%s]", rdma))
+		// Add RDMA GUIDs (of the first device) if present
+		if details.NodeGUID != "" {
+			label = append(label, fmt.Sprintf("N: %s", details.NodeGUID))
+		}
+		if details.SysImageGUID != "" {
+			label = append(label, fmt.Sprintf("S: %s", details.SysImageGUID))
+		}
+	}
+	return label
+}
+
+// interfaceTooltip describes the link settings and driver of an interface,
+// e.g. "driver mlx5_core 6.8.0", "firmware 22.36.1010", "bus 0000:3b:00.0",
+// "full duplex, autoneg off, DA"
+func interfaceTooltip(et *graph.Ethtool) []string {
+	if et == nil {
+		return nil
+	}
+	var lines []string
+	if et.Driver != "" {
+		lines = append(lines, strings.TrimSpace("driver "+et.Driver+" "+et.DriverVersion))
+	}
+	if et.FirmwareVersion != "" {
+		lines = append(lines, "firmware "+et.FirmwareVersion)
+	}
+	if et.BusInfo != "" {
+		lines = append(lines, "bus "+et.BusInfo)
+	}
+	var link []string
+	if et.Duplex != "" {
+		link = append(link, et.Duplex+" duplex")
+	}
+	if et.Autoneg != "" {
+		link = append(link, "autoneg "+et.Autoneg)
+	}
+	if et.Port != "" {
+		link = append(link, et.Port)
+	}
+	if len(link) > 0 {
+		lines = append(lines, strings.Join(link, ", "))
+	}
+	return lines
+}
+
+// rdmaLabel lists the RDMA devices of an interface, e.g. "mlx5_0, mlx5_1".
+// A device is suffixed with its ports unless the interface uses just port 1,
+// so the two ports of a dual-port HCA read "mlx4_0" and "mlx4_0:2".
+func rdmaLabel(primary string, devices []graph.RDMADevice) string {
+	if len(devices) == 0 {
+		return primary
+	}
+	names := make([]string, 0, len(devices))
+	for _, d := range devices {
+		name := d.Name
+		if len(d.Ports) > 1 || (len(d.Ports) == 1 && d.Ports[0].Port != 1) {
+			ports := make([]string, len(d.Ports))
+			for i, p := range d.Ports {
+				ports[i] = fmt.Sprint(p.Port)
+			}
+			name += ":" + strings.Join(ports, "/")
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// segmentLabel builds the label lines for a segment hub
+func segmentLabel(segment graph.NetworkSegment) []string {
+	var label []string
+	if len(segment.NetworkPrefixes) > 0 {
+		// Use network prefixes as primary label, at most 3 followed by "..."
+		if len(segment.NetworkPrefixes) <= 3 {
+			label = append(label, segment.NetworkPrefixes...)
+		} else {
+			label = append(label, segment.NetworkPrefixes[:3]...)
+			label = append(label, "...")
+		}
+		label = append(label, fmt.Sprintf("%d nodes", len(segment.ConnectedNodes)))
+		// Add interface name as secondary info
+		label = append(label, fmt.Sprintf("(%s)", segment.Interface))
+	} else {
+		// Fall back to interface name
+		label = append(label, fmt.Sprintf("segment: %s", segment.Interface), fmt.Sprintf("%d nodes", len(segment.ConnectedNodes)))
+	}
+
+	// IPoIB segments are separated by partition, VLAN segments by VLAN ID
+	if segment.PKey != "" {
+		label = append(label, graph.PartitionLabel(segment.PKey))
+	}
+	if segment.VLANID != 0 {
+		label = append(label, fmt.Sprintf("VLAN %d", segment.VLANID))
+	}
+
+	// Mark segments where all edges have RDMA
+	allHaveRDMA := true
+	for _, edge := range segment.EdgeInfo {
+		if edge.LocalRDMADevice == "" && edge.RemoteRDMADevice == "" {
+			allHaveRDMA = false
+			break
+		}
+	}
+	if allHaveRDMA && len(segment.EdgeInfo) > 0 {
+		label = append(label, "[RDMA]")
+	}
+
+	return label
+}
+
+// edgeLabel builds the label lines for a connection between interfaces
+// (addresses only, since interface info is in nodes)
+func edgeLabel(edge *graph.Edge) []string {
+	label := []string{fmt.Sprintf("%s <-> %s", edge.LocalAddress, edge.RemoteAddress)}
+
+	// Add speed information if available
+	if edge.LocalSpeed > 0 || edge.RemoteSpeed > 0 {
+		speedLine := ""
+		if edge.LocalSpeed > 0 {
+			speedLine += fmt.Sprintf("%d", edge.LocalSpeed)
+		}
+		if edge.RemoteSpeed > 0 && edge.RemoteSpeed != edge.LocalSpeed {
+			speedLine += fmt.Sprintf(" <-> %d Mbps", edge.RemoteSpeed)
+		} else if edge.LocalSpeed > 0 {
+			speedLine += " Mbps"
+		} else if edge.RemoteSpeed > 0 {
+			speedLine += fmt.Sprintf("%d Mbps", edge.RemoteSpeed)
+		}
+		label = append(label, speedLine)
+	}
+
+	// Add RDMA-to-RDMA indicator (device names are in interface nodes)
+	if edge.LocalRDMADevice != "" && edge.RemoteRDMADevice != "" {
+		label = append(label, "[RDMA-to-RDMA]")
+	}
+
+	// VFs folded into their PF by CollapseVFs
+	if edge.LocalVF != "" || edge.RemoteVF != "" {
+		label = append(label, fmt.Sprintf("VF %s <-> %s", orInterface(edge.LocalVF, edge.LocalInterface), orInterface(edge.RemoteVF, edge.RemoteInterface)))
+	}
+
+	return label
+}
+
+// degradedLabel returns a label line naming the error rates of a degraded
+// interface, "" if the interface is healthy or its health is unknown
+func degradedLabel(node *graph.Node, iface string) string {
+	if node == nil {
+		return ""
+	}
+	health := node.Interfaces[iface].Health
+	if !health.Degraded() {
+		return ""
+	}
+	if summary := health.Summary(); summary != "" {
+		return fmt.Sprintf("%s degraded: %s", iface, summary)
+	}
+	return iface + " degraded"
+}
+
+// flapLabel returns a label line for a link that lost its carrier
+func flapLabel(flap *graph.Flap) string {
+	label := fmt.Sprintf("%d flaps", flap.Flaps)
+	if flap.Flaps == 1 {
+		label = "1 flap"
+	}
+	if flap.Dampened {
+		return "FLAPPING: " + label + ", dampened"
+	}
+	return label
+}
+
+// interfaceFlap returns the flap history of the least stable link on an
+// interface of a machine, nil if none of its links flapped. Segment edges do
+// not carry it. Edges are scanned in sorted order so ties resolve the same way
+// on every export.
+func interfaceFlap(edges map[string]map[string][]*graph.Edge, machineID, iface string) *graph.Flap {
+	var worst *graph.Flap
+	for _, srcID := range sortedEdgeSources(edges) {
+		dests := edges[srcID]
+		dstIDs := make([]string, 0, len(dests))
+		for dstID := range dests {
+			dstIDs = append(dstIDs, dstID)
+		}
+		sort.Strings(dstIDs)
+		for _, dstID := range dstIDs {
+			for _, edge := range dests[dstID] {
+				if edge.Flap == nil || !(srcID == machineID && edge.LocalInterface == iface ||
+					dstID == machineID && edge.RemoteInterface == iface) {
+					continue
+				}
+				if worst == nil || lessStable(edge.Flap, worst) {
+					worst = edge.Flap
+				}
+			}
+		}
+	}
+	return worst
+}
+
+// lessStable reports whether flap a describes a less stable link than b: a
+// dampened link, else a higher penalty, more flaps or a later flap
+func lessStable(a, b *graph.Flap) bool {
+	switch {
+	case a.Dampened != b.Dampened:
+		return a.Dampened
+	case a.Penalty != b.Penalty:
+		return a.Penalty > b.Penalty
+	case a.Flaps != b.Flaps:
+		return a.Flaps > b.Flaps
+	default:
+		return a.LastFlap.After(b.LastFlap)
+	}
+}
+
+// receptionLabel returns a label line for a link that lost discovery packets,
+// "" if none were lost or the reception is unknown
+func receptionLabel(r *graph.Reception) string {
+	if r == nil || r.Gaps == 0 {
+		return ""
+	}
+	gaps := fmt.Sprintf("%d gaps", r.Gaps)
+	if r.Gaps == 1 {
+		gaps = "1 gap"
+	}
+	label := fmt.Sprintf("loss %.1f%% (%s)", r.Loss()*100, gaps)
+	if r.JitterMs > 0 {
+		label += fmt.Sprintf(", jitter %g ms", r.JitterMs)
+	}
+	return label
+}
+
+// linkReception returns the reception of the edge from srcID to dstID between
+// the same interfaces as edge. Segment edges do not carry it.
+func linkReception(edges map[string]map[string][]*graph.Edge, srcID, dstID string, edge *graph.Edge) *graph.Reception {
+	if srcID == "" {
+		return nil
+	}
+	for _, e := range edges[srcID][dstID] {
+		if e.Reception != nil && e.LocalInterface == edge.LocalInterface && e.RemoteInterface == edge.RemoteInterface {
+			return e.Reception
+		}
+	}
+	return nil
+}
+
+// segmentSource returns the machine the edges of a segment start from: the
+// local node for its own segments, "" for segments of remote nodes, whose
+// edges may start from either member
+func segmentSource(segment graph.NetworkSegment, nodes map[string]*graph.Node) string {
+	for _, nodeID := range segment.ConnectedNodes {
+		if node, ok := nodes[nodeID]; ok && node.IsLocal {
+			return nodeID
+		}
+	}
+	return ""
+}
+
+// orInterface returns the folded VFs of an edge end, else its interface
+func orInterface(vf, iface string) string {
+	if vf != "" {
+		return vf
+	}
+	return iface
+}
+
+// collectConnectedInterfaces returns which interfaces of each machine take part in an edge
+func collectConnectedInterfaces(edges map[string]map[string][]*graph.Edge) map[string]map[string]bool {
+	connectedInterfaces := make(map[string]map[string]bool) // [machineID][interface] -> true
+
+	for srcMachineID, dests := range edges {
+		if connectedInterfaces[srcMachineID] == nil {
+			connectedInterfaces[srcMachineID] = make(map[string]bool)
+		}
+		for dstMachineID, edgeList := range dests {
+			if connectedInterfaces[dstMachineID] == nil {
+				connectedInterfaces[dstMachineID] = make(map[string]bool)
+			}
+			for _, edge := range edgeList {
+				connectedInterfaces[srcMachineID][edge.LocalInterface] = true
+				connectedInterfaces[dstMachineID][edge.RemoteInterface] = true
+			}
+		}
+	}
+
+	return connectedInterfaces
+}
+
+// buildSegmentEdgeMap marks ALL edges between members of the same segment
+// (both direct and indirect) so they can be hidden behind the segment hub.
+// Keyed by "nodeA:nodeB", then by interface name.
+func buildSegmentEdgeMap(nodes map[string]*graph.Node, segments []graph.NetworkSegment) map[string]map[string]bool {
+	segmentEdgeMap := make(map[string]map[string]bool) // [nodeA:nodeB][interface] -> true
+
+	for _, segment := range segments {
+		// Build a map of which interfaces each node uses in this segment
+		// A node can have MULTIPLE interfaces on the same segment (e.g., wired + WiFi)
+		nodeInterfaces := make(map[string][]string) // nodeID -> list of interface names
+
+		// Collect interface information from EdgeInfo
+		// Sort node IDs for deterministic processing
+		var edgeInfoNodeIDs []string
+		for nodeID := range segment.EdgeInfo {
+			edgeInfoNodeIDs = append(edgeInfoNodeIDs, nodeID)
+		}
+		sort.Strings(edgeInfoNodeIDs)
+
+		for _, nodeID := range edgeInfoNodeIDs {
+			edgeInfo := segment.EdgeInfo[nodeID]
+			if edgeInfo.RemoteInterface != "" {
+				// This is the interface the remote node uses
+				nodeInterfaces[nodeID] = append(nodeInterfaces[nodeID], edgeInfo.RemoteInterface)
+			}
+		}
+
+		// Try to identify the reference/local node by finding LocalInterface
+		var referenceInterfaces []string
+		for _, nodeID := range edgeInfoNodeIDs {
+			edgeInfo := segment.EdgeInfo[nodeID]
+			if edgeInfo.LocalInterface != "" && !containsString(referenceInterfaces, edgeInfo.LocalInterface) {
+				referenceInterfaces = append(referenceInterfaces, edgeInfo.LocalInterface)
+			}
+		}
+
+		// Check ALL nodes for additional interfaces beyond what EdgeInfo provides
+		for _, nodeID := range segment.ConnectedNodes {
+			if node, exists := nodes[nodeID]; exists {
+				for _, ifaceName := range sortedInterfaceNames(node) {
+					// Skip if already added from EdgeInfo
+					if containsString(nodeInterfaces[nodeID], ifaceName) {
+						continue
+					}
+
+					// Check if this interface has any of the segment's prefixes
+					for _, ifacePrefix := range node.Interfaces[ifaceName].GlobalPrefixes {
+						if containsString(segment.NetworkPrefixes, ifacePrefix) {
+							nodeInterfaces[nodeID] = append(nodeInterfaces[nodeID], ifaceName)
+						}
+					}
+				}
+			}
+
+			// If still no interfaces found, use reference interfaces as fallback
+			if len(nodeInterfaces[nodeID]) == 0 && len(referenceInterfaces) > 0 {
+				nodeInterfaces[nodeID] = referenceInterfaces
+			}
+
+			// Final fallback: use segment.Interface
+			if len(nodeInterfaces[nodeID]) == 0 {
+				nodeInterfaces[nodeID] = []string{segment.Interface}
+			}
+		}
+
+		// For each pair of nodes in this segment, mark ALL interface combinations
+		for i, nodeA := range segment.ConnectedNodes {
+			for j, nodeB := range segment.ConnectedNodes {
+				if i >= j {
+					continue // Skip self and duplicates
+				}
+
+				key1 := nodeA + ":" + nodeB
+				key2 := nodeB + ":" + nodeA
+
+				for _, interfaceA := range nodeInterfaces[nodeA] {
+					for _, interfaceB := range nodeInterfaces[nodeB] {
+						if segmentEdgeMap[key1] == nil {
+							segmentEdgeMap[key1] = make(map[string]bool)
+						}
+						if segmentEdgeMap[key2] == nil {
+							segmentEdgeMap[key2] = make(map[string]bool)
+						}
+
+						// Mark BOTH interfaces on BOTH directions
+						// This ensures we catch edges regardless of which node is src/dst
+						segmentEdgeMap[key1][interfaceA] = true
+						segmentEdgeMap[key1][interfaceB] = true
+						segmentEdgeMap[key2][interfaceA] = true
+						segmentEdgeMap[key2][interfaceB] = true
+					}
+				}
+			}
+		}
+	}
+
+	return segmentEdgeMap
+}
+
+// segmentNodeInterfaces returns all interfaces a node uses on a segment
+// (e.g., wired + WiFi), sorted for deterministic output
+func segmentNodeInterfaces(segment graph.NetworkSegment, nodeID string, nodes map[string]*graph.Node, connectedInterfaces map[string]map[string]bool) []string {
+	var nodeInterfaces []string
+
+	// First, get from EdgeInfo if available
+	if edge, hasEdge := segment.EdgeInfo[nodeID]; hasEdge && edge.RemoteInterface != "" {
+		nodeInterfaces = append(nodeInterfaces, edge.RemoteInterface)
+	}
+
+	// Then, find any other interfaces with matching prefixes
+	if node, exists := nodes[nodeID]; exists {
+		for _, ifaceName := range sortedInterfaceNames(node) {
+			if containsString(nodeInterfaces, ifaceName) {
+				continue
+			}
+			for _, ifacePrefix := range node.Interfaces[ifaceName].GlobalPrefixes {
+				if containsString(segment.NetworkPrefixes, ifacePrefix) {
+					nodeInterfaces = append(nodeInterfaces, ifaceName)
+					break
+				}
+			}
+		}
+	}
+
+	// If no interfaces found, fall back to any connected interface
+	if len(nodeInterfaces) == 0 {
+		for iface := range connectedInterfaces[nodeID] {
+			nodeInterfaces = append(nodeInterfaces, iface)
+			break // Just use first one as fallback
+		}
+	}
+
+	sort.Strings(nodeInterfaces)
+	return nodeInterfaces
+}
+
+// sortedNodeIDs returns machine IDs in deterministic order
+func sortedNodeIDs(nodes map[string]*graph.Node) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedEdgeSources returns source machine IDs of the edge map in deterministic order
+func sortedEdgeSources(edges map[string]map[string][]*graph.Edge) []string {
+	ids := make([]string, 0, len(edges))
+	for id := range edges {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedInterfaceNames returns the interface names of a node in deterministic order
+func sortedInterfaceNames(node *graph.Node) []string {
+	names := make([]string, 0, len(node.Interfaces))
+	for name := range node.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containsString checks if a string slice contains a specific string
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
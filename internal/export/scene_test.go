@@ -0,0 +1,271 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func TestRDMALabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary string
+		devices []graph.RDMADevice
+		want    string
+	}{
+		{"none", "", nil, ""},
+		{"older peer", "mlx5_0", nil, "mlx5_0"},
+		{"port 1", "mlx5_0", []graph.RDMADevice{{Name: "mlx5_0", Ports: []graph.RDMAPort{{Port: 1}}}}, "mlx5_0"},
+		{"port 2", "mlx4_0", []graph.RDMADevice{{Name: "mlx4_0", Ports: []graph.RDMAPort{{Port: 2}}}}, "mlx4_0:2"},
+		{"both ports", "mlx4_0", []graph.RDMADevice{{Name: "mlx4_0", Ports: []graph.RDMAPort{{Port: 1}, {Port: 2}}}}, "mlx4_0:1/2"},
+		{"two devices", "mlx5_0", []graph.RDMADevice{{Name: "mlx5_0"}, {Name: "mlx5_1"}}, "mlx5_0, mlx5_1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rdmaLabel(tt.primary, tt.devices); got != tt.want {
+				t.Errorf("rdmaLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportMultipleRDMADevices(t *testing.T) {
+	devices := []graph.RDMADevice{
+		{Name: "mlx5_0", Ports: []graph.RDMAPort{{Port: 1}}},
+		{Name: "mlx5_1", Ports: []graph.RDMAPort{{Port: 1}}},
+	}
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"ib0": {IPAddress: "fe80::1%ib0", RDMADevice: "mlx5_0", RDMADevices: devices, Speed: 100000},
+	})
+	g.AddOrUpdate("remote-id", "remote-host", "ib1", "fe80::2", "ib0", "mlx4_0", "", "", 56000, nil, true, "")
+	g.SetInterfaceRDMA("remote-id", "ib1", []graph.RDMADevice{{Name: "mlx4_0", Ports: []graph.RDMAPort{{Port: 2}}}})
+
+	nodes, edges := g.GetNodes(), g.GetEdges()
+	dot := GenerateDOT(nodes, edges)
+	for _, want := range []string{"[mlx5_0, mlx5_1]", "[mlx4_0:2]", "color=blue"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	nwdiag := ExportNwdiag(nodes, edges, nil)
+	for _, want := range []string{"ib0, mlx5_0, mlx5_1", "ib1, mlx4_0:2"} {
+		if !strings.Contains(nwdiag, want) {
+			t.Errorf("nwdiag output missing %q:\n%s", want, nwdiag)
+		}
+	}
+}
+
+func TestDOTAdapterGroups(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"ens1f0": {IPAddress: "fe80::1", RDMADevice: "mlx5_0", SysImageGUID: "0c42:a103:0000:0001"},
+		"ens1f1": {IPAddress: "fe80::2", RDMADevice: "mlx5_1", SysImageGUID: "0c42:a103:0000:0001"},
+		"eth0":   {IPAddress: "fe80::3"},
+	})
+	g.AddOrUpdate("remote-id", "remote-host", "ens1f0", "fe80::11", "ens1f0", "mlx5_0", "", "0c42:a103:0000:0002", 100000, nil, true, "")
+	g.AddOrUpdate("remote-id", "remote-host", "ens1f1", "fe80::12", "ens1f1", "mlx5_1", "", "0c42:a103:0000:0002", 100000, nil, true, "")
+	g.AddOrUpdate("remote-id", "remote-host", "eth0", "fe80::13", "eth0", "", "", "", 1000, nil, true, "")
+	// The second host of a multi-host adapter
+	g.AddOrUpdate("shared-id", "shared-host", "ens2", "fe80::21", "eth0", "mlx5_2", "", "0c42:a103:0000:0001", 100000, nil, true, "")
+
+	dot := GenerateDOTWithSegments(g.GetNodes(), g.GetEdges(), nil)
+	for _, want := range []string{
+		"subgraph cluster_local-id_adapter_0 {",
+		"label=\"adapter 0c42:a103:0000:0001\\nshared with shared-host\";",
+		"subgraph cluster_remote-id_adapter_0 {",
+		"label=\"adapter 0c42:a103:0000:0002\";",
+		"subgraph cluster_shared-id_adapter_0 {",
+		"label=\"adapter 0c42:a103:0000:0001\\nshared with local-host\";",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	// Grouped interfaces are inside the adapter cluster, others are not
+	start := strings.Index(dot, "subgraph cluster_local-id_adapter_0 {")
+	block := dot[start : start+strings.Index(dot[start:], "    }\n")]
+	if !strings.Contains(block, "\"local-id__ens1f0\"") || !strings.Contains(block, "\"local-id__ens1f1\"") || strings.Contains(block, "eth0") {
+		t.Errorf("unexpected adapter cluster:\n%s", block)
+	}
+	if strings.Count(dot, "\"local-id__ens1f0\" [label=") != 1 {
+		t.Errorf("expected ens1f0 to be drawn once:\n%s", dot)
+	}
+}
+
+func TestInterfaceTooltip(t *testing.T) {
+	et := &graph.Ethtool{Duplex: "full", Autoneg: "off", Port: "DA", Driver: "mlx5_core", DriverVersion: "6.8.0",
+		FirmwareVersion: "22.36.1010", BusInfo: "0000:3b:00.0"}
+	want := []string{"driver mlx5_core 6.8.0", "firmware 22.36.1010", "bus 0000:3b:00.0", "full duplex, autoneg off, DA"}
+	if got := interfaceTooltip(et); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("interfaceTooltip() = %q, want %q", got, want)
+	}
+	if got := interfaceTooltip(nil); got != nil {
+		t.Errorf("expected no tooltip without link settings, got %q", got)
+	}
+
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"ens1f0": {IPAddress: "fe80::1", Ethtool: et},
+		"eth0":   {IPAddress: "fe80::2"},
+	})
+	g.AddOrUpdate("remote-id", "remote-host", "ens1f0", "fe80::11", "ens1f0", "", "", "", 100000, nil, true, "")
+	g.AddOrUpdate("remote-id", "remote-host", "eth0", "fe80::12", "eth0", "", "", "", 1000, nil, true, "")
+	dot := GenerateDOT(g.GetNodes(), g.GetEdges())
+	if !strings.Contains(dot, `tooltip="driver mlx5_core 6.8.0\nfirmware 22.36.1010\nbus 0000:3b:00.0\nfull duplex, autoneg off, DA"`) {
+		t.Errorf("DOT output missing tooltip:\n%s", dot)
+	}
+	if strings.Count(dot, "tooltip=") != 1 {
+		t.Errorf("expected a tooltip only on ens1f0:\n%s", dot)
+	}
+}
+
+func TestExportSRIOV(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "gpu-01", map[string]graph.InterfaceDetails{
+		"ens1f0v0": {IPAddress: "fe80::2", SRIOV: &graph.SRIOV{Role: graph.SRIOVVF, PF: "ens1f0np0", VF: 0}},
+		"ens1f0v1": {IPAddress: "fe80::3", SRIOV: &graph.SRIOV{Role: graph.SRIOVVF, PF: "ens1f0np0", VF: 1}},
+	})
+	g.AddOrUpdate("peer-id", "gpu-02", "eth0", "fe80::12", "ens1f0v0", "", "", "", 100000, nil, true, "")
+	g.AddOrUpdate("peer-id", "gpu-02", "eth0", "fe80::12", "ens1f0v1", "", "", "", 100000, nil, true, "")
+	nodes, edges := g.GetNodes(), g.GetEdges()
+
+	// VFs are drawn on their PF
+	dot := GenerateDOT(nodes, edges)
+	for _, want := range []string{
+		"\"local-id__ens1f0v1\" -- \"local-id__ens1f0np0\" [style=dotted, color=gray];",
+		"\"local-id__ens1f0np0\" [label=\"ens1f0np0\", shape=box, style=\"rounded,dashed\"",
+		"ens1f0v0\\nfe80::2\\nVF 0 of ens1f0np0",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	// Folded into the PF, the link names both VFs
+	nodes, edges, _ = graph.Filter{CollapseVFs: true}.Apply(nodes, edges, nil)
+	dot = GenerateDOT(nodes, edges)
+	if !strings.Contains(dot, "VF ens1f0v0,ens1f0v1 <-> eth0") {
+		t.Errorf("DOT output missing the folded VFs:\n%s", dot)
+	}
+	if strings.Contains(dot, "local-id__ens1f0v0") {
+		t.Errorf("expected VFs to be hidden:\n%s", dot)
+	}
+}
+
+func TestExportHealth(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+		"eth1": {IPAddress: "fe80::2"},
+	})
+	g.AddOrUpdate("peer-a", "host-a", "eth0", "fe80::11", "eth0", "", "", "", 10000, nil, true, "")
+	g.AddOrUpdate("peer-b", "host-b", "eth0", "fe80::21", "eth1", "", "", "", 10000, nil, true, "")
+	g.SetInterfaceHealth("local-id", "eth0", &graph.Health{Status: graph.HealthDegraded, RxErrors: 2, RxCRCErrors: 2})
+	g.SetInterfaceHealth("peer-b", "eth0", &graph.Health{Status: graph.HealthOK})
+	nodes, edges := g.GetNodes(), g.GetEdges()
+
+	dot := GenerateDOT(nodes, edges)
+	if !strings.Contains(dot, "eth0 degraded: rx errors 2.00/s, crc 2.00/s\", color=\"red\", fontcolor=\"red\"") {
+		t.Errorf("DOT output missing the degraded link:\n%s", dot)
+	}
+	if strings.Count(dot, "fontcolor=\"red\"") != 1 {
+		t.Errorf("expected only the degraded link to be red:\n%s", dot)
+	}
+
+	nwdiag := ExportNwdiag(nodes, edges, nil)
+	if strings.Count(nwdiag, "color = \"#FF6347\"") != 1 || !strings.Contains(nwdiag, "(eth0, degraded)") {
+		t.Errorf("nwdiag output missing the degraded network:\n%s", nwdiag)
+	}
+}
+
+func TestExportFlaps(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+		"eth1": {IPAddress: "fe80::2"},
+	})
+	g.AddOrUpdate("peer-a", "host-a", "eth0", "fe80::11", "eth0", "", "", "", 10000, nil, true, "")
+	g.AddOrUpdate("peer-b", "host-b", "eth0", "fe80::21", "eth1", "", "", "", 10000, nil, true, "")
+	g.RecordFlaps("peer-a", "eth0", 3)
+	g.RecordFlaps("local-id", "eth1", 1)
+	nodes, edges := g.GetNodes(), g.GetEdges()
+
+	dot := GenerateDOT(nodes, edges)
+	for _, want := range []string{
+		"FLAPPING: 3 flaps, dampened\", color=\"darkorange\", fontcolor=\"darkorange\"",
+		"10000 Mbps\\n1 flap\", penwidth=3.0",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	nwdiag := ExportNwdiag(nodes, edges, nil)
+	if strings.Count(nwdiag, "color = \"#FF8C00\"") != 1 {
+		t.Errorf("nwdiag output missing the dampened network:\n%s", nwdiag)
+	}
+
+	// Links of equal penalty on one interface resolve to the one with more flaps
+	now := time.Now()
+	tied := map[string]map[string][]*graph.Edge{
+		"peer-a": {"local-id": {{LocalInterface: "eth0", RemoteInterface: "eth0",
+			Flap: &graph.Flap{Flaps: 2, Penalty: 1500, LastFlap: now}}}},
+		"peer-b": {"local-id": {{LocalInterface: "eth0", RemoteInterface: "eth0",
+			Flap: &graph.Flap{Flaps: 3, Penalty: 1500, LastFlap: now.Add(-time.Minute)}}}},
+	}
+	for i := 0; i < 10; i++ {
+		if flap := interfaceFlap(tied, "local-id", "eth0"); flap == nil || flap.Flaps != 3 {
+			t.Fatalf("expected the link with more flaps, got %+v", flap)
+		}
+	}
+}
+
+func TestExportReception(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+		"eth1": {IPAddress: "fe80::2"},
+	})
+	g.AddOrUpdate("peer-a", "host-a", "eth0", "fe80::11", "eth0", "", "", "", 10000, nil, true, "")
+	g.AddOrUpdate("peer-b", "host-b", "eth0", "fe80::21", "eth1", "", "", "", 10000, nil, true, "")
+	for _, seq := range []uint64{1, 2, 4, 5, 8} {
+		g.RecordSequence("peer-a", "eth0", "eth0", seq, 0)
+	}
+	for seq := uint64(1); seq <= 5; seq++ {
+		g.RecordSequence("peer-b", "eth0", "eth1", seq, 0)
+	}
+	nodes, edges := g.GetNodes(), g.GetEdges()
+
+	dot := GenerateDOT(nodes, edges)
+	if !strings.Contains(dot, "10000 Mbps\\nloss 37.5% (2 gaps)\"") {
+		t.Errorf("DOT output missing the lossy link:\n%s", dot)
+	}
+	if strings.Count(dot, "\\nloss ") != 1 {
+		t.Errorf("expected only the lossy link to be annotated:\n%s", dot)
+	}
+
+	// Segment links take the reception of the local node's edge, not that of
+	// another host reporting a link between interfaces of the same names
+	g.AddOrUpdate("peer-c", "host-c", "eth0", "fe80::31", "eth0", "", "", "", 10000, nil, true, "")
+	nodes, edges = g.GetNodes(), g.GetEdges()
+	edges["other-id"] = map[string][]*graph.Edge{"peer-a": {{
+		LocalInterface:  "eth0",
+		RemoteInterface: "eth0",
+		Direct:          true,
+		Reception:       &graph.Reception{Received: 1, Expected: 10, Gaps: 1},
+	}}}
+	segmented := GenerateDOTWithSegments(nodes, edges, g.GetNetworkSegments())
+	if !strings.Contains(segmented, "\"segment_0\" -- \"peer-a__eth0\" [label=\"fe80::11\\n10000 Mbps\\nloss 37.5% (2 gaps)\"") {
+		t.Errorf("expected the segment link to carry the local reception:\n%s", segmented)
+	}
+
+	r := &graph.Reception{Received: 9, Expected: 10, Gaps: 1, JitterMs: 12.5}
+	if got := receptionLabel(r); got != "loss 10.0% (1 gap), jitter 12.5 ms" {
+		t.Errorf("unexpected reception label %q", got)
+	}
+}
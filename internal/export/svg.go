@@ -0,0 +1,469 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// SVG geometry. Text is rendered with a monospace font so label widths can be
+// estimated without font metrics.
+const (
+	svgCharWidth      = 6.6  // Approximate width of one character at svgFontSize
+	svgLineHeight     = 13.0 // Line height for interface and hub labels
+	svgFontSize       = 11
+	svgEdgeFontSize   = 9
+	svgEdgeLineHeight = 11.0
+	svgPadding        = 10.0 // Inner padding of machine clusters
+	svgIfaceGap       = 8.0  // Vertical gap between interface boxes
+	svgMargin         = 40.0 // Canvas margin
+	svgIdealLength    = 260.0
+	svgIterations     = 300
+	svgGravity        = 0.3
+)
+
+// svgBlock is a laid out element: a machine cluster or a segment hub
+type svgBlock struct {
+	id      string
+	x, y    float64 // Center
+	w, h    float64
+	hub     bool
+	machine *sceneMachine
+	segment *sceneSegment
+}
+
+// GenerateSVG renders the topology as a standalone SVG document without segments
+func GenerateSVG(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge) string {
+	return GenerateSVGWithSegments(nodes, edges, nil)
+}
+
+// GenerateSVGWithSegments renders the topology as a standalone SVG document.
+// It uses a built-in force-directed layout so no Graphviz installation is needed,
+// and follows the same visual conventions as GenerateDOTWithSegments.
+func GenerateSVGWithSegments(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment) string {
+	sc := buildScene(nodes, edges, segments)
+
+	blocks, ifacePos := layoutScene(sc)
+
+	// Canvas bounds
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, b := range blocks {
+		minX = math.Min(minX, b.x-b.w/2)
+		minY = math.Min(minY, b.y-b.h/2)
+		maxX = math.Max(maxX, b.x+b.w/2)
+		maxY = math.Max(maxY, b.y+b.h/2)
+	}
+	if len(blocks) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	width := maxX - minX + 2*svgMargin
+	height := maxY - minY + 2*svgMargin
+	offsetX := svgMargin - minX
+	offsetY := svgMargin - minY
+
+	var sb strings.Builder
+
+	sb.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	sb.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" font-family=\"monospace\" font-size=\"%d\">\n",
+		width, height, width, height, svgFontSize))
+	sb.WriteString("  <title>lldiscovery</title>\n")
+	sb.WriteString("  <!-- Each machine is a cluster with interface nodes -->\n")
+	sb.WriteString("  <!-- Direct links: bold lines, indirect links: dashed lines -->\n")
+	sb.WriteString("  <!-- RDMA-to-RDMA connections: blue, thickness based on speed -->\n")
+	sb.WriteString("  <!-- Links with a degraded interface: red -->\n")
+	sb.WriteString("  <!-- Dampened flapping links: dark orange -->\n")
+	sb.WriteString("  <!-- Links losing discovery packets: labelled with loss and jitter -->\n")
+	if len(sc.segments) > 0 {
+		sb.WriteString("  <!-- Network segments: yellow ellipses, individual links within segments hidden -->\n")
+	}
+	sb.WriteString("  <rect width=\"100%\" height=\"100%\" fill=\"white\"/>\n")
+	sb.WriteString(fmt.Sprintf("  <g transform=\"translate(%.1f,%.1f)\">\n", offsetX, offsetY))
+
+	// Machine cluster backgrounds first so links are drawn above them
+	sb.WriteString("    <g class=\"machines\">\n")
+	for _, b := range blocks {
+		if b.hub {
+			continue
+		}
+		m := b.machine
+		color := "black"
+		title := m.hostname
+		if m.local {
+			color = "blue"
+			title += " (local)"
+		}
+		sb.WriteString(fmt.Sprintf("      <g id=\"%s\">\n", svgEscape("cluster_"+m.id)))
+		sb.WriteString(fmt.Sprintf("        <rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" rx=\"8\" fill=\"white\" stroke=\"%s\"/>\n",
+			b.x-b.w/2, b.y-b.h/2, b.w, b.h, color))
+		writeSVGText(&sb, "        ", b.x, b.y-b.h/2+svgPadding+svgLineHeight-3, svgLineHeight, []string{title, m.shortID}, "middle", "")
+		if len(m.ifaces) == 0 {
+			writeSVGText(&sb, "        ", b.x, b.y+b.h/2-svgPadding-3, svgLineHeight, []string{"(no connections)"}, "middle", "gray")
+		}
+		sb.WriteString("      </g>\n")
+	}
+	sb.WriteString("    </g>\n")
+
+	// Links between segment hubs and interfaces, and between interfaces
+	sb.WriteString("    <g class=\"links\" fill=\"none\">\n")
+	hubPos := make(map[string]svgPoint)
+	for _, b := range blocks {
+		if b.hub {
+			hubPos[b.id] = svgPoint{b.x, b.y}
+		}
+	}
+	for _, b := range blocks {
+		if !b.hub {
+			continue
+		}
+		for _, link := range b.segment.links {
+			to, ok := ifacePos[link.to]
+			if !ok {
+				continue
+			}
+			penwidth := link.penwidth
+			if penwidth == 0 {
+				penwidth = 2.0
+			}
+			writeSVGLink(&sb, hubPos[link.from], to.center(), link, penwidth, "")
+		}
+	}
+	for _, link := range sc.links {
+		from, okFrom := ifacePos[link.from]
+		to, okTo := ifacePos[link.to]
+		if !okFrom || !okTo {
+			continue
+		}
+		dash := " stroke-dasharray=\"6,4\""
+		penwidth := link.penwidth
+		if link.direct {
+			// Bold: slightly thicker than the speed-based width
+			dash = ""
+			penwidth += 1.0
+		}
+		writeSVGLink(&sb, from.center(), to.center(), link, penwidth, dash)
+	}
+	sb.WriteString("    </g>\n")
+
+	// Interface nodes and segment hubs on top of links
+	sb.WriteString("    <g class=\"nodes\">\n")
+	for _, b := range blocks {
+		if b.hub {
+			sb.WriteString(fmt.Sprintf("      <g id=\"%s\">\n", svgEscape(b.segment.id)))
+			sb.WriteString(fmt.Sprintf("        <ellipse cx=\"%.1f\" cy=\"%.1f\" rx=\"%.1f\" ry=\"%.1f\" fill=\"#ffffcc\" stroke=\"black\"/>\n",
+				b.x, b.y, b.w/2, b.h/2))
+			writeSVGText(&sb, "        ", b.x, b.y-float64(len(b.segment.label)-1)*svgLineHeight/2+4, svgLineHeight, b.segment.label, "middle", "")
+			sb.WriteString("      </g>\n")
+			continue
+		}
+		for _, iface := range b.machine.ifaces {
+			r := ifacePos[iface.id]
+			fill := "white"
+			if iface.rdma {
+				fill = "#e6f3ff"
+			}
+			sb.WriteString(fmt.Sprintf("      <g id=\"%s\">\n", svgEscape(iface.id)))
+			title := append(append([]string(nil), iface.label...), iface.tooltip...)
+			sb.WriteString(fmt.Sprintf("        <title>%s</title>\n", svgEscape(strings.Join(title, "\n"))))
+			sb.WriteString(fmt.Sprintf("        <rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" rx=\"5\" fill=\"%s\" stroke=\"black\"/>\n",
+				r.x, r.y, r.w, r.h, fill))
+			writeSVGText(&sb, "        ", r.x+r.w/2, r.y+svgLineHeight, svgLineHeight, iface.label, "middle", "")
+			sb.WriteString("      </g>\n")
+		}
+	}
+	sb.WriteString("    </g>\n")
+
+	sb.WriteString("  </g>\n")
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+type svgPoint struct {
+	x, y float64
+}
+
+type svgRect struct {
+	x, y, w, h float64 // Top-left corner and size
+}
+
+func (r svgRect) center() svgPoint {
+	return svgPoint{r.x + r.w/2, r.y + r.h/2}
+}
+
+// layoutScene positions machine clusters and segment hubs with a deterministic
+// force-directed layout and returns the blocks and interface box positions
+func layoutScene(sc *scene) ([]*svgBlock, map[string]svgRect) {
+	var blocks []*svgBlock
+	index := make(map[string]int) // Block ID -> index
+	owner := make(map[string]int) // Interface node ID -> owning block index
+
+	for i := range sc.segments {
+		seg := &sc.segments[i]
+		w := float64(maxLineLength(seg.label))*svgCharWidth + 40
+		h := float64(len(seg.label))*svgLineHeight + 24
+		index[seg.id] = len(blocks)
+		blocks = append(blocks, &svgBlock{id: seg.id, w: w, h: h, hub: true, segment: seg})
+	}
+
+	for i := range sc.machines {
+		m := &sc.machines[i]
+		title := m.hostname
+		if m.local {
+			title += " (local)"
+		}
+		w := math.Max(float64(len(title)), float64(len(m.shortID)))*svgCharWidth + 2*svgPadding
+		h := 2*svgLineHeight + 2*svgPadding
+		if len(m.ifaces) == 0 {
+			h += svgLineHeight + svgIfaceGap
+		}
+		for _, iface := range m.ifaces {
+			w = math.Max(w, ifaceBoxWidth(iface)+2*svgPadding)
+			h += ifaceBoxHeight(iface) + svgIfaceGap
+		}
+		index[m.id] = len(blocks)
+		for _, iface := range m.ifaces {
+			owner[iface.id] = len(blocks)
+		}
+		blocks = append(blocks, &svgBlock{id: m.id, w: w, h: h, machine: m})
+	}
+
+	// Block-level adjacency derived from links
+	type pair struct{ a, b int }
+	adjacency := make(map[pair]bool)
+	addEdge := func(a, b int) {
+		if a == b {
+			return
+		}
+		if a > b {
+			a, b = b, a
+		}
+		adjacency[pair{a, b}] = true
+	}
+	for _, seg := range sc.segments {
+		for _, link := range seg.links {
+			if o, ok := owner[link.to]; ok {
+				addEdge(index[seg.id], o)
+			}
+		}
+	}
+	for _, link := range sc.links {
+		a, okA := owner[link.from]
+		b, okB := owner[link.to]
+		if okA && okB {
+			addEdge(a, b)
+		}
+	}
+	var adjacent []pair
+	for i := range blocks {
+		for j := i + 1; j < len(blocks); j++ {
+			if adjacency[pair{i, j}] {
+				adjacent = append(adjacent, pair{i, j})
+			}
+		}
+	}
+
+	// Initial placement: segment hubs on an inner circle, machines on the periphery
+	n := float64(len(blocks))
+	outer := math.Max(svgIdealLength, n*svgIdealLength/(2*math.Pi))
+	hubs := len(sc.segments)
+	for i, b := range blocks {
+		if b.hub {
+			angle := 2 * math.Pi * float64(i) / math.Max(float64(hubs), 1)
+			radius := 0.0
+			if hubs > 1 {
+				radius = outer / 3
+			}
+			b.x, b.y = radius*math.Cos(angle), radius*math.Sin(angle)
+		} else {
+			k := i - hubs
+			angle := 2 * math.Pi * float64(k) / math.Max(float64(len(blocks)-hubs), 1)
+			b.x, b.y = outer*math.Cos(angle), outer*math.Sin(angle)
+		}
+	}
+
+	// Fruchterman-Reingold iterations with linear cooling
+	radius := func(b *svgBlock) float64 {
+		return math.Hypot(b.w, b.h) / 2
+	}
+	dx := make([]float64, len(blocks))
+	dy := make([]float64, len(blocks))
+	for iter := 0; iter < svgIterations && len(blocks) > 1; iter++ {
+		temperature := svgIdealLength * (1 - float64(iter)/svgIterations)
+		for i := range dx {
+			dx[i], dy[i] = 0, 0
+		}
+
+		// Repulsion between all blocks, taking block size into account
+		for i := 0; i < len(blocks); i++ {
+			for j := i + 1; j < len(blocks); j++ {
+				vx := blocks[i].x - blocks[j].x
+				vy := blocks[i].y - blocks[j].y
+				dist := math.Hypot(vx, vy)
+				if dist < 0.01 {
+					// Coincident blocks: separate deterministically
+					vx, vy, dist = float64(j-i), float64(i+1), math.Hypot(float64(j-i), float64(i+1))
+				}
+				k := svgIdealLength/2 + radius(blocks[i]) + radius(blocks[j])
+				force := k * k / dist
+				dx[i] += vx / dist * force
+				dy[i] += vy / dist * force
+				dx[j] -= vx / dist * force
+				dy[j] -= vy / dist * force
+			}
+		}
+
+		// Attraction along links
+		for _, p := range adjacent {
+			a, b := blocks[p.a], blocks[p.b]
+			vx := a.x - b.x
+			vy := a.y - b.y
+			dist := math.Max(math.Hypot(vx, vy), 0.01)
+			k := svgIdealLength/2 + radius(a) + radius(b)
+			force := dist * dist / k
+			dx[p.a] -= vx / dist * force
+			dy[p.a] -= vy / dist * force
+			dx[p.b] += vx / dist * force
+			dy[p.b] += vy / dist * force
+		}
+
+		// Gravity towards the center keeps disconnected parts together
+		for i, b := range blocks {
+			dist := math.Hypot(b.x, b.y)
+			if dist < 0.01 {
+				continue
+			}
+			force := svgGravity * dist * dist / svgIdealLength
+			dx[i] -= b.x / dist * force
+			dy[i] -= b.y / dist * force
+		}
+
+		for i, b := range blocks {
+			disp := math.Hypot(dx[i], dy[i])
+			if disp < 0.01 {
+				continue
+			}
+			step := math.Min(disp, temperature)
+			b.x += dx[i] / disp * step
+			b.y += dy[i] / disp * step
+		}
+	}
+
+	removeOverlaps(blocks)
+
+	// Interface boxes are stacked inside their machine cluster
+	ifacePos := make(map[string]svgRect)
+	for _, b := range blocks {
+		if b.hub {
+			continue
+		}
+		y := b.y - b.h/2 + 2*svgLineHeight + svgPadding + svgIfaceGap
+		for _, iface := range b.machine.ifaces {
+			w := b.w - 2*svgPadding
+			h := ifaceBoxHeight(iface)
+			ifacePos[iface.id] = svgRect{x: b.x - w/2, y: y, w: w, h: h}
+			y += h + svgIfaceGap
+		}
+	}
+
+	return blocks, ifacePos
+}
+
+// removeOverlaps pushes overlapping blocks apart along the axis of least overlap
+func removeOverlaps(blocks []*svgBlock) {
+	const gap = 30.0
+	for pass := 0; pass < 100; pass++ {
+		moved := false
+		for i := 0; i < len(blocks); i++ {
+			for j := i + 1; j < len(blocks); j++ {
+				a, b := blocks[i], blocks[j]
+				overlapX := (a.w+b.w)/2 + gap - math.Abs(a.x-b.x)
+				overlapY := (a.h+b.h)/2 + gap - math.Abs(a.y-b.y)
+				if overlapX <= 0 || overlapY <= 0 {
+					continue
+				}
+				moved = true
+				if overlapX < overlapY {
+					shift := overlapX / 2
+					if a.x < b.x || (a.x == b.x && i < j) {
+						shift = -shift
+					}
+					a.x += shift
+					b.x -= shift
+				} else {
+					shift := overlapY / 2
+					if a.y < b.y || (a.y == b.y && i < j) {
+						shift = -shift
+					}
+					a.y += shift
+					b.y -= shift
+				}
+			}
+		}
+		if !moved {
+			return
+		}
+	}
+}
+
+// writeSVGLink draws a line with an optional label at its midpoint
+func writeSVGLink(sb *strings.Builder, from, to svgPoint, link sceneLink, penwidth float64, extra string) {
+	color := link.color
+	if color == "" {
+		color = "black"
+	}
+	sb.WriteString(fmt.Sprintf("      <line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"%s\" stroke-width=\"%.1f\"%s/>\n",
+		from.x, from.y, to.x, to.y, color, penwidth, extra))
+
+	if strings.Join(link.label, "") == "" {
+		return
+	}
+	midX := (from.x + to.x) / 2
+	midY := (from.y+to.y)/2 - float64(len(link.label)-1)*svgEdgeLineHeight/2
+	sb.WriteString(fmt.Sprintf("      <g font-size=\"%d\" fill=\"#333333\" stroke=\"white\" stroke-width=\"3\" paint-order=\"stroke\">\n", svgEdgeFontSize))
+	writeSVGText(sb, "        ", midX, midY, svgEdgeLineHeight, link.label, "middle", "")
+	sb.WriteString("      </g>\n")
+}
+
+// writeSVGText writes a multi-line text element, one tspan per line
+func writeSVGText(sb *strings.Builder, indent string, x, y, lineHeight float64, lines []string, anchor, fill string) {
+	fillAttr := ""
+	if fill != "" {
+		fillAttr = fmt.Sprintf(" fill=\"%s\"", fill)
+	}
+	sb.WriteString(fmt.Sprintf("%s<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"%s\"%s>", indent, x, y, anchor, fillAttr))
+	for i, line := range lines {
+		if i == 0 {
+			sb.WriteString(fmt.Sprintf("<tspan x=\"%.1f\">%s</tspan>", x, svgEscape(line)))
+		} else {
+			sb.WriteString(fmt.Sprintf("<tspan x=\"%.1f\" dy=\"%.1f\">%s</tspan>", x, lineHeight, svgEscape(line)))
+		}
+	}
+	sb.WriteString("</text>\n")
+}
+
+func ifaceBoxWidth(iface sceneIface) float64 {
+	return float64(maxLineLength(iface.label))*svgCharWidth + 16
+}
+
+func ifaceBoxHeight(iface sceneIface) float64 {
+	return float64(len(iface.label))*svgLineHeight + 8
+}
+
+// maxLineLength returns the length in characters of the longest label line
+func maxLineLength(lines []string) int {
+	longest := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// svgEscape escapes text for use in SVG content and attribute values
+func svgEscape(s string) string {
+	return html.EscapeString(s)
+}
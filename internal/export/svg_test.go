@@ -0,0 +1,128 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// assertWellFormedSVG parses the document to make sure it is valid XML
+func assertWellFormedSVG(t *testing.T, svg string) {
+	t.Helper()
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well-formed XML: %v", err)
+		}
+	}
+}
+
+func TestGenerateSVGWithSegments(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1%eth0", GlobalPrefixes: []string{"192.168.1.0/24"}, Speed: 1000},
+		"ib0":  {IPAddress: "fe80::9%ib0", RDMADevice: "mlx5_0", Speed: 100000},
+	})
+	g.AddOrUpdate("node1-id", "node1", "eth0", "fe80::100", "eth0", "", "", "", 1000, []string{"192.168.1.0/24"}, true, "")
+	g.AddOrUpdate("node2-id", "node2", "eth0", "fe80::200", "eth0", "", "", "", 1000, []string{"192.168.1.0/24"}, true, "")
+	g.AddOrUpdate("node3-id", "node3", "ib0", "fe80::300", "ib0", "mlx5_1", "", "", 100000, nil, true, "")
+	g.AddOrUpdateIndirectEdge("node4-id", "node4", "eth1", "fe80::400", "", "", "", 10000, nil,
+		"eth1", "fe80::101", "", "", "", 10000, nil, "node1-id")
+
+	nodes := g.GetNodes()
+	edges := g.GetEdges()
+	segments := g.GetNetworkSegments()
+	if len(segments) == 0 {
+		t.Fatal("Expected at least one segment to be created")
+	}
+
+	svg := GenerateSVGWithSegments(nodes, edges, segments)
+	assertWellFormedSVG(t, svg)
+
+	if !strings.HasPrefix(svg, "<?xml") || !strings.Contains(svg, "<svg xmlns=\"http://www.w3.org/2000/svg\"") {
+		t.Error("Expected standalone SVG document")
+	}
+
+	// Machines, interfaces and segment hubs
+	for _, id := range []string{"cluster_local-id", "local-id__eth0", "node3-id__ib0", "segment_0"} {
+		if !strings.Contains(svg, fmt.Sprintf("id=\"%s\"", id)) {
+			t.Errorf("Expected element %q in SVG", id)
+		}
+	}
+	if !strings.Contains(svg, "<ellipse") || !strings.Contains(svg, "#ffffcc") {
+		t.Error("Expected yellow segment hub ellipse")
+	}
+
+	// Local machine cluster has a blue border, RDMA interfaces are filled
+	if !strings.Contains(svg, "stroke=\"blue\"/>") {
+		t.Error("Expected blue border for local machine")
+	}
+	if !strings.Contains(svg, "fill=\"#e6f3ff\"") {
+		t.Error("Expected RDMA interface fill")
+	}
+
+	// RDMA-to-RDMA direct link: blue, bold (100G penwidth 5.0 + 1.0)
+	if !strings.Contains(svg, "stroke=\"blue\" stroke-width=\"6.0\"/>") {
+		t.Error("Expected bold blue RDMA link")
+	}
+
+	// Indirect link: dashed
+	if !strings.Contains(svg, "stroke-dasharray=\"6,4\"") {
+		t.Error("Expected dashed indirect link")
+	}
+
+	// Intra-segment links are hidden behind the hub
+	if strings.Contains(svg, "fe80::1%eth0 &lt;-&gt; fe80::100") {
+		t.Error("Expected intra-segment link to be hidden")
+	}
+}
+
+func TestGenerateSVGDeterministic(t *testing.T) {
+	g := graph.New()
+	g.SetLocalNode("local-id", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+	})
+	for i := 0; i < 10; i++ {
+		g.AddOrUpdate(fmt.Sprintf("node%d-id", i), fmt.Sprintf("node%d", i), "eth0", fmt.Sprintf("fe80::%d", i+10), "eth0", "", "", "", 1000, nil, true, "")
+	}
+
+	nodes := g.GetNodes()
+	edges := g.GetEdges()
+
+	first := GenerateSVG(nodes, edges)
+	second := GenerateSVG(nodes, edges)
+	if first != second {
+		t.Error("Expected identical SVG output for identical input")
+	}
+	assertWellFormedSVG(t, first)
+}
+
+func TestGenerateSVGEmptyGraph(t *testing.T) {
+	svg := GenerateSVG(make(map[string]*graph.Node), make(map[string]map[string][]*graph.Edge))
+	assertWellFormedSVG(t, svg)
+	if strings.Contains(svg, "NaN") || strings.Contains(svg, "Inf") {
+		t.Error("Expected finite coordinates for empty graph")
+	}
+}
+
+func TestGenerateSVGEscapesLabels(t *testing.T) {
+	nodes := map[string]*graph.Node{
+		"m1": {Hostname: "a<b>&c", MachineID: "m1", Interfaces: map[string]graph.InterfaceDetails{}},
+	}
+	svg := GenerateSVG(nodes, make(map[string]map[string][]*graph.Edge))
+	assertWellFormedSVG(t, svg)
+	if !strings.Contains(svg, "a&lt;b&gt;&amp;c") {
+		t.Error("Expected hostname to be escaped")
+	}
+	if !strings.Contains(svg, "(no connections)") {
+		t.Error("Expected placeholder for machine without connections")
+	}
+}
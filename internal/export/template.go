@@ -0,0 +1,305 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// TemplateData is the view model passed to user-defined export templates.
+// Field names are part of the template contract: they may be extended but
+// existing fields are not renamed or removed. All slices are sorted so the
+// same topology always renders the same output.
+type TemplateData struct {
+	GeneratedAt    time.Time         // Time the data was collected
+	LocalMachineID string            // Machine ID of the node running the daemon ("" if unknown)
+	Nodes          []TemplateNode    // Sorted by hostname, then machine ID
+	Edges          []TemplateEdge    // Sorted by source hostname, destination hostname, interfaces
+	Segments       []TemplateSegment // Sorted by primary prefix, then interface
+}
+
+// TemplateNode is a discovered machine
+type TemplateNode struct {
+	MachineID  string
+	ShortID    string // First 8 characters of MachineID
+	Hostname   string
+	IsLocal    bool
+	LastSeen   time.Time
+	Labels     map[string]string   // Operator-assigned labels, nil if none
+	Interfaces []TemplateInterface // Sorted by name
+}
+
+// TemplateInterface is a network interface of a machine
+type TemplateInterface struct {
+	Name         string
+	IPAddress    string   // IPv6 link-local address
+	Prefixes     []string // Global unicast network prefixes
+	RDMADevice   string   // First of RDMADevices
+	RDMADevices  []string // Names of all RDMA devices
+	NodeGUID     string
+	SysImageGUID string
+	Speed        int // Link speed in Mbps, 0 if unknown
+}
+
+// TemplateEndpoint is one side of a link
+type TemplateEndpoint struct {
+	MachineID    string
+	Hostname     string
+	Interface    string
+	Address      string
+	Prefixes     []string
+	RDMADevice   string
+	RDMADevices  []string
+	NodeGUID     string
+	SysImageGUID string
+	Speed        int
+}
+
+// TemplateEdge is a link between two interfaces
+type TemplateEdge struct {
+	From        TemplateEndpoint
+	To          TemplateEndpoint
+	Direct      bool   // Observed directly (false when learned through a neighbor)
+	LearnedFrom string // Machine ID of the neighbor that reported an indirect link
+	RDMA        bool   // Both ends have an RDMA device
+	Speed       int    // Effective link speed in Mbps (the slower end), 0 if unknown
+}
+
+// TemplateSegmentMember is a machine attached to a network segment
+type TemplateSegmentMember struct {
+	MachineID  string
+	Hostname   string
+	Interface  string
+	Address    string
+	RDMADevice string
+	Speed      int
+}
+
+// TemplateSegment is a shared network (switch/VLAN) with its members
+type TemplateSegment struct {
+	ID        string
+	Interface string
+	Prefix    string   // Primary network prefix
+	Prefixes  []string // All network prefixes
+	Members   []TemplateSegmentMember
+}
+
+// Template is a named user-defined exporter backed by text/template
+type Template struct {
+	Name string
+	tmpl *template.Template
+}
+
+// templateFuncs are helpers available to every export template
+var templateFuncs = template.FuncMap{
+	"join":      strings.Join,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"replace":   strings.ReplaceAll,
+	"hasPrefix": strings.HasPrefix,
+	"trimZone": func(addr string) string {
+		// Strip "%iface" zone from link-local addresses
+		if i := strings.Index(addr, "%"); i >= 0 {
+			return addr[:i]
+		}
+		return addr
+	},
+}
+
+// ParseTemplate compiles template text into a named exporter
+func ParseTemplate(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %w", name, err)
+	}
+	return &Template{Name: name, tmpl: tmpl}, nil
+}
+
+// LoadTemplate reads and compiles a template file
+func LoadTemplate(name, path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %q: %w", name, err)
+	}
+	return ParseTemplate(name, string(data))
+}
+
+// Render executes the template against the view model
+func (t *Template) Render(data *TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", t.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// NewTemplateData builds the template view model from graph snapshots
+func NewTemplateData(nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment) *TemplateData {
+	data := &TemplateData{
+		GeneratedAt: time.Now(),
+		Nodes:       []TemplateNode{},
+		Edges:       []TemplateEdge{},
+		Segments:    []TemplateSegment{},
+	}
+
+	hostname := func(id string) string {
+		if node, ok := nodes[id]; ok {
+			return node.Hostname
+		}
+		return id
+	}
+
+	for _, id := range sortedNodeIDs(nodes) {
+		node := nodes[id]
+		if node.IsLocal {
+			data.LocalMachineID = id
+		}
+
+		tn := TemplateNode{
+			MachineID:  id,
+			ShortID:    shortMachineID(id),
+			Hostname:   node.Hostname,
+			IsLocal:    node.IsLocal,
+			LastSeen:   node.LastSeen,
+			Labels:     node.Labels,
+			Interfaces: []TemplateInterface{},
+		}
+		for _, name := range sortedInterfaceNames(node) {
+			details := node.Interfaces[name]
+			tn.Interfaces = append(tn.Interfaces, TemplateInterface{
+				Name:         name,
+				IPAddress:    details.IPAddress,
+				Prefixes:     details.GlobalPrefixes,
+				RDMADevice:   details.RDMADevice,
+				RDMADevices:  graph.RDMANames(details.RDMADevice, details.RDMADevices),
+				NodeGUID:     details.NodeGUID,
+				SysImageGUID: details.SysImageGUID,
+				Speed:        details.Speed,
+			})
+		}
+		data.Nodes = append(data.Nodes, tn)
+	}
+	sort.SliceStable(data.Nodes, func(i, j int) bool {
+		return data.Nodes[i].Hostname < data.Nodes[j].Hostname
+	})
+
+	for srcID, dsts := range edges {
+		for dstID, edgeList := range dsts {
+			for _, edge := range edgeList {
+				speed := edge.LocalSpeed
+				if edge.RemoteSpeed > 0 && (speed == 0 || edge.RemoteSpeed < speed) {
+					speed = edge.RemoteSpeed
+				}
+				data.Edges = append(data.Edges, TemplateEdge{
+					From: TemplateEndpoint{
+						MachineID:    srcID,
+						Hostname:     hostname(srcID),
+						Interface:    edge.LocalInterface,
+						Address:      edge.LocalAddress,
+						Prefixes:     edge.LocalPrefixes,
+						RDMADevice:   edge.LocalRDMADevice,
+						RDMADevices:  graph.RDMANames(edge.LocalRDMADevice, edge.LocalRDMADevices),
+						NodeGUID:     edge.LocalNodeGUID,
+						SysImageGUID: edge.LocalSysImageGUID,
+						Speed:        edge.LocalSpeed,
+					},
+					To: TemplateEndpoint{
+						MachineID:    dstID,
+						Hostname:     hostname(dstID),
+						Interface:    edge.RemoteInterface,
+						Address:      edge.RemoteAddress,
+						Prefixes:     edge.RemotePrefixes,
+						RDMADevice:   edge.RemoteRDMADevice,
+						RDMADevices:  graph.RDMANames(edge.RemoteRDMADevice, edge.RemoteRDMADevices),
+						NodeGUID:     edge.RemoteNodeGUID,
+						SysImageGUID: edge.RemoteSysImageGUID,
+						Speed:        edge.RemoteSpeed,
+					},
+					Direct:      edge.Direct,
+					LearnedFrom: edge.LearnedFrom,
+					RDMA:        edge.LocalRDMADevice != "" && edge.RemoteRDMADevice != "",
+					Speed:       speed,
+				})
+			}
+		}
+	}
+	sort.Slice(data.Edges, func(i, j int) bool {
+		a, b := data.Edges[i], data.Edges[j]
+		keyA := []string{a.From.Hostname, a.To.Hostname, a.From.Interface, a.To.Interface, a.From.MachineID, a.To.MachineID}
+		keyB := []string{b.From.Hostname, b.To.Hostname, b.From.Interface, b.To.Interface, b.From.MachineID, b.To.MachineID}
+		for k := range keyA {
+			if keyA[k] != keyB[k] {
+				return keyA[k] < keyB[k]
+			}
+		}
+		return false
+	})
+
+	for _, segment := range segments {
+		ts := TemplateSegment{
+			ID:        segment.ID,
+			Interface: segment.Interface,
+			Prefix:    segment.NetworkPrefix,
+			Prefixes:  segment.NetworkPrefixes,
+			Members:   []TemplateSegmentMember{},
+		}
+		for _, nodeID := range segment.ConnectedNodes {
+			member := TemplateSegmentMember{
+				MachineID: nodeID,
+				Hostname:  hostname(nodeID),
+			}
+			if edge, ok := segment.EdgeInfo[nodeID]; ok && edge != nil {
+				member.Interface = edge.RemoteInterface
+				member.Address = edge.RemoteAddress
+				member.RDMADevice = edge.RemoteRDMADevice
+				member.Speed = edge.RemoteSpeed
+			} else if node, ok := nodes[nodeID]; ok {
+				// No edge info (e.g. the local node): use the interface on the segment's prefixes
+				if name := segmentMemberInterface(segment, node); name != "" {
+					details := node.Interfaces[name]
+					member.Interface = name
+					member.Address = details.IPAddress
+					member.RDMADevice = details.RDMADevice
+					member.Speed = details.Speed
+				}
+			}
+			ts.Members = append(ts.Members, member)
+		}
+		sort.Slice(ts.Members, func(i, j int) bool {
+			if ts.Members[i].Hostname != ts.Members[j].Hostname {
+				return ts.Members[i].Hostname < ts.Members[j].Hostname
+			}
+			return ts.Members[i].MachineID < ts.Members[j].MachineID
+		})
+		data.Segments = append(data.Segments, ts)
+	}
+	sort.SliceStable(data.Segments, func(i, j int) bool {
+		if data.Segments[i].Prefix != data.Segments[j].Prefix {
+			return data.Segments[i].Prefix < data.Segments[j].Prefix
+		}
+		return data.Segments[i].Interface < data.Segments[j].Interface
+	})
+
+	return data
+}
+
+// segmentMemberInterface finds the interface of a node attached to a segment
+func segmentMemberInterface(segment graph.NetworkSegment, node *graph.Node) string {
+	for _, name := range sortedInterfaceNames(node) {
+		for _, prefix := range node.Interfaces[name].GlobalPrefixes {
+			if containsString(segment.NetworkPrefixes, prefix) {
+				return name
+			}
+		}
+	}
+	if _, ok := node.Interfaces[segment.Interface]; ok {
+		return segment.Interface
+	}
+	return ""
+}
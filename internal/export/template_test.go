@@ -0,0 +1,144 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+func createTemplateTestGraph() *graph.Graph {
+	g := graph.New()
+	g.SetLocalNode("local-id-123456789", "local-host", map[string]graph.InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1%eth0", GlobalPrefixes: []string{"192.168.1.0/24"}, Speed: 1000},
+		"ib0":  {IPAddress: "fe80::9%ib0", RDMADevice: "mlx5_0", Speed: 100000},
+	})
+	g.AddOrUpdate("node-b", "bravo", "eth0", "fe80::200", "eth0", "", "", "", 1000, []string{"192.168.1.0/24"}, true, "")
+	g.AddOrUpdate("node-a", "alpha", "eth0", "fe80::100", "eth0", "", "", "", 1000, []string{"192.168.1.0/24"}, true, "")
+	g.AddOrUpdate("node-c", "charlie", "ib0", "fe80::300", "ib0", "mlx5_1", "", "", 100000, nil, true, "")
+	return g
+}
+
+func TestNewTemplateData(t *testing.T) {
+	g := createTemplateTestGraph()
+	data := NewTemplateData(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+
+	if data.LocalMachineID != "local-id-123456789" {
+		t.Errorf("Expected local machine ID, got %q", data.LocalMachineID)
+	}
+
+	// Nodes sorted by hostname
+	var hostnames []string
+	for _, n := range data.Nodes {
+		hostnames = append(hostnames, n.Hostname)
+	}
+	if got := strings.Join(hostnames, ","); got != "alpha,bravo,charlie,local-host" {
+		t.Errorf("Expected nodes sorted by hostname, got %s", got)
+	}
+
+	local := data.Nodes[3]
+	if !local.IsLocal || local.ShortID != "local-id" {
+		t.Errorf("Unexpected local node: %+v", local)
+	}
+	if len(local.Interfaces) != 2 || local.Interfaces[0].Name != "eth0" || local.Interfaces[1].RDMADevice != "mlx5_0" {
+		t.Errorf("Expected sorted interfaces with details, got %+v", local.Interfaces)
+	}
+
+	if len(data.Edges) != 3 {
+		t.Fatalf("Expected 3 edges, got %d", len(data.Edges))
+	}
+	for _, e := range data.Edges {
+		if e.From.Hostname != "local-host" {
+			t.Errorf("Expected edges from local host, got %q", e.From.Hostname)
+		}
+		if e.To.Hostname == "charlie" {
+			if !e.RDMA || e.Speed != 100000 || e.From.RDMADevice != "mlx5_0" || e.To.RDMADevice != "mlx5_1" {
+				t.Errorf("Unexpected RDMA edge: %+v", e)
+			}
+		}
+	}
+	if data.Edges[0].To.Hostname != "alpha" {
+		t.Errorf("Expected edges sorted by destination hostname, got %q first", data.Edges[0].To.Hostname)
+	}
+
+	if len(data.Segments) != 1 {
+		t.Fatalf("Expected 1 segment, got %d", len(data.Segments))
+	}
+	seg := data.Segments[0]
+	if seg.Prefix != "192.168.1.0/24" || len(seg.Members) != 3 {
+		t.Errorf("Unexpected segment: %+v", seg)
+	}
+	if seg.Members[0].Hostname != "alpha" || seg.Members[0].Interface != "eth0" {
+		t.Errorf("Expected members sorted by hostname with interface, got %+v", seg.Members[0])
+	}
+	if seg.Members[2].Hostname != "local-host" || seg.Members[2].Interface != "eth0" || seg.Members[2].Speed != 1000 {
+		t.Errorf("Expected local member resolved from its interfaces, got %+v", seg.Members[2])
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	g := createTemplateTestGraph()
+	data := NewTemplateData(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+
+	tmpl, err := ParseTemplate("cables", `{{range .Edges}}{{.From.Hostname}},{{.From.Interface}},{{.To.Hostname}},{{.To.Interface}},{{trimZone .To.Address}}
+{{end}}{{range .Segments}}{{upper .Interface}} {{join .Prefixes " "}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	out, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	expected := "local-host,eth0,alpha,eth0,fe80::100\n" +
+		"local-host,eth0,bravo,eth0,fe80::200\n" +
+		"local-host,ib0,charlie,ib0,fe80::300\n" +
+		"ETH0 192.168.1.0/24"
+	if out != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := ParseTemplate("bad", "{{range .Nodes}"); err == nil {
+		t.Error("Expected parse error for malformed template")
+	}
+
+	if _, err := LoadTemplate("missing", filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("Expected error for missing template file")
+	}
+
+	tmpl, err := ParseTemplate("unknown-field", "{{.Hosts}}")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	if _, err := tmpl.Render(&TemplateData{}); err == nil {
+		t.Error("Expected render error for unknown field")
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.tmpl")
+	if err := os.WriteFile(path, []byte("{{range .Nodes}}{{.Hostname}} {{end}}"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	tmpl, err := LoadTemplate("hosts", path)
+	if err != nil {
+		t.Fatalf("LoadTemplate failed: %v", err)
+	}
+	if tmpl.Name != "hosts" {
+		t.Errorf("Expected name 'hosts', got %q", tmpl.Name)
+	}
+
+	out, err := tmpl.Render(&TemplateData{Nodes: []TemplateNode{{Hostname: "a"}, {Hostname: "b"}}})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if out != "a b " {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// Adapter is a physical RDMA adapter, recognised by the system image GUID that
+// all of its devices report. Multi-port HCAs expose one device per port, and
+// multi-host adapters are shared by several machines.
+type Adapter struct {
+	SysImageGUID string
+	Members      []AdapterMember // Sorted by machine ID, interface and device
+}
+
+// AdapterMember is an interface backed by a device of an adapter
+type AdapterMember struct {
+	MachineID  string
+	Interface  string
+	RDMADevice string
+	NodeGUID   string
+}
+
+// MultiHost reports whether the adapter is shared by several machines
+func (a Adapter) MultiHost() bool {
+	for _, m := range a.Members {
+		if m.MachineID != a.Members[0].MachineID {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupAdapters groups the RDMA-backed interfaces of all nodes by system image
+// GUID. Only adapters backing at least two interfaces are returned, sorted by
+// GUID; a single interface says nothing about its adapter that the interface's
+// own GUIDs do not.
+func GroupAdapters(nodes map[string]*Node) []Adapter {
+	byGUID := make(map[string][]AdapterMember)
+	for machineID, node := range nodes {
+		for iface, details := range node.Interfaces {
+			devices := details.RDMADevices
+			if len(devices) == 0 && details.RDMADevice != "" {
+				// Older peers only report one device
+				devices = []RDMADevice{{Name: details.RDMADevice, NodeGUID: details.NodeGUID, SysImageGUID: details.SysImageGUID}}
+			}
+			for _, dev := range devices {
+				if !validGUID(dev.SysImageGUID) {
+					continue
+				}
+				byGUID[dev.SysImageGUID] = append(byGUID[dev.SysImageGUID], AdapterMember{
+					MachineID:  machineID,
+					Interface:  iface,
+					RDMADevice: dev.Name,
+					NodeGUID:   dev.NodeGUID,
+				})
+			}
+		}
+	}
+
+	var adapters []Adapter
+	for guid, members := range byGUID {
+		ifaces := make(map[string]bool)
+		for _, m := range members {
+			ifaces[m.MachineID+":"+m.Interface] = true
+		}
+		if len(ifaces) < 2 {
+			continue
+		}
+
+		sort.Slice(members, func(i, j int) bool {
+			if members[i].MachineID != members[j].MachineID {
+				return members[i].MachineID < members[j].MachineID
+			}
+			if members[i].Interface != members[j].Interface {
+				return members[i].Interface < members[j].Interface
+			}
+			return members[i].RDMADevice < members[j].RDMADevice
+		})
+		adapters = append(adapters, Adapter{SysImageGUID: guid, Members: members})
+	}
+	sort.Slice(adapters, func(i, j int) bool {
+		return adapters[i].SysImageGUID < adapters[j].SysImageGUID
+	})
+	return adapters
+}
+
+// validGUID reports whether a GUID is set; unconfigured devices report zeros
+func validGUID(guid string) bool {
+	return strings.Trim(guid, "0:") != ""
+}
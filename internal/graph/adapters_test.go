@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"testing"
+)
+
+func TestGroupAdapters(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local", "gpu-01", map[string]InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+		// Dual-port HCA: one device per port, same system image GUID
+		"ens1f0": {IPAddress: "fe80::2", RDMADevice: "mlx5_0", NodeGUID: "0c42:a103:0000:0001", SysImageGUID: "0c42:a103:0000:0001",
+			RDMADevices: []RDMADevice{{Name: "mlx5_0", NodeGUID: "0c42:a103:0000:0001", SysImageGUID: "0c42:a103:0000:0001"}}},
+		"ens1f1": {IPAddress: "fe80::3", RDMADevice: "mlx5_1", NodeGUID: "0c42:a103:0000:0002", SysImageGUID: "0c42:a103:0000:0001",
+			RDMADevices: []RDMADevice{{Name: "mlx5_1", NodeGUID: "0c42:a103:0000:0002", SysImageGUID: "0c42:a103:0000:0001"}}},
+		// Single-interface adapter and an unconfigured GUID are not grouped
+		"ib0":  {IPAddress: "fe80::4", RDMADevice: "mlx4_0", SysImageGUID: "0002:c903:0000:0001"},
+		"rxe0": {IPAddress: "fe80::5", RDMADevice: "rxe0", SysImageGUID: "0000:0000:0000:0000"},
+		"rxe1": {IPAddress: "fe80::6", RDMADevice: "rxe1", SysImageGUID: "0000:0000:0000:0000"},
+	})
+	// Multi-host adapter: the peer reports the same system image GUID
+	g.AddOrUpdate("peer", "gpu-02", "ens2", "fe80::7", "ens1f0", "mlx5_2", "0c42:a103:0000:0003", "0c42:a103:0000:0001", 100000, nil, true, "")
+
+	adapters := GroupAdapters(g.GetNodes())
+	if len(adapters) != 1 {
+		t.Fatalf("expected 1 adapter, got %+v", adapters)
+	}
+	a := adapters[0]
+	if a.SysImageGUID != "0c42:a103:0000:0001" {
+		t.Errorf("unexpected GUID %q", a.SysImageGUID)
+	}
+	want := []AdapterMember{
+		{MachineID: "local", Interface: "ens1f0", RDMADevice: "mlx5_0", NodeGUID: "0c42:a103:0000:0001"},
+		{MachineID: "local", Interface: "ens1f1", RDMADevice: "mlx5_1", NodeGUID: "0c42:a103:0000:0002"},
+		{MachineID: "peer", Interface: "ens2", RDMADevice: "mlx5_2", NodeGUID: "0c42:a103:0000:0003"},
+	}
+	if len(a.Members) != len(want) {
+		t.Fatalf("expected %d members, got %+v", len(want), a.Members)
+	}
+	for i := range want {
+		if a.Members[i] != want[i] {
+			t.Errorf("member %d = %+v, want %+v", i, a.Members[i], want[i])
+		}
+	}
+	if !a.MultiHost() {
+		t.Error("expected adapter shared by two machines to be multi-host")
+	}
+
+	// Without the peer the adapter is local to one machine
+	local := map[string]*Node{"local": g.GetNodes()["local"]}
+	if adapters := GroupAdapters(local); len(adapters) != 1 || adapters[0].MultiHost() {
+		t.Errorf("expected one single-host adapter, got %+v", adapters)
+	}
+}
@@ -0,0 +1,330 @@
+package graph
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+)
+
+// Filter selects a subgraph of a topology snapshot.
+// The zero value matches everything. All criteria are combined with AND;
+// list criteria match when any entry matches.
+type Filter struct {
+	Hosts       []string // Hostname glob patterns (path.Match syntax)
+	Labels      []string // Node labels as "key=value" or "key" (label present); all must match
+	Segments    []string // Segment IDs, either stable ("seg-...") or detection IDs ("segment_0")
+	Prefixes    []string // Network prefixes (CIDR); keep links and segments on overlapping prefixes
+	Interfaces  []string // Interface name glob patterns; keep links where either end matches
+	RDMAOnly    bool     // Keep only edges where both ends have an RDMA device
+	DirectOnly  bool     // Keep only directly observed edges
+	Around      string   // Hostname or machine ID; keep only nodes within Hops links of it
+	Hops        int      // Radius for Around, 1 if zero
+	CollapseVFs bool     // Fold SR-IOV VFs into their PF before selecting (see CollapseVFs)
+}
+
+// IsEmpty reports whether the filter matches everything
+func (f Filter) IsEmpty() bool {
+	return len(f.Hosts) == 0 && len(f.Labels) == 0 && len(f.Segments) == 0 &&
+		len(f.Prefixes) == 0 && len(f.Interfaces) == 0 &&
+		!f.RDMAOnly && !f.DirectOnly && f.Around == "" && !f.CollapseVFs
+}
+
+// NeedsSegments reports whether Apply needs network segments to select nodes
+func (f Filter) NeedsSegments() bool {
+	return len(f.Segments) > 0
+}
+
+// Validate checks patterns, prefixes and labels for syntax errors
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string(nil), f.Hosts...), f.Interfaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for _, prefix := range f.Prefixes {
+		if _, err := netip.ParsePrefix(prefix); err != nil {
+			return fmt.Errorf("invalid prefix %q: %w", prefix, err)
+		}
+	}
+	for _, label := range f.Labels {
+		if key, _, _ := strings.Cut(label, "="); key == "" {
+			return fmt.Errorf("invalid label selector %q", label)
+		}
+	}
+	if f.Hops < 0 {
+		return fmt.Errorf("invalid hops %d", f.Hops)
+	}
+	return nil
+}
+
+// hasLinkCriteria reports whether the filter selects individual links
+func (f Filter) hasLinkCriteria() bool {
+	return f.RDMAOnly || f.DirectOnly || len(f.Interfaces) > 0 || len(f.Prefixes) > 0
+}
+
+// matchGlob reports whether a value matches any of the patterns (all match when empty)
+func matchGlob(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchNode reports whether a node passes the hostname and label criteria
+func (f Filter) matchNode(node *Node) bool {
+	if !matchGlob(f.Hosts, node.Hostname) {
+		return false
+	}
+	for _, selector := range f.Labels {
+		key, value, hasValue := strings.Cut(selector, "=")
+		actual, ok := node.Labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLink reports whether an edge passes the RDMA, direct and interface criteria
+func (f Filter) matchLink(edge *Edge) bool {
+	if f.DirectOnly && !edge.Direct {
+		return false
+	}
+	if f.RDMAOnly && (edge.LocalRDMADevice == "" || edge.RemoteRDMADevice == "") {
+		return false
+	}
+	if len(f.Interfaces) > 0 && !matchGlob(f.Interfaces, edge.LocalInterface) && !matchGlob(f.Interfaces, edge.RemoteInterface) {
+		return false
+	}
+	return true
+}
+
+// matchEdge reports whether an edge passes all link criteria
+func (f Filter) matchEdge(edge *Edge) bool {
+	if !f.matchLink(edge) {
+		return false
+	}
+	if len(f.Prefixes) > 0 && !f.matchPrefixes(edge.LocalPrefixes) && !f.matchPrefixes(edge.RemotePrefixes) {
+		return false
+	}
+	return true
+}
+
+// matchPrefixes reports whether any of the prefixes overlaps a filter prefix
+func (f Filter) matchPrefixes(prefixes []string) bool {
+	for _, want := range f.Prefixes {
+		wantPrefix, err := netip.ParsePrefix(want)
+		for _, have := range prefixes {
+			if have == want {
+				return true
+			}
+			if err != nil {
+				continue
+			}
+			if havePrefix, err := netip.ParsePrefix(have); err == nil && havePrefix.Overlaps(wantPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegmentID reports whether a segment is selected by ID
+func (f Filter) matchSegmentID(segment NetworkSegment) bool {
+	if len(f.Segments) == 0 {
+		return true
+	}
+	stableID := segment.StableID()
+	for _, id := range f.Segments {
+		if id == segment.ID || id == stableID {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the subgraph selected by the filter. Inputs are not modified.
+// Segments must be provided when NeedsSegments is true.
+//
+// Nodes are kept when they match the hostname, label and segment criteria.
+// Link criteria (RDMA, direct, interface, prefix) then select edges, and remote
+// nodes left without any edge are dropped. Around keeps the nodes reachable from
+// the given node within Hops selected links. Segments keep only surviving members
+// and are dropped when fewer than two members remain. With CollapseVFs the
+// criteria see the PFs in place of their VFs.
+func (f Filter) Apply(nodes map[string]*Node, edges map[string]map[string][]*Edge, segments []NetworkSegment) (map[string]*Node, map[string]map[string][]*Edge, []NetworkSegment) {
+	if f.IsEmpty() {
+		return nodes, edges, segments
+	}
+	if f.CollapseVFs {
+		nodes, edges, segments = CollapseVFs(nodes, edges, segments)
+	}
+
+	keptNodes := make(map[string]*Node)
+	for id, node := range nodes {
+		if f.matchNode(node) {
+			keptNodes[id] = node
+		}
+	}
+
+	// Segment selection restricts nodes to the members of the selected segments
+	var candidateSegments []NetworkSegment
+	members := make(map[string]bool)
+	for _, segment := range segments {
+		if !f.matchSegmentID(segment) {
+			continue
+		}
+		if len(f.Prefixes) > 0 && !f.matchPrefixes(segment.NetworkPrefixes) {
+			continue
+		}
+		candidateSegments = append(candidateSegments, segment)
+		for _, id := range segment.ConnectedNodes {
+			members[id] = true
+		}
+	}
+	if len(f.Segments) > 0 {
+		for id := range keptNodes {
+			if !members[id] {
+				delete(keptNodes, id)
+			}
+		}
+	}
+
+	keptEdges := selectEdges(edges, keptNodes, f.matchEdge)
+
+	if f.Around != "" {
+		reached := f.neighborhood(keptNodes, keptEdges)
+		for id := range keptNodes {
+			if !reached[id] {
+				delete(keptNodes, id)
+			}
+		}
+		keptEdges = selectEdges(keptEdges, keptNodes, func(*Edge) bool { return true })
+	}
+
+	// Link criteria describe links, so nodes without matching links are not of interest
+	if f.hasLinkCriteria() {
+		connected := make(map[string]bool)
+		for srcID, dests := range keptEdges {
+			for dstID := range dests {
+				connected[srcID] = true
+				connected[dstID] = true
+			}
+		}
+		for id, node := range keptNodes {
+			if !node.IsLocal && !connected[id] {
+				delete(keptNodes, id)
+			}
+		}
+	}
+
+	var keptSegments []NetworkSegment
+	for _, segment := range candidateSegments {
+		var segmentMembers []string
+		edgeInfo := make(map[string]*Edge)
+		for _, nodeID := range segment.ConnectedNodes {
+			if _, ok := keptNodes[nodeID]; !ok {
+				continue
+			}
+			edge, hasEdge := segment.EdgeInfo[nodeID]
+			if hasEdge && !f.matchLink(edge) {
+				continue
+			}
+			segmentMembers = append(segmentMembers, nodeID)
+			if hasEdge {
+				edgeInfo[nodeID] = edge
+			}
+		}
+		if len(segmentMembers) < 2 {
+			continue
+		}
+		segment.ConnectedNodes = segmentMembers
+		segment.EdgeInfo = edgeInfo
+		keptSegments = append(keptSegments, segment)
+	}
+
+	return keptNodes, keptEdges, keptSegments
+}
+
+// selectEdges keeps edges between kept nodes that satisfy match
+func selectEdges(edges map[string]map[string][]*Edge, keptNodes map[string]*Node, match func(*Edge) bool) map[string]map[string][]*Edge {
+	result := make(map[string]map[string][]*Edge)
+	for srcID, dests := range edges {
+		if _, ok := keptNodes[srcID]; !ok {
+			continue
+		}
+		for dstID, edgeList := range dests {
+			if _, ok := keptNodes[dstID]; !ok {
+				continue
+			}
+			var filtered []*Edge
+			for _, edge := range edgeList {
+				if match(edge) {
+					filtered = append(filtered, edge)
+				}
+			}
+			if len(filtered) == 0 {
+				continue
+			}
+			if result[srcID] == nil {
+				result[srcID] = make(map[string][]*Edge)
+			}
+			result[srcID][dstID] = filtered
+		}
+	}
+	return result
+}
+
+// neighborhood returns the nodes within Hops links of the Around node,
+// treating edges as undirected. Around matches a machine ID or a hostname.
+func (f Filter) neighborhood(nodes map[string]*Node, edges map[string]map[string][]*Edge) map[string]bool {
+	reached := make(map[string]bool)
+
+	var start []string
+	for id, node := range nodes {
+		if id == f.Around || node.Hostname == f.Around {
+			start = append(start, id)
+		}
+	}
+	if len(start) == 0 {
+		return reached
+	}
+
+	adjacency := make(map[string][]string)
+	for srcID, dests := range edges {
+		for dstID := range dests {
+			adjacency[srcID] = append(adjacency[srcID], dstID)
+			adjacency[dstID] = append(adjacency[dstID], srcID)
+		}
+	}
+
+	hops := f.Hops
+	if hops == 0 {
+		hops = 1
+	}
+
+	frontier := start
+	for _, id := range start {
+		reached[id] = true
+	}
+	for depth := 0; depth < hops && len(frontier) > 0; depth++ {
+		var next []string
+		for _, id := range frontier {
+			for _, neighbor := range adjacency[id] {
+				if !reached[neighbor] {
+					reached[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return reached
+}
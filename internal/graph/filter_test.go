@@ -0,0 +1,244 @@
+package graph
+
+import (
+	"testing"
+)
+
+func createFilterTestGraph() *Graph {
+	g := New()
+	g.SetLocalNode("local", "gw-01", map[string]InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1"},
+		"ib0":  {IPAddress: "fe80::9", RDMADevice: "mlx5_0"},
+	})
+	g.AddOrUpdate("n1", "compute-01", "eth0", "fe80::11", "eth0", "", "", "", 1000, nil, true, "")
+	g.AddOrUpdate("n2", "compute-02", "eth0", "fe80::12", "eth0", "", "", "", 1000, nil, true, "")
+	g.AddOrUpdate("n3", "storage-01", "eth0", "fe80::13", "eth0", "", "", "", 1000, nil, true, "")
+	g.AddOrUpdate("n1", "compute-01", "ib0", "fe80::21", "ib0", "mlx5_0", "", "", 100000, nil, true, "")
+	g.AddOrUpdateIndirectEdge("n4", "compute-03", "eth0", "fe80::14", "", "", "", 1000, nil,
+		"eth0", "fe80::11", "", "", "", 1000, nil, "n1")
+	return g
+}
+
+func countEdges(edges map[string]map[string][]*Edge) int {
+	count := 0
+	for _, dests := range edges {
+		for _, edgeList := range dests {
+			count += len(edgeList)
+		}
+	}
+	return count
+}
+
+func TestFilter_Empty(t *testing.T) {
+	g := createFilterTestGraph()
+	nodes, edges, segments := g.GetNodes(), g.GetEdges(), g.GetNetworkSegments()
+
+	f := Filter{}
+	if !f.IsEmpty() {
+		t.Error("expected zero filter to be empty")
+	}
+
+	fn, fe, fs := f.Apply(nodes, edges, segments)
+	if len(fn) != len(nodes) || countEdges(fe) != countEdges(edges) || len(fs) != len(segments) {
+		t.Error("expected empty filter to keep everything")
+	}
+}
+
+func TestFilter_Hosts(t *testing.T) {
+	g := createFilterTestGraph()
+
+	f := Filter{Hosts: []string{"gw-*", "compute-0[12]"}}
+	nodes, edges, segments := f.Apply(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+
+	if len(nodes) != 3 {
+		t.Errorf("expected 3 nodes, got %d", len(nodes))
+	}
+	if _, ok := nodes["n3"]; ok {
+		t.Error("expected storage-01 to be filtered out")
+	}
+	if _, ok := edges["local"]["n3"]; ok {
+		t.Error("expected edge to filtered node to be removed")
+	}
+	if countEdges(edges) != 3 {
+		t.Errorf("expected 3 edges, got %d", countEdges(edges))
+	}
+
+	// eth0 segment keeps local, n1, n2
+	for _, seg := range segments {
+		for _, id := range seg.ConnectedNodes {
+			if id == "n3" || id == "n4" {
+				t.Errorf("expected filtered node %s to be removed from segment", id)
+			}
+		}
+		if _, ok := seg.EdgeInfo["n3"]; ok {
+			t.Error("expected filtered node edge info to be removed from segment")
+		}
+	}
+}
+
+func TestFilter_RDMAOnly(t *testing.T) {
+	g := createFilterTestGraph()
+
+	f := Filter{RDMAOnly: true}
+	nodes, edges, segments := f.Apply(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+
+	if countEdges(edges) != 1 {
+		t.Fatalf("expected 1 RDMA edge, got %d", countEdges(edges))
+	}
+	if edges["local"]["n1"][0].LocalInterface != "ib0" {
+		t.Error("expected remaining edge to be the ib0 link")
+	}
+
+	// Local node is always kept, unconnected remotes are dropped
+	if len(nodes) != 2 {
+		t.Errorf("expected 2 nodes (local, n1), got %d", len(nodes))
+	}
+	if len(segments) != 0 {
+		t.Errorf("expected no segments without RDMA members, got %d", len(segments))
+	}
+}
+
+func TestFilter_DirectOnly(t *testing.T) {
+	g := createFilterTestGraph()
+
+	f := Filter{DirectOnly: true}
+	nodes, edges, _ := f.Apply(g.GetNodes(), g.GetEdges(), g.GetNetworkSegments())
+
+	if _, ok := nodes["n4"]; ok {
+		t.Error("expected node reachable only indirectly to be removed")
+	}
+	for _, dests := range edges {
+		for _, edgeList := range dests {
+			for _, edge := range edgeList {
+				if !edge.Direct {
+					t.Error("expected only direct edges")
+				}
+			}
+		}
+	}
+}
+
+func TestFilter_Labels(t *testing.T) {
+	g := createFilterTestGraph()
+	g.SetNodeLabels("n1", map[string]string{"role": "compute", "rack": "r1"})
+	g.SetNodeLabels("n2", map[string]string{"role": "compute", "rack": "r2"})
+	g.SetNodeLabels("local", map[string]string{"role": "gateway"})
+
+	f := Filter{Labels: []string{"role=compute", "rack"}}
+	nodes, _, _ := f.Apply(g.GetNodes(), g.GetEdges(), nil)
+	if len(nodes) != 2 {
+		t.Errorf("expected 2 compute nodes with a rack label, got %d", len(nodes))
+	}
+
+	f = Filter{Labels: []string{"rack=r2"}}
+	nodes, _, _ = f.Apply(g.GetNodes(), g.GetEdges(), nil)
+	if _, ok := nodes["n2"]; !ok || len(nodes) != 1 {
+		t.Errorf("expected only n2, got %d nodes", len(nodes))
+	}
+}
+
+func TestFilter_Segments(t *testing.T) {
+	g := createFilterTestGraph()
+	segments := g.GetNetworkSegments()
+	if len(segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(segments))
+	}
+
+	for _, id := range []string{segments[0].ID, segments[0].StableID()} {
+		f := Filter{Segments: []string{id}}
+		if !f.NeedsSegments() {
+			t.Error("expected segment filter to need segments")
+		}
+		nodes, _, fs := f.Apply(g.GetNodes(), g.GetEdges(), segments)
+		if len(fs) != 1 {
+			t.Errorf("expected segment %s to be kept", id)
+		}
+		if len(nodes) != len(segments[0].ConnectedNodes) {
+			t.Errorf("expected %d segment members, got %d nodes", len(segments[0].ConnectedNodes), len(nodes))
+		}
+	}
+
+	f := Filter{Segments: []string{"seg-00000000"}}
+	nodes, _, fs := f.Apply(g.GetNodes(), g.GetEdges(), segments)
+	if len(nodes) != 0 || len(fs) != 0 {
+		t.Error("expected unknown segment ID to select nothing")
+	}
+}
+
+func TestFilter_PrefixesAndInterfaces(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local", "gw-01", map[string]InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1", GlobalPrefixes: []string{"10.0.1.0/24"}},
+		"eth1": {IPAddress: "fe80::2", GlobalPrefixes: []string{"10.0.2.0/24"}},
+	})
+	g.AddOrUpdate("n1", "a", "eth0", "fe80::11", "eth0", "", "", "", 0, []string{"10.0.1.0/24"}, true, "")
+	g.AddOrUpdate("n2", "b", "eth1", "fe80::12", "eth1", "", "", "", 0, []string{"10.0.2.0/24"}, true, "")
+
+	f := Filter{Prefixes: []string{"10.0.2.0/24"}}
+	nodes, edges, _ := f.Apply(g.GetNodes(), g.GetEdges(), nil)
+	if _, ok := nodes["n1"]; ok || len(nodes) != 2 || countEdges(edges) != 1 {
+		t.Errorf("expected only the 10.0.2.0/24 link, got %d nodes %d edges", len(nodes), countEdges(edges))
+	}
+
+	// Containing prefix overlaps both
+	f = Filter{Prefixes: []string{"10.0.0.0/16"}}
+	_, edges, _ = f.Apply(g.GetNodes(), g.GetEdges(), nil)
+	if countEdges(edges) != 2 {
+		t.Errorf("expected both links for containing prefix, got %d", countEdges(edges))
+	}
+
+	f = Filter{Interfaces: []string{"eth0"}}
+	nodes, edges, _ = f.Apply(g.GetNodes(), g.GetEdges(), nil)
+	if _, ok := nodes["n2"]; ok || countEdges(edges) != 1 {
+		t.Errorf("expected only the eth0 link, got %d edges", countEdges(edges))
+	}
+}
+
+func TestFilter_Around(t *testing.T) {
+	// Chain: local -- n1 -- n2 -- n3
+	g := New()
+	g.SetLocalNode("local", "h0", map[string]InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("n1", "h1", "eth0", "fe80::11", "eth0", "", "", "", 0, nil, true, "")
+	g.AddOrUpdateIndirectEdge("n2", "h2", "eth0", "fe80::12", "", "", "", 0, nil, "eth0", "fe80::11", "", "", "", 0, nil, "n1")
+	g.AddOrUpdateIndirectEdge("n3", "h3", "eth0", "fe80::13", "", "", "", 0, nil, "eth0", "fe80::12", "", "", "", 0, nil, "n2")
+
+	tests := []struct {
+		around string
+		hops   int
+		want   int
+	}{
+		{"h2", 0, 3},   // Default of 1 hop: n1, n2, n3
+		{"n3", 1, 2},   // By machine ID
+		{"h0", 2, 3},   // local, n1, n2
+		{"h0", 3, 4},   // Whole chain
+		{"nope", 1, 0}, // Unknown node selects nothing
+	}
+
+	for _, tt := range tests {
+		f := Filter{Around: tt.around, Hops: tt.hops}
+		nodes, _, _ := f.Apply(g.GetNodes(), g.GetEdges(), nil)
+		if len(nodes) != tt.want {
+			t.Errorf("around %s hops %d: expected %d nodes, got %d", tt.around, tt.hops, tt.want, len(nodes))
+		}
+	}
+}
+
+func TestFilter_Validate(t *testing.T) {
+	valid := Filter{Hosts: []string{"node-*"}, Prefixes: []string{"10.0.0.0/8"}, Labels: []string{"rack=r1"}, Hops: 2}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid filter, got %v", err)
+	}
+
+	invalid := []Filter{
+		{Hosts: []string{"node-["}},
+		{Interfaces: []string{"eth["}},
+		{Prefixes: []string{"not-a-prefix"}},
+		{Labels: []string{"=value"}},
+		{Hops: -1},
+	}
+	for _, f := range invalid {
+		if err := f.Validate(); err == nil {
+			t.Errorf("expected validation error for %+v", f)
+		}
+	}
+}
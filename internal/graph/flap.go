@@ -0,0 +1,232 @@
+package graph
+
+import (
+	"math"
+	"time"
+)
+
+// FlapDampening configures how carrier flaps are penalised, after BGP route
+// flap dampening (RFC 2439). Every flap adds Penalty to the interface; the
+// penalty halves every HalfLife. An interface is dampened once its penalty
+// exceeds Suppress and stays dampened until it decays below Reuse.
+type FlapDampening struct {
+	Penalty  float64
+	Suppress float64
+	Reuse    float64
+	HalfLife time.Duration
+}
+
+// DefaultFlapDampening uses the customary BGP values: three flaps within a
+// few minutes dampen an interface for about half an hour
+var DefaultFlapDampening = FlapDampening{
+	Penalty:  1000,
+	Suppress: 2000,
+	Reuse:    750,
+	HalfLife: 15 * time.Minute,
+}
+
+// maxPenalty caps the penalty so that dampening ends at most four half-lives
+// after the last flap, however long an interface kept flapping
+func (d FlapDampening) maxPenalty() float64 {
+	return d.Reuse * 16
+}
+
+// Flap is the flap history of an interface. On an edge it describes the less
+// stable end of the link.
+type Flap struct {
+	Flaps    int       `json:"flaps"`     // Carrier losses counted
+	Penalty  float64   `json:"penalty"`   // Decayed to the time of the query
+	Dampened bool      `json:"dampened"`  // Changes of the link do not trigger exports
+	LastFlap time.Time `json:"last_flap"` // Time of the latest flap
+}
+
+// flapState is the penalty of an interface as of its last flap
+type flapState struct {
+	flaps      int
+	penalty    float64
+	at         time.Time
+	suppressed bool
+	seq        uint64 // Packet whose flaps were counted last, 0 if unknown
+}
+
+// penaltyAt returns the penalty decayed to now
+func (s *flapState) penaltyAt(now time.Time, d FlapDampening) float64 {
+	elapsed := now.Sub(s.at)
+	if elapsed <= 0 || d.HalfLife <= 0 {
+		return s.penalty
+	}
+	return s.penalty * math.Exp2(-elapsed.Seconds()/d.HalfLife.Seconds())
+}
+
+// dampenedAt reports whether the interface is dampened at now. Reads do not
+// modify the state: a suppressed interface counts as released as soon as its
+// penalty decays below the reuse threshold.
+func (s *flapState) dampenedAt(now time.Time, d FlapDampening) bool {
+	return s.suppressed && s.penaltyAt(now, d) >= d.Reuse
+}
+
+// record adds n flaps at now and reports whether the interface became dampened
+func (s *flapState) record(n int, now time.Time, d FlapDampening) bool {
+	wasDampened := s.dampenedAt(now, d)
+	s.penalty = math.Min(s.penaltyAt(now, d)+float64(n)*d.Penalty, d.maxPenalty())
+	s.flaps += n
+	s.at = now
+	s.suppressed = wasDampened || s.penalty > d.Suppress
+	return s.suppressed && !wasDampened
+}
+
+// flapAt returns the flap history of the interface at now
+func (s *flapState) flapAt(now time.Time, d FlapDampening) *Flap {
+	return &Flap{
+		Flaps:    s.flaps,
+		Penalty:  math.Round(s.penaltyAt(now, d)),
+		Dampened: s.dampenedAt(now, d),
+		LastFlap: s.at,
+	}
+}
+
+// SetFlapDampening replaces the dampening parameters, DefaultFlapDampening
+// unless set. It must be called before flaps are recorded.
+func (g *Graph) SetFlapDampening(d FlapDampening) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dampening = d
+}
+
+// RecordFlaps counts n carrier losses of an interface of a node and reports
+// whether the interface became dampened. Becoming dampened is a change of the
+// graph, the flaps themselves are not.
+func (g *Graph) RecordFlaps(machineID, iface string, n int) bool {
+	return g.RecordSequencedFlaps(machineID, iface, 0, n)
+}
+
+// RecordSequencedFlaps is RecordFlaps for flaps advertised in the packet with
+// sequence number seq of the interface. A packet received on several local
+// interfaces is counted once; seq 0, from agents that do not number their
+// packets, is always counted.
+func (g *Graph) RecordSequencedFlaps(machineID, iface string, seq uint64, n int) bool {
+	if n <= 0 {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.flaps[machineID] == nil {
+		g.flaps[machineID] = make(map[string]*flapState)
+	}
+	state, ok := g.flaps[machineID][iface]
+	if !ok {
+		state = &flapState{}
+		g.flaps[machineID][iface] = state
+	}
+	if seq != 0 && seq == state.seq {
+		return false
+	}
+	state.seq = seq
+	if state.record(n, time.Now(), g.dampening) {
+		g.changed = true
+		return true
+	}
+	return false
+}
+
+// GetInterfaceFlap returns the flap history of an interface of a node, nil if
+// it never flapped or its penalty has been forgotten
+func (g *Graph) GetInterfaceFlap(machineID, iface string) *Flap {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if state, ok := g.flaps[machineID][iface]; ok {
+		return state.flapAt(time.Now(), g.dampening)
+	}
+	return nil
+}
+
+// releaseDampened ends the dampening of interfaces whose penalty decayed below
+// the reuse threshold, which is a change of the graph, and forgets interfaces
+// whose penalty has decayed to nothing. The caller must hold the write lock.
+func (g *Graph) releaseDampened(now time.Time) {
+	for machineID, ifaces := range g.flaps {
+		for iface, state := range ifaces {
+			if state.suppressed && !state.dampenedAt(now, g.dampening) {
+				state.suppressed = false
+				g.changed = true
+			}
+			if state.penaltyAt(now, g.dampening) < 1 {
+				delete(ifaces, iface)
+			}
+		}
+		if len(ifaces) == 0 {
+			delete(g.flaps, machineID)
+		}
+	}
+}
+
+// edgeFlap returns the flap history of the less stable end of an edge from
+// srcID to dstID, nil if neither end flapped. The caller must hold the lock.
+func (g *Graph) edgeFlap(srcID, dstID string, edge *Edge, now time.Time) *Flap {
+	var worst *Flap
+	for _, end := range []struct{ machineID, iface string }{
+		{srcID, edge.LocalInterface},
+		{dstID, edge.RemoteInterface},
+	} {
+		state, ok := g.flaps[end.machineID][end.iface]
+		if !ok {
+			continue
+		}
+		flap := state.flapAt(now, g.dampening)
+		if worst == nil || flap.Dampened && !worst.Dampened ||
+			flap.Dampened == worst.Dampened && flap.Penalty > worst.Penalty {
+			worst = flap
+		}
+	}
+	return worst
+}
+
+// interfaceDampened reports whether changes of an interface of a node should
+// not trigger an export: the interface is dampened, or a link on it has a
+// dampened far end. The caller must hold the lock.
+func (g *Graph) interfaceDampened(machineID, iface string, now time.Time) bool {
+	if len(g.flaps) == 0 {
+		return false
+	}
+	if state, ok := g.flaps[machineID][iface]; ok && state.dampenedAt(now, g.dampening) {
+		return true
+	}
+	for srcID, dests := range g.edges {
+		for dstID, edges := range dests {
+			for _, edge := range edges {
+				var farID, farIface string
+				switch {
+				case srcID == machineID && edge.LocalInterface == iface:
+					farID, farIface = dstID, edge.RemoteInterface
+				case dstID == machineID && edge.RemoteInterface == iface:
+					farID, farIface = srcID, edge.LocalInterface
+				default:
+					continue
+				}
+				if state, ok := g.flaps[farID][farIface]; ok && state.dampenedAt(now, g.dampening) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// edgeChanged marks the graph changed for an edge from srcID to dstID that
+// appeared, changed or went away, unless an end of it is dampened. The caller
+// must hold the write lock.
+func (g *Graph) edgeChanged(srcID, dstID string, edge *Edge, now time.Time) {
+	if flap := g.edgeFlap(srcID, dstID, edge, now); flap == nil || !flap.Dampened {
+		g.changed = true
+	}
+}
+
+// interfaceChanged marks the graph changed for a change of an interface of a
+// node, unless the interface is dampened. The caller must hold the write lock.
+func (g *Graph) interfaceChanged(machineID, iface string) {
+	if !g.interfaceDampened(machineID, iface, time.Now()) {
+		g.changed = true
+	}
+}
@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlapStateDampening(t *testing.T) {
+	d := DefaultFlapDampening
+	start := time.Unix(1000, 0)
+	s := &flapState{}
+
+	if s.record(2, start, d) {
+		t.Error("expected two flaps to stay below the suppress threshold")
+	}
+	if !s.record(1, start.Add(time.Minute), d) {
+		t.Error("expected the third flap within minutes to dampen")
+	}
+	if s.record(1, start.Add(2*time.Minute), d) {
+		t.Error("expected a dampened interface not to become dampened again")
+	}
+
+	// Penalty halves every half-life
+	at := s.at
+	if got := s.penaltyAt(at.Add(d.HalfLife), d); got < s.penalty/2-1 || got > s.penalty/2+1 {
+		t.Errorf("expected half of %.0f after a half-life, got %.0f", s.penalty, got)
+	}
+
+	// Dampened until the penalty decays below reuse, not just below suppress
+	if !s.dampenedAt(at.Add(d.HalfLife), d) {
+		t.Errorf("expected to stay dampened at penalty %.0f", s.penaltyAt(at.Add(d.HalfLife), d))
+	}
+	if s.dampenedAt(at.Add(3*d.HalfLife), d) {
+		t.Errorf("expected dampening to end at penalty %.0f", s.penaltyAt(at.Add(3*d.HalfLife), d))
+	}
+
+	// However long it flapped, dampening ends four half-lives after the last flap
+	s.record(100, at, d)
+	if s.penalty != d.maxPenalty() || s.dampenedAt(at.Add(4*d.HalfLife+time.Second), d) {
+		t.Errorf("expected the penalty to be capped, got %.0f", s.penalty)
+	}
+}
+
+func TestRecordFlaps(t *testing.T) {
+	g := New()
+	g.SetFlapDampening(FlapDampening{Penalty: 1000, Suppress: 2000, Reuse: 750, HalfLife: 50 * time.Millisecond})
+	g.SetLocalNode("local", "local-host", map[string]InterfaceDetails{"eth0": {IPAddress: "fe80::1"}, "eth1": {IPAddress: "fe80::3"}})
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 25000, nil, true, "")
+	g.AddOrUpdate("other", "other-host", "eth0", "fe80::4", "eth1", "", "", "", 25000, nil, true, "")
+	g.ClearChanges()
+
+	if g.RecordFlaps("remote", "ens1f0", 1) || g.HasChanges() {
+		t.Error("expected a single flap not to change the graph")
+	}
+	flap := g.GetEdges()["local"]["remote"][0].Flap
+	if flap == nil || flap.Flaps != 1 || flap.Dampened {
+		t.Errorf("expected the edge to carry one flap, got %+v", flap)
+	}
+	if g.GetEdges()["local"]["other"][0].Flap != nil {
+		t.Error("expected no flap on a stable edge")
+	}
+
+	// The same packet received on a second interface is not counted again
+	if g.RecordSequencedFlaps("remote", "ens1f0", 7, 1) || g.RecordSequencedFlaps("remote", "ens1f0", 7, 1) {
+		t.Error("expected two flaps not to dampen the link")
+	}
+	if flap := g.GetInterfaceFlap("remote", "ens1f0"); flap == nil || flap.Flaps != 2 {
+		t.Errorf("expected a repeated packet to be counted once, got %+v", flap)
+	}
+
+	if !g.RecordFlaps("remote", "ens1f0", 1) || !g.HasChanges() {
+		t.Error("expected dampening to change the graph")
+	}
+	if flap := g.GetEdges()["local"]["remote"][0].Flap; flap == nil || !flap.Dampened || flap.Flaps != 3 {
+		t.Errorf("expected a dampened edge, got %+v", flap)
+	}
+	g.ClearChanges()
+
+	// Changes of both ends of a dampened link do not trigger exports
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 10000, nil, true, "")
+	g.SetInterfaceHealth("local", "eth0", &Health{Status: HealthDegraded, CarrierChanges: 2})
+	if g.HasChanges() {
+		t.Error("expected changes of a dampened link to be suppressed")
+	}
+	if speed := g.GetNodes()["remote"].Interfaces["ens1f0"].Speed; speed != 10000 {
+		t.Errorf("expected the new speed to be recorded, got %d", speed)
+	}
+	g.SetInterfaceHealth("local", "eth1", &Health{Status: HealthDegraded})
+	if !g.HasChanges() {
+		t.Error("expected changes of other links to trigger exports")
+	}
+	g.ClearChanges()
+
+	// Once the penalty decays below reuse, dampening ends with an export
+	time.Sleep(150 * time.Millisecond)
+	g.RemoveExpired(time.Hour)
+	if !g.HasChanges() {
+		t.Error("expected the end of dampening to change the graph")
+	}
+	if flap := g.GetEdges()["local"]["remote"][0].Flap; flap != nil && flap.Dampened {
+		t.Errorf("expected dampening to end, got %+v", flap)
+	}
+}
+
+func TestDampenedLinkExpiresAndReturns(t *testing.T) {
+	g := New()
+	g.SetFlapDampening(FlapDampening{Penalty: 1000, Suppress: 2000, Reuse: 750, HalfLife: time.Hour})
+	g.SetLocalNode("local", "local-host", map[string]InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 25000, nil, true, "")
+	if !g.RecordFlaps("remote", "ens1f0", 3) {
+		t.Fatal("expected three flaps to dampen the link")
+	}
+	g.ClearChanges()
+
+	// The peer goes silent while its carrier is down
+	g.nodes["remote"].LastSeen = time.Now().Add(-2 * time.Hour)
+	if removed := g.RemoveExpired(time.Hour); removed != 1 {
+		t.Fatalf("expected the peer to expire, removed %d", removed)
+	}
+	if g.HasChanges() {
+		t.Error("expected a dampened link going away not to change the graph")
+	}
+
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 25000, nil, true, "")
+	if g.HasChanges() {
+		t.Error("expected a dampened link coming back not to change the graph")
+	}
+	if flap := g.GetEdges()["local"]["remote"][0].Flap; flap == nil || !flap.Dampened {
+		t.Errorf("expected the returned edge to stay dampened, got %+v", flap)
+	}
+}
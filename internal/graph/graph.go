@@ -1,8 +1,11 @@
 package graph
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,10 +33,268 @@ type NeighborData struct {
 type InterfaceDetails struct {
 	IPAddress      string
 	GlobalPrefixes []string // Global unicast network prefixes
-	RDMADevice     string
+	RDMADevice     string   // First of RDMADevices, also set for peers that only report one device
 	NodeGUID       string
 	SysImageGUID   string
-	Speed          int // Link speed in Mbps
+	Speed          int          // Link speed in Mbps
+	RDMADevices    []RDMADevice // All RDMA devices carrying the interface, with their ports
+	IPoIB          *IPoIB       // Set for IP-over-InfiniBand interfaces
+	Ethtool        *Ethtool     // Link settings and driver, nil if unknown
+	Stack          *Stack       // VLAN, bond or bridge relationships, nil for plain interfaces
+	SRIOV          *SRIOV       // SR-IOV role and switchdev port identity, nil if neither applies
+	Health         *Health      // Error and drop rates over the sender's last interval, nil if unknown
+}
+
+// Kinds of Stack
+const (
+	StackVLAN   = "vlan"
+	StackBond   = "bond"
+	StackBridge = "bridge"
+)
+
+// Stack relates an interface to the other interfaces of its host: a VLAN on a
+// parent, a bond or bridge with its member ports, or a port enslaved to a bond
+// or bridge. It is sent in discovery packets, hence the JSON tags.
+type Stack struct {
+	Kind     string   `json:"kind,omitempty"`      // StackVLAN, StackBond, StackBridge or "" for a plain port
+	Parent   string   `json:"parent,omitempty"`    // Lower interface of a VLAN
+	VLANID   int      `json:"vlan_id,omitempty"`   // 802.1Q VLAN ID of a VLAN interface
+	BondMode string   `json:"bond_mode,omitempty"` // e.g. "802.3ad", "active-backup"
+	Members  []string `json:"members,omitempty"`   // Bond slaves or bridge ports, sorted
+	Master   string   `json:"master,omitempty"`    // Bond or bridge the interface is a member of
+}
+
+// Lower returns the interfaces a stacked interface is built on: the parent of
+// a VLAN or the members of a bond or bridge
+func (s *Stack) Lower() []string {
+	if s == nil {
+		return nil
+	}
+	if s.Parent != "" {
+		return []string{s.Parent}
+	}
+	return s.Members
+}
+
+// Label describes the interface for display, e.g. "VLAN 100", "bond 802.3ad"
+// or "bridge", "" for plain ports
+func (s *Stack) Label() string {
+	if s == nil {
+		return ""
+	}
+	switch s.Kind {
+	case StackVLAN:
+		return fmt.Sprintf("VLAN %d", s.VLANID)
+	case StackBond:
+		return strings.TrimSpace("bond " + s.BondMode)
+	case StackBridge:
+		return "bridge"
+	}
+	return ""
+}
+
+func stacksEqual(a, b *Stack) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind || a.Parent != b.Parent || a.VLANID != b.VLANID || a.BondMode != b.BondMode ||
+		a.Master != b.Master || len(a.Members) != len(b.Members) {
+		return false
+	}
+	for i := range a.Members {
+		if a.Members[i] != b.Members[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Ethtool holds the link settings and driver of an interface as reported by
+// ethtool. It is sent in discovery packets, hence the JSON tags.
+type Ethtool struct {
+	Duplex          string `json:"duplex,omitempty"`           // "full" or "half"
+	Autoneg         string `json:"autoneg,omitempty"`          // "on" or "off"
+	Port            string `json:"port,omitempty"`             // Connector type, e.g. "TP", "FIBRE", "DA"
+	Driver          string `json:"driver,omitempty"`           // e.g. "mlx5_core"
+	DriverVersion   string `json:"driver_version,omitempty"`   // In-tree drivers report the kernel version
+	FirmwareVersion string `json:"firmware_version,omitempty"` // e.g. "22.36.1010 (MT_0000000359)"
+	BusInfo         string `json:"bus_info,omitempty"`         // PCI address, e.g. "0000:3b:00.0"
+}
+
+// Roles of SRIOV
+const (
+	SRIOVPF          = "pf"
+	SRIOVVF          = "vf"
+	SRIOVRepresentor = "representor"
+)
+
+// SRIOV describes the SR-IOV role of an interface and, for switchdev NICs, its
+// port on the embedded switch. A VF names the PF it belongs to; a representor
+// names the uplink of its switch and the VF it stands for. It is sent in
+// discovery packets, hence the JSON tags.
+type SRIOV struct {
+	Role         string `json:"role,omitempty"`           // SRIOVPF, SRIOVVF, SRIOVRepresentor or "" for a plain switchdev port
+	PF           string `json:"pf,omitempty"`             // PF interface of a VF or representor
+	VF           int    `json:"vf,omitempty"`             // VF index of a VF or representor
+	NumVFs       int    `json:"num_vfs,omitempty"`        // Enabled VFs of a PF
+	PhysPortID   string `json:"phys_port_id,omitempty"`   // Physical port, shared by a PF and its VFs
+	PhysSwitchID string `json:"phys_switch_id,omitempty"` // Embedded switch, shared by its uplinks and representors
+	PhysPortName string `json:"phys_port_name,omitempty"` // Port on the switch, e.g. "p0" or "pf0vf3"
+}
+
+// Label describes the interface for display, e.g. "PF, 8 VFs", "VF 3 of
+// ens1f0np0" or "rep of VF 3", "" for plain interfaces
+func (s *SRIOV) Label() string {
+	if s == nil {
+		return ""
+	}
+	switch s.Role {
+	case SRIOVPF:
+		return fmt.Sprintf("PF, %d VFs", s.NumVFs)
+	case SRIOVVF:
+		if s.PF == "" {
+			return fmt.Sprintf("VF %d", s.VF)
+		}
+		return fmt.Sprintf("VF %d of %s", s.VF, s.PF)
+	case SRIOVRepresentor:
+		return fmt.Sprintf("rep of VF %d", s.VF)
+	}
+	return ""
+}
+
+// Values of Health.Status
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// Health summarises the error and drop counters of an interface over the last
+// sampling interval as rates per second. The sender decides the status from
+// its thresholds. It is sent in discovery packets, hence the JSON tags.
+type Health struct {
+	Status         string  `json:"status"`              // HealthOK or HealthDegraded
+	RxErrors       float64 `json:"rx_errors,omitempty"` // Includes CRC errors
+	TxErrors       float64 `json:"tx_errors,omitempty"`
+	RxDropped      float64 `json:"rx_dropped,omitempty"`
+	TxDropped      float64 `json:"tx_dropped,omitempty"`
+	RxCRCErrors    float64 `json:"rx_crc_errors,omitempty"`
+	RDMAErrors     float64 `json:"rdma_errors,omitempty"`     // Error counters of the interface's RDMA ports
+	CarrierChanges int     `json:"carrier_changes,omitempty"` // Link up/down transitions during the interval
+}
+
+// Degraded reports whether the interface exceeded the sender's thresholds
+func (h *Health) Degraded() bool {
+	return h != nil && h.Status == HealthDegraded
+}
+
+// Summary lists the non-zero rates, e.g. "rx errors 0.50/s, crc 0.50/s,
+// 2 carrier changes", "" if there are none
+func (h *Health) Summary() string {
+	if h == nil {
+		return ""
+	}
+	var parts []string
+	for _, r := range []struct {
+		name string
+		rate float64
+	}{
+		{"rx errors", h.RxErrors},
+		{"tx errors", h.TxErrors},
+		{"crc", h.RxCRCErrors},
+		{"rx drops", h.RxDropped},
+		{"tx drops", h.TxDropped},
+		{"rdma errors", h.RDMAErrors},
+	} {
+		if r.rate > 0 {
+			parts = append(parts, fmt.Sprintf("%s %.2f/s", r.name, r.rate))
+		}
+	}
+	if h.CarrierChanges > 0 {
+		parts = append(parts, fmt.Sprintf("%d carrier changes", h.CarrierChanges))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IPoIB describes an IP-over-InfiniBand interface: the partition it is a member
+// of and the HCA port it runs on. It is sent in discovery packets, hence the
+// JSON tags.
+type IPoIB struct {
+	PKey   string `json:"pkey"`             // Partition key with the full-membership bit set, e.g. "0x8001"
+	Parent string `json:"parent,omitempty"` // Parent of child interfaces such as ib0.8001
+	Mode   string `json:"mode,omitempty"`   // "datagram" or "connected"
+	Device string `json:"device,omitempty"` // HCA, e.g. "mlx5_0"
+	Port   int    `json:"port,omitempty"`   // HCA port number, 0 if unknown
+}
+
+// defaultPKey is the partition every port is a member of unless configured otherwise
+const defaultPKey = "0xffff"
+
+// NormalizePKey returns the partition key in the form "0x8001", with the
+// full-membership bit set so that full and limited members of a partition
+// compare equal. It returns "" for values that are not a 15-bit key.
+func NormalizePKey(pkey string) string {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(pkey), "0x"), 16, 16)
+	if err != nil || v&0x7fff == 0 {
+		return ""
+	}
+	return fmt.Sprintf("0x%04x", v|0x8000)
+}
+
+// PartitionLabel returns a display label for a partition key
+func PartitionLabel(pkey string) string {
+	if pkey == defaultPKey {
+		return "P_Key " + pkey + " (default)"
+	}
+	return "P_Key " + pkey
+}
+
+// RDMADevice is an RDMA device carrying an interface, with the ports of the
+// device that belong to the interface. It is sent in discovery packets, hence
+// the JSON tags.
+type RDMADevice struct {
+	Name         string     `json:"name"`
+	NodeGUID     string     `json:"node_guid,omitempty"`
+	SysImageGUID string     `json:"sys_image_guid,omitempty"`
+	Ports        []RDMAPort `json:"ports,omitempty"`
+}
+
+// RDMANames returns the RDMA device names of an interface, falling back to
+// the single device reported by older peers
+func RDMANames(primary string, devices []RDMADevice) []string {
+	if len(devices) == 0 {
+		if primary == "" {
+			return nil
+		}
+		return []string{primary}
+	}
+	names := make([]string, len(devices))
+	for i, d := range devices {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// RDMAPort describes a port of an RDMA device as read from
+// /sys/class/infiniband/<dev>/ports/<n>/. It is sent in discovery packets,
+// hence the JSON tags.
+type RDMAPort struct {
+	Port      int       `json:"port"`
+	State     string    `json:"state,omitempty"`      // e.g. "ACTIVE"
+	PhysState string    `json:"phys_state,omitempty"` // e.g. "LinkUp"
+	LinkLayer string    `json:"link_layer,omitempty"` // "InfiniBand" or "Ethernet"
+	Rate      string    `json:"rate,omitempty"`       // e.g. "100 Gb/sec (4X EDR)"
+	ActiveMTU int       `json:"active_mtu,omitempty"` // Bytes, estimated from the netdev MTU; 0 if unknown
+	LID       string    `json:"lid,omitempty"`
+	SMLID     string    `json:"sm_lid,omitempty"`
+	GIDs      []RDMAGID `json:"gids,omitempty"` // Populated GID table entries
+}
+
+// RDMAGID is a populated entry of a port's GID table
+type RDMAGID struct {
+	Index  int    `json:"index"`
+	GID    string `json:"gid"`
+	Type   string `json:"type,omitempty"`   // "IB/RoCE v1" or "RoCE v2"
+	Netdev string `json:"netdev,omitempty"` // Network device of RoCE GIDs
 }
 
 type Node struct {
@@ -42,6 +303,7 @@ type Node struct {
 	LastSeen   time.Time
 	Interfaces map[string]InterfaceDetails
 	IsLocal    bool
+	Labels     map[string]string // Operator-assigned labels advertised by the node
 }
 
 type Edge struct {
@@ -51,16 +313,22 @@ type Edge struct {
 	LocalRDMADevice    string
 	LocalNodeGUID      string
 	LocalSysImageGUID  string
-	LocalSpeed         int // Link speed in Mbps
+	LocalSpeed         int          // Link speed in Mbps
+	LocalRDMADevices   []RDMADevice // All RDMA devices of the local interface
 	RemoteInterface    string
 	RemoteAddress      string
 	RemotePrefixes     []string // Global unicast network prefixes
 	RemoteRDMADevice   string
 	RemoteNodeGUID     string
 	RemoteSysImageGUID string
-	RemoteSpeed        int // Link speed in Mbps
+	RemoteSpeed        int          // Link speed in Mbps
+	RemoteRDMADevices  []RDMADevice // All RDMA devices of the remote interface
 	Direct             bool
 	LearnedFrom        string
+	LocalVF            string     // VF folded into LocalInterface by CollapseVFs
+	RemoteVF           string     // VF folded into RemoteInterface by CollapseVFs
+	Flap               *Flap      // Less stable end, set by GetEdges if either end flapped
+	Reception          *Reception // Packets received over a direct edge, set by GetEdges
 }
 
 type Graph struct {
@@ -69,15 +337,40 @@ type Graph struct {
 	localNode *Node
 	edges     map[string]map[string][]*Edge // [localMachineID][remoteMachineID] -> []Edge (multiple edges)
 	changed   bool
+	flaps     map[string]map[string]*flapState // [machineID][interface], see flap.go
+	dampening FlapDampening
+	reception map[string]map[receptionKey]*receptionState // [machineID][link], see reception.go
 }
 
 func New() *Graph {
 	return &Graph{
-		nodes: make(map[string]*Node),
-		edges: make(map[string]map[string][]*Edge),
+		nodes:     make(map[string]*Node),
+		edges:     make(map[string]map[string][]*Edge),
+		flaps:     make(map[string]map[string]*flapState),
+		dampening: DefaultFlapDampening,
+		reception: make(map[string]map[receptionKey]*receptionState),
 	}
 }
 
+// NewFromSnapshot builds a graph from node and edge maps as returned by GetNodes
+// and GetEdges. The node marked IsLocal, if any, becomes the local node. The
+// graph takes ownership of the maps.
+func NewFromSnapshot(nodes map[string]*Node, edges map[string]map[string][]*Edge) *Graph {
+	g := New()
+	for id, node := range nodes {
+		if node.IsLocal && g.localNode == nil {
+			g.localNode = node
+			continue
+		}
+		g.nodes[id] = node
+	}
+	for srcID, dests := range edges {
+		g.edges[srcID] = dests
+	}
+	g.changed = true
+	return g
+}
+
 func (g *Graph) SetLocalNode(machineID, hostname string, interfaces map[string]InterfaceDetails) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -96,6 +389,9 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// A node or interface that appears with a link changes the graph with
+	// the link, so a dampened link coming back does not trigger an export
+	appeared := false
 	node, exists := g.nodes[machineID]
 	if !exists {
 		node = &Node{
@@ -105,7 +401,7 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 			IsLocal:    false,
 		}
 		g.nodes[machineID] = node
-		g.changed = true
+		appeared = true
 	}
 
 	if node.Hostname != hostname {
@@ -127,12 +423,21 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 
 	if existing, ok := node.Interfaces[remoteIface]; !ok || existing.IPAddress != details.IPAddress ||
 		existing.RDMADevice != details.RDMADevice || existing.Speed != details.Speed {
-		node.Interfaces[remoteIface] = details
-		g.changed = true
+		node.Interfaces[remoteIface] = preserveAdvertised(details, existing)
+		if ok {
+			// A bouncing link renegotiates its speed
+			g.interfaceChanged(machineID, remoteIface)
+		} else {
+			appeared = true
+		}
 	}
 
 	// Track edge (connection between interfaces)
-	if g.localNode != nil {
+	if g.localNode == nil {
+		if appeared {
+			g.changed = true
+		}
+	} else {
 		// For indirect edges, receivingIface may be empty
 		if _, ok := g.edges[g.localNode.MachineID]; !ok {
 			g.edges[g.localNode.MachineID] = make(map[string][]*Edge)
@@ -154,6 +459,7 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 			LocalNodeGUID:      localDetails.NodeGUID,
 			LocalSysImageGUID:  localDetails.SysImageGUID,
 			LocalSpeed:         localDetails.Speed,
+			LocalRDMADevices:   localDetails.RDMADevices,
 			RemoteInterface:    remoteIface,
 			RemoteAddress:      sourceIP,
 			RemotePrefixes:     remotePrefixes,
@@ -161,6 +467,7 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 			RemoteNodeGUID:     nodeGUID,
 			RemoteSysImageGUID: sysImageGUID,
 			RemoteSpeed:        remoteSpeed,
+			RemoteRDMADevices:  node.Interfaces[remoteIface].RDMADevices,
 			Direct:             direct,
 			LearnedFrom:        learnedFrom,
 		}
@@ -175,7 +482,7 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 				// Upgrade indirect edge to direct if direct packet arrives
 				if !existingEdge.Direct && direct {
 					edges[i] = edge
-					g.changed = true
+					appeared = true
 				} else if existingEdge.Direct == direct {
 					// Update existing edge of same type
 					*existingEdge = *edge
@@ -188,7 +495,10 @@ func (g *Graph) AddOrUpdate(machineID, hostname, remoteIface, sourceIP, receivin
 		if !found {
 			// Add new edge
 			g.edges[g.localNode.MachineID][machineID] = append(edges, edge)
-			g.changed = true
+			appeared = true
+		}
+		if appeared {
+			g.edgeChanged(g.localNode.MachineID, machineID, edge, node.LastSeen)
 		}
 	}
 }
@@ -210,6 +520,7 @@ func (g *Graph) AddOrUpdateIndirectEdge(
 	defer g.mu.Unlock()
 
 	// Ensure neighbor node exists
+	appeared := false
 	node, exists := g.nodes[neighborMachineID]
 	if !exists {
 		node = &Node{
@@ -219,7 +530,7 @@ func (g *Graph) AddOrUpdateIndirectEdge(
 			IsLocal:    false,
 		}
 		g.nodes[neighborMachineID] = node
-		g.changed = true
+		appeared = true
 	}
 
 	node.LastSeen = time.Now()
@@ -235,8 +546,12 @@ func (g *Graph) AddOrUpdateIndirectEdge(
 	}
 	if existing, ok := node.Interfaces[neighborIface]; !ok || existing.IPAddress != neighborDetails.IPAddress ||
 		existing.RDMADevice != neighborDetails.RDMADevice || existing.Speed != neighborDetails.Speed {
-		node.Interfaces[neighborIface] = neighborDetails
-		g.changed = true
+		node.Interfaces[neighborIface] = preserveAdvertised(neighborDetails, existing)
+		if ok {
+			g.changed = true
+		} else {
+			appeared = true
+		}
 	}
 
 	// Also ensure the intermediate node exists and update its interface
@@ -252,14 +567,18 @@ func (g *Graph) AddOrUpdateIndirectEdge(
 		}
 		if existing, ok := intermediateNode.Interfaces[intermediateIface]; !ok || existing.IPAddress != intermediateDetails.IPAddress ||
 			existing.RDMADevice != intermediateDetails.RDMADevice || existing.Speed != intermediateDetails.Speed {
-			intermediateNode.Interfaces[intermediateIface] = intermediateDetails
+			intermediateNode.Interfaces[intermediateIface] = preserveAdvertised(intermediateDetails, existing)
 			g.changed = true
 		}
 	}
 
 	// Create edge showing the connection between intermediate and neighbor
 	// This edge is from intermediate node's perspective, so we store it there
-	if intermediateExists {
+	if !intermediateExists {
+		if appeared {
+			g.changed = true
+		}
+	} else {
 		if _, ok := g.edges[learnedFrom]; !ok {
 			g.edges[learnedFrom] = make(map[string][]*Edge)
 		}
@@ -300,25 +619,36 @@ func (g *Graph) AddOrUpdateIndirectEdge(
 
 		if !found {
 			g.edges[learnedFrom][neighborMachineID] = append(edges, edge)
-			g.changed = true
+			appeared = true
+		}
+		if appeared {
+			g.edgeChanged(learnedFrom, neighborMachineID, edge, node.LastSeen)
 		}
 	}
 }
 
+// RemoveExpired removes nodes not seen within timeout with their edges and
+// returns how many. It also ends the dampening of interfaces that stopped
+// flapping.
 func (g *Graph) RemoveExpired(timeout time.Duration) int {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
 	now := time.Now()
+	g.releaseDampened(now)
 	removed := 0
 	expiredMachineIDs := []string{}
 
 	for machineID, node := range g.nodes {
 		if now.Sub(node.LastSeen) > timeout {
 			delete(g.nodes, machineID)
+			delete(g.reception, machineID)
 			expiredMachineIDs = append(expiredMachineIDs, machineID)
 			removed++
-			g.changed = true
+			// A node with links changes the graph with them below
+			if !g.hasEdges(machineID) {
+				g.changed = true
+			}
 		}
 	}
 
@@ -340,7 +670,9 @@ func (g *Graph) RemoveExpired(timeout time.Duration) int {
 					if len(dstMap) == 0 {
 						delete(g.edges, srcID)
 					}
-					g.changed = true
+					for _, edge := range edges {
+						g.edgeChanged(srcID, dstID, edge, now)
+					}
 					continue
 				}
 
@@ -357,7 +689,7 @@ func (g *Graph) RemoveExpired(timeout time.Duration) int {
 					if !isLearnedFromExpired {
 						filteredEdges = append(filteredEdges, edge)
 					} else {
-						g.changed = true
+						g.edgeChanged(srcID, dstID, edge, now)
 					}
 				}
 
@@ -376,6 +708,20 @@ func (g *Graph) RemoveExpired(timeout time.Duration) int {
 	return removed
 }
 
+// hasEdges reports whether a node is an end of any edge. The caller must hold
+// the lock.
+func (g *Graph) hasEdges(machineID string) bool {
+	if len(g.edges[machineID]) > 0 {
+		return true
+	}
+	for _, dests := range g.edges {
+		if len(dests[machineID]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *Graph) GetNodes() map[string]*Node {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -390,6 +736,7 @@ func (g *Graph) GetNodes() map[string]*Node {
 			LastSeen:   g.localNode.LastSeen,
 			Interfaces: make(map[string]InterfaceDetails),
 			IsLocal:    true,
+			Labels:     copyLabels(g.localNode.Labels),
 		}
 		for ik, iv := range g.localNode.Interfaces {
 			nodeCopy.Interfaces[ik] = iv
@@ -405,6 +752,7 @@ func (g *Graph) GetNodes() map[string]*Node {
 			LastSeen:   v.LastSeen,
 			Interfaces: make(map[string]InterfaceDetails),
 			IsLocal:    false,
+			Labels:     copyLabels(v.Labels),
 		}
 		for ik, iv := range v.Interfaces {
 			nodeCopy.Interfaces[ik] = iv
@@ -419,6 +767,7 @@ func (g *Graph) GetEdges() map[string]map[string][]*Edge {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
+	now := time.Now()
 	result := make(map[string]map[string][]*Edge)
 	for src, dests := range g.edges {
 		result[src] = make(map[string][]*Edge)
@@ -433,6 +782,7 @@ func (g *Graph) GetEdges() map[string]map[string][]*Edge {
 					LocalNodeGUID:      edge.LocalNodeGUID,
 					LocalSysImageGUID:  edge.LocalSysImageGUID,
 					LocalSpeed:         edge.LocalSpeed,
+					LocalRDMADevices:   edge.LocalRDMADevices,
 					RemoteInterface:    edge.RemoteInterface,
 					RemoteAddress:      edge.RemoteAddress,
 					RemotePrefixes:     edge.RemotePrefixes,
@@ -440,8 +790,17 @@ func (g *Graph) GetEdges() map[string]map[string][]*Edge {
 					RemoteNodeGUID:     edge.RemoteNodeGUID,
 					RemoteSysImageGUID: edge.RemoteSysImageGUID,
 					RemoteSpeed:        edge.RemoteSpeed,
+					RemoteRDMADevices:  edge.RemoteRDMADevices,
 					Direct:             edge.Direct,
 					LearnedFrom:        edge.LearnedFrom,
+					Flap:               edge.Flap,
+					Reception:          edge.Reception,
+				}
+				if flap := g.edgeFlap(src, dst, edge, now); flap != nil {
+					edgeCopies[i].Flap = flap
+				}
+				if reception := g.edgeReception(src, dst, edge); reception != nil {
+					edgeCopies[i].Reception = reception
 				}
 			}
 			result[src][dst] = edgeCopies
@@ -515,6 +874,25 @@ type NetworkSegment struct {
 	NetworkPrefixes []string         // All network prefixes on this segment (both IPv4 and IPv6)
 	ConnectedNodes  []string         // Machine IDs of nodes in this segment
 	EdgeInfo        map[string]*Edge // Map of nodeID -> edge info for connections to segment
+	PKey            string           // InfiniBand partition of IPoIB segments, e.g. "0x8001"; "" otherwise
+	VLANID          int              // 802.1Q VLAN of the segment's interfaces, 0 if untagged or unknown
+}
+
+// StableID returns an identifier that, unlike ID, does not depend on detection order.
+// It is derived from the interface, primary prefix and partition, or from the
+// members when the segment has no prefix.
+func (s NetworkSegment) StableID() string {
+	key := s.Interface + "|" + s.NetworkPrefix
+	if s.PKey != "" {
+		key += "|" + s.PKey
+	}
+	if s.NetworkPrefix == "" {
+		members := append([]string(nil), s.ConnectedNodes...)
+		sort.Strings(members)
+		key += "|" + strings.Join(members, ",")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "seg-" + hex.EncodeToString(sum[:4])
 }
 
 // GetNetworkSegments finds groups of nodes connected to shared network segments
@@ -585,6 +963,8 @@ func (g *Graph) GetNetworkSegments() []NetworkSegment {
 				NetworkPrefixes: allPrefixes,
 				ConnectedNodes:  nodeIDs,
 				EdgeInfo:        edgeInfo,
+				PKey:            g.partition(localID, localIface),
+				VLANID:          g.segmentVLAN(localID, localIface, edgeInfo),
 			})
 			segmentID++
 		}
@@ -598,7 +978,15 @@ func (g *Graph) GetNetworkSegments() []NetworkSegment {
 		localInterfaces[seg.Interface] = true
 	}
 
-	remoteInterfaceGroups := make(map[string]map[string]*Edge) // [interface_name][node_id] = edge
+	// IPoIB interfaces of the same name in different partitions, and VLAN
+	// interfaces of the same name on different VLANs, do not reach each other,
+	// so the partition and VLAN are part of the group key
+	type remoteGroup struct {
+		iface string
+		pkey  string
+		vlan  int
+	}
+	remoteInterfaceGroups := make(map[remoteGroup]map[string]*Edge) // [interface_name, partition, VLAN][node_id] = edge
 
 	for srcID, dests := range g.edges {
 		if srcID == localID {
@@ -621,16 +1009,32 @@ func (g *Graph) GetNetworkSegments() []NetworkSegment {
 						continue
 					}
 
-					if remoteInterfaceGroups[ifaceName] == nil {
-						remoteInterfaceGroups[ifaceName] = make(map[string]*Edge)
+					// Ends in different partitions cannot share a segment;
+					// an unknown partition takes the other end's
+					pkey := g.partition(srcID, ifaceName)
+					if dstKey := g.partition(dstID, ifaceName); pkey == "" {
+						pkey = dstKey
+					} else if dstKey != "" && dstKey != pkey {
+						continue
+					}
+					vlan := g.vlanID(srcID, ifaceName)
+					if dstVLAN := g.vlanID(dstID, ifaceName); vlan == 0 {
+						vlan = dstVLAN
+					} else if dstVLAN != 0 && dstVLAN != vlan {
+						continue
+					}
+					group := remoteGroup{iface: ifaceName, pkey: pkey, vlan: vlan}
+
+					if remoteInterfaceGroups[group] == nil {
+						remoteInterfaceGroups[group] = make(map[string]*Edge)
 					}
 
 					// Add both source and destination to this interface group
-					if _, exists := remoteInterfaceGroups[ifaceName][srcID]; !exists {
-						remoteInterfaceGroups[ifaceName][srcID] = edge
+					if _, exists := remoteInterfaceGroups[group][srcID]; !exists {
+						remoteInterfaceGroups[group][srcID] = edge
 					}
-					if _, exists := remoteInterfaceGroups[ifaceName][dstID]; !exists {
-						remoteInterfaceGroups[ifaceName][dstID] = edge
+					if _, exists := remoteInterfaceGroups[group][dstID]; !exists {
+						remoteInterfaceGroups[group][dstID] = edge
 					}
 				}
 			}
@@ -639,7 +1043,8 @@ func (g *Graph) GetNetworkSegments() []NetworkSegment {
 
 	// Create segments for remote VLANs with 3+ nodes
 	// BUT: verify nodes are actually connected (not just using same interface name)
-	for ifaceName, nodeEdges := range remoteInterfaceGroups {
+	for group, nodeEdges := range remoteInterfaceGroups {
+		ifaceName := group.iface
 		minNodes := 3
 
 		// Special case: For 2-node groups, check if they share a network prefix
@@ -754,6 +1159,8 @@ func (g *Graph) GetNetworkSegments() []NetworkSegment {
 				NetworkPrefixes: allPrefixes,
 				ConnectedNodes:  component,
 				EdgeInfo:        componentEdgeInfo,
+				PKey:            group.pkey,
+				VLANID:          group.vlan,
 			})
 			segmentID++
 		}
@@ -770,6 +1177,14 @@ func (g *Graph) GetNetworkSegments() []NetworkSegment {
 	return segments
 }
 
+// MergeSegments combines segments detected from several points of view (e.g. by
+// different agents) so that each physical segment appears once. Segments on the
+// same network prefix or with the same members are merged.
+func MergeSegments(segments []NetworkSegment) []NetworkSegment {
+	segments = mergeSegmentsByPrefix(segments)
+	return mergeSegmentsByNodeSet(segments, nil)
+}
+
 // getEffectiveSpeed returns the effective speed for an interface
 // WiFi interfaces often report 0, so we default them to 100 Mbps
 func getEffectiveSpeed(speed int, interfaceName string) int {
@@ -889,9 +1304,10 @@ func mergeSegmentsByPrefix(segments []NetworkSegment) []NetworkSegment {
 	prefixGroups := make(map[string][]int) // prefix -> list of segment indices
 
 	for i, seg := range segments {
-		// Only merge segments with non-empty prefixes
+		// Only merge segments with non-empty prefixes, within one partition
 		if seg.NetworkPrefix != "" {
-			prefixGroups[seg.NetworkPrefix] = append(prefixGroups[seg.NetworkPrefix], i)
+			key := seg.NetworkPrefix + "|" + seg.PKey
+			prefixGroups[key] = append(prefixGroups[key], i)
 		}
 	}
 
@@ -901,7 +1317,7 @@ func mergeSegmentsByPrefix(segments []NetworkSegment) []NetworkSegment {
 	nextID := 0
 
 	// Process each prefix group
-	for prefix, indices := range prefixGroups {
+	for _, indices := range prefixGroups {
 		if len(indices) == 1 {
 			// Only one segment with this prefix, keep as-is
 			continue
@@ -982,10 +1398,12 @@ func mergeSegmentsByPrefix(segments []NetworkSegment) []NetworkSegment {
 		result = append(result, NetworkSegment{
 			ID:              fmt.Sprintf("segment_%d", nextID),
 			Interface:       primaryInterface,
-			NetworkPrefix:   prefix,
+			NetworkPrefix:   segments[indices[0]].NetworkPrefix,
 			NetworkPrefixes: prefixList,
 			ConnectedNodes:  nodeList,
 			EdgeInfo:        mergedEdgeInfo,
+			PKey:            segments[indices[0]].PKey,
+			VLANID:          mergedVLAN(segments, indices),
 		})
 		nextID++
 	}
@@ -1000,6 +1418,8 @@ func mergeSegmentsByPrefix(segments []NetworkSegment) []NetworkSegment {
 				NetworkPrefixes: seg.NetworkPrefixes,
 				ConnectedNodes:  seg.ConnectedNodes,
 				EdgeInfo:        seg.EdgeInfo,
+				PKey:            seg.PKey,
+				VLANID:          seg.VLANID,
 			})
 			nextID++
 		}
@@ -1028,7 +1448,8 @@ func mergeSegmentsByNodeSet(segments []NetworkSegment, g *Graph) []NetworkSegmen
 	nodeSetGroups := make(map[string][]int) // nodeSetKey -> list of segment indices
 
 	for i, seg := range segments {
-		key := makeNodeSetKey(seg.ConnectedNodes)
+		// Partitions and VLANs over the same nodes stay separate segments
+		key := makeNodeSetKey(seg.ConnectedNodes) + "|" + seg.PKey + "|" + strconv.Itoa(seg.VLANID)
 		nodeSetGroups[key] = append(nodeSetGroups[key], i)
 	}
 
@@ -1145,6 +1566,8 @@ func mergeSegmentsByNodeSet(segments []NetworkSegment, g *Graph) []NetworkSegmen
 			NetworkPrefixes: prefixList,
 			ConnectedNodes:  nodeList,
 			EdgeInfo:        mergedEdgeInfo,
+			PKey:            segments[indices[0]].PKey,
+			VLANID:          segments[indices[0]].VLANID,
 		})
 		nextID++
 	}
@@ -1159,6 +1582,8 @@ func mergeSegmentsByNodeSet(segments []NetworkSegment, g *Graph) []NetworkSegmen
 				NetworkPrefixes: seg.NetworkPrefixes,
 				ConnectedNodes:  seg.ConnectedNodes,
 				EdgeInfo:        seg.EdgeInfo,
+				PKey:            seg.PKey,
+				VLANID:          seg.VLANID,
 			})
 			nextID++
 		}
@@ -1216,6 +1641,308 @@ func (g *Graph) ClearChanges() {
 	g.changed = false
 }
 
+// SetNodeLabels replaces the labels of a known node (local or remote).
+// Unknown machine IDs are ignored.
+func (g *Graph) SetNodeLabels(machineID string, labels map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	node := g.nodeLocked(machineID)
+	if node == nil || labelsEqual(node.Labels, labels) {
+		return
+	}
+
+	node.Labels = copyLabels(labels)
+	g.changed = true
+}
+
+// SetInterfaceRDMA records the RDMA devices advertised for an interface of a
+// node, on the interface and on the edges ending or starting at it. Unknown
+// nodes and interfaces are ignored.
+func (g *Graph) SetInterfaceRDMA(machineID, iface string, devices []RDMADevice) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	known := g.setAdvertised(machineID, iface, func(details *InterfaceDetails) bool {
+		if rdmaDevicesEqual(details.RDMADevices, devices) {
+			return false
+		}
+		details.RDMADevices = devices
+		return true
+	})
+	if !known {
+		return
+	}
+
+	for srcID, dests := range g.edges {
+		for dstID, edges := range dests {
+			for _, edge := range edges {
+				if dstID == machineID && edge.RemoteInterface == iface {
+					edge.RemoteRDMADevices = devices
+				}
+				if srcID == machineID && edge.LocalInterface == iface {
+					edge.LocalRDMADevices = devices
+				}
+			}
+		}
+	}
+}
+
+// SetInterfaceIPoIB records the IPoIB attributes advertised for an interface
+// of a node. Unknown nodes and interfaces are ignored.
+func (g *Graph) SetInterfaceIPoIB(machineID, iface string, ipoib *IPoIB) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.setAdvertised(machineID, iface, func(details *InterfaceDetails) bool {
+		if (details.IPoIB == nil) != (ipoib == nil) || (ipoib != nil && *details.IPoIB != *ipoib) {
+			details.IPoIB = ipoib
+			return true
+		}
+		return false
+	})
+}
+
+// SetInterfaceEthtool records the link settings and driver advertised for an
+// interface of a node. Unknown nodes and interfaces are ignored.
+func (g *Graph) SetInterfaceEthtool(machineID, iface string, ethtool *Ethtool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.setAdvertised(machineID, iface, func(details *InterfaceDetails) bool {
+		if (details.Ethtool == nil) != (ethtool == nil) || (ethtool != nil && *details.Ethtool != *ethtool) {
+			details.Ethtool = ethtool
+			return true
+		}
+		return false
+	})
+}
+
+// SetInterfaceStack records the VLAN, bond or bridge relationships advertised
+// for an interface of a node. Unknown nodes and interfaces are ignored.
+func (g *Graph) SetInterfaceStack(machineID, iface string, stack *Stack) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.setAdvertised(machineID, iface, func(details *InterfaceDetails) bool {
+		if !stacksEqual(details.Stack, stack) {
+			details.Stack = stack
+			return true
+		}
+		return false
+	})
+}
+
+// SetInterfaceHealth records the health advertised for an interface of a node.
+// Rates change with every sample, so only a change of status marks the graph
+// changed, and not while the interface is dampened. Unknown nodes and
+// interfaces are ignored.
+func (g *Graph) SetInterfaceHealth(machineID, iface string, health *Health) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.setAdvertised(machineID, iface, func(details *InterfaceDetails) bool {
+		changed := (details.Health == nil) != (health == nil) || (health != nil && details.Health.Status != health.Status)
+		details.Health = health
+		return changed
+	})
+}
+
+// SetInterfaceSRIOV records the SR-IOV role and switchdev port identity
+// advertised for an interface of a node. Unknown nodes and interfaces are
+// ignored.
+func (g *Graph) SetInterfaceSRIOV(machineID, iface string, sriov *SRIOV) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.setAdvertised(machineID, iface, func(details *InterfaceDetails) bool {
+		if (details.SRIOV == nil) != (sriov == nil) || (sriov != nil && *details.SRIOV != *sriov) {
+			details.SRIOV = sriov
+			return true
+		}
+		return false
+	})
+}
+
+// nodeLocked returns the node with machineID, the local node included, nil if
+// unknown. Callers hold g.mu.
+func (g *Graph) nodeLocked(machineID string) *Node {
+	if g.localNode != nil && g.localNode.MachineID == machineID {
+		return g.localNode
+	}
+	return g.nodes[machineID]
+}
+
+// interfaceLocked returns the details of an interface of a node and whether
+// the node and interface are known. Callers hold g.mu.
+func (g *Graph) interfaceLocked(machineID, iface string) (InterfaceDetails, bool) {
+	node := g.nodeLocked(machineID)
+	if node == nil {
+		return InterfaceDetails{}, false
+	}
+	details, ok := node.Interfaces[iface]
+	return details, ok
+}
+
+// setAdvertised applies update to the details of an interface of a node and
+// stores them. When update reports a change, the graph is marked changed
+// unless the interface is dampened: a flapping link would otherwise churn
+// exports with every packet. It reports whether the node and interface are
+// known; unknown ones are ignored. Callers hold g.mu for writing.
+func (g *Graph) setAdvertised(machineID, iface string, update func(*InterfaceDetails) bool) bool {
+	node := g.nodeLocked(machineID)
+	if node == nil {
+		return false
+	}
+	details, ok := node.Interfaces[iface]
+	if !ok {
+		return false
+	}
+	if update(&details) {
+		g.interfaceChanged(machineID, iface)
+	}
+	node.Interfaces[iface] = details
+	return true
+}
+
+// vlanID returns the VLAN ID of an interface, 0 for interfaces that are not
+// VLANs or unknown. Callers hold g.mu.
+func (g *Graph) vlanID(machineID, iface string) int {
+	if details, ok := g.interfaceLocked(machineID, iface); ok && details.Stack != nil && details.Stack.Kind == StackVLAN {
+		return details.Stack.VLANID
+	}
+	return 0
+}
+
+// segmentVLAN returns the VLAN of a local segment: the VLAN of the local
+// interface, else the VLAN the members' interfaces agree on. Callers hold g.mu.
+func (g *Graph) segmentVLAN(localID, localIface string, edges map[string]*Edge) int {
+	if vlan := g.vlanID(localID, localIface); vlan != 0 {
+		return vlan
+	}
+	vlan := 0
+	for remoteID, edge := range edges {
+		v := g.vlanID(remoteID, edge.RemoteInterface)
+		if v == 0 {
+			continue
+		}
+		if vlan != 0 && v != vlan {
+			return 0
+		}
+		vlan = v
+	}
+	return vlan
+}
+
+// mergedVLAN returns the VLAN the given segments agree on, 0 if none is known
+// or they disagree
+func mergedVLAN(segments []NetworkSegment, indices []int) int {
+	vlan := 0
+	for _, idx := range indices {
+		v := segments[idx].VLANID
+		if v == 0 {
+			continue
+		}
+		if vlan != 0 && v != vlan {
+			return 0
+		}
+		vlan = v
+	}
+	return vlan
+}
+
+// partition returns the normalized P_Key of an interface, "" for interfaces
+// that are not IPoIB or unknown. Callers hold g.mu.
+func (g *Graph) partition(machineID, iface string) string {
+	if details, ok := g.interfaceLocked(machineID, iface); ok && details.IPoIB != nil {
+		return NormalizePKey(details.IPoIB.PKey)
+	}
+	return ""
+}
+
+// preserveAdvertised keeps what SetInterfaceRDMA, SetInterfaceIPoIB,
+// SetInterfaceEthtool, SetInterfaceStack, SetInterfaceSRIOV and
+// SetInterfaceHealth recorded for an interface when its details are replaced:
+// the RDMA device list as long as the primary device is unchanged, the IPoIB
+// attributes, the link settings, the stack, the SR-IOV identity and the health
+func preserveAdvertised(details, existing InterfaceDetails) InterfaceDetails {
+	if details.RDMADevice == existing.RDMADevice {
+		details.RDMADevices = existing.RDMADevices
+	}
+	if details.IPoIB == nil {
+		details.IPoIB = existing.IPoIB
+	}
+	if details.Ethtool == nil {
+		details.Ethtool = existing.Ethtool
+	}
+	if details.Stack == nil {
+		details.Stack = existing.Stack
+	}
+	if details.SRIOV == nil {
+		details.SRIOV = existing.SRIOV
+	}
+	if details.Health == nil {
+		details.Health = existing.Health
+	}
+	return details
+}
+
+func rdmaDevicesEqual(a, b []RDMADevice) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].NodeGUID != b[i].NodeGUID || a[i].SysImageGUID != b[i].SysImageGUID ||
+			!rdmaPortsEqual(a[i].Ports, b[i].Ports) {
+			return false
+		}
+	}
+	return true
+}
+
+func rdmaPortsEqual(a, b []RDMAPort) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.Port != y.Port || x.State != y.State || x.PhysState != y.PhysState || x.LinkLayer != y.LinkLayer ||
+			x.Rate != y.Rate || x.ActiveMTU != y.ActiveMTU || x.LID != y.LID || x.SMLID != y.SMLID || len(x.GIDs) != len(y.GIDs) {
+			return false
+		}
+		for j := range x.GIDs {
+			if x.GIDs[j] != y.GIDs[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// copyLabels returns a copy of a label map, nil for empty input
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
 func (g *Graph) GetLocalMachineID() string {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
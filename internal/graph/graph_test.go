@@ -434,6 +434,45 @@ func TestClearChanges(t *testing.T) {
 	}
 }
 
+func TestSetNodeLabels(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local-123", "localhost", nil)
+	g.AddOrUpdate("remote-1", "host1", "eth0", "fe80::1", "eth0", "", "", "", 0, nil, true, "")
+	g.ClearChanges()
+
+	g.SetNodeLabels("local-123", map[string]string{"role": "gateway"})
+	g.SetNodeLabels("remote-1", map[string]string{"rack": "r1"})
+	g.SetNodeLabels("unknown", map[string]string{"rack": "r2"})
+
+	if !g.HasChanges() {
+		t.Error("expected changes after setting labels")
+	}
+
+	nodes := g.GetNodes()
+	if nodes["local-123"].Labels["role"] != "gateway" {
+		t.Errorf("unexpected local labels: %v", nodes["local-123"].Labels)
+	}
+	if nodes["remote-1"].Labels["rack"] != "r1" {
+		t.Errorf("unexpected remote labels: %v", nodes["remote-1"].Labels)
+	}
+	if _, ok := nodes["unknown"]; ok {
+		t.Error("expected labels for unknown node to be ignored")
+	}
+
+	// Returned labels are copies
+	nodes["remote-1"].Labels["rack"] = "changed"
+	if g.GetNodes()["remote-1"].Labels["rack"] != "r1" {
+		t.Error("expected GetNodes to return a copy of labels")
+	}
+
+	// Unchanged labels do not mark the graph as changed
+	g.ClearChanges()
+	g.SetNodeLabels("remote-1", map[string]string{"rack": "r1"})
+	if g.HasChanges() {
+		t.Error("expected no changes when labels are unchanged")
+	}
+}
+
 func TestGetLocalMachineID(t *testing.T) {
 	g := New()
 
@@ -513,3 +552,129 @@ func TestConcurrentAccess(t *testing.T) {
 	<-done
 	<-done
 }
+
+func TestSetInterfaceRDMA(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local", "local-host", map[string]InterfaceDetails{"ib0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("remote", "remote-host", "ib0", "fe80::2", "ib0", "mlx5_0", "0x1", "0x2", 100000, nil, true, "")
+	g.ClearChanges()
+
+	devices := []RDMADevice{
+		{Name: "mlx5_0", NodeGUID: "0x1", SysImageGUID: "0x2", Ports: []RDMAPort{{Port: 1, State: "ACTIVE", GIDs: []RDMAGID{{Index: 0, GID: "fe80::2"}}}}},
+		{Name: "mlx5_1", NodeGUID: "0x3", SysImageGUID: "0x2", Ports: []RDMAPort{{Port: 1, State: "DOWN"}}},
+	}
+	g.SetInterfaceRDMA("remote", "ib0", devices)
+	if !g.HasChanges() {
+		t.Error("expected change after setting devices")
+	}
+	if got := g.GetNodes()["remote"].Interfaces["ib0"].RDMADevices; len(got) != 2 || got[1].Ports[0].State != "DOWN" {
+		t.Errorf("unexpected devices %+v", got)
+	}
+	if e := g.GetEdges()["local"]["remote"]; len(e) != 1 || len(e[0].RemoteRDMADevices) != 2 {
+		t.Errorf("expected edge to carry the remote devices, got %+v", e)
+	}
+
+	g.ClearChanges()
+	g.SetInterfaceRDMA("remote", "ib0", []RDMADevice{
+		{Name: "mlx5_0", NodeGUID: "0x1", SysImageGUID: "0x2", Ports: []RDMAPort{{Port: 1, State: "ACTIVE", GIDs: []RDMAGID{{Index: 0, GID: "fe80::2"}}}}},
+		{Name: "mlx5_1", NodeGUID: "0x3", SysImageGUID: "0x2", Ports: []RDMAPort{{Port: 1, State: "DOWN"}}},
+	})
+	if g.HasChanges() {
+		t.Error("expected no change for identical devices")
+	}
+
+	// A changed address replaces the interface details but keeps the devices,
+	// also on the refreshed edge
+	g.AddOrUpdate("remote", "remote-host", "ib0", "fe80::3", "ib0", "mlx5_0", "0x1", "0x2", 100000, nil, true, "")
+	if got := g.GetNodes()["remote"].Interfaces["ib0"].RDMADevices; len(got) != 2 {
+		t.Errorf("expected devices to survive an update, got %+v", got)
+	}
+	if e := g.GetEdges()["local"]["remote"]; len(e) != 1 || len(e[0].RemoteRDMADevices) != 2 {
+		t.Errorf("expected refreshed edge to keep the remote devices, got %+v", e)
+	}
+
+	// A different primary device drops the stale list
+	g.AddOrUpdate("remote", "remote-host", "ib0", "fe80::3", "ib0", "mlx5_4", "0x9", "0x9", 100000, nil, true, "")
+	if got := g.GetNodes()["remote"].Interfaces["ib0"].RDMADevices; len(got) != 0 {
+		t.Errorf("expected stale devices to be dropped, got %+v", got)
+	}
+
+	// Unknown nodes and interfaces are ignored
+	g.SetInterfaceRDMA("unknown", "ib0", devices)
+	g.SetInterfaceRDMA("remote", "ib9", devices)
+	if _, ok := g.GetNodes()["remote"].Interfaces["ib9"]; ok {
+		t.Error("expected unknown interface to be ignored")
+	}
+}
+
+func TestSetInterfaceEthtool(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local", "local-host", map[string]InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 25000, nil, true, "")
+	g.ClearChanges()
+
+	ethtool := &Ethtool{Duplex: "full", Autoneg: "off", Port: "DA", Driver: "mlx5_core", FirmwareVersion: "22.36.1010", BusInfo: "0000:3b:00.0"}
+	g.SetInterfaceEthtool("remote", "ens1f0", ethtool)
+	if !g.HasChanges() {
+		t.Error("expected change after setting link settings")
+	}
+	g.ClearChanges()
+	g.SetInterfaceEthtool("remote", "ens1f0", &Ethtool{Duplex: "full", Autoneg: "off", Port: "DA", Driver: "mlx5_core", FirmwareVersion: "22.36.1010", BusInfo: "0000:3b:00.0"})
+	if g.HasChanges() {
+		t.Error("expected no change for identical link settings")
+	}
+
+	// A changed address replaces the interface details but keeps the settings
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::3", "eth0", "", "", "", 25000, nil, true, "")
+	if got := g.GetNodes()["remote"].Interfaces["ens1f0"].Ethtool; got == nil || *got != *ethtool {
+		t.Errorf("expected link settings to survive an update, got %+v", got)
+	}
+
+	g.SetInterfaceEthtool("remote", "ens1f0", nil)
+	if got := g.GetNodes()["remote"].Interfaces["ens1f0"].Ethtool; got != nil {
+		t.Errorf("expected link settings to be cleared, got %+v", got)
+	}
+}
+
+func TestSetInterfaceHealth(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local", "local-host", map[string]InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 25000, nil, true, "")
+	g.ClearChanges()
+
+	g.SetInterfaceHealth("local", "eth0", &Health{Status: HealthOK})
+	if !g.HasChanges() {
+		t.Error("expected change after the first health sample")
+	}
+	g.ClearChanges()
+
+	// New rates with the same status are stored without triggering an export
+	g.SetInterfaceHealth("local", "eth0", &Health{Status: HealthOK, RxDropped: 0.5})
+	if g.HasChanges() {
+		t.Error("expected no change while the status holds")
+	}
+	if got := g.GetNodes()["local"].Interfaces["eth0"].Health; got == nil || got.RxDropped != 0.5 {
+		t.Errorf("expected the latest rates, got %+v", got)
+	}
+
+	g.SetInterfaceHealth("remote", "ens1f0", &Health{Status: HealthDegraded, CarrierChanges: 3})
+	if !g.HasChanges() {
+		t.Error("expected change when an interface degrades")
+	}
+	health := g.GetNodes()["remote"].Interfaces["ens1f0"].Health
+	if !health.Degraded() || health.Summary() != "3 carrier changes" {
+		t.Errorf("unexpected health %+v", health)
+	}
+}
+
+func TestRDMANames(t *testing.T) {
+	if got := RDMANames("", nil); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+	if got := RDMANames("mlx5_0", nil); len(got) != 1 || got[0] != "mlx5_0" {
+		t.Errorf("expected primary device, got %v", got)
+	}
+	if got := RDMANames("mlx5_0", []RDMADevice{{Name: "mlx5_0"}, {Name: "mlx5_1"}}); len(got) != 2 || got[1] != "mlx5_1" {
+		t.Errorf("expected both devices, got %v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"math"
+	"time"
+)
+
+// Reception measures how reliably the packets of a neighbor interface arrive
+// on a local interface, from the per-interface sequence numbers of discovery
+// packets. Counts start with the first packet seen and restart when the
+// sender restarts.
+type Reception struct {
+	Received uint64  `json:"received"`
+	Expected uint64  `json:"expected"`  // Sequence numbers spanned since the first packet
+	Gaps     uint64  `json:"gaps"`      // Runs of missing sequence numbers
+	JitterMs float64 `json:"jitter_ms"` // Smoothed deviation of arrivals from the send interval
+}
+
+// Loss returns the fraction of expected packets that did not arrive
+func (r *Reception) Loss() float64 {
+	if r == nil || r.Expected == 0 || r.Received >= r.Expected {
+		return 0
+	}
+	return float64(r.Expected-r.Received) / float64(r.Expected)
+}
+
+// SequenceSample is the outcome of one received packet for the metrics
+type SequenceSample struct {
+	Missed    uint64        // Sequence numbers skipped right before the packet
+	Deviation time.Duration // Arrival deviation from the send interval, 0 if unknown
+	Loss      float64       // Loss of the link after the packet
+}
+
+// receptionState tracks the sequence numbers of one link
+type receptionState struct {
+	first, last uint64
+	received    uint64
+	gaps        uint64
+	lastArrival time.Time
+	jitter      float64 // Seconds
+}
+
+// record counts a packet with sequence number seq arriving at now, sent every
+// interval (0 if unknown). Sequence numbers that go backwards mean the sender
+// restarted; repeated ones are ignored.
+func (s *receptionState) record(seq uint64, interval time.Duration, now time.Time) SequenceSample {
+	var sample SequenceSample
+	switch {
+	case s.received == 0 || seq < s.last:
+		*s = receptionState{first: seq, last: seq, received: 1, lastArrival: now}
+		return sample
+	case seq == s.last:
+		return SequenceSample{Loss: s.reception().Loss()}
+	}
+
+	if missed := seq - s.last - 1; missed > 0 {
+		s.gaps++
+		sample.Missed = missed
+	}
+	if interval > 0 {
+		// RFC 3550 interarrival jitter, with the send times derived from the
+		// sequence numbers instead of sender timestamps
+		d := now.Sub(s.lastArrival) - time.Duration(seq-s.last)*interval
+		if d < 0 {
+			d = -d
+		}
+		s.jitter += (d.Seconds() - s.jitter) / 16
+		sample.Deviation = d
+	}
+	s.last = seq
+	s.received++
+	s.lastArrival = now
+	sample.Loss = s.reception().Loss()
+	return sample
+}
+
+func (s *receptionState) reception() *Reception {
+	return &Reception{
+		Received: s.received,
+		Expected: s.last - s.first + 1,
+		Gaps:     s.gaps,
+		JitterMs: math.Round(s.jitter*1e5) / 100,
+	}
+}
+
+// receptionKey identifies a received link: the neighbor's interface and the
+// local interface its packets arrive on
+type receptionKey struct {
+	remoteIface, localIface string
+}
+
+// RecordSequence counts a packet with sequence number seq from an interface of
+// a node, received on a local interface, with the sender's interval (0 if
+// unknown). The statistics themselves are not changes of the graph, except
+// for the first loss on a link, so that lossy links show up in exports.
+func (g *Graph) RecordSequence(machineID, remoteIface, localIface string, seq uint64, interval time.Duration) SequenceSample {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.reception[machineID] == nil {
+		g.reception[machineID] = make(map[receptionKey]*receptionState)
+	}
+	key := receptionKey{remoteIface: remoteIface, localIface: localIface}
+	state, ok := g.reception[machineID][key]
+	if !ok {
+		state = &receptionState{}
+		g.reception[machineID][key] = state
+	}
+	hadGaps := state.gaps > 0
+	sample := state.record(seq, interval, time.Now())
+	if !hadGaps && state.gaps > 0 {
+		g.interfaceChanged(machineID, remoteIface)
+	}
+	return sample
+}
+
+// edgeReception returns the reception of packets over an edge from the local
+// node, nil if none were counted. The caller must hold the lock.
+func (g *Graph) edgeReception(srcID, dstID string, edge *Edge) *Reception {
+	if g.localNode == nil || srcID != g.localNode.MachineID {
+		return nil
+	}
+	state, ok := g.reception[dstID][receptionKey{remoteIface: edge.RemoteInterface, localIface: edge.LocalInterface}]
+	if !ok {
+		return nil
+	}
+	return state.reception()
+}
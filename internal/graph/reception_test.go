@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReceptionState(t *testing.T) {
+	interval := 30 * time.Second
+	at := time.Unix(1000, 0)
+	s := &receptionState{}
+
+	s.record(10, interval, at)
+	s.record(11, interval, at.Add(30*time.Second))
+	s.record(11, interval, at.Add(31*time.Second)) // Duplicate
+	sample := s.record(14, interval, at.Add(120*time.Second+160*time.Millisecond))
+	if sample.Missed != 2 || sample.Deviation != 160*time.Millisecond {
+		t.Errorf("unexpected sample %+v", sample)
+	}
+	want := Reception{Received: 3, Expected: 5, Gaps: 1, JitterMs: 10}
+	if got := s.reception(); *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+	if loss := s.reception().Loss(); loss != 0.4 {
+		t.Errorf("expected 40%% loss, got %v", loss)
+	}
+
+	// A restarted sender starts over
+	s.record(1, interval, at.Add(150*time.Second))
+	if got := s.reception(); got.Received != 1 || got.Expected != 1 || got.Gaps != 0 {
+		t.Errorf("expected counts to restart, got %+v", got)
+	}
+}
+
+func TestRecordSequence(t *testing.T) {
+	g := New()
+	g.SetLocalNode("local", "local-host", map[string]InterfaceDetails{"eth0": {IPAddress: "fe80::1"}})
+	g.AddOrUpdate("remote", "remote-host", "ens1f0", "fe80::2", "eth0", "", "", "", 25000, nil, true, "")
+	g.ClearChanges()
+
+	g.RecordSequence("remote", "ens1f0", "eth0", 1, 0)
+	g.RecordSequence("remote", "ens1f0", "eth0", 2, 0)
+	if g.HasChanges() {
+		t.Error("expected reception counts not to change the graph")
+	}
+	if sample := g.RecordSequence("remote", "ens1f0", "eth0", 4, 0); sample.Missed != 1 || sample.Loss != 0.25 {
+		t.Errorf("unexpected sample %+v", sample)
+	}
+	if !g.HasChanges() {
+		t.Error("expected the first loss to change the graph")
+	}
+	g.ClearChanges()
+	g.RecordSequence("remote", "ens1f0", "eth0", 6, 0)
+	if g.HasChanges() {
+		t.Error("expected further losses not to change the graph")
+	}
+
+	r := g.GetEdges()["local"]["remote"][0].Reception
+	if r == nil || r.Received != 4 || r.Expected != 6 || r.Gaps != 2 {
+		t.Errorf("unexpected reception %+v", r)
+	}
+
+	g.RemoveExpired(0)
+	if len(g.reception) != 0 {
+		t.Error("expected reception of expired nodes to be forgotten")
+	}
+}
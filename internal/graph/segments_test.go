@@ -1,6 +1,8 @@
 package graph
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -212,3 +214,167 @@ func TestGetNetworkSegments_NoLocalNode(t *testing.T) {
 		t.Errorf("expected no segments without local node, got %d", len(segments))
 	}
 }
+
+// TestGetNetworkSegments_FromSnapshot verifies detection on a graph rebuilt from
+// another agent's nodes and edges.
+func TestGetNetworkSegments_FromSnapshot(t *testing.T) {
+	g := New()
+	g.SetLocalNode("machine-local", "host-local", map[string]InterfaceDetails{
+		"eth0": {IPAddress: "fe80::1", GlobalPrefixes: []string{"10.0.0.0/24"}},
+	})
+	for i, id := range []string{"machine-a", "machine-b", "machine-c"} {
+		g.AddOrUpdate(id, "host-"+id, "eth0", fmt.Sprintf("fe80::%d", i+2), "eth0", "", "", "", 0, []string{"10.0.0.0/24"}, true, "")
+	}
+
+	rebuilt := NewFromSnapshot(g.GetNodes(), g.GetEdges())
+	if rebuilt.GetLocalMachineID() != "machine-local" {
+		t.Errorf("expected local node to be restored, got %q", rebuilt.GetLocalMachineID())
+	}
+	if len(rebuilt.GetNodes()) != 4 {
+		t.Errorf("expected 4 nodes, got %d", len(rebuilt.GetNodes()))
+	}
+
+	segments := rebuilt.GetNetworkSegments()
+	if len(segments) != 1 || len(segments[0].ConnectedNodes) != 4 {
+		t.Fatalf("expected 1 segment with 4 nodes, got %+v", segments)
+	}
+}
+
+// TestMergeSegments verifies segments reported by different agents are merged by prefix.
+func TestMergeSegments(t *testing.T) {
+	segments := []NetworkSegment{
+		{ID: "segment-0", Interface: "eth0", NetworkPrefix: "10.0.0.0/24", NetworkPrefixes: []string{"10.0.0.0/24"},
+			ConnectedNodes: []string{"a", "b", "c"}, EdgeInfo: map[string]*Edge{}},
+		{ID: "segment-0", Interface: "eth1", NetworkPrefix: "10.0.0.0/24", NetworkPrefixes: []string{"10.0.0.0/24"},
+			ConnectedNodes: []string{"b", "c", "d"}, EdgeInfo: map[string]*Edge{}},
+		{ID: "segment-1", Interface: "eth0", NetworkPrefix: "10.1.0.0/24", NetworkPrefixes: []string{"10.1.0.0/24"},
+			ConnectedNodes: []string{"x", "y", "z"}, EdgeInfo: map[string]*Edge{}},
+	}
+
+	merged := MergeSegments(segments)
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 merged segments, got %d", len(merged))
+	}
+	for _, seg := range merged {
+		if seg.NetworkPrefix == "10.0.0.0/24" && len(seg.ConnectedNodes) != 4 {
+			t.Errorf("expected 4 members in merged segment, got %v", seg.ConnectedNodes)
+		}
+	}
+}
+
+// TestGetNetworkSegments_Partitions verifies that IPoIB partitions over the same
+// nodes are reported as separate segments instead of being merged by node set.
+func TestGetNetworkSegments_Partitions(t *testing.T) {
+	g := New()
+	g.SetLocalNode("machine-a", "host-a", map[string]InterfaceDetails{
+		"ib0":      {IPAddress: "fe80::1", IPoIB: &IPoIB{PKey: "0xffff"}},
+		"ib0.8001": {IPAddress: "fe80::2", IPoIB: &IPoIB{PKey: "0x8001", Parent: "ib0"}},
+	})
+	for i, id := range []string{"machine-b", "machine-c", "machine-d"} {
+		host := "host-" + id[len(id)-1:]
+		g.AddOrUpdate(id, host, "ib0", fmt.Sprintf("fe80::1%d", i), "ib0", "", "", "", 0, nil, true, "")
+		g.SetInterfaceIPoIB(id, "ib0", &IPoIB{PKey: "0xffff"})
+		g.AddOrUpdate(id, host, "ib0.8001", fmt.Sprintf("fe80::2%d", i), "ib0.8001", "", "", "", 0, nil, true, "")
+		// A limited member of the partition reports the key without the membership bit
+		g.SetInterfaceIPoIB(id, "ib0.8001", &IPoIB{PKey: "0x0001", Parent: "ib0"})
+	}
+
+	segments := g.GetNetworkSegments()
+	if len(segments) != 2 {
+		t.Fatalf("expected one segment per partition, got %+v", segments)
+	}
+	byKey := make(map[string]NetworkSegment)
+	for _, seg := range segments {
+		byKey[seg.PKey] = seg
+	}
+	if seg, ok := byKey["0xffff"]; !ok || seg.Interface != "ib0" || len(seg.ConnectedNodes) != 4 {
+		t.Errorf("unexpected default partition segment: %+v", seg)
+	}
+	if seg, ok := byKey["0x8001"]; !ok || seg.Interface != "ib0.8001" || len(seg.ConnectedNodes) != 4 {
+		t.Errorf("unexpected partition 0x8001 segment: %+v", seg)
+	}
+	if byKey["0xffff"].StableID() == byKey["0x8001"].StableID() {
+		t.Error("expected partitions to have distinct stable IDs")
+	}
+
+	// Merging segments seen by several agents keeps partitions apart
+	if merged := MergeSegments(segments); len(merged) != 2 {
+		t.Errorf("expected partitions to stay separate when merged, got %+v", merged)
+	}
+}
+
+// TestGetNetworkSegments_VLANs verifies that VLANs over the same nodes are
+// reported as separate segments, labelled with their VLAN ID, even when the
+// hosts name their VLAN interfaces differently.
+func TestGetNetworkSegments_VLANs(t *testing.T) {
+	g := New()
+	g.SetLocalNode("machine-a", "host-a", map[string]InterfaceDetails{
+		"bond0.100": {IPAddress: "fe80::1", Stack: &Stack{Kind: StackVLAN, Parent: "bond0", VLANID: 100}},
+		"bond0.200": {IPAddress: "fe80::2", Stack: &Stack{Kind: StackVLAN, Parent: "bond0", VLANID: 200}},
+	})
+	for i, id := range []string{"machine-b", "machine-c", "machine-d"} {
+		host := "host-" + id[len(id)-1:]
+		g.AddOrUpdate(id, host, "vlan100", fmt.Sprintf("fe80::1%d", i), "bond0.100", "", "", "", 0, nil, true, "")
+		g.SetInterfaceStack(id, "vlan100", &Stack{Kind: StackVLAN, Parent: "eth0", VLANID: 100})
+		g.AddOrUpdate(id, host, "vlan200", fmt.Sprintf("fe80::2%d", i), "bond0.200", "", "", "", 0, nil, true, "")
+		g.SetInterfaceStack(id, "vlan200", &Stack{Kind: StackVLAN, Parent: "eth0", VLANID: 200})
+	}
+
+	segments := g.GetNetworkSegments()
+	if len(segments) != 2 {
+		t.Fatalf("expected one segment per VLAN, got %+v", segments)
+	}
+	byVLAN := make(map[int]NetworkSegment)
+	for _, seg := range segments {
+		byVLAN[seg.VLANID] = seg
+	}
+	if seg, ok := byVLAN[100]; !ok || seg.Interface != "bond0.100" || len(seg.ConnectedNodes) != 4 {
+		t.Errorf("unexpected VLAN 100 segment: %+v", seg)
+	}
+	if seg, ok := byVLAN[200]; !ok || seg.Interface != "bond0.200" || len(seg.ConnectedNodes) != 4 {
+		t.Errorf("unexpected VLAN 200 segment: %+v", seg)
+	}
+	if merged := MergeSegments(segments); len(merged) != 2 {
+		t.Errorf("expected VLANs to stay separate when merged, got %+v", merged)
+	}
+}
+
+func TestStackLabel(t *testing.T) {
+	tests := []struct {
+		stack *Stack
+		label string
+		lower []string
+	}{
+		{nil, "", nil},
+		{&Stack{Kind: StackVLAN, Parent: "bond0", VLANID: 100}, "VLAN 100", []string{"bond0"}},
+		{&Stack{Kind: StackBond, BondMode: "802.3ad", Members: []string{"ens1f0", "ens1f1"}}, "bond 802.3ad", []string{"ens1f0", "ens1f1"}},
+		{&Stack{Kind: StackBridge, Members: []string{"bond0"}}, "bridge", []string{"bond0"}},
+		{&Stack{Master: "br0"}, "", nil},
+	}
+	for _, tt := range tests {
+		if got := tt.stack.Label(); got != tt.label {
+			t.Errorf("Label() = %q, want %q", got, tt.label)
+		}
+		if got := tt.stack.Lower(); strings.Join(got, ",") != strings.Join(tt.lower, ",") {
+			t.Errorf("Lower() = %v, want %v", got, tt.lower)
+		}
+	}
+}
+
+func TestNormalizePKey(t *testing.T) {
+	tests := map[string]string{
+		"0xffff": "0xffff",
+		"0x7fff": "0xffff",
+		"0x8001": "0x8001",
+		"0x0001": "0x8001",
+		"8002":   "0x8002",
+		"0x8000": "", // Invalid key
+		"":       "",
+		"bogus":  "",
+	}
+	for in, want := range tests {
+		if got := NormalizePKey(in); got != want {
+			t.Errorf("NormalizePKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
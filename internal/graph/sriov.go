@@ -0,0 +1,115 @@
+package graph
+
+import "strings"
+
+// CollapseVFs folds the SR-IOV VFs of every node into their PF, for drawings
+// of hosts with many VFs. VF interfaces are removed from the nodes and their
+// PF is added if it was not listed. Links on a VF are moved to the PF and name
+// the VF in LocalVF or RemoteVF; links of several VFs of one PF to the same
+// neighbor interface become one link naming all of them, comma-separated.
+// Segment members are moved the same way, the local interface of a segment
+// through the local node. Inputs are not modified.
+func CollapseVFs(nodes map[string]*Node, edges map[string]map[string][]*Edge, segments []NetworkSegment) (map[string]*Node, map[string]map[string][]*Edge, []NetworkSegment) {
+	var localID string
+	keptNodes := make(map[string]*Node, len(nodes))
+	for id, node := range nodes {
+		if node.IsLocal {
+			localID = id
+		}
+		nodeCopy := *node
+		nodeCopy.Interfaces = make(map[string]InterfaceDetails, len(node.Interfaces))
+		for name, details := range node.Interfaces {
+			if pf := physFn(node, name); pf != "" {
+				if _, ok := nodeCopy.Interfaces[pf]; !ok {
+					nodeCopy.Interfaces[pf] = node.Interfaces[pf]
+				}
+				continue
+			}
+			nodeCopy.Interfaces[name] = details
+		}
+		keptNodes[id] = &nodeCopy
+	}
+
+	keptEdges := make(map[string]map[string][]*Edge, len(edges))
+	for srcID, dests := range edges {
+		keptEdges[srcID] = make(map[string][]*Edge, len(dests))
+		for dstID, edgeList := range dests {
+			var collapsed []*Edge
+			for _, edge := range edgeList {
+				e := collapseEdge(edge, nodes[srcID], nodes[dstID])
+				merged := false
+				for _, other := range collapsed {
+					if other.LocalInterface == e.LocalInterface && other.RemoteInterface == e.RemoteInterface {
+						other.LocalVF = joinVF(other.LocalVF, e.LocalVF)
+						other.RemoteVF = joinVF(other.RemoteVF, e.RemoteVF)
+						merged = true
+						break
+					}
+				}
+				if !merged {
+					collapsed = append(collapsed, e)
+				}
+			}
+			keptEdges[srcID][dstID] = collapsed
+		}
+	}
+
+	keptSegments := make([]NetworkSegment, len(segments))
+	for i, segment := range segments {
+		if pf := physFn(nodes[localID], segment.Interface); pf != "" {
+			segment.Interface = pf
+		}
+		edgeInfo := make(map[string]*Edge, len(segment.EdgeInfo))
+		for nodeID, edge := range segment.EdgeInfo {
+			edgeInfo[nodeID] = collapseEdge(edge, nodes[localID], nodes[nodeID])
+		}
+		segment.EdgeInfo = edgeInfo
+		keptSegments[i] = segment
+	}
+
+	return keptNodes, keptEdges, keptSegments
+}
+
+// physFn returns the PF of an interface of a node, "" unless it is a VF with a
+// known PF
+func physFn(node *Node, iface string) string {
+	if node == nil {
+		return ""
+	}
+	sriov := node.Interfaces[iface].SRIOV
+	if sriov == nil || sriov.Role != SRIOVVF {
+		return ""
+	}
+	return sriov.PF
+}
+
+// collapseEdge returns a copy of an edge between two nodes with VF ends moved
+// to their PF
+func collapseEdge(edge *Edge, src, dst *Node) *Edge {
+	e := *edge
+	if pf := physFn(src, e.LocalInterface); pf != "" {
+		e.LocalVF = e.LocalInterface
+		e.LocalInterface = pf
+	}
+	if pf := physFn(dst, e.RemoteInterface); pf != "" {
+		e.RemoteVF = e.RemoteInterface
+		e.RemoteInterface = pf
+	}
+	return &e
+}
+
+// joinVF adds a VF to a comma-separated list of VFs
+func joinVF(list, vf string) string {
+	if vf == "" {
+		return list
+	}
+	if list == "" {
+		return vf
+	}
+	for _, v := range strings.Split(list, ",") {
+		if v == vf {
+			return list
+		}
+	}
+	return list + "," + vf
+}
@@ -0,0 +1,74 @@
+package graph
+
+import "testing"
+
+func TestSRIOVLabel(t *testing.T) {
+	tests := []struct {
+		sriov *SRIOV
+		want  string
+	}{
+		{nil, ""},
+		{&SRIOV{PhysSwitchID: "8a3b0c0003a2420c", PhysPortName: "p0"}, ""},
+		{&SRIOV{Role: SRIOVPF, NumVFs: 8}, "PF, 8 VFs"},
+		{&SRIOV{Role: SRIOVVF, PF: "ens1f0np0", VF: 3}, "VF 3 of ens1f0np0"},
+		{&SRIOV{Role: SRIOVVF}, "VF 0"},
+		{&SRIOV{Role: SRIOVRepresentor, PF: "ens1f0np0", VF: 3}, "rep of VF 3"},
+	}
+	for _, tt := range tests {
+		if got := tt.sriov.Label(); got != tt.want {
+			t.Errorf("Label(%+v) = %q, want %q", tt.sriov, got, tt.want)
+		}
+	}
+}
+
+func TestCollapseVFs(t *testing.T) {
+	g := New()
+	// The PF has no link-local address, only its VFs take part in discovery
+	g.SetLocalNode("local", "gpu-01", map[string]InterfaceDetails{
+		"eth0":     {IPAddress: "fe80::1"},
+		"ens1f0v0": {IPAddress: "fe80::2", SRIOV: &SRIOV{Role: SRIOVVF, PF: "ens1f0np0", VF: 0}},
+		"ens1f0v1": {IPAddress: "fe80::3", SRIOV: &SRIOV{Role: SRIOVVF, PF: "ens1f0np0", VF: 1}},
+	})
+	g.AddOrUpdate("peer", "gpu-02", "ens1f0v2", "fe80::12", "ens1f0v0", "", "", "", 100000, nil, true, "")
+	g.AddOrUpdate("peer", "gpu-02", "ens1f0v2", "fe80::12", "ens1f0v1", "", "", "", 100000, nil, true, "")
+	g.SetInterfaceSRIOV("peer", "ens1f0v2", &SRIOV{Role: SRIOVVF, PF: "ens1f0np0", VF: 2})
+	g.AddOrUpdate("mgmt", "mgmt-01", "eth0", "fe80::21", "eth0", "", "", "", 1000, nil, true, "")
+
+	nodes, edges := g.GetNodes(), g.GetEdges()
+	keptNodes, keptEdges, _ := CollapseVFs(nodes, edges, nil)
+
+	local := keptNodes["local"].Interfaces
+	if _, ok := local["ens1f0np0"]; !ok || len(local) != 2 {
+		t.Errorf("expected eth0 and the PF, got %v", local)
+	}
+	if _, ok := keptNodes["peer"].Interfaces["ens1f0np0"]; !ok {
+		t.Errorf("expected the peer's VF to be folded, got %v", keptNodes["peer"].Interfaces)
+	}
+
+	// Both VFs reach the same neighbor VF: one link naming both
+	links := keptEdges["local"]["peer"]
+	if len(links) != 1 {
+		t.Fatalf("expected 1 link, got %+v", links)
+	}
+	if e := links[0]; e.LocalInterface != "ens1f0np0" || e.LocalVF != "ens1f0v0,ens1f0v1" ||
+		e.RemoteInterface != "ens1f0np0" || e.RemoteVF != "ens1f0v2" {
+		t.Errorf("unexpected link %+v", e)
+	}
+	if e := keptEdges["local"]["mgmt"][0]; e.LocalInterface != "eth0" || e.LocalVF != "" {
+		t.Errorf("expected plain links to be kept, got %+v", e)
+	}
+
+	// Inputs are not modified
+	if _, ok := nodes["local"].Interfaces["ens1f0v0"]; !ok || len(edges["local"]["peer"]) != 2 || edges["local"]["peer"][0].LocalVF != "" {
+		t.Error("expected the input to be unchanged")
+	}
+
+	// As a filter criterion
+	f := Filter{CollapseVFs: true}
+	if f.IsEmpty() {
+		t.Error("expected CollapseVFs to make the filter non-empty")
+	}
+	if _, keptEdges, _ := f.Apply(nodes, edges, nil); len(keptEdges["local"]["peer"]) != 1 {
+		t.Errorf("expected the filter to collapse VFs, got %+v", keptEdges["local"]["peer"])
+	}
+}
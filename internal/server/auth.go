@@ -0,0 +1,175 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Token scopes. Admin grants every scope.
+const (
+	ScopeGraphRead  = "graph:read"  // Topology endpoints, exports and collector sources
+	ScopeEventsRead = "events:read" // Event streams
+	ScopeAdmin      = "admin"       // Mutating endpoints such as /api/v1/push
+)
+
+var validScopes = map[string]bool{
+	ScopeGraphRead:  true,
+	ScopeEventsRead: true,
+	ScopeAdmin:      true,
+}
+
+// Token is a bearer token with the scopes it grants. Name identifies the token in
+// logs; the secret itself is never logged.
+type Token struct {
+	Name   string   `json:"name"`
+	Secret string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// LoadTokens reads a tokens file of the form
+//
+//	{"tokens": [{"name": "grafana", "token": "...", "scopes": ["graph:read"]}]}
+func LoadTokens(path string) ([]Token, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Tokens []Token `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid tokens file: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i, t := range file.Tokens {
+		if t.Name == "" {
+			return nil, fmt.Errorf("token %d: name is required", i)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("token %q: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if t.Secret == "" {
+			return nil, fmt.Errorf("token %q: token is required", t.Name)
+		}
+		if len(t.Scopes) == 0 {
+			return nil, fmt.Errorf("token %q: at least one scope is required", t.Name)
+		}
+		for _, scope := range t.Scopes {
+			if !validScopes[scope] {
+				return nil, fmt.Errorf("token %q: unknown scope %q (use graph:read, events:read or admin)", t.Name, scope)
+			}
+		}
+	}
+	if len(file.Tokens) == 0 {
+		return nil, fmt.Errorf("tokens file %s defines no tokens", path)
+	}
+	return file.Tokens, nil
+}
+
+// WithTokens requires a bearer token on every endpoint except /health and
+// /openapi.json
+func WithTokens(tokens []Token) Option {
+	return func(s *Server) {
+		s.tokens = make([]authToken, 0, len(tokens))
+		for _, t := range tokens {
+			s.tokens = append(s.tokens, newAuthToken(t))
+		}
+	}
+}
+
+// authToken is a Token prepared for comparison
+type authToken struct {
+	name   string
+	digest [sha256.Size]byte
+	scopes map[string]bool
+}
+
+func newAuthToken(t Token) authToken {
+	scopes := make(map[string]bool, len(t.Scopes))
+	for _, scope := range t.Scopes {
+		scopes[scope] = true
+	}
+	return authToken{name: t.Name, digest: sha256.Sum256([]byte(t.Secret)), scopes: scopes}
+}
+
+func (t *authToken) allows(scope string) bool {
+	return t.scopes[ScopeAdmin] || t.scopes[scope]
+}
+
+// lookupToken finds the token matching secret. Digests are compared in constant
+// time and every token is checked, so timing does not reveal how much of a
+// secret matched or which token it resembled.
+func (s *Server) lookupToken(secret string) *authToken {
+	digest := sha256.Sum256([]byte(secret))
+	var found *authToken
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare(digest[:], s.tokens[i].digest[:]) == 1 {
+			found = &s.tokens[i]
+		}
+	}
+	return found
+}
+
+// requireScope wraps h so it only runs for requests carrying a token with the
+// given scope. Without configured tokens h is returned unchanged.
+func (s *Server) requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	if len(s.tokens) == 0 {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if !ok {
+			s.deny(w, r, scope, "", "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		token := s.lookupToken(secret)
+		if token == nil {
+			s.deny(w, r, scope, "", "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if !token.allows(scope) {
+			s.deny(w, r, scope, token.name, "insufficient scope", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// deny writes the error response and the audit log entry for a rejected request
+func (s *Server) deny(w http.ResponseWriter, r *http.Request, scope, tokenName, reason string, status int) {
+	s.logger.Warn("request denied",
+		"audit", true,
+		"reason", reason,
+		"token", tokenName,
+		"scope", scope,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"remote", r.RemoteAddr,
+		"user_agent", r.UserAgent())
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lldiscovery"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="lldiscovery", error="insufficient_scope", scope="%s"`, scope))
+	}
+	http.Error(w, reason, status)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kad/lldiscovery/internal/collector"
+)
+
+func TestLoadTokens(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokens.json")
+	data := `{"tokens": [
+		{"name": "grafana", "token": "read-secret", "scopes": ["graph:read"]},
+		{"name": "ops", "token": "admin-secret", "scopes": ["admin"]}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("failed to write tokens file: %v", err)
+	}
+
+	tokens, err := LoadTokens(path)
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].Name != "grafana" || tokens[1].Scopes[0] != ScopeAdmin {
+		t.Errorf("unexpected tokens: %+v", tokens)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"not JSON", `tokens`, "invalid tokens file"},
+		{"empty", `{"tokens": []}`, "no tokens"},
+		{"no name", `{"tokens": [{"token": "x", "scopes": ["admin"]}]}`, "name is required"},
+		{"duplicate name", `{"tokens": [{"name": "a", "token": "x", "scopes": ["admin"]}, {"name": "a", "token": "y", "scopes": ["admin"]}]}`, "duplicate name"},
+		{"no secret", `{"tokens": [{"name": "a", "scopes": ["admin"]}]}`, "token is required"},
+		{"no scopes", `{"tokens": [{"name": "a", "token": "x"}]}`, "at least one scope"},
+		{"unknown scope", `{"tokens": [{"name": "a", "token": "x", "scopes": ["graph:write"]}]}`, "unknown scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "bad.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatalf("failed to write tokens file: %v", err)
+			}
+			_, err := LoadTokens(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	c := collector.New(logger)
+	s := New(":0", c, logger, false, WithCollector(c), WithTokens([]Token{
+		{Name: "grafana", Secret: "read-secret", Scopes: []string{ScopeGraphRead}},
+		{Name: "events", Secret: "events-secret", Scopes: []string{ScopeEventsRead}},
+		{Name: "ops", Secret: "admin-secret", Scopes: []string{ScopeAdmin}},
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		auth       string
+		wantStatus int
+	}{
+		{"health is open", http.MethodGet, "/health", "", http.StatusOK},
+		{"openapi is open", http.MethodGet, "/openapi.json", "", http.StatusOK},
+		{"missing token", http.MethodGet, "/graph", "", http.StatusUnauthorized},
+		{"wrong scheme", http.MethodGet, "/graph", "Basic cmVhZC1zZWNyZXQ=", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/graph", "Bearer nope", http.StatusUnauthorized},
+		{"prefix of token", http.MethodGet, "/graph", "Bearer read", http.StatusUnauthorized},
+		{"graph read", http.MethodGet, "/graph", "Bearer read-secret", http.StatusOK},
+		{"graph read DOT", http.MethodGet, "/graph.dot", "bearer read-secret", http.StatusOK},
+		{"graph read sources", http.MethodGet, "/api/v1/sources", "Bearer read-secret", http.StatusOK},
+		{"events token on graph", http.MethodGet, "/graph", "Bearer events-secret", http.StatusForbidden},
+		{"graph token on push", http.MethodPost, "/api/v1/push", "Bearer read-secret", http.StatusForbidden},
+		{"admin on graph", http.MethodGet, "/graph.nwdiag", "Bearer admin-secret", http.StatusOK},
+		{"admin on push", http.MethodPost, "/api/v1/push", "Bearer admin-secret", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+
+			s.srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if (w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden) && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on denied request")
+			}
+		})
+	}
+
+	// Denials are audited with the token name but never the secret
+	out := logs.String()
+	if !strings.Contains(out, "request denied") || !strings.Contains(out, "audit=true") {
+		t.Errorf("expected audit log entries, got %q", out)
+	}
+	if !strings.Contains(out, "token=grafana") || !strings.Contains(out, `reason="insufficient scope"`) {
+		t.Errorf("expected scope denial for grafana in audit log, got %q", out)
+	}
+	if strings.Contains(out, "read-secret") || strings.Contains(out, "events-secret") {
+		t.Error("audit log must not contain token secrets")
+	}
+}
+
+func TestRequireScopeDisabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", createTestGraph(), logger, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/graph", nil)
+	w := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected open access without tokens, got %d", w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kad/lldiscovery/internal/graph"
+)
+
+// parseFilter builds a subgraph filter from query parameters.
+// List parameters may be repeated or comma-separated:
+//
+//	host=compute-*   label=rack=r1   segment=seg-1a2b3c4d   prefix=10.0.0.0/24
+//	iface=ib*        rdma=true       direct=true            around=gw-01&hops=2
+//	collapse_vfs=true
+func parseFilter(query url.Values) (graph.Filter, error) {
+	f := graph.Filter{
+		Hosts:      queryList(query, "host"),
+		Labels:     queryList(query, "label"),
+		Segments:   queryList(query, "segment"),
+		Prefixes:   queryList(query, "prefix"),
+		Interfaces: queryList(query, "iface"),
+		Around:     query.Get("around"),
+	}
+
+	var err error
+	if f.RDMAOnly, err = queryBool(query, "rdma"); err != nil {
+		return f, err
+	}
+	if f.DirectOnly, err = queryBool(query, "direct"); err != nil {
+		return f, err
+	}
+	if f.CollapseVFs, err = queryBool(query, "collapse_vfs"); err != nil {
+		return f, err
+	}
+	if v := query.Get("hops"); v != "" {
+		if f.Around == "" {
+			return f, fmt.Errorf("hops requires around")
+		}
+		if f.Hops, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid hops %q", v)
+		}
+	}
+
+	return f, f.Validate()
+}
+
+// queryList returns the non-empty values of a repeated, comma-separated parameter
+func queryList(query url.Values, key string) []string {
+	var values []string
+	for _, v := range query[key] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// queryBool parses a boolean parameter; a bare "?rdma" counts as true
+func queryBool(query url.Values, key string) (bool, error) {
+	if !query.Has(key) {
+		return false, nil
+	}
+	v := query.Get(key)
+	if v == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	query, _ := url.ParseQuery("host=a*,b*&host=c&label=rack=r1&segment=seg-1&prefix=10.0.0.0/8&iface=ib*&rdma&direct=false&around=gw&hops=2&collapse_vfs=1")
+
+	f, err := parseFilter(query)
+	if err != nil {
+		t.Fatalf("parseFilter failed: %v", err)
+	}
+	if len(f.Hosts) != 3 || f.Hosts[2] != "c" {
+		t.Errorf("unexpected hosts: %v", f.Hosts)
+	}
+	if len(f.Labels) != 1 || f.Labels[0] != "rack=r1" {
+		t.Errorf("unexpected labels: %v", f.Labels)
+	}
+	if len(f.Segments) != 1 || len(f.Prefixes) != 1 || len(f.Interfaces) != 1 {
+		t.Errorf("unexpected list criteria: %+v", f)
+	}
+	if !f.RDMAOnly {
+		t.Error("expected bare rdma parameter to enable RDMA filter")
+	}
+	if f.DirectOnly {
+		t.Error("expected direct=false to leave direct filter off")
+	}
+	if !f.CollapseVFs {
+		t.Error("expected collapse_vfs=1 to fold VFs")
+	}
+	if f.Around != "gw" || f.Hops != 2 {
+		t.Errorf("unexpected around: %q hops %d", f.Around, f.Hops)
+	}
+
+	empty, err := parseFilter(url.Values{})
+	if err != nil || !empty.IsEmpty() {
+		t.Errorf("expected empty filter without parameters, got %+v, %v", empty, err)
+	}
+}
@@ -3,50 +3,157 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/kad/lldiscovery/internal/api"
 	"github.com/kad/lldiscovery/internal/export"
 	"github.com/kad/lldiscovery/internal/graph"
 )
 
+// Source provides the topology served by the API: the local graph.Graph of an
+// agent, or the merged view of a collector
+type Source interface {
+	GetNodes() map[string]*graph.Node
+	GetEdges() map[string]map[string][]*graph.Edge
+	GetNetworkSegments() []graph.NetworkSegment
+}
+
+// Collector accepts agent snapshots in collector mode
+type Collector interface {
+	Ingest(mode, address string, doc *api.Graph) error
+	Sources() []api.Source
+}
+
+// maxPushSize bounds the body of /api/v1/push
+const maxPushSize = 32 << 20
+
 type Server struct {
 	addr         string
-	graph        *graph.Graph
+	graph        Source
 	logger       *slog.Logger
 	showSegments bool
+	templates    map[string]*export.Template
+	collector    Collector
+	tls          *TLSConfig
+	tokens       []authToken // Empty disables authorization
+	socketPath   string
 	srv          *http.Server
+	socketSrv    *http.Server // Control socket, nil unless enabled
 }
 
-func New(addr string, g *graph.Graph, logger *slog.Logger, showSegments bool) *Server {
+// Option configures optional server features
+type Option func(*Server)
+
+// WithTemplates exposes user-defined export templates at /export/{name}
+func WithTemplates(templates ...*export.Template) Option {
+	return func(s *Server) {
+		for _, t := range templates {
+			s.templates[t.Name] = t
+		}
+	}
+}
+
+// WithCollector enables collector mode: agents push snapshots to /api/v1/push
+// and source freshness is reported at /api/v1/sources
+func WithCollector(c Collector) Option {
+	return func(s *Server) {
+		s.collector = c
+	}
+}
+
+func New(addr string, g Source, logger *slog.Logger, showSegments bool, opts ...Option) *Server {
 	s := &Server{
 		addr:         addr,
 		graph:        g,
 		logger:       logger,
 		showSegments: showSegments,
+		templates:    make(map[string]*export.Template),
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/graph", s.handleGraph)
-	mux.HandleFunc("/graph.dot", s.handleGraphDOT)
-	mux.HandleFunc("/graph.nwdiag", s.handleGraphNwdiag)
-	mux.HandleFunc("/health", s.handleHealth)
+	for _, opt := range opts {
+		opt(s)
+	}
 
 	s.srv = &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: s.routes(true),
+	}
+	if s.socketPath != "" {
+		s.socketSrv = &http.Server{Handler: s.routes(false)}
 	}
 
 	return s
 }
 
+// routes builds the request multiplexer. Without authorize, token checks are
+// skipped; the control socket relies on filesystem permissions instead.
+func (s *Server) routes(authorize bool) *http.ServeMux {
+	scope := func(scope string, h http.HandlerFunc) http.HandlerFunc {
+		if !authorize {
+			return h
+		}
+		return s.requireScope(scope, h)
+	}
+
+	// /health and /openapi.json stay open for probes and API discovery
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graph", scope(ScopeGraphRead, s.handleGraph))
+	mux.HandleFunc("/api/v1/graph", scope(ScopeGraphRead, s.handleGraph))
+	mux.HandleFunc("/legacy/graph", scope(ScopeGraphRead, s.handleGraphLegacy))
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/graph.dot", scope(ScopeGraphRead, s.handleGraphDOT))
+	mux.HandleFunc("/graph.nwdiag", scope(ScopeGraphRead, s.handleGraphNwdiag))
+	mux.HandleFunc("/graph.svg", scope(ScopeGraphRead, s.handleGraphSVG))
+	mux.HandleFunc("/export/{name}", scope(ScopeGraphRead, s.handleExport))
+	mux.HandleFunc("/health", s.handleHealth)
+	if s.collector != nil {
+		mux.HandleFunc("/api/v1/push", scope(ScopeAdmin, s.handlePush))
+		mux.HandleFunc("/api/v1/sources", scope(ScopeGraphRead, s.handleSources))
+	}
+	return mux
+}
+
 func (s *Server) Run(ctx context.Context) error {
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 2)
+
+	if s.tls != nil {
+		reloader, err := newCertReloader(*s.tls, s.logger)
+		if err != nil {
+			return err
+		}
+		s.srv.TLSConfig = reloader.tlsConfig()
+	}
+
+	if s.socketSrv != nil {
+		listener, err := listenUnix(s.socketPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(s.socketPath)
+
+		go func() {
+			s.logger.Info("starting control socket", "path", s.socketPath)
+			if err := s.socketSrv.Serve(listener); err != nil && err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}()
+	}
 
 	go func() {
-		s.logger.Info("starting HTTP server", "address", s.addr)
-		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.tls != nil {
+			s.logger.Info("starting HTTPS server", "address", s.addr, "client_auth", s.tls.ClientCAFile != "")
+			err = s.srv.ListenAndServeTLS("", "")
+		} else {
+			s.logger.Info("starting HTTP server", "address", s.addr)
+			err = s.srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
 	}()
@@ -57,18 +164,74 @@ func (s *Server) Run(ctx context.Context) error {
 	case <-ctx.Done():
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
+		if s.socketSrv != nil {
+			s.socketSrv.Shutdown(shutdownCtx)
+		}
 		return s.srv.Shutdown(shutdownCtx)
 	}
 }
 
+// snapshot returns the subgraph selected by the request's query parameters.
+// Segments are returned only when withSegments is set, but are still computed
+// when the filter selects by segment. On invalid parameters it responds with
+// 400 Bad Request and returns ok=false.
+func (s *Server) snapshot(w http.ResponseWriter, r *http.Request, withSegments bool) (nodes map[string]*graph.Node, edges map[string]map[string][]*graph.Edge, segments []graph.NetworkSegment, ok bool) {
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+
+	nodes = s.graph.GetNodes()
+	edges = s.graph.GetEdges()
+	if withSegments || filter.NeedsSegments() {
+		segments = s.graph.GetNetworkSegments()
+	}
+
+	nodes, edges, segments = filter.Apply(nodes, edges, segments)
+	if !withSegments {
+		segments = nil
+	}
+	return nodes, edges, segments, true
+}
+
 func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	nodes := s.graph.GetNodes()
-	edges := s.graph.GetEdges()
+	// ?segments includes segments even when show_segments is off
+	withSegments, err := queryBool(r.URL.Query(), "segments")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nodes, edges, segments, ok := s.snapshot(w, r, s.showSegments || withSegments)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(api.FromGraph(nodes, edges, segments)); err != nil {
+		s.logger.Error("failed to encode JSON", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handleGraphLegacy serves the unversioned pre-v1 document, which exposes Go field
+// names and the internal edge map. Kept for a deprecation period.
+func (s *Server) handleGraphLegacy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nodes, edges, segments, ok := s.snapshot(w, r, s.showSegments)
+	if !ok {
+		return
+	}
 
 	// Build response with full topology information
 	response := map[string]interface{}{
@@ -78,29 +241,41 @@ func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
 
 	// Include segments if enabled
 	if s.showSegments {
-		segments := s.graph.GetNetworkSegments()
 		response["segments"] = segments
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "</graph>; rel=\"successor-version\"")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		s.logger.Error("failed to encode JSON", "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 	}
 }
 
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(api.OpenAPI)
+}
+
 func (s *Server) handleGraphDOT(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	nodes := s.graph.GetNodes()
-	edges := s.graph.GetEdges()
+	nodes, edges, segments, ok := s.snapshot(w, r, s.showSegments)
+	if !ok {
+		return
+	}
 
 	var dot string
 	if s.showSegments {
-		segments := s.graph.GetNetworkSegments()
 		dot = export.GenerateDOTWithSegments(nodes, edges, segments)
 	} else {
 		dot = export.GenerateDOT(nodes, edges)
@@ -116,9 +291,10 @@ func (s *Server) handleGraphNwdiag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nodes := s.graph.GetNodes()
-	edges := s.graph.GetEdges()
-	segments := s.graph.GetNetworkSegments()
+	nodes, edges, segments, ok := s.snapshot(w, r, true)
+	if !ok {
+		return
+	}
 
 	nwdiag := export.ExportNwdiag(nodes, edges, segments)
 
@@ -126,6 +302,100 @@ func (s *Server) handleGraphNwdiag(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(nwdiag))
 }
 
+func (s *Server) handleGraphSVG(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nodes, edges, segments, ok := s.snapshot(w, r, s.showSegments)
+	if !ok {
+		return
+	}
+
+	var svg string
+	if s.showSegments {
+		svg = export.GenerateSVGWithSegments(nodes, edges, segments)
+	} else {
+		svg = export.GenerateSVG(nodes, edges)
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write([]byte(svg))
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, ok := s.templates[r.PathValue("name")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	nodes, edges, segments, ok := s.snapshot(w, r, true)
+	if !ok {
+		return
+	}
+
+	out, err := tmpl.Render(export.NewTemplateData(nodes, edges, segments))
+	if err != nil {
+		s.logger.Error("failed to render export template", "template", tmpl.Name, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(out))
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var doc api.Graph
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushSize)).Decode(&doc); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "snapshot too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid graph document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		address = host
+	}
+
+	if err := s.collector.Ingest(api.ModePush, address, &doc); err != nil {
+		s.logger.Warn("rejected pushed snapshot", "address", address, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(api.SourceList{Version: api.Version, Sources: s.collector.Sources()}); err != nil {
+		s.logger.Error("failed to encode JSON", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"log/slog"
 	"net/http"
@@ -8,6 +9,9 @@ import (
 	"os"
 	"testing"
 
+	"github.com/kad/lldiscovery/internal/api"
+	"github.com/kad/lldiscovery/internal/collector"
+	"github.com/kad/lldiscovery/internal/export"
 	"github.com/kad/lldiscovery/internal/graph"
 )
 
@@ -47,11 +51,100 @@ func TestHandleGraph(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	s := New(":0", g, logger, false)
 
+	for _, path := range []string{"/graph", "/api/v1/graph"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+
+			s.srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", w.Code)
+			}
+
+			contentType := w.Header().Get("Content-Type")
+			if contentType != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %s", contentType)
+			}
+
+			var doc api.Graph
+			if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if doc.Version != api.Version {
+				t.Errorf("expected version %s, got %q", api.Version, doc.Version)
+			}
+			if len(doc.Nodes) != 3 {
+				t.Errorf("expected 3 nodes, got %d", len(doc.Nodes))
+			}
+			if len(doc.Edges) != 3 {
+				t.Errorf("expected 3 edges, got %d", len(doc.Edges))
+			}
+			if doc.LocalNodeID != "local-123" {
+				t.Errorf("expected local node ID local-123, got %q", doc.LocalNodeID)
+			}
+			if len(doc.Segments) != 0 {
+				t.Error("expected no segments when showSegments=false")
+			}
+		})
+	}
+}
+
+func TestHandleGraphWithSegments(t *testing.T) {
+	g := createTestGraph()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", g, logger, true) // Enable segments
+
 	req := httptest.NewRequest(http.MethodGet, "/graph", nil)
 	w := httptest.NewRecorder()
 
 	s.handleGraph(w, req)
 
+	var doc api.Graph
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(doc.Segments) != 1 {
+		t.Fatalf("expected 1 segment when showSegments=true, got %d", len(doc.Segments))
+	}
+	if len(doc.Segments[0].Members) != 3 {
+		t.Errorf("expected 3 segment members, got %d", len(doc.Segments[0].Members))
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", graph.New(), logger, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	s.handleOpenAPI(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	var doc map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode OpenAPI document: %v", err)
+	}
+	if _, ok := doc["components"]; !ok {
+		t.Error("expected components in OpenAPI document")
+	}
+}
+
+func TestHandleGraphLegacy(t *testing.T) {
+	g := createTestGraph()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", g, logger, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/legacy/graph", nil)
+	w := httptest.NewRecorder()
+
+	s.handleGraphLegacy(w, req)
+
 	if w.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", w.Code)
 	}
@@ -61,6 +154,10 @@ func TestHandleGraph(t *testing.T) {
 		t.Errorf("expected Content-Type application/json, got %s", contentType)
 	}
 
+	if w.Header().Get("Deprecation") != "true" {
+		t.Error("expected Deprecation header on legacy endpoint")
+	}
+
 	// Parse response
 	var response map[string]interface{}
 	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
@@ -111,15 +208,15 @@ func TestHandleGraph(t *testing.T) {
 	}
 }
 
-func TestHandleGraphWithSegments(t *testing.T) {
+func TestHandleGraphLegacyWithSegments(t *testing.T) {
 	g := createTestGraph()
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	s := New(":0", g, logger, true) // Enable segments
 
-	req := httptest.NewRequest(http.MethodGet, "/graph", nil)
+	req := httptest.NewRequest(http.MethodGet, "/legacy/graph", nil)
 	w := httptest.NewRecorder()
 
-	s.handleGraph(w, req)
+	s.handleGraphLegacy(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", w.Code)
@@ -176,6 +273,76 @@ func TestHandleGraphDOT(t *testing.T) {
 	}
 }
 
+func TestHandleGraphSVG(t *testing.T) {
+	g := createTestGraph()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", g, logger, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/graph.svg", nil)
+	w := httptest.NewRecorder()
+
+	s.handleGraphSVG(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	contentType := w.Header().Get("Content-Type")
+	if contentType != "image/svg+xml" {
+		t.Errorf("expected Content-Type image/svg+xml, got %s", contentType)
+	}
+
+	body := w.Body.String()
+	if !contains(body, "<svg xmlns=") {
+		t.Error("expected SVG document")
+	}
+	if !contains(body, "cluster_local-123") {
+		t.Error("expected local machine cluster in SVG")
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	g := createTestGraph()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	hosts, err := export.ParseTemplate("hosts", "{{range .Nodes}}{{.Hostname}}\n{{end}}")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	broken, err := export.ParseTemplate("broken", "{{.NoSuchField}}")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	s := New(":0", g, logger, false, WithTemplates(hosts, broken))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/export/hosts", http.StatusOK, "local-host\nremote-1\nremote-2\n"},
+		{"/export/unknown", http.StatusNotFound, ""},
+		{"/export/broken", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			// Route through the mux so {name} is resolved
+			s.srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestHandleHealth(t *testing.T) {
 	g := graph.New()
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
@@ -251,6 +418,214 @@ func TestHandleGraphNwdiag(t *testing.T) {
 	}
 }
 
+func TestHandleGraphFiltered(t *testing.T) {
+	g := createTestGraph()
+	g.SetNodeLabels("remote-456", map[string]string{"rack": "r1"})
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", g, logger, false)
+
+	segments := g.GetNetworkSegments()
+	if len(segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(segments))
+	}
+
+	tests := []struct {
+		query     string
+		wantNodes int
+		wantEdges int
+	}{
+		{"host=remote-*", 2, 1},
+		{"host=local-host&host=remote-1", 2, 1},
+		{"host=local-host,remote-1", 2, 1},
+		{"label=rack=r1", 1, 0},
+		{"direct=true", 3, 2},
+		{"iface=eth1", 1, 0},
+		{"around=remote-2&hops=1", 3, 3},
+		{"segment=" + segments[0].StableID(), 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/graph?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			s.srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var doc api.Graph
+			if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(doc.Nodes) != tt.wantNodes {
+				t.Errorf("expected %d nodes, got %d", tt.wantNodes, len(doc.Nodes))
+			}
+			if len(doc.Edges) != tt.wantEdges {
+				t.Errorf("expected %d edges, got %d", tt.wantEdges, len(doc.Edges))
+			}
+			if len(doc.Segments) != 0 {
+				t.Error("expected no segments when showSegments=false")
+			}
+		})
+	}
+
+	// The same selection applies to the rendered formats
+	for _, path := range []string{"/graph.dot", "/graph.nwdiag", "/graph.svg"} {
+		req := httptest.NewRequest(http.MethodGet, path+"?host=remote-*", nil)
+		w := httptest.NewRecorder()
+
+		s.srv.Handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, w.Code)
+		}
+		if !contains(w.Body.String(), "remote-1") || contains(w.Body.String(), "local-host") {
+			t.Errorf("%s: expected only remote hosts in output", path)
+		}
+	}
+}
+
+func TestHandleGraphBadFilter(t *testing.T) {
+	g := createTestGraph()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", g, logger, false)
+
+	for _, query := range []string{"host=[", "prefix=nope", "rdma=maybe", "around=remote-1&hops=x", "hops=2", "around=remote-1&hops=-1"} {
+		for _, path := range []string{"/graph", "/graph.dot", "/graph.nwdiag"} {
+			req := httptest.NewRequest(http.MethodGet, path+"?"+query, nil)
+			w := httptest.NewRecorder()
+
+			s.srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s?%s: expected status 400, got %d", path, query, w.Code)
+			}
+		}
+	}
+}
+
+func TestHandleGraphSegmentsParam(t *testing.T) {
+	g := createTestGraph()
+	g.AddOrUpdate("remote-abc", "remote-3", "eth0", "fe80::5", "eth0", "", "", "", 1000, nil, true, "")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", g, logger, false)
+
+	tests := []struct {
+		query        string
+		wantStatus   int
+		wantSegments bool
+	}{
+		{"", http.StatusOK, false},
+		{"?segments", http.StatusOK, true},
+		{"?segments=false", http.StatusOK, false},
+		{"?segments=maybe", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/graph"+tt.query, nil)
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.wantStatus, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var doc api.Graph
+		if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+			t.Fatalf("failed to decode graph: %v", err)
+		}
+		if got := len(doc.Segments) > 0; got != tt.wantSegments {
+			t.Errorf("%q: expected segments=%v, got %d segments", tt.query, tt.wantSegments, len(doc.Segments))
+		}
+	}
+}
+
+func TestHandlePush(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	c := collector.New(logger)
+	s := New(":0", c, logger, false, WithCollector(c))
+
+	agent := createTestGraph()
+	body, err := json.Marshal(api.FromGraph(agent.GetNodes(), agent.GetEdges(), nil))
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       []byte
+		wantStatus int
+	}{
+		{"valid snapshot", http.MethodPost, body, http.StatusNoContent},
+		{"not JSON", http.MethodPost, []byte("{"), http.StatusBadRequest},
+		{"no local node", http.MethodPost, []byte(`{"version":"v1","nodes":[],"edges":[]}`), http.StatusBadRequest},
+		{"wrong method", http.MethodGet, nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/push", bytes.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			s.srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// The pushed topology is served from /graph
+	req := httptest.NewRequest(http.MethodGet, "/graph", nil)
+	w := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(w, req)
+
+	var doc api.Graph
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode graph: %v", err)
+	}
+	if len(doc.Nodes) != 3 {
+		t.Errorf("expected 3 nodes from pushed snapshot, got %d", len(doc.Nodes))
+	}
+	if doc.LocalNodeID != "" {
+		t.Errorf("expected no local node in collector view, got %q", doc.LocalNodeID)
+	}
+
+	// The source list reports the pushing agent
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/sources", nil)
+	w = httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(w, req)
+
+	var list api.SourceList
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode sources: %v", err)
+	}
+	if len(list.Sources) != 1 || list.Sources[0].NodeID != "local-123" || list.Sources[0].Address != "192.0.2.1" {
+		t.Errorf("unexpected sources: %+v", list.Sources)
+	}
+}
+
+func TestCollectorRoutesDisabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := New(":0", createTestGraph(), logger, false)
+
+	for _, path := range []string{"/api/v1/push", "/api/v1/sources"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+
+		s.srv.Handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404 without collector, got %d", path, w.Code)
+		}
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && findSubstring(s, substr))
 }
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// controlSocketMode lets the daemon's user and group use the control socket.
+// Access is granted by membership in the daemon's group.
+const controlSocketMode = 0660
+
+// WithControlSocket additionally serves the API on a Unix domain socket. Token
+// authorization does not apply there; filesystem permissions on the socket
+// (mode 0660) control access instead.
+func WithControlSocket(path string) Option {
+	return func(s *Server) {
+		s.socketPath = path
+	}
+}
+
+// listenUnix listens on a Unix socket at path. A stale socket left behind by a
+// crashed daemon is removed; a socket still accepting connections is an error.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("control socket %s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, controlSocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "lldiscovery.sock")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	// Tokens protect TCP; the socket relies on file permissions
+	s := New("127.0.0.1:0", createTestGraph(), logger, false,
+		WithControlSocket(path),
+		WithTokens([]Token{{Name: "ops", Secret: "secret", Scopes: []string{ScopeAdmin}}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	var info os.FileInfo
+	var err error
+	for i := 0; i < 100; i++ {
+		if info, err = os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("control socket not created: %v", err)
+	}
+	if info.Mode().Perm() != controlSocketMode {
+		t.Errorf("expected socket mode %o, got %o", controlSocketMode, info.Mode().Perm())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://lldiscovery/graph?segments=true")
+	if err != nil {
+		t.Fatalf("request over control socket failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 without token on control socket, got %d", resp.StatusCode)
+	}
+
+	// A second server must not steal the socket of a running one
+	if _, err := listenUnix(path); err == nil {
+		t.Error("expected error for socket in use")
+	}
+
+	client.CloseIdleConnections()
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run returned error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected control socket to be removed on shutdown")
+	}
+}
+
+func TestListenUnix_Stale(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket file without a listener is replaced
+	stale := filepath.Join(dir, "stale.sock")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = listenUnix(stale)
+	if err != nil {
+		t.Fatalf("expected stale socket to be replaced: %v", err)
+	}
+	l.Close()
+
+	// A regular file is never removed
+	regular := filepath.Join(dir, "regular")
+	if err := os.WriteFile(regular, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := listenUnix(regular); err == nil {
+		t.Error("expected error for non-socket path")
+	}
+	if _, err := os.Stat(regular); err != nil {
+		t.Error("expected regular file to be kept")
+	}
+}
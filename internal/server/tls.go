@@ -0,0 +1,175 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig enables HTTPS. CertFile, KeyFile and ClientCAFile are re-read when
+// their modification time or size changes, so renewed certificates are picked
+// up without a restart.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // Optional; when set, clients must present a certificate signed by it
+	MinVersion   uint16 // Defaults to TLS 1.2
+}
+
+// WithTLS serves the API over TLS instead of plain HTTP
+func WithTLS(cfg TLSConfig) Option {
+	return func(s *Server) {
+		s.tls = &cfg
+	}
+}
+
+// reloadCheckInterval limits how often the files are stat'ed during handshakes
+const reloadCheckInterval = time.Second
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// certReloader provides the current certificate and client CA pool to the TLS
+// stack, reloading them when the files change. A failed reload is logged and the
+// previous material stays in use.
+type certReloader struct {
+	cfg    TLSConfig
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamps    []fileStamp
+	lastCheck time.Time
+}
+
+// newCertReloader loads the initial certificate and CA bundle
+func newCertReloader(cfg TLSConfig, logger *slog.Logger) (*certReloader, error) {
+	r := &certReloader{cfg: cfg, logger: logger}
+	stamps, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(stamps); err != nil {
+		return nil, err
+	}
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+// files lists the watched files in a fixed order
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *certReloader) stat() ([]fileStamp, error) {
+	var stamps []fileStamp
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, fileStamp{modTime: info.ModTime(), size: info.Size()})
+	}
+	return stamps, nil
+}
+
+// load reads all files and replaces the current material. Must be called with mu
+// held, or before the reloader is shared.
+func (r *certReloader) load(stamps []fileStamp) error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.cert = &cert
+	r.clientCAs = pool
+	r.stamps = stamps
+	return nil
+}
+
+// maybeReload reloads the files if any of them changed since the last load
+func (r *certReloader) maybeReload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < reloadCheckInterval {
+		return
+	}
+	r.lastCheck = time.Now()
+
+	stamps, err := r.stat()
+	if err != nil {
+		r.logger.Warn("failed to check TLS files", "error", err)
+		return
+	}
+	changed := false
+	for i := range stamps {
+		if !stamps[i].modTime.Equal(r.stamps[i].modTime) || stamps[i].size != r.stamps[i].size {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+
+	if err := r.load(stamps); err != nil {
+		r.logger.Warn("failed to reload TLS files, keeping previous certificate", "error", err)
+		return
+	}
+	r.logger.Info("reloaded TLS certificate", "cert_file", r.cfg.CertFile)
+}
+
+// tlsConfig returns a server configuration that asks the reloader for the
+// current material on every handshake
+func (r *certReloader) tlsConfig() *tls.Config {
+	minVersion := r.cfg.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+
+	return &tls.Config{
+		MinVersion: minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.maybeReload()
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			cfg := &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if r.clientCAs != nil {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = r.clientCAs
+			}
+			return cfg, nil
+		},
+	}
+}
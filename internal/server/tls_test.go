@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kad/lldiscovery/internal/collector"
+)
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key for a server or client
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// startTLSServer serves the health endpoint with the reloader's configuration
+func startTLSServer(t *testing.T, cfg TLSConfig) (*httptest.Server, *certReloader) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	reloader, err := newCertReloader(cfg, logger)
+	if err != nil {
+		t.Fatalf("newCertReloader failed: %v", err)
+	}
+
+	s := New(":0", createTestGraph(), logger, false, WithTLS(cfg))
+	ts := httptest.NewUnstartedServer(s.srv.Handler)
+	ts.TLS = reloader.tlsConfig()
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts, reloader
+}
+
+func tlsClient(ca *testCA, clientCert *tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	cfg := &tls.Config{RootCAs: pool}
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{*clientCert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+}
+
+func TestTLS_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	certPEM, keyPEM := ca.issue(t, "first", 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	ts, reloader := startTLSServer(t, TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	client := tlsClient(ca, nil)
+
+	servedCN := func() string {
+		resp, err := client.Get(ts.URL + "/health")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		client.CloseIdleConnections()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	if cn := servedCN(); cn != "first" {
+		t.Errorf("expected first certificate, got %q", cn)
+	}
+
+	// Replace the certificate on disk
+	certPEM, keyPEM = ca.issue(t, "second", 3, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	reloader.mu.Lock()
+	reloader.lastCheck = time.Time{}
+	reloader.mu.Unlock()
+
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("expected reloaded certificate, got %q", cn)
+	}
+
+	// A broken file keeps the previous certificate
+	writeFile(t, keyFile, []byte("garbage"))
+	future = future.Add(time.Minute)
+	os.Chtimes(keyFile, future, future)
+	reloader.mu.Lock()
+	reloader.lastCheck = time.Time{}
+	reloader.mu.Unlock()
+
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("expected previous certificate after failed reload, got %q", cn)
+	}
+}
+
+func TestTLS_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+	certPEM, keyPEM := ca.issue(t, "server", 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	ts, _ := startTLSServer(t, TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, MinVersion: tls.VersionTLS13})
+
+	// Without a client certificate the handshake fails
+	if resp, err := tlsClient(ca, nil).Get(ts.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("expected request without client certificate to fail")
+	}
+
+	// A certificate from another CA is rejected
+	other := newTestCA(t)
+	otherPEM, otherKey := other.issue(t, "intruder", 2, x509.ExtKeyUsageClientAuth)
+	otherCert, err := tls.X509KeyPair(otherPEM, otherKey)
+	if err != nil {
+		t.Fatalf("failed to load client certificate: %v", err)
+	}
+	if resp, err := tlsClient(ca, &otherCert).Get(ts.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("expected certificate from unknown CA to be rejected")
+	}
+
+	clientPEM, clientKey := ca.issue(t, "dashboard", 3, x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKey)
+	if err != nil {
+		t.Fatalf("failed to load client certificate: %v", err)
+	}
+	resp, err := tlsClient(ca, &clientCert).Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("expected TLS 1.3, got %x", resp.TLS.Version)
+	}
+}
+
+func TestTLS_PushWithClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	file := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, data)
+		return path
+	}
+	certPEM, keyPEM := ca.issue(t, "collector", 2, x509.ExtKeyUsageServerAuth)
+	cfg := TLSConfig{CertFile: file("server.crt", certPEM), KeyFile: file("server.key", keyPEM), ClientCAFile: file("ca.crt", ca.pem)}
+	agentPEM, agentKey := ca.issue(t, "agent", 3, x509.ExtKeyUsageClientAuth)
+	agentCert, agentKeyFile := file("agent.crt", agentPEM), file("agent.key", agentKey)
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	reloader, err := newCertReloader(cfg, logger)
+	if err != nil {
+		t.Fatalf("newCertReloader failed: %v", err)
+	}
+	c := collector.New(logger)
+	s := New(":0", c, logger, false, WithCollector(c), WithTLS(cfg))
+	ts := httptest.NewUnstartedServer(s.srv.Handler)
+	ts.TLS = reloader.tlsConfig()
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	// Trusting the CA is not enough, the collector wants a client certificate
+	caOnly, err := collector.NewTLSConfig(cfg.ClientCAFile, "", "")
+	if err != nil {
+		t.Fatalf("NewTLSConfig failed: %v", err)
+	}
+	p := collector.NewPusher(ts.URL+"/api/v1/push", time.Second, createTestGraph(), logger)
+	p.SetTLSConfig(caOnly)
+	if err := p.Push(context.Background()); err == nil {
+		t.Error("expected push without client certificate to fail")
+	}
+
+	mutual, err := collector.NewTLSConfig(cfg.ClientCAFile, agentCert, agentKeyFile)
+	if err != nil {
+		t.Fatalf("NewTLSConfig failed: %v", err)
+	}
+	p.SetTLSConfig(mutual)
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("push with client certificate failed: %v", err)
+	}
+	if sources := c.Sources(); len(sources) != 1 || sources[0].Mode != "push" {
+		t.Errorf("expected one pushed source, got %+v", sources)
+	}
+}
+
+func TestTLS_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	if _, err := newCertReloader(TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}, logger); err == nil {
+		t.Error("expected error for missing files")
+	}
+
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+	certPEM, keyPEM := ca.issue(t, "server", 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, []byte("not a certificate"))
+
+	if _, err := newCertReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, logger); err == nil {
+		t.Error("expected error for empty client CA bundle")
+	}
+}
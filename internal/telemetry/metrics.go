@@ -16,6 +16,11 @@ type Metrics struct {
 	NodesExpired          metric.Int64Counter
 	DiscoveryErrors       metric.Int64Counter
 	MulticastJoinFailures metric.Int64Counter
+	LinkDegraded          metric.Int64Counter
+	LinkErrorRate         metric.Float64Gauge
+	NeighborMissed        metric.Int64Counter
+	NeighborLoss          metric.Float64Gauge
+	NeighborJitter        metric.Float64Histogram
 }
 
 func NewMetrics(ctx context.Context) (*Metrics, error) {
@@ -93,6 +98,51 @@ func NewMetrics(ctx context.Context) (*Metrics, error) {
 		return nil, err
 	}
 
+	linkDegraded, err := meter.Int64Counter(
+		"lldiscovery.link.degraded",
+		metric.WithDescription("Number of health samples that found a local interface degraded"),
+		metric.WithUnit("{sample}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	linkErrorRate, err := meter.Float64Gauge(
+		"lldiscovery.link.error_rate",
+		metric.WithDescription("Error and drop rates of local interfaces"),
+		metric.WithUnit("{event}/s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	neighborMissed, err := meter.Int64Counter(
+		"lldiscovery.neighbor.packets.missed",
+		metric.WithDescription("Number of discovery packets from neighbors that never arrived"),
+		metric.WithUnit("{packet}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	neighborLoss, err := meter.Float64Gauge(
+		"lldiscovery.neighbor.loss",
+		metric.WithDescription("Fraction of discovery packets lost per neighbor interface"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	neighborJitter, err := meter.Float64Histogram(
+		"lldiscovery.neighbor.jitter",
+		metric.WithDescription("Deviation of discovery packet arrivals from the neighbor's send interval"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Metrics{
 		PacketsSent:           packetsSent,
 		PacketsReceived:       packetsReceived,
@@ -102,5 +152,10 @@ func NewMetrics(ctx context.Context) (*Metrics, error) {
 		NodesExpired:          nodesExpired,
 		DiscoveryErrors:       discoveryErrors,
 		MulticastJoinFailures: multicastJoinFailures,
+		LinkDegraded:          linkDegraded,
+		LinkErrorRate:         linkErrorRate,
+		NeighborMissed:        neighborMissed,
+		NeighborLoss:          neighborLoss,
+		NeighborJitter:        neighborJitter,
 	}, nil
 }